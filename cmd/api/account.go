@@ -0,0 +1,482 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/elhambadri2411/social/internal/blobstore"
+	"github.com/elhambadri2411/social/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	exportBuildTimeout = time.Minute * 5
+	accountsBatch      = 100
+
+	// `exportKeyLabel` derives the key of export download links from the token secret
+	exportKeyLabel = "social export links v1"
+)
+
+type deletionStatus struct {
+	DeletionScheduledAt string `json:"deletion_scheduled_at"`
+}
+
+// RequestExport godoc
+//
+//	@Summary		Exports the authenticated user's data
+//	@Description	Starts building a ZIP archive with JSON files of the profile, posts, comments, follows, follow
+//	@Description	requests, blocks, mutes and followed tags. Poll the returned export until it is `ready`,
+//	@Description	it then comes with a signed download link that expires. There are no reactions to export,
+//	@Description	the API doesn't have them.
+//	@Tags			users
+//	@Produce		json
+//	@Success		202	{object}	store.Export
+//	@Failure		409	{object}	error	"An export is already being built"
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/export [post]
+func (app *application) requestExportHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	export := &store.Export{
+		UserId:    user.ID,
+		ExpiresAt: time.Now().Add(app.config.accounts.exportRetention).UTC().Format(time.RFC3339),
+	}
+
+	if err := app.store.ExportsRepository.Create(r.Context(), export); err != nil {
+		switch {
+		case errors.Is(err, store.ErrConflict):
+			app.conflictError(w, r, errors.New("an export is already being built"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	exportId := export.ID
+	app.background(func() {
+		app.buildExport(exportId, user.ID)
+	})
+
+	if err := app.jsonResponse(w, http.StatusAccepted, export); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetExport godoc
+//
+//	@Summary		Fetches a data export
+//	@Description	Returns the status of an export, with a signed `download_url` once it is ready
+//	@Tags			users
+//	@Produce		json
+//	@Param			exportID	path		int	true	"Export ID"
+//	@Success		200			{object}	store.Export
+//	@Failure		400			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/export/{exportID} [get]
+func (app *application) getExportHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	exportId, err := strconv.ParseInt(chi.URLParam(r, "exportId"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	export, err := app.store.ExportsRepository.GetById(r.Context(), exportId)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	// other users' exports don't exist as far as this user is concerned
+	if export.UserId != user.ID {
+		app.notFoundResponse(w, r, store.ErrNotFound)
+		return
+	}
+
+	if export.Status == store.ExportReady {
+		export.DownloadURL = app.exportDownloadURL(export.ID, time.Now().Add(app.config.accounts.exportLinkTTL))
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, export); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// DownloadExport godoc
+//
+//	@Summary		Downloads a data export
+//	@Description	Serves the ZIP archive of an export. The link is signed and expires, it doesn't need a token.
+//	@Tags			users
+//	@Produce		application/zip
+//	@Param			exportID	path		int		true	"Export ID"
+//	@Param			expires		query		int		true	"Unix time the link expires at"
+//	@Param			signature	query		string	true	"Link signature"
+//	@Success		200			{file}		binary
+//	@Failure		403			{object}	error	"Invalid or expired link"
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Router			/exports/{exportID}/download [get]
+func (app *application) downloadExportHandler(w http.ResponseWriter, r *http.Request) {
+	exportId, err := strconv.ParseInt(chi.URLParam(r, "exportId"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	expires, err := strconv.ParseInt(r.URL.Query().Get("expires"), 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		app.forbiddenResponse(w, r)
+		return
+	}
+
+	signature, err := hex.DecodeString(r.URL.Query().Get("signature"))
+	if err != nil || !hmac.Equal(signature, app.signExport(exportId, expires)) {
+		app.forbiddenResponse(w, r)
+		return
+	}
+
+	export, err := app.store.ExportsRepository.GetById(r.Context(), exportId)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	if export.Status != store.ExportReady || export.StorageKey == nil {
+		app.notFoundResponse(w, r, store.ErrNotFound)
+		return
+	}
+
+	archive, info, err := app.blobs.Get(r.Context(), *export.StorageKey)
+	if err != nil {
+		switch {
+		case errors.Is(err, blobstore.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	defer archive.Close()
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Length", strconv.FormatInt(info.Size, 10))
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="export-%d.zip"`, export.ID))
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.WriteHeader(http.StatusOK)
+
+	if _, err := io.Copy(w, archive); err != nil {
+		app.logger.Warnw("error streaming export", "export_id", export.ID, "error", err.Error())
+	}
+}
+
+// DeleteMyAccount godoc
+//
+//	@Summary		Deletes the authenticated user's account
+//	@Description	Schedules the account for deletion once the grace period is over. Until then it keeps working
+//	@Description	and the deletion can be cancelled. Posts and comments are then removed, along with follows,
+//	@Description	blocks, mutes, mentions, uploaded images and data exports.
+//	@Tags			users
+//	@Produce		json
+//	@Success		202	{object}	deletionStatus
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me [delete]
+func (app *application) deleteMyAccountHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+	ctx := r.Context()
+
+	scheduledAt, err := app.store.UsersRepository.ScheduleDeletion(ctx, user.ID, time.Now().Add(app.config.accounts.deletionGrace))
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	user.DeletionScheduledAt = &scheduledAt
+	if err := app.cache.UsersCache.Set(ctx, user); err != nil {
+		app.logger.Warnw("error caching user", "user_id", user.ID, "error", err.Error())
+	}
+
+	if err := app.jsonResponse(w, http.StatusAccepted, deletionStatus{DeletionScheduledAt: scheduledAt}); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// RestoreMyAccount godoc
+//
+//	@Summary	Cancels the deletion of the authenticated user's account
+//	@Tags		users
+//	@Success	204	{string}	string	"Deletion cancelled"
+//	@Failure	404	{object}	error	"No deletion is scheduled"
+//	@Failure	500	{object}	error
+//	@Security	ApiKeyAuth
+//	@Router		/users/me/restore [put]
+func (app *application) restoreMyAccountHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+	ctx := r.Context()
+
+	if err := app.store.UsersRepository.CancelDeletion(ctx, user.ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	user.DeletionScheduledAt = nil
+	if err := app.cache.UsersCache.Set(ctx, user); err != nil {
+		app.logger.Warnw("error caching user", "user_id", user.ID, "error", err.Error())
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// `buildExport` writes the archive of an export to blob storage, marking it failed on errors
+func (app *application) buildExport(exportId int64, userId int64) {
+	ctx, cancel := context.WithTimeout(context.Background(), exportBuildTimeout)
+	defer cancel()
+
+	if err := app.writeExport(ctx, exportId, userId); err != nil {
+		app.logger.Errorw("error building export", "export_id", exportId, "user_id", userId, "error", err.Error())
+
+		// the build may have failed because `ctx` timed out, marking it failed needs its own
+		failCtx, cancel := context.WithTimeout(context.Background(), store.QueryContextTimeoutDuration)
+		defer cancel()
+
+		if err := app.store.ExportsRepository.Fail(failCtx, exportId); err != nil {
+			app.logger.Errorw("error marking export as failed", "export_id", exportId, "error", err.Error())
+		}
+	}
+}
+
+func (app *application) writeExport(ctx context.Context, exportId int64, userId int64) error {
+	user, err := app.store.UsersRepository.GetById(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	profile, err := app.privateProfile(ctx, user)
+	if err != nil {
+		return err
+	}
+
+	data, err := app.store.ExportsRepository.GetUserData(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	// archives can get large, they are staged on disk rather than in memory
+	file, err := os.CreateTemp("", "export-*.zip")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	archive := zip.NewWriter(file)
+	files := []struct {
+		name    string
+		content any
+	}{
+		{"profile.json", profile},
+		{"posts.json", data.Posts},
+		{"comments.json", data.Comments},
+		{"followers.json", data.Followers},
+		{"following.json", data.Following},
+		{"follow_requests.json", data.FollowRequests},
+		{"blocked.json", data.Blocked},
+		{"muted.json", data.Muted},
+		{"followed_tags.json", data.FollowedTags},
+	}
+	for _, f := range files {
+		entry, err := archive.Create(f.name)
+		if err != nil {
+			return err
+		}
+
+		encoder := json.NewEncoder(entry)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(f.content); err != nil {
+			return err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return err
+	}
+
+	size, err := file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	key, err := newExportKey(time.Now())
+	if err != nil {
+		return err
+	}
+
+	if err := app.blobs.Put(ctx, key, file, size, "application/zip"); err != nil {
+		return err
+	}
+
+	if err := app.store.ExportsRepository.Complete(ctx, exportId, key, size); err != nil {
+		_ = app.blobs.Delete(ctx, key)
+		return err
+	}
+
+	return nil
+}
+
+// `newExportKey` returns a random, unguessable key like `exports/2025/08/<hex>.zip`
+func newExportKey(now time.Time) (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("exports/%s/%s.zip", now.UTC().Format("2006/01"), hex.EncodeToString(id)), nil
+}
+
+// `exportDownloadURL` returns a link to download an export that works until `expires`
+func (app *application) exportDownloadURL(exportId int64, expires time.Time) string {
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expires.Unix(), 10))
+	query.Set("signature", hex.EncodeToString(app.signExport(exportId, expires.Unix())))
+
+	return fmt.Sprintf("/v1/exports/%d/download?%s", exportId, query.Encode())
+}
+
+// `signExport` signs a download link of an export with a key derived from the token secret, like
+// `signUnsubscribe`
+func (app *application) signExport(exportId int64, expires int64) []byte {
+	mac := hmac.New(sha256.New, app.derivedKey(exportKeyLabel))
+	fmt.Fprintf(mac, "export:%d:%d", exportId, expires)
+	return mac.Sum(nil)
+}
+
+// `purgeDeletedAccounts` deletes the accounts whose deletion grace period is over.
+// A failed account is logged and tried again on the next run.
+func (app *application) purgeDeletedAccounts(ctx context.Context) error {
+	for {
+		users, err := app.store.UsersRepository.GetDueDeletions(ctx, accountsBatch)
+		if err != nil {
+			return err
+		}
+
+		deleted := 0
+		for _, user := range users {
+			if err := app.purgeAccount(ctx, user); err != nil {
+				app.logger.Errorw("error deleting account", "user_id", user.ID, "error", err.Error())
+				continue
+			}
+			deleted++
+		}
+
+		if deleted > 0 {
+			app.logger.Infow("deleted accounts", "count", deleted)
+		}
+
+		// failed accounts are still due, fetching another batch would return them again
+		if len(users) < accountsBatch || deleted < len(users) {
+			return nil
+		}
+	}
+}
+
+func (app *application) purgeAccount(ctx context.Context, user *store.User) error {
+	postIds, err := app.store.UsersRepository.Delete(ctx, user.ID)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return err
+	}
+
+	// the auth middleware reads users from the cache, it must not find this one again
+	if err := app.cache.UsersCache.Delete(ctx, user.ID); err != nil {
+		app.logger.Warnw("error evicting user", "user_id", user.ID, "error", err.Error())
+	}
+
+	for _, postId := range postIds {
+		app.unindexPost(ctx, postId)
+	}
+
+	for _, key := range []*string{user.AvatarKey, user.AvatarThumbnailKey} {
+		if key == nil {
+			continue
+		}
+		if err := app.blobs.Delete(ctx, *key); err != nil {
+			app.logger.Warnw("error deleting avatar", "key", *key, "error", err.Error())
+		}
+	}
+
+	return nil
+}
+
+// `purgeExpiredExports` removes the archives and rows of expired exports and of deleted users
+func (app *application) purgeExpiredExports(ctx context.Context) error {
+	for {
+		expired, err := app.store.ExportsRepository.GetExpired(ctx, accountsBatch)
+		if err != nil {
+			return err
+		}
+
+		purged := 0
+		for _, export := range expired {
+			if err := app.purgeExport(ctx, export); err != nil {
+				app.logger.Errorw("error purging export", "export_id", export.ID, "error", err.Error())
+				continue
+			}
+			purged++
+		}
+
+		if purged > 0 {
+			app.logger.Infow("purged expired exports", "count", purged)
+		}
+
+		// failed exports stay expired, fetching another batch would return them again
+		if len(expired) < accountsBatch || purged < len(expired) {
+			return nil
+		}
+	}
+}
+
+func (app *application) purgeExport(ctx context.Context, export store.Export) error {
+	if export.StorageKey != nil {
+		if err := app.blobs.Delete(ctx, *export.StorageKey); err != nil {
+			return err
+		}
+	}
+
+	if err := app.store.ExportsRepository.Delete(ctx, export.ID); err != nil && !errors.Is(err, store.ErrNotFound) {
+		return err
+	}
+
+	return nil
+}
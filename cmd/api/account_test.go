@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/elhambadri2411/social/internal/store"
+	"github.com/elhambadri2411/social/internal/store/cache"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestAccountDeletion(t *testing.T) {
+	mockApp := newTestApplication(t)
+	mockMux := mockApp.mount()
+
+	testToken, _ := mockApp.authenticator.GenerateToken(nil)
+
+	mockCacheStore := mockApp.cache.UsersCache.(*cache.MockUsersCacheRedis)
+	mockCacheStore.On("Get", mock.Anything, mock.Anything).Return(nil, nil)
+	mockCacheStore.On("Set", mock.Anything, mock.Anything).Return(nil)
+
+	request := func(method string, url string) *http.Request {
+		req, err := http.NewRequest(method, url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		return req
+	}
+
+	t.Run("should schedule the deletion after the grace period", func(t *testing.T) {
+		rr := execRequest(request(http.MethodDelete, "/v1/users/me"), mockMux)
+		assertResponseCode(t, http.StatusAccepted, rr.Code)
+
+		var response struct {
+			Data deletionStatus `json:"data"`
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+			t.Fatal(err)
+		}
+
+		scheduledAt, err := time.Parse(time.RFC3339, response.Data.DeletionScheduledAt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if scheduledAt.Before(time.Now().Add(mockApp.config.accounts.deletionGrace - time.Minute)) {
+			t.Errorf("deletion scheduled at %s, before the grace period is over", scheduledAt)
+		}
+	})
+
+	t.Run("should cancel a scheduled deletion", func(t *testing.T) {
+		rr := execRequest(request(http.MethodPut, "/v1/users/me/restore"), mockMux)
+		assertResponseCode(t, http.StatusNoContent, rr.Code)
+	})
+}
+
+// `dueUserStore` has two accounts past their deletion grace period, deleting the first one fails
+type dueUserStore struct {
+	store.MockUserStore
+	callLog
+}
+
+func (s *dueUserStore) GetDueDeletions(ctx context.Context, limit int) ([]*store.User, error) {
+	return []*store.User{{ID: 5}, {ID: 7}}, nil
+}
+
+func (s *dueUserStore) Delete(ctx context.Context, id int64) ([]int64, error) {
+	s.record("Delete(%d)", id)
+	if id == 5 {
+		return nil, errors.New("connection reset")
+	}
+	return []int64{}, nil
+}
+
+func TestPurgeDeletedAccounts(t *testing.T) {
+	mockApp := newTestApplication(t)
+	users := &dueUserStore{}
+	mockApp.store.UsersRepository = users
+
+	mockCacheStore := mockApp.cache.UsersCache.(*cache.MockUsersCacheRedis)
+	mockCacheStore.On("Delete", mock.Anything, mock.Anything).Return(nil)
+
+	if err := mockApp.purgeDeletedAccounts(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// the failed account stays cached until it's deleted on a later run
+	users.assertCalls(t, "Delete(5)", "Delete(7)")
+	mockCacheStore.AssertNotCalled(t, "Delete", mock.Anything, int64(5))
+	mockCacheStore.AssertCalled(t, "Delete", mock.Anything, int64(7))
+}
+
+func TestExports(t *testing.T) {
+	mockApp := newTestApplication(t)
+	mockMux := mockApp.mount()
+
+	testToken, _ := mockApp.authenticator.GenerateToken(nil)
+
+	mockCacheStore := mockApp.cache.UsersCache.(*cache.MockUsersCacheRedis)
+	mockCacheStore.On("Get", mock.Anything, mock.Anything).Return(nil, nil)
+	mockCacheStore.On("Set", mock.Anything, mock.Anything).Return(nil)
+
+	// the mock store reports export 1 as ready with this archive
+	archive := "PK fake archive"
+	err := mockApp.blobs.Put(context.Background(), "exports/2025/08/test.zip", strings.NewReader(archive), int64(len(archive)), "application/zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := func(method string, url string) *http.Request {
+		req, err := http.NewRequest(method, url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		return req
+	}
+
+	t.Run("should start an export in the background", func(t *testing.T) {
+		rr := execRequest(request(http.MethodPost, "/v1/users/me/export"), mockMux)
+		assertResponseCode(t, http.StatusAccepted, rr.Code)
+
+		mockApp.wg.Wait()
+	})
+
+	t.Run("should serve a ready export through a signed link", func(t *testing.T) {
+		rr := execRequest(request(http.MethodGet, "/v1/users/me/export/1"), mockMux)
+		assertResponseCode(t, http.StatusOK, rr.Code)
+
+		var response struct {
+			Data struct {
+				DownloadURL string `json:"download_url"`
+			} `json:"data"`
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+			t.Fatal(err)
+		}
+		if response.Data.DownloadURL == "" {
+			t.Fatal("a ready export has no download link")
+		}
+
+		// the link works without a token
+		req, err := http.NewRequest(http.MethodGet, response.Data.DownloadURL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr = execRequest(req, mockMux)
+		assertResponseCode(t, http.StatusOK, rr.Code)
+		if rr.Body.String() != archive || rr.Header().Get("Content-Type") != "application/zip" {
+			t.Errorf("unexpected download %q of type %q", rr.Body.String(), rr.Header().Get("Content-Type"))
+		}
+	})
+
+	t.Run("should reject tampered and expired links", func(t *testing.T) {
+		valid := mockApp.exportDownloadURL(1, time.Now().Add(time.Hour))
+		expired := mockApp.exportDownloadURL(1, time.Now().Add(-time.Minute))
+
+		for _, url := range []string{
+			strings.Replace(valid, "/exports/1/", "/exports/2/", 1),
+			strings.Replace(valid, "signature=", "signature=00", 1),
+			expired,
+			"/v1/exports/1/download",
+		} {
+			req, err := http.NewRequest(http.MethodGet, url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertResponseCode(t, http.StatusForbidden, execRequest(req, mockMux).Code)
+		}
+	})
+	t.Run("should not sign links with the token secret itself", func(t *testing.T) {
+		expires := time.Now().Add(time.Hour).Unix()
+		mac := hmac.New(sha256.New, []byte(mockApp.config.auth.token.secret))
+		fmt.Fprintf(mac, "export:%d:%d", 1, expires)
+
+		req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("/v1/exports/1/download?expires=%d&signature=%s", expires, hex.EncodeToString(mac.Sum(nil))), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertResponseCode(t, http.StatusForbidden, execRequest(req, mockMux).Code)
+	})
+}
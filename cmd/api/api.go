@@ -7,15 +7,21 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/elhambadri2411/social/docs" // internal package, used for generating swagger docs
 	"github.com/elhambadri2411/social/internal/auth"
+	"github.com/elhambadri2411/social/internal/blobstore"
 	"github.com/elhambadri2411/social/internal/mailer"
+	"github.com/elhambadri2411/social/internal/notifications"
 	"github.com/elhambadri2411/social/internal/ratelimiter"
+	"github.com/elhambadri2411/social/internal/search"
 	"github.com/elhambadri2411/social/internal/store" // internal package, serves as abstraction layer for db
 	"github.com/elhambadri2411/social/internal/store/cache"
+	"github.com/elhambadri2411/social/internal/stream"
+	"github.com/elhambadri2411/social/internal/timeline"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
@@ -25,13 +31,22 @@ import (
 
 // `application` config struct which represents application context
 type application struct {
-	config        config             // app level config settings
-	store         store.Storage      // "Repository"
-	logger        *zap.SugaredLogger // logger
-	mailer        mailer.Client
-	authenticator auth.Authenticator
-	cache         cache.Storage
-	rateLimiter   ratelimiter.Limiter
+	config          config             // app level config settings
+	store           store.Storage      // "Repository"
+	logger          *zap.SugaredLogger // logger
+	mailer          mailer.Client
+	authenticator   auth.Authenticator
+	cache           cache.Storage
+	rateLimiter     ratelimiter.Limiter
+	anonRateLimiter ratelimiter.Limiter // stricter limit for visitors of the public endpoints
+	search          search.Index
+	blobs           blobstore.Store
+	timelines       *timeline.Service
+	stream          stream.Broker // live events for connected clients
+	comments        stream.Broker // comment events of each post, for live threads
+	sockets         *socketSet    // open WebSockets, closed on shutdown
+	notifications   *notifications.Service
+	wg              sync.WaitGroup // background work the server waits for on shutdown
 }
 
 // `authConfig` struct stores applciation auth configuration
@@ -55,15 +70,83 @@ type tokenConfig struct {
 
 // `config` struct stores application configuration, including:
 type config struct {
-	addr        string     // port
-	db          dbConfig   // db config settings
-	env         string     // env (PROD or DEV)
-	apiUrl      string     // the external url
-	mail        mailConfig // mail config settings
-	auth        authConfig // auth config settings
-	frontendUrl string     // url for the frontend
-	redis       redisConfig
-	rateLimiter ratelimiter.Config
+	addr          string     // port
+	db            dbConfig   // db config settings
+	env           string     // env (PROD or DEV)
+	apiUrl        string     // the external url
+	mail          mailConfig // mail config settings
+	auth          authConfig // auth config settings
+	frontendUrl   string     // url for the frontend
+	redis         redisConfig
+	rateLimiter   ratelimiter.Config
+	posts         postsConfig // posts related settings
+	search        searchConfig
+	media         mediaConfig
+	suggestions   suggestionsConfig
+	accounts      accountsConfig
+	usernames     usernamesConfig
+	timelines     timeline.Config
+	ranking       store.FeedRanking // weights of the ranked feed
+	publicFeeds   publicFeedsConfig
+	stream        streamConfig
+	liveComments  liveCommentsConfig
+	notifications notificationsConfig
+}
+
+// `publicFeedsConfig` holds settings for the feeds open to visitors without an account
+type publicFeedsConfig struct {
+	cacheTTL    time.Duration      // how long rendered pages are served from Redis and by HTTP caches
+	rateLimiter ratelimiter.Config // limit for anonymous callers, on top of the global one
+}
+
+// `usernamesConfig` holds settings for username changes
+type usernamesConfig struct {
+	changeCooldown time.Duration // how long after a change the username can be changed again
+	reservation    time.Duration // how long an old username stays reserved and redirects to the new one
+}
+
+// `accountsConfig` holds settings for account deletion and data exports
+type accountsConfig struct {
+	deletionGrace   time.Duration // how long a deleted account can be restored before being purged
+	exportRetention time.Duration // how long export archives are kept
+	exportLinkTTL   time.Duration // how long signed download links of exports work
+	purgeInterval   time.Duration // how often deleted accounts and expired exports are purged (0 disables it)
+}
+
+// `suggestionsConfig` holds settings for who-to-follow suggestions
+type suggestionsConfig struct {
+	size            int           // suggestions computed and cached per user
+	ttl             time.Duration // how long cached suggestions are served
+	refreshInterval time.Duration // how often the refresh job runs (0 disables it)
+	activeWindow    time.Duration // the job refreshes users who asked for suggestions this recently
+}
+
+// `mediaConfig` holds settings for image uploads and where they are stored
+type mediaConfig struct {
+	backend        string // "local" or "s3"
+	localPath      string // root directory of the local backend
+	s3             blobstore.S3Config
+	publicURL      string        // base URL media is served from, the API itself by default
+	maxUploadBytes int64         // largest accepted upload
+	maxPixels      int           // largest accepted width * height
+	maxGIFPixels   int           // largest accepted width * height * frames of a GIF
+	thumbnailSize  int           // thumbnails fit in a square of this size
+	avatarSize     int           // avatars are cropped to a square of this size
+	maxPerPost     int           // attachments allowed per post
+	purgeInterval  time.Duration // how often files of detached attachments are removed (0 disables it)
+}
+
+// `searchConfig` selects the search backend
+type searchConfig struct {
+	backend   string // "postgres" (full-text search in the database) or "bleve" (embedded index)
+	indexPath string // directory of the bleve index
+}
+
+// `postsConfig` holds settings for the posts endpoints
+type postsConfig struct {
+	requireIfMatch bool          // reject PATCH/DELETE without an If-Match header (428)
+	trashRetention time.Duration // how long deleted posts can be restored before being purged
+	purgeInterval  time.Duration // how often the purge job runs (0 disables it)
 }
 
 type redisConfig struct {
@@ -108,55 +191,139 @@ func (app *application) mount() *chi.Mux {
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins: []string{"https://*"},
 		AllowedMethods: []string{"GET", "POST", "PUT", "DELETE", "PATCH", "OPTIONS", "DELETE"},
+		AllowedHeaders: []string{"Accept", "Authorization", "Content-Type", "If-Match", "If-None-Match", "If-Modified-Since"},
+		ExposedHeaders: []string{"ETag", "Last-Modified"},
 	}))
 	r.Use(app.RateLimiterMiddleware)
 
-	// Set a timeout for all HTTP requests to prevent hanging requests.
-	// If a request takes longer than 60 seconds, it is automatically canceled.
-	r.Use(middleware.Timeout(60 * time.Second))
-
 	// Define API routes under the `/v1` prefix
 	r.Route("/v1", func(r chi.Router) {
-		// r.With(app.BasicAuthMiddleware()).Get("/health", app.healthCheckHandler)
-		r.Get("/health", app.healthCheckHandler)
-		docsURL := fmt.Sprintf("%s/swagger/doc.json", app.config.addr)
+		// Streams stay open as long as the client is connected, so they don't get the timeout below
+		r.With(app.AuthTokenMiddleware).Get("/stream", app.getStreamHandler)
+		r.Get("/comments/live", app.liveCommentsHandler)
 
-		r.Get("/swagger/*", httpSwagger.Handler(httpSwagger.URL(docsURL)))
+		r.Group(func(r chi.Router) {
+			// Set a timeout for all other HTTP requests to prevent hanging requests.
+			// If a request takes longer than 60 seconds, it is automatically canceled.
+			r.Use(middleware.Timeout(60 * time.Second))
 
-		r.Route("/posts", func(r chi.Router) {
-			r.Use(app.AuthTokenMiddleware)
+			// r.With(app.BasicAuthMiddleware()).Get("/health", app.healthCheckHandler)
+			r.Get("/health", app.healthCheckHandler)
+			docsURL := fmt.Sprintf("%s/swagger/doc.json", app.config.addr)
 
-			r.Post("/", app.createPostsHandler)
-			r.Route("/{postId}", func(r chi.Router) {
-				r.Use(app.postsContextMiddleware)
+			r.Get("/swagger/*", httpSwagger.Handler(httpSwagger.URL(docsURL)))
 
-				r.Get("/", app.getPostByIdHandler)
-				r.Delete("/", app.checkPostOwnership("admin", app.deletePostByIdHandler))
-				r.Patch("/", app.checkPostOwnership("moderator", app.updatePostByIdHandler))
-			})
-			// r.Get("/", app.getPostsHandler)
-		})
+			r.Get("/snippets/highlight.css", app.getSnippetStylesheetHandler)
+			r.Get("/media/*", app.getMediaHandler)
+			r.Get("/exports/{exportId}/download", app.downloadExportHandler)
+			r.Post("/notifications/unsubscribe", app.unsubscribeHandler)
+			r.With(app.AnonymousRateLimiterMiddleware).Get("/explore", app.getExploreHandler)
 
-		r.Route("/users", func(r chi.Router) {
-			r.Route("/{userId}", func(r chi.Router) {
+			r.Route("/posts", func(r chi.Router) {
 				r.Use(app.AuthTokenMiddleware)
 
-				r.Get("/", app.getUserHandler)
-				r.Put("/follow", app.followUserHandler)
-				r.Put("/unfollow", app.unfollowUserHandler)
+				r.Post("/", app.createPostsHandler)
+				r.Get("/trash", app.getTrashHandler)
+				r.Put("/trash/{postId}/restore", app.restorePostHandler)
+				r.With(app.requireRole("moderator")).Get("/deleted", app.getDeletedPostsHandler)
+				r.Route("/{postId}", func(r chi.Router) {
+					r.Use(app.postsContextMiddleware)
+
+					r.Get("/", app.getPostByIdHandler)
+					r.Get("/snippets/{snippetId}/raw", app.getRawSnippetHandler)
+					r.Post("/comments", app.createCommentHandler)
+					r.Route("/comments/{commentId}", func(r chi.Router) {
+						r.Use(app.commentsContextMiddleware)
+
+						r.Patch("/", app.checkCommentOwnership("moderator", app.updateCommentHandler))
+						r.Delete("/", app.checkCommentOwnership("admin", app.deleteCommentHandler))
+					})
+					r.Post("/media", app.uploadMediaHandler)
+					r.Delete("/media/{mediaId}", app.deleteMediaHandler)
+					r.Delete("/", app.checkPostOwnership("admin", app.deletePostByIdHandler))
+					r.Patch("/", app.checkPostOwnership("moderator", app.updatePostByIdHandler))
+				})
+				// r.Get("/", app.getPostsHandler)
 			})
-			r.Put("/activate/{token}", app.activateUserHandler)
 
-			r.Group(func(r chi.Router) {
-				r.Use(app.AuthTokenMiddleware)
-				r.Get("/feed", app.getUserFeedHandler)
+			r.Route("/users", func(r chi.Router) {
+				r.Route("/{userId}", func(r chi.Router) {
+					r.Use(app.AuthTokenMiddleware)
+
+					r.Get("/", app.getUserHandler)
+					r.Put("/follow", app.followUserHandler)
+					r.Put("/unfollow", app.unfollowUserHandler)
+					r.Put("/block", app.blockUserHandler)
+					r.Put("/unblock", app.unblockUserHandler)
+					r.Put("/mute", app.muteUserHandler)
+					r.Put("/unmute", app.unmuteUserHandler)
+					r.Get("/followers", app.getFollowersHandler)
+					r.Get("/following", app.getFollowingHandler)
+				})
+				r.Put("/activate/{token}", app.activateUserHandler)
+				r.Get("/{username}/feed.atom", app.getUserAtomFeedHandler)
+				r.Get("/{username}/feed.rss", app.getUserRSSFeedHandler)
+				r.With(app.AuthTokenMiddleware).Get("/by-username/{username}", app.getUserByUsernameHandler)
+
+				r.Route("/me", func(r chi.Router) {
+					r.Use(app.AuthTokenMiddleware)
+
+					r.Get("/", app.getMyProfileHandler)
+					r.Patch("/", app.updateMyProfileHandler)
+					r.Put("/username", app.changeUsernameHandler)
+					r.Delete("/", app.deleteMyAccountHandler)
+					r.Put("/restore", app.restoreMyAccountHandler)
+					r.Post("/export", app.requestExportHandler)
+					r.Get("/export/{exportId}", app.getExportHandler)
+					r.Put("/avatar", app.uploadAvatarHandler)
+					r.Delete("/avatar", app.deleteAvatarHandler)
+					r.Get("/follow-requests", app.getFollowRequestsHandler)
+					r.Put("/follow-requests/{userId}/approve", app.approveFollowRequestHandler)
+					r.Delete("/follow-requests/{userId}", app.rejectFollowRequestHandler)
+					r.Get("/blocks", app.getBlockedUsersHandler)
+					r.Get("/mutes", app.getMutedUsersHandler)
+					r.Get("/suggestions", app.getSuggestionsHandler)
+					r.Get("/mentions", app.getMyMentionsHandler)
+					r.Get("/notifications", app.getMyNotificationsHandler)
+					r.Get("/notifications/preferences", app.getNotificationPreferencesHandler)
+					r.Put("/notifications/preferences", app.updateNotificationPreferencesHandler)
+					r.Put("/notifications/read", app.markAllNotificationsReadHandler)
+					r.Put("/notifications/{notificationId}/read", app.markNotificationReadHandler)
+					r.Get("/tags", app.getFollowedTagsHandler)
+				})
+
+				r.Group(func(r chi.Router) {
+					r.Use(app.AuthTokenMiddleware)
+					r.Get("/feed", app.getUserFeedHandler)
+				})
+			})
+
+			r.Route("/tags", func(r chi.Router) {
+				r.With(app.AnonymousRateLimiterMiddleware).Get("/{tag}/feed", app.getTagFeedHandler)
+				r.Get("/{tag}/feed.atom", app.getTagAtomFeedHandler)
+
+				r.Group(func(r chi.Router) {
+					r.Use(app.AuthTokenMiddleware)
+
+					r.Get("/trending", app.getTrendingTagsHandler)
+					r.Route("/{tag}", func(r chi.Router) {
+						r.Use(app.tagsContextMiddleware)
+
+						r.Get("/", app.getTagHandler)
+						r.Get("/posts", app.getTagPostsHandler)
+						r.Put("/follow", app.followTagHandler)
+						r.Put("/unfollow", app.unfollowTagHandler)
+					})
+				})
 			})
-		})
 
-		// Public
-		r.Route("/authentication", func(r chi.Router) {
-			r.Post("/user", app.registerUserHandler)
-			r.Post("/token", app.createTokenHandler)
+			r.With(app.AuthTokenMiddleware).Get("/search", app.searchHandler)
+
+			// Public
+			r.Route("/authentication", func(r chi.Router) {
+				r.Post("/user", app.registerUserHandler)
+				r.Post("/token", app.createTokenHandler)
+			})
 		})
 	})
 
@@ -175,6 +342,24 @@ func (app *application) run(mux *chi.Mux) error {
 	}
 
 	app.logger.Infow("Server listening", "addr", app.config.addr, "env", app.config.env)
+
+	// open event streams would otherwise hold up the shutdown until it times out,
+	// and the server doesn't know about upgraded WebSockets at all
+	server.RegisterOnShutdown(func() {
+		app.sockets.closeAll()
+		if err := app.stream.Close(); err != nil {
+			app.logger.Warnw("failed to close event streams", "error", err.Error())
+		}
+		if err := app.comments.Close(); err != nil {
+			app.logger.Warnw("failed to close comment streams", "error", err.Error())
+		}
+	})
+
+	// background jobs live as long as the server
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	app.startJobs(jobsCtx)
+
 	shutdown := make(chan error)
 	go func() {
 		quit := make(chan os.Signal, 1)
@@ -185,6 +370,7 @@ func (app *application) run(mux *chi.Mux) error {
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 		app.logger.Infow("signal caught", "signal", s.String())
+		stopJobs()
 
 		shutdown <- server.Shutdown(ctx)
 	}()
@@ -199,6 +385,8 @@ func (app *application) run(mux *chi.Mux) error {
 		return err
 	}
 
+	app.wg.Wait()
+
 	app.logger.Infow("server has stopped")
 	return nil
 }
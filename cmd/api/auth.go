@@ -1,6 +1,7 @@
 package main
 
 import (
+	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
@@ -109,7 +110,7 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 		app.logger.Errorw("error sending welcome email", "error", err)
 
 		// rollback user creating if email fails
-		if err := app.store.UsersRepository.Delete(ctx, user.ID); err != nil {
+		if _, err := app.store.UsersRepository.Delete(ctx, user.ID); err != nil {
 			app.logger.Errorw("error deleting user after email sending fails", "error", err)
 		}
 
@@ -211,3 +212,11 @@ func (app *application) createTokenHandler(w http.ResponseWriter, r *http.Reques
 		app.internalServerError(w, r, err)
 	}
 }
+
+// `derivedKey` derives a key from the token secret for the purpose named by `label`, so what is
+// signed for one purpose can't be replayed for another or mistaken for a token
+func (app *application) derivedKey(label string) []byte {
+	key := hmac.New(sha256.New, []byte(app.config.auth.token.secret))
+	key.Write([]byte(label))
+	return key.Sum(nil)
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/elhambadri2411/social/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+// BlockUser godoc
+//
+//	@Summary		Blocks a user
+//	@Description	Blocks a user by ID. Follows in both directions are removed, and neither user can see
+//	@Description	the other's posts, follow, comment on or mention the other.
+//	@Tags			users
+//	@Param			userID	path		int		true	"User ID"
+//	@Success		204		{string}	string	"User blocked"
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/block [put]
+func (app *application) blockUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.relateUser(w, r, func(ctx context.Context, blockerId int64, blockedId int64) error {
+		if err := app.store.BlocksRepository.Block(ctx, blockerId, blockedId); err != nil {
+			return err
+		}
+
+		// blocking removed the follows in both directions
+		app.trimTimeline(blockerId, blockedId)
+		app.trimTimeline(blockedId, blockerId)
+		app.forgetSuggestions(ctx, blockerId)
+		app.forgetSuggestions(ctx, blockedId)
+		return nil
+	})
+}
+
+// UnblockUser godoc
+//
+//	@Summary	Unblocks a user
+//	@Tags		users
+//	@Param		userID	path		int		true	"User ID"
+//	@Success	204		{string}	string	"User unblocked"
+//	@Failure	400		{object}	error
+//	@Failure	404		{object}	error	"User is not blocked"
+//	@Failure	500		{object}	error
+//	@Security	ApiKeyAuth
+//	@Router		/users/{userID}/unblock [put]
+func (app *application) unblockUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.relateUser(w, r, app.store.BlocksRepository.Unblock)
+}
+
+// MuteUser godoc
+//
+//	@Summary		Mutes a user
+//	@Description	Mutes a user by ID. Their posts are hidden from your feed and their mentions don't notify you, they are not told.
+//	@Tags			users
+//	@Param			userID	path		int		true	"User ID"
+//	@Success		204		{string}	string	"User muted"
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/mute [put]
+func (app *application) muteUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.relateUser(w, r, func(ctx context.Context, muterId int64, mutedId int64) error {
+		if err := app.store.BlocksRepository.Mute(ctx, muterId, mutedId); err != nil {
+			return err
+		}
+
+		app.forgetSuggestions(ctx, muterId)
+		return nil
+	})
+}
+
+// UnmuteUser godoc
+//
+//	@Summary	Unmutes a user
+//	@Tags		users
+//	@Param		userID	path		int		true	"User ID"
+//	@Success	204		{string}	string	"User unmuted"
+//	@Failure	400		{object}	error
+//	@Failure	404		{object}	error	"User is not muted"
+//	@Failure	500		{object}	error
+//	@Security	ApiKeyAuth
+//	@Router		/users/{userID}/unmute [put]
+func (app *application) unmuteUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.relateUser(w, r, app.store.BlocksRepository.Unmute)
+}
+
+// GetBlockedUsers godoc
+//
+//	@Summary	Lists blocked users
+//	@Tags		users
+//	@Produce	json
+//	@Param		limit	query		int	false	"Limit"
+//	@Param		offset	query		int	false	"Offset"
+//	@Success	200		{object}	[]store.RelatedUser
+//	@Failure	400		{object}	error
+//	@Failure	500		{object}	error
+//	@Security	ApiKeyAuth
+//	@Router		/users/me/blocks [get]
+func (app *application) getBlockedUsersHandler(w http.ResponseWriter, r *http.Request) {
+	app.listRelatedUsers(w, r, app.store.BlocksRepository.GetBlocked)
+}
+
+// GetMutedUsers godoc
+//
+//	@Summary	Lists muted users
+//	@Tags		users
+//	@Produce	json
+//	@Param		limit	query		int	false	"Limit"
+//	@Param		offset	query		int	false	"Offset"
+//	@Success	200		{object}	[]store.RelatedUser
+//	@Failure	400		{object}	error
+//	@Failure	500		{object}	error
+//	@Security	ApiKeyAuth
+//	@Router		/users/me/mutes [get]
+func (app *application) getMutedUsersHandler(w http.ResponseWriter, r *http.Request) {
+	app.listRelatedUsers(w, r, app.store.BlocksRepository.GetMuted)
+}
+
+// `relateUser` applies `relate` between the authenticated user and the user in the path
+func (app *application) relateUser(w http.ResponseWriter, r *http.Request, relate func(context.Context, int64, int64) error) {
+	user := getUserFromCtx(r)
+	ctx := r.Context()
+
+	otherId, err := strconv.ParseInt(chi.URLParam(r, "userId"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if otherId == user.ID {
+		app.badRequestResponse(w, r, errors.New("you can't block or mute yourself"))
+		return
+	}
+
+	if _, err := app.store.UsersRepository.GetById(ctx, otherId); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := relate(ctx, user.ID, otherId); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *application) listRelatedUsers(
+	w http.ResponseWriter,
+	r *http.Request,
+	list func(ctx context.Context, userId int64, limit int, offset int) ([]store.RelatedUser, error),
+) {
+	user := getUserFromCtx(r)
+
+	pfq := store.PaginatedFeedQuery{
+		Limit:  20,
+		Offset: 0,
+		Sort:   "desc",
+	}
+
+	pfq, err := pfq.Parse(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(pfq); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	users, err := list(r.Context(), user.ID, pfq.Limit, pfq.Offset)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	for i := range users {
+		users[i].AvatarURL, users[i].AvatarThumbnailURL = app.avatarURLs(users[i].AvatarKey, users[i].AvatarThumbnailKey)
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, users); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// `isBlocked` reports whether either of the two users blocked the other
+func (app *application) isBlocked(ctx context.Context, userId int64, otherId int64) (bool, error) {
+	if userId == otherId {
+		return false, nil
+	}
+
+	return app.store.BlocksRepository.IsBlocked(ctx, userId, otherId)
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/elhambadri2411/social/internal/store"
+	"github.com/elhambadri2411/social/internal/stream"
+	"github.com/go-chi/chi/v5"
+)
+
+type commentKey string
+
+const commentCtx commentKey = "comment"
+
+type createCommentPayload struct {
+	Content string `json:"content" validate:"required,max=1000"`
+}
+
+type updateCommentPayload struct {
+	Content string `json:"content" validate:"required,max=1000"`
+}
+
+// CreateComment godoc
+//
+//	@Summary		Comments on a post
+//	@Description	Creates a comment on a post
+//	@Tags			posts
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int						true	"Post ID"
+//	@Param			payload	body		createCommentPayload	true	"Comment payload"
+//	@Success		201		{object}	store.Comment
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error	"The author blocked you or you blocked them"
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{id}/comments [post]
+func (app *application) createCommentHandler(w http.ResponseWriter, r *http.Request) {
+	var payload createCommentPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromCtx(r)
+	post := getPostFromCtx(r)
+	ctx := r.Context()
+
+	blocked, err := app.isBlocked(ctx, user.ID, post.UserId)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if blocked {
+		app.forbiddenResponse(w, r)
+		return
+	}
+
+	comment := &store.Comment{
+		PostId:  post.ID,
+		UserId:  user.ID,
+		Content: payload.Content,
+		User:    store.Author{ID: user.ID, Username: user.Username},
+	}
+
+	if err := app.store.CommentsRepository.Create(ctx, comment); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	comment.Mentions = app.syncMentions(ctx, store.MentionSource{
+		PostId:    post.ID,
+		CommentId: &comment.ID,
+		AuthorId:  user.ID,
+	}, comment.Content)
+
+	app.indexComment(ctx, post, comment)
+	app.streamComment(post, comment)
+	app.notifyComment(post, comment)
+	app.publishCommentEvent(ctx, stream.EventCommentCreated, post.ID, comment)
+
+	if err := app.jsonResponse(w, http.StatusCreated, comment); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// UpdateComment godoc
+//
+//	@Summary		Edits a comment
+//	@Description	Replaces the content of a comment, it is marked as edited with `updated_at`
+//	@Tags			posts
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path		int						true	"Post ID"
+//	@Param			commentId	path		int						true	"Comment ID"
+//	@Param			payload		body		updateCommentPayload	true	"Comment payload"
+//	@Success		200			{object}	store.Comment
+//	@Failure		400			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{id}/comments/{commentId} [patch]
+func (app *application) updateCommentHandler(w http.ResponseWriter, r *http.Request) {
+	var payload updateCommentPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	post := getPostFromCtx(r)
+	comment := getCommentFromCtx(r)
+	ctx := r.Context()
+
+	comment.Content = payload.Content
+	if err := app.store.CommentsRepository.Update(ctx, comment); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	comment.Mentions = app.syncMentions(ctx, store.MentionSource{
+		PostId:    post.ID,
+		CommentId: &comment.ID,
+		AuthorId:  comment.UserId,
+	}, comment.Content)
+
+	app.indexComment(ctx, post, comment)
+	app.publishCommentEvent(ctx, stream.EventCommentUpdated, post.ID, comment)
+
+	if err := app.jsonResponse(w, http.StatusOK, comment); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// DeleteComment godoc
+//
+//	@Summary		Deletes a comment
+//	@Description	Deletes a comment for good
+//	@Tags			posts
+//	@Produce		json
+//	@Param			id			path		int		true	"Post ID"
+//	@Param			commentId	path		int		true	"Comment ID"
+//	@Success		204			{object}	string
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{id}/comments/{commentId} [delete]
+func (app *application) deleteCommentHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+	comment := getCommentFromCtx(r)
+	ctx := r.Context()
+
+	if err := app.store.CommentsRepository.Delete(ctx, comment.ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.unindexComment(ctx, comment.ID)
+	app.publishCommentEvent(ctx, stream.EventCommentDeleted, post.ID, commentDeletedEvent{
+		PostId:    post.ID,
+		CommentId: comment.ID,
+		UserId:    comment.UserId,
+	})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *application) commentsContextMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		commentId, err := strconv.ParseInt(chi.URLParam(r, "commentId"), 10, 64)
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+
+		comment, err := app.store.CommentsRepository.GetById(ctx, getPostFromCtx(r).ID, commentId)
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				app.notFoundResponse(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		ctx = context.WithValue(ctx, commentCtx, comment)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func getCommentFromCtx(r *http.Request) *store.Comment {
+	comment := r.Context().Value(commentCtx)
+	return comment.(*store.Comment)
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"net/http"
 )
 
@@ -53,3 +54,27 @@ func (app *application) rateLimitExceededResponse(w http.ResponseWriter, r *http
 
 	writeJSONError(w, http.StatusTooManyRequests, "rate limit exceeded, retry after: "+retryAfter)
 }
+
+func (app *application) preconditionFailedResponse(w http.ResponseWriter, r *http.Request) {
+	app.logger.Warnw("precondition failed", "method", r.Method, "path", r.URL.Path)
+
+	writeJSONError(w, http.StatusPreconditionFailed, "the resource has been modified, refetch it and try again")
+}
+
+func (app *application) preconditionRequiredResponse(w http.ResponseWriter, r *http.Request) {
+	app.logger.Warnw("precondition required", "method", r.Method, "path", r.URL.Path)
+
+	writeJSONError(w, http.StatusPreconditionRequired, "the If-Match header is required")
+}
+
+func (app *application) payloadTooLargeResponse(w http.ResponseWriter, r *http.Request, maxBytes int64) {
+	app.logger.Warnw("payload too large", "method", r.Method, "path", r.URL.Path)
+
+	writeJSONError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("the upload must not be larger than %d bytes", maxBytes))
+}
+
+func (app *application) unsupportedMediaTypeResponse(w http.ResponseWriter, r *http.Request, err error) {
+	app.logger.Warnw("unsupported media type", "method", r.Method, "path", r.URL.Path, "error", err.Error())
+
+	writeJSONError(w, http.StatusUnsupportedMediaType, err.Error())
+}
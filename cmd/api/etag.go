@@ -0,0 +1,85 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/elhambadri2411/social/internal/store"
+)
+
+// `postETag` derives a strong entity tag from the optimistic locking version of a post
+func postETag(post *store.Post) string {
+	return `"` + strconv.FormatInt(post.Version, 10) + `"`
+}
+
+// `postRepresentationETag` tags a post as served by GET: its version followed by a digest of `body`.
+// Comments, media and mentions don't bump the version and comments depend on the viewer,
+// the digest changes with them so If-None-Match doesn't answer 304 for a stale thread.
+func postRepresentationETag(post *store.Post, body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + strconv.FormatInt(post.Version, 10) + "-" + hex.EncodeToString(sum[:12]) + `"`
+}
+
+// `versionETag` reduces a tag from `postRepresentationETag` to the version tag it starts with,
+// If-Match only guards the post itself, so both kinds of tags can be sent back
+func versionETag(etag string) string {
+	if version, _, ok := strings.Cut(etag, "-"); ok && strings.HasPrefix(etag, `"`) {
+		return version + `"`
+	}
+
+	return etag
+}
+
+// `etagMatches` reports whether `etag` is listed in an If-Match / If-None-Match header value.
+// A `*` matches any current representation. When `weak` is set, the `W/` prefix is ignored
+// on both sides (weak comparison, as required for If-None-Match).
+func etagMatches(header, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+
+		if weak {
+			candidate = strings.TrimPrefix(candidate, "W/")
+			etag = strings.TrimPrefix(etag, "W/")
+		} else if strings.HasPrefix(candidate, "W/") {
+			// weak tags never match under strong comparison
+			continue
+		}
+
+		if candidate == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// `checkIfMatch` validates the If-Match precondition for a state changing request on `post`.
+// It writes the appropriate error response and returns false when the request must not proceed.
+func (app *application) checkIfMatch(w http.ResponseWriter, r *http.Request, post *store.Post) bool {
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" {
+		if app.config.posts.requireIfMatch {
+			app.preconditionRequiredResponse(w, r)
+			return false
+		}
+		return true
+	}
+
+	candidates := strings.Split(ifMatch, ",")
+	for i, candidate := range candidates {
+		candidates[i] = versionETag(strings.TrimSpace(candidate))
+	}
+
+	if !etagMatches(strings.Join(candidates, ","), postETag(post), false) {
+		app.preconditionFailedResponse(w, r)
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/elhambadri2411/social/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+// GetExplore godoc
+//
+//	@Summary		Fetches the explore feed
+//	@Description	Fetches the latest posts of public accounts, newest first. No authentication is needed,
+//	@Description	pages are cached for a short while so new posts can take some time to show up.
+//	@Description	Pass `next_cursor` from a response as `cursor` to fetch the following page.
+//	@Tags			feed
+//	@Produce		json
+//	@Param			limit	query		int		false	"Limit (default 20, max 100)"
+//	@Param			cursor	query		string	false	"Cursor from a previous page"
+//	@Success		200		{object}	feedPage
+//	@Failure		400		{object}	error
+//	@Failure		429		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/explore [get]
+func (app *application) getExploreHandler(w http.ResponseWriter, r *http.Request) {
+	app.publicFeed(w, r, "")
+}
+
+// GetTagFeed godoc
+//
+//	@Summary		Fetches the public feed of a tag
+//	@Description	Fetches the latest posts of public accounts carrying a tag (aliases are resolved), newest first.
+//	@Description	No authentication is needed, pages are cached for a short while like the explore feed.
+//	@Tags			tags
+//	@Produce		json
+//	@Param			tag		path		string	true	"Tag name or alias"
+//	@Param			limit	query		int		false	"Limit (default 20, max 100)"
+//	@Param			cursor	query		string	false	"Cursor from a previous page"
+//	@Success		200		{object}	feedPage
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		429		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/tags/{tag}/feed [get]
+func (app *application) getTagFeedHandler(w http.ResponseWriter, r *http.Request) {
+	app.publicFeed(w, r, chi.URLParam(r, "tag"))
+}
+
+// `publicFeed` serves a page of the public posts with `tag`, or of every public post when `tag`
+// is empty. Pages are cached in Redis as rendered, so busy pages are served without the database.
+func (app *application) publicFeed(w http.ResponseWriter, r *http.Request, tag string) {
+	pfq := store.PaginatedFeedQuery{
+		Limit: 20,
+		Sort:  "desc",
+	}
+
+	pfq, err := pfq.Parse(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(pfq); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if pfq.Limit < 1 || pfq.Limit > maxFeedLimit {
+		app.badRequestResponse(w, r, fmt.Errorf("limit must be between 1 and %d", maxFeedLimit))
+		return
+	}
+
+	// the public feeds only take a page size and a cursor, so every visitor shares the same pages
+	if pfq.Offset != 0 || pfq.Sort != "desc" || pfq.Mode == store.FeedModeRanked || pfq.Search != "" || len(pfq.Tags) != 0 ||
+		pfq.Since != nil || pfq.Until != nil {
+		app.badRequestResponse(w, r, errors.New("public feeds only support limit and cursor"))
+		return
+	}
+
+	ctx := r.Context()
+	key := publicFeedKey(tag, pfq)
+
+	page, err := app.cache.PublicFeedsCache.Get(ctx, key)
+	if err != nil {
+		// a cache outage shouldn't fail the request
+		app.logger.Warnw("failed to read public feed cache", "key", key, "error", err.Error())
+	}
+	if page != nil {
+		app.writePublicFeed(w, r, page)
+		return
+	}
+
+	// filter on the canonical name, so "golang" finds posts tagged "go"
+	if tag != "" {
+		found, err := app.store.TagsRepository.GetByName(ctx, tag)
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				app.notFoundResponse(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+		tag = found.Name
+	}
+
+	feed, err := app.store.PostsRepository.GetPublic(ctx, 0, tag, pfq.Limit, pfq.After)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	page, err = json.Marshal(feedPage{Posts: feed, NextCursor: store.NextFeedCursor(feed, pfq.Limit)})
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.cache.PublicFeedsCache.Set(ctx, key, page); err != nil {
+		app.logger.Warnw("failed to cache public feed", "key", key, "error", err.Error())
+	}
+
+	app.writePublicFeed(w, r, page)
+}
+
+// `writePublicFeed` answers a rendered page, only successful pages may be cached by browsers and proxies
+func (app *application) writePublicFeed(w http.ResponseWriter, r *http.Request, page []byte) {
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(app.config.publicFeeds.cacheTTL.Seconds())))
+
+	if err := app.jsonResponse(w, http.StatusOK, json.RawMessage(page)); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// `publicFeedKey` names a cached page of a public feed, `tag` is the tag as requested
+func publicFeedKey(tag string, pfq store.PaginatedFeedQuery) string {
+	cursor := ""
+	if pfq.After != nil {
+		cursor = pfq.After.Encode()
+	}
+
+	feed := "explore"
+	if tag != "" {
+		feed = "tag:" + tag
+	}
+
+	return feed + ":" + strconv.Itoa(pfq.Limit) + ":" + cursor
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/elhambadri2411/social/internal/ratelimiter"
+	"github.com/elhambadri2411/social/internal/store"
+	"github.com/elhambadri2411/social/internal/store/cache"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestPublicFeeds(t *testing.T) {
+	mockApp := newTestApplication(t)
+	mockApp.anonRateLimiter = ratelimiter.NewFixedWindowRateLimiter(4, time.Minute)
+	mockMux := mockApp.mount()
+
+	testToken, _ := mockApp.authenticator.GenerateToken(nil)
+
+	mockCacheStore := mockApp.cache.UsersCache.(*cache.MockUsersCacheRedis)
+	mockCacheStore.On("Get", mock.Anything, mock.Anything).Return(nil, nil)
+	mockCacheStore.On("Set", mock.Anything, mock.Anything).Return(nil)
+
+	cachedPage := []byte(`{"posts":[{"id":42}]}`)
+	mockPublicFeeds := mockApp.cache.PublicFeedsCache.(*cache.MockPublicFeedsCacheRedis)
+	mockPublicFeeds.On("Get", mock.Anything, "explore:20:").Return(nil, nil)
+	mockPublicFeeds.On("Get", mock.Anything, "tag:golang:20:").Return(cachedPage, nil)
+	mockPublicFeeds.On("Set", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	get := func(url string, token string) (int, []byte) {
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rr := execRequest(req, mockMux)
+		return rr.Code, rr.Body.Bytes()
+	}
+
+	t.Run("should serve the explore feed without authentication", func(t *testing.T) {
+		code, body := get("/v1/explore", "")
+		assertResponseCode(t, http.StatusOK, code)
+
+		var response struct {
+			Data feedPage `json:"data"`
+		}
+		if err := json.Unmarshal(body, &response); err != nil {
+			t.Fatal(err)
+		}
+		if len(response.Data.Posts) != 1 || response.Data.Posts[0].ID != 1 {
+			t.Errorf("unexpected posts %+v", response.Data.Posts)
+		}
+		mockPublicFeeds.AssertCalled(t, "Set", mock.Anything, "explore:20:", mock.Anything)
+	})
+
+	t.Run("should serve cached pages of a tag feed", func(t *testing.T) {
+		code, body := get("/v1/tags/golang/feed", "")
+		assertResponseCode(t, http.StatusOK, code)
+
+		var response struct {
+			Data struct {
+				Posts []struct {
+					ID int64 `json:"id"`
+				} `json:"posts"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(body, &response); err != nil {
+			t.Fatal(err)
+		}
+		if len(response.Data.Posts) != 1 || response.Data.Posts[0].ID != 42 {
+			t.Errorf("expected the cached page, got %s", body)
+		}
+		mockPublicFeeds.AssertNotCalled(t, "Set", mock.Anything, "tag:golang:20:", mock.Anything)
+	})
+
+	t.Run("should only take a limit and a cursor", func(t *testing.T) {
+		code, _ := get("/v1/explore?offset=20", "")
+		assertResponseCode(t, http.StatusBadRequest, code)
+		code, _ = get("/v1/explore?search=go", "")
+		assertResponseCode(t, http.StatusBadRequest, code)
+		code, _ = get("/v1/explore?limit=101", testToken)
+		assertResponseCode(t, http.StatusBadRequest, code)
+	})
+
+	t.Run("should only let browsers cache successful pages", func(t *testing.T) {
+		mockApp.store.TagsRepository = &unknownTagStore{}
+		defer func() { mockApp.store.TagsRepository = &store.MockTagStore{} }()
+		mockPublicFeeds.On("Get", mock.Anything, "tag:nope:20:").Return(nil, nil)
+
+		authenticatedGet := func(url string) *httptest.ResponseRecorder {
+			req, err := http.NewRequest(http.MethodGet, url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", "Bearer "+testToken)
+			return execRequest(req, mockMux)
+		}
+
+		rr := authenticatedGet("/v1/tags/nope/feed")
+		assertResponseCode(t, http.StatusNotFound, rr.Code)
+		if cacheControl := rr.Header().Get("Cache-Control"); cacheControl != "" {
+			t.Errorf("Expected no Cache-Control on a 404. Got %q", cacheControl)
+		}
+
+		rr = authenticatedGet("/v1/tags/golang/feed")
+		assertResponseCode(t, http.StatusOK, rr.Code)
+		if cacheControl := rr.Header().Get("Cache-Control"); cacheControl != "public, max-age=60" {
+			t.Errorf("Expected a public Cache-Control. Got %q", cacheControl)
+		}
+	})
+
+	t.Run("should rate limit anonymous callers only", func(t *testing.T) {
+		code, _ := get("/v1/explore", "")
+		assertResponseCode(t, http.StatusTooManyRequests, code)
+
+		code, _ = get("/v1/explore", testToken)
+		assertResponseCode(t, http.StatusOK, code)
+	})
+
+	t.Run("should keep the other tag endpoints authenticated", func(t *testing.T) {
+		code, _ := get("/v1/tags/golang/posts", "")
+		assertResponseCode(t, http.StatusUnauthorized, code)
+	})
+}
+
+// `unknownTagStore` only knows the golang tag
+type unknownTagStore struct {
+	store.MockTagStore
+}
+
+func (s *unknownTagStore) GetByName(ctx context.Context, name string) (*store.Tag, error) {
+	if name != "golang" {
+		return nil, store.ErrNotFound
+	}
+	return s.MockTagStore.GetByName(ctx, name)
+}
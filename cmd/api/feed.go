@@ -1,31 +1,55 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/elhambadri2411/social/internal/store"
 )
 
+// `maxFeedLimit` is the largest page of the home and public feeds
+const maxFeedLimit = 100
+
+// `feedPage` is a page of a feed. The home feed used to answer a bare array of posts,
+// it answers this object since it pages with cursors.
+type feedPage struct {
+	Posts      []*store.FeedPost `json:"posts"`
+	NextCursor string            `json:"next_cursor,omitempty"`
+}
+
 // getUserFeedHandler godoc
 //
 //	@Summary		Fetches the user feed
-//	@Description	Fetches the user feed
+//	@Description	Fetches the feed of the authenticated user: their own posts, posts of users they follow and
+//	@Description	posts with tags they follow. Pass `next_cursor` from a response as `cursor` to fetch the following page.
+//	@Description	Pages of the unfiltered, newest first feed can be shorter than `limit` without being the last one.
+//	@Description	With `mode=ranked`, posts of followed users and tags from the last few days are ranked by recency,
+//	@Description	comment velocity and how often you interact with the author instead. `debug=true` adds the score of each post.
+//	@Description	Velocity and interactions only count comments and mentions, the API has no reactions.
+//	@Description	The response is an object with `posts` and `next_cursor`, it used to be a bare array of posts.
 //	@Tags			feed
 //	@Accept			json
 //	@Produce		json
-//	@Param			since	query		string	false	"Since"
-//	@Param			until	query		string	false	"Until"
-//	@Param			limit	query		int		false	"Limit"
-//	@Param			offset	query		int		false	"Offset"
-//	@Param			sort	query		string	false	"Sort"
+//	@Param			since	query		string	false	"Only posts created at or after this time (RFC 3339 or 2006-01-02)"
+//	@Param			until	query		string	false	"Only posts created before this time (RFC 3339 or 2006-01-02)"
+//	@Param			limit	query		int		false	"Limit (default 20, max 100)"
+//	@Param			cursor	query		string	false	"Cursor from a previous page"
+//	@Param			sort	query		string	false	"Sort by creation time, asc or desc (default)"
 //	@Param			tags	query		string	false	"Tags"
 //	@Param			search	query		string	false	"Search"
-//	@Success		200		{object}	[]store.FeedPost
+//	@Param			mode	query		string	false	"latest (default) or ranked"
+//	@Param			debug	query		bool	false	"Include the score breakdown of ranked posts"
+//	@Success		200		{object}	feedPage
 //	@Failure		400		{object}	error
 //	@Failure		500		{object}	error
 //	@Security		ApiKeyAuth
 //	@Router			/users/feed [get]
 func (app *application) getUserFeedHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
 	pfq := store.PaginatedFeedQuery{
 		Limit:  20,
 		Offset: 0,
@@ -44,16 +68,94 @@ func (app *application) getUserFeedHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	if pfq.Limit < 1 || pfq.Limit > maxFeedLimit {
+		app.badRequestResponse(w, r, fmt.Errorf("limit must be between 1 and %d", maxFeedLimit))
+		return
+	}
+
+	// offsets skip or repeat posts as new ones arrive, the feed only pages with cursors
+	if pfq.Offset != 0 {
+		app.badRequestResponse(w, r, errors.New("offset is not supported, use cursor"))
+		return
+	}
+
 	ctx := r.Context()
 
-	feed, err := app.store.PostsRepository.GetUserFeed(ctx, 199, pfq)
+	if pfq.Mode == store.FeedModeRanked && pfq.Sort != "desc" {
+		app.badRequestResponse(w, r, errors.New("sort does not apply to the ranked feed"))
+		return
+	}
+
+	// the plain newest first feed is read from the user's timeline, filtered ones from the database
+	if pfq.Mode != store.FeedModeRanked && pfq.Sort == "desc" && pfq.Search == "" && len(pfq.Tags) == 0 && pfq.Since == nil && pfq.Until == nil {
+		feed, nextCursor, err := app.timelines.Feed(ctx, user.ID, pfq.After, pfq.Limit)
+		if err == nil {
+			if err := app.jsonResponse(w, http.StatusOK, feedPage{Posts: feed, NextCursor: nextCursor}); err != nil {
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		// a timeline outage shouldn't fail the request
+		app.logger.Warnw("failed to read timeline", "user", user.ID, "error", err.Error())
+	}
+
+	// filter on canonical names, so "golang" finds posts tagged "go"
+	pfq.Tags, err = app.store.TagsRepository.Resolve(ctx, pfq.Tags)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if len(pfq.Tags) == 0 {
+		pfq.Tags = nil
+	}
+
+	if pfq.Mode == store.FeedModeRanked {
+		page, err := app.rankedFeed(ctx, user.ID, pfq)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		if err := app.jsonResponse(w, http.StatusOK, page); err != nil {
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	feed, err := app.store.PostsRepository.GetUserFeed(ctx, user.ID, pfq)
 	if err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
 
-	if err := app.jsonResponse(w, http.StatusOK, feed); err != nil {
+	page := feedPage{Posts: feed, NextCursor: store.NextFeedCursor(feed, pfq.Limit)}
+	if err := app.jsonResponse(w, http.StatusOK, page); err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
 }
+
+// `rankedFeed` fetches a page of the ranked feed, scored as of the first page
+func (app *application) rankedFeed(ctx context.Context, userId int64, pfq store.PaginatedFeedQuery) (feedPage, error) {
+	asOf := time.Now()
+	if pfq.AfterRanked != nil {
+		asOf = pfq.AfterRanked.AsOf
+	}
+
+	feed, err := app.store.PostsRepository.GetRankedFeed(ctx, userId, pfq, app.config.ranking, asOf)
+	if err != nil {
+		return feedPage{}, err
+	}
+
+	page := feedPage{Posts: feed, NextCursor: store.NextRankedFeedCursor(feed, pfq.Limit, asOf)}
+
+	// scores are only shown to tune the weights
+	if !pfq.Debug {
+		for _, post := range feed {
+			post.Score = nil
+		}
+	}
+
+	return page, nil
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/elhambadri2411/social/internal/store"
+	"github.com/elhambadri2411/social/internal/store/cache"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestUserFeed(t *testing.T) {
+	mockApp := newTestApplication(t)
+	mockMux := mockApp.mount()
+
+	testToken, _ := mockApp.authenticator.GenerateToken(nil)
+
+	mockCacheStore := mockApp.cache.UsersCache.(*cache.MockUsersCacheRedis)
+	mockCacheStore.On("Get", mock.Anything, mock.Anything).Return(nil, nil)
+	mockCacheStore.On("Set", mock.Anything, mock.Anything).Return(nil)
+
+	// a built timeline holding 30 posts, newest first
+	entries := make([]store.TimelineEntry, 0, 30)
+	for i := range 30 {
+		entries = append(entries, store.TimelineEntry{PostId: int64(100 - i), CreatedAt: time.Now().Add(-time.Duration(i) * time.Minute)})
+	}
+	mockTimelines := mockApp.cache.TimelinesCache.(*cache.MockTimelinesCacheRedis)
+	mockTimelines.On("Range", mock.Anything, int64(21), mock.Anything, 20).Return(entries[:20], nil)
+	mockTimelines.On("Range", mock.Anything, int64(21), mock.Anything, 50).Return(entries, nil)
+	mockTimelines.On("Len", mock.Anything, int64(21)).Return(len(entries), nil)
+	mockTimelines.On("PulledAuthors", mock.Anything).Return([]int64{}, nil)
+
+	get := func(url string) int {
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		return execRequest(req, mockMux).Code
+	}
+
+	t.Run("should fetch the feed", func(t *testing.T) {
+		assertResponseCode(t, http.StatusOK, get("/v1/users/feed"))
+		assertResponseCode(t, http.StatusOK, get("/v1/users/feed?sort=asc&since=2025-01-01&until=2025-08-01T10:00:00Z"))
+	})
+
+	t.Run("should read the newest posts from the timeline", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/v1/users/feed", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := execRequest(req, mockMux)
+		assertResponseCode(t, http.StatusOK, rr.Code)
+
+		var response struct {
+			Data feedPage `json:"data"`
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+			t.Fatal(err)
+		}
+		if len(response.Data.Posts) != 20 || response.Data.Posts[0].ID != 100 || response.Data.Posts[19].ID != 81 {
+			t.Fatalf("expected posts 100 to 81 of the timeline, got %d posts", len(response.Data.Posts))
+		}
+
+		after, err := store.DecodeFeedCursor(response.Data.NextCursor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if after.PostId != 81 {
+			t.Errorf("expected the next page after post 81, got %d", after.PostId)
+		}
+	})
+
+	t.Run("should end on the last page of the timeline", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/v1/users/feed?limit=50", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := execRequest(req, mockMux)
+		assertResponseCode(t, http.StatusOK, rr.Code)
+
+		var response struct {
+			Data feedPage `json:"data"`
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+			t.Fatal(err)
+		}
+		if len(response.Data.Posts) != 30 || response.Data.NextCursor != "" {
+			t.Errorf("expected all 30 posts and no next page, got %d posts and cursor %q", len(response.Data.Posts), response.Data.NextCursor)
+		}
+	})
+
+	t.Run("should page with its own cursors", func(t *testing.T) {
+		cursor := store.FeedCursor{CreatedAt: time.Now(), PostId: 42}.Encode()
+		assertResponseCode(t, http.StatusOK, get("/v1/users/feed?cursor="+cursor))
+		assertResponseCode(t, http.StatusBadRequest, get("/v1/users/feed?cursor=bm9wZQ"))
+	})
+
+	t.Run("should reject invalid queries", func(t *testing.T) {
+		for _, query := range []string{
+			"offset=20",
+			"limit=0",
+			"limit=500",
+			"since=yesterday",
+			"sort=" + url.QueryEscape("created_at; DROP TABLE posts"),
+		} {
+			assertResponseCode(t, http.StatusBadRequest, get("/v1/users/feed?"+query))
+		}
+	})
+}
+
+func TestUserFeedTimelineFallbacks(t *testing.T) {
+	mockApp := newTestApplication(t)
+	mockMux := mockApp.mount()
+
+	testToken, _ := mockApp.authenticator.GenerateToken(nil)
+
+	mockCacheStore := mockApp.cache.UsersCache.(*cache.MockUsersCacheRedis)
+	mockCacheStore.On("Get", mock.Anything, mock.Anything).Return(nil, nil)
+	mockCacheStore.On("Set", mock.Anything, mock.Anything).Return(nil)
+
+	mockTimelines := mockApp.cache.TimelinesCache.(*cache.MockTimelinesCacheRedis)
+
+	get := func(url string) int {
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		return execRequest(req, mockMux).Code
+	}
+
+	t.Run("should rebuild a missing timeline", func(t *testing.T) {
+		mockTimelines.On("Range", mock.Anything, int64(21), mock.Anything, 20).Return(nil, nil).Once()
+		mockTimelines.On("Build", mock.Anything, int64(21), mock.Anything).Return(nil).Once()
+		mockTimelines.On("Range", mock.Anything, int64(21), mock.Anything, 20).Return([]store.TimelineEntry{}, nil).Once()
+		mockTimelines.On("Len", mock.Anything, int64(21)).Return(0, nil).Once()
+		mockTimelines.On("PulledAuthors", mock.Anything).Return([]int64{}, nil).Once()
+
+		assertResponseCode(t, http.StatusOK, get("/v1/users/feed"))
+		mockTimelines.AssertCalled(t, "Build", mock.Anything, int64(21), mock.Anything)
+	})
+
+	t.Run("should serve the feed from the database when redis fails", func(t *testing.T) {
+		mockTimelines.On("Range", mock.Anything, int64(21), mock.Anything, 20).Return(nil, errors.New("connection refused")).Once()
+
+		assertResponseCode(t, http.StatusOK, get("/v1/users/feed"))
+	})
+}
+
+func TestRankedFeed(t *testing.T) {
+	mockApp := newTestApplication(t)
+	mockMux := mockApp.mount()
+
+	testToken, _ := mockApp.authenticator.GenerateToken(nil)
+
+	mockCacheStore := mockApp.cache.UsersCache.(*cache.MockUsersCacheRedis)
+	mockCacheStore.On("Get", mock.Anything, mock.Anything).Return(nil, nil)
+	mockCacheStore.On("Set", mock.Anything, mock.Anything).Return(nil)
+
+	get := func(url string) (int, feedPage) {
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := execRequest(req, mockMux)
+
+		var response struct {
+			Data feedPage `json:"data"`
+		}
+		if rr.Code == http.StatusOK {
+			if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return rr.Code, response.Data
+	}
+
+	t.Run("should hide scores unless debugging", func(t *testing.T) {
+		code, page := get("/v1/users/feed?mode=ranked")
+		assertResponseCode(t, http.StatusOK, code)
+		for _, post := range page.Posts {
+			if post.Score != nil {
+				t.Errorf("post %d has a score without debug", post.ID)
+			}
+		}
+
+		code, page = get("/v1/users/feed?mode=ranked&debug=true")
+		assertResponseCode(t, http.StatusOK, code)
+		if len(page.Posts) == 0 || page.Posts[0].Score == nil || page.Posts[0].Score.Total != 1.5 {
+			t.Errorf("expected the score breakdown of each post, got %+v", page.Posts)
+		}
+	})
+
+	t.Run("should page with ranked cursors", func(t *testing.T) {
+		code, page := get("/v1/users/feed?mode=ranked&limit=2")
+		assertResponseCode(t, http.StatusOK, code)
+
+		after, err := store.DecodeRankedFeedCursor(page.NextCursor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if after.PostId != 1 || after.Score != 0.8 {
+			t.Errorf("expected the next page after post 1 scored 0.8, got %+v", after)
+		}
+
+		code, _ = get("/v1/users/feed?mode=ranked&limit=2&cursor=" + page.NextCursor)
+		assertResponseCode(t, http.StatusOK, code)
+
+		// cursors of one mode don't work in the other
+		code, _ = get("/v1/users/feed?cursor=" + page.NextCursor)
+		assertResponseCode(t, http.StatusBadRequest, code)
+	})
+
+	t.Run("should reject invalid modes", func(t *testing.T) {
+		for _, query := range []string{"mode=popular", "mode=ranked&sort=asc", "mode=ranked&debug=maybe"} {
+			code, _ := get("/v1/users/feed?" + query)
+			assertResponseCode(t, http.StatusBadRequest, code)
+		}
+	})
+}
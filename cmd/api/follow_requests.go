@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/elhambadri2411/social/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+// GetFollowRequests godoc
+//
+//	@Summary		Lists pending follow requests
+//	@Description	Lists the users waiting for the authenticated user to approve their follow, most recent first.
+//	@Description	Pass `next_cursor` from a response as `cursor` to fetch the following page.
+//	@Tags			users
+//	@Produce		json
+//	@Param			limit	query		int		false	"Limit (default 20, max 100)"
+//	@Param			cursor	query		string	false	"Cursor from a previous page"
+//	@Success		200		{object}	followList
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/follow-requests [get]
+func (app *application) getFollowRequestsHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	query, err := app.readFollowListQuery(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	entries, err := app.store.FollowersRepository.GetRequests(r.Context(), user.ID, *query)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.writeFollowList(w, r, entries, query.Limit)
+}
+
+// ApproveFollowRequest godoc
+//
+//	@Summary	Approves a follow request
+//	@Tags		users
+//	@Param		userID	path		int		true	"ID of the user who asked to follow"
+//	@Success	204		{string}	string	"Request approved"
+//	@Failure	400		{object}	error
+//	@Failure	404		{object}	error	"No pending request"
+//	@Failure	500		{object}	error
+//	@Security	ApiKeyAuth
+//	@Router		/users/me/follow-requests/{userID}/approve [put]
+func (app *application) approveFollowRequestHandler(w http.ResponseWriter, r *http.Request) {
+	app.resolveFollowRequest(w, r, func(ctx context.Context, userId int64, followerId int64) error {
+		if err := app.store.FollowersRepository.ApproveRequest(ctx, userId, followerId); err != nil {
+			return err
+		}
+
+		app.backfillTimeline(followerId, userId)
+		return nil
+	})
+}
+
+// RejectFollowRequest godoc
+//
+//	@Summary	Rejects a follow request
+//	@Tags		users
+//	@Param		userID	path		int		true	"ID of the user who asked to follow"
+//	@Success	204		{string}	string	"Request rejected"
+//	@Failure	400		{object}	error
+//	@Failure	404		{object}	error	"No pending request"
+//	@Failure	500		{object}	error
+//	@Security	ApiKeyAuth
+//	@Router		/users/me/follow-requests/{userID} [delete]
+func (app *application) rejectFollowRequestHandler(w http.ResponseWriter, r *http.Request) {
+	app.resolveFollowRequest(w, r, app.store.FollowersRepository.DeleteRequest)
+}
+
+func (app *application) resolveFollowRequest(
+	w http.ResponseWriter,
+	r *http.Request,
+	resolve func(context.Context, int64, int64) error,
+) {
+	user := getUserFromCtx(r)
+	followerId, err := strconv.ParseInt(chi.URLParam(r, "userId"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := resolve(r.Context(), user.ID, followerId); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// `canView` reports whether `viewer` may see the posts and follows of `authorId`.
+// Moderators see everything, so private accounts can still be moderated.
+func (app *application) canView(ctx context.Context, viewer *store.User, authorId int64) (bool, error) {
+	visible, err := app.store.FollowersRepository.CanView(ctx, viewer.ID, authorId)
+	if err != nil || visible {
+		return visible, err
+	}
+
+	return app.checkRolePrecedence(ctx, viewer, "moderator")
+}
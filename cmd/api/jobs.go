@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// `startJobs` launches the background jobs of the application.
+// They run until `ctx` is cancelled, which happens when the server shuts down.
+func (app *application) startJobs(ctx context.Context) {
+	go app.runPeriodically(ctx, "purge deleted posts", app.config.posts.purgeInterval, app.purgeDeletedPosts)
+	go app.runPeriodically(ctx, "purge detached media", app.config.media.purgeInterval, app.purgeDetachedMedia)
+	go app.runPeriodically(ctx, "refresh suggestions", app.config.suggestions.refreshInterval, app.refreshSuggestions)
+	go app.runPeriodically(ctx, "purge deleted accounts", app.config.accounts.purgeInterval, app.purgeDeletedAccounts)
+	go app.runPeriodically(ctx, "purge expired exports", app.config.accounts.purgeInterval, app.purgeExpiredExports)
+	go app.runPeriodically(ctx, "purge notifications", app.config.notifications.purgeInterval, app.purgeNotifications)
+	go app.runPeriodically(ctx, "send digests", app.config.notifications.digestInterval, app.sendDigests)
+}
+
+// `background` runs `fn` in a goroutine the server waits for before exiting, panics are logged
+func (app *application) background(fn func()) {
+	app.wg.Add(1)
+
+	go func() {
+		defer app.wg.Done()
+		defer func() {
+			if err := recover(); err != nil {
+				app.logger.Errorw("background task panicked", "error", err)
+			}
+		}()
+
+		fn()
+	}()
+}
+
+// `runPeriodically` calls `job` every `interval` until `ctx` is done. Errors are logged, not fatal.
+func (app *application) runPeriodically(ctx context.Context, name string, interval time.Duration, job func(context.Context) error) {
+	if interval <= 0 {
+		app.logger.Infow("job disabled", "job", name)
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := job(ctx); err != nil {
+				app.logger.Errorw("job failed", "job", name, "error", err.Error())
+			}
+		}
+	}
+}
+
+// `purgeDeletedPosts` permanently removes posts whose trash retention window has passed
+func (app *application) purgeDeletedPosts(ctx context.Context) error {
+	purged, err := app.store.PostsRepository.PurgeDeleted(ctx, app.config.posts.trashRetention)
+	if err != nil {
+		return err
+	}
+
+	if purged > 0 {
+		app.logger.Infow("purged deleted posts", "count", purged)
+	}
+
+	return nil
+}
@@ -0,0 +1,398 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/elhambadri2411/social/internal/store"
+	"github.com/elhambadri2411/social/internal/stream"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	socketWriteWait       = 10 * time.Second // how long a single write may take
+	socketMaxMessageBytes = 4096             // largest message accepted from clients
+	socketRelationTTL     = time.Minute      // how long a socket trusts it checked whether an author is blocked or muted
+	socketTokenProtocol   = "access_token"   // subprotocol followed by the JWT, for clients that can't set headers
+)
+
+// `liveCommentsConfig` holds settings for the WebSocket gateway of live comment threads
+type liveCommentsConfig struct {
+	maxPosts     int           // posts a connection can follow at once
+	sendBuffer   int           // messages queued per connection before it's closed as too slow
+	pingInterval time.Duration // how often connections are pinged, they are dropped when no pong comes back in time
+}
+
+var socketUpgrader = websocket.Upgrader{
+	// clients authenticate with a token rather than cookies, so other sites can't use their session
+	CheckOrigin:  func(r *http.Request) bool { return true },
+	Subprotocols: []string{socketTokenProtocol},
+}
+
+// `commentDeletedEvent` tells the viewers of a thread a comment is gone
+type commentDeletedEvent struct {
+	PostId    int64 `json:"post_id"`
+	CommentId int64 `json:"comment_id"`
+	UserId    int64 `json:"user_id"` // author of the comment
+}
+
+// `commentAuthor` is the author of the comment in any comment event
+type commentAuthor struct {
+	UserId int64 `json:"user_id"`
+}
+
+// `socketRequest` is a message from a client, `Type` is "subscribe" or "unsubscribe"
+type socketRequest struct {
+	Type    string  `json:"type"`
+	PostIds []int64 `json:"post_ids"`
+}
+
+// `socketReply` answers a `socketRequest`, `Type` is "subscribed", "unsubscribed" or "error"
+type socketReply struct {
+	Type    string  `json:"type"`
+	PostIds []int64 `json:"post_ids,omitempty"`
+	Error   string  `json:"error,omitempty"`
+}
+
+// liveComments godoc
+//
+//	@Summary		Streams live comment threads
+//	@Description	Upgrades to a WebSocket. Send `{"type": "subscribe", "post_ids": [1, 2]}` to follow the comments of posts
+//	@Description	and `{"type": "unsubscribe", "post_ids": [1]}` to stop. Events come as `{"id", "type", "data"}` with the type
+//	@Description	`comment.created`, `comment.updated` (data has `post_id` and `comment`) or `comment.deleted` (data has
+//	@Description	`post_id`, `comment_id` and `user_id`). Comments of users you blocked, who blocked you or you muted
+//	@Description	are left out. Browsers can't set headers on WebSockets, so the token can be passed as the subprotocols
+//	@Description	`access_token, <token>` instead, the server answers with `access_token`. Tokens aren't taken from the
+//	@Description	query string, where they would end up in logs. Connections that don't keep up with their events are
+//	@Description	closed with code 1013.
+//	@Tags			posts
+//	@Param			Sec-WebSocket-Protocol	header	string	false	"access_token, followed by the JWT when the Authorization header can't be set"
+//	@Success		101
+//	@Failure		401	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/comments/live [get]
+func (app *application) liveCommentsHandler(w http.ResponseWriter, r *http.Request) {
+	token := socketToken(r)
+	if header := r.Header.Get("Authorization"); header != "" {
+		token, _ = strings.CutPrefix(header, "Bearer ")
+	}
+	if token == "" {
+		app.unauthorizedError(w, r, errors.New("authorization header is missing"))
+		return
+	}
+
+	user, err := app.authenticateToken(r.Context(), token)
+	if err != nil {
+		app.unauthorizedError(w, r, err)
+		return
+	}
+
+	conn, err := socketUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// the upgrader already answered the client
+		app.logger.Warnw("websocket upgrade failed", "error", err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	socket := &liveSocket{
+		app:           app,
+		user:          user,
+		conn:          conn,
+		send:          make(chan any, app.config.liveComments.sendBuffer),
+		ctx:           ctx,
+		cancel:        cancel,
+		subscriptions: make(map[int64]context.CancelFunc),
+		authors:       make(map[int64]authorCheck),
+	}
+
+	if !app.sockets.add(socket) {
+		socket.close(websocket.CloseGoingAway, "server shutting down")
+		return
+	}
+	defer app.sockets.remove(socket)
+
+	go socket.writeLoop()
+	socket.readLoop()
+	socket.close(websocket.CloseNormalClosure, "")
+}
+
+// `socketToken` returns the token passed as the subprotocol after `socketTokenProtocol`, if any
+func socketToken(r *http.Request) string {
+	protocols := websocket.Subprotocols(r)
+	for i := 0; i+1 < len(protocols); i++ {
+		if protocols[i] == socketTokenProtocol {
+			return protocols[i+1]
+		}
+	}
+
+	return ""
+}
+
+// `publishCommentEvent` tells the live viewers of a post about a change in its comments.
+// It's not done in the background so the events of a thread keep their order.
+func (app *application) publishCommentEvent(ctx context.Context, eventType string, postId int64, data any) {
+	if err := app.comments.Publish(ctx, []int64{postId}, eventType, data); err != nil {
+		app.logger.Warnw("failed to publish comment event", "type", eventType, "post", postId, "error", err.Error())
+	}
+}
+
+// `liveSocket` is a WebSocket connection following comment threads. The handler goroutine reads,
+// `writeLoop` writes and one goroutine per followed post forwards its events.
+type liveSocket struct {
+	app    *application
+	user   *store.User
+	conn   *websocket.Conn
+	send   chan any // messages waiting for `writeLoop`
+	ctx    context.Context
+	cancel context.CancelFunc
+	once   sync.Once
+
+	subscriptions map[int64]context.CancelFunc // only used by the reading goroutine
+
+	authorsMu sync.Mutex
+	authors   map[int64]authorCheck // whether comment authors are hidden from the user, by id
+}
+
+// `authorCheck` remembers whether an author is hidden from the user of a socket
+type authorCheck struct {
+	hidden    bool
+	checkedAt time.Time
+}
+
+// `close` sends a close frame with `code` and ends the connection, only the first call does anything.
+// `websocket.CloseAbnormalClosure` can't be sent, it drops the connection without a close frame.
+func (s *liveSocket) close(code int, reason string) {
+	s.once.Do(func() {
+		s.cancel()
+		if code != websocket.CloseAbnormalClosure {
+			_ = s.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(socketWriteWait))
+		}
+		s.conn.Close()
+	})
+}
+
+// `enqueue` queues `msg` for the client. A full queue means the client can't keep up,
+// it's disconnected rather than holding up the broker and everyone else.
+func (s *liveSocket) enqueue(msg any) {
+	select {
+	case s.send <- msg:
+	default:
+		s.close(websocket.CloseTryAgainLater, "too slow")
+	}
+}
+
+func (s *liveSocket) writeLoop() {
+	ping := time.NewTicker(s.app.config.liveComments.pingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case msg := <-s.send:
+			_ = s.conn.SetWriteDeadline(time.Now().Add(socketWriteWait))
+			if err := s.conn.WriteJSON(msg); err != nil {
+				s.close(websocket.CloseAbnormalClosure, "")
+				return
+			}
+		case <-ping.C:
+			if err := s.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(socketWriteWait)); err != nil {
+				s.close(websocket.CloseAbnormalClosure, "")
+				return
+			}
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// `readLoop` handles requests until the connection ends
+func (s *liveSocket) readLoop() {
+	pongWait := 2 * s.app.config.liveComments.pingInterval
+
+	s.conn.SetReadLimit(socketMaxMessageBytes)
+	_ = s.conn.SetReadDeadline(time.Now().Add(pongWait))
+	s.conn.SetPongHandler(func(string) error {
+		return s.conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	for {
+		_, message, err := s.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		_ = s.conn.SetReadDeadline(time.Now().Add(pongWait))
+
+		var request socketRequest
+		if err := json.Unmarshal(message, &request); err != nil {
+			s.enqueue(socketReply{Type: "error", Error: "invalid message"})
+			continue
+		}
+
+		switch request.Type {
+		case "subscribe":
+			s.subscribe(request.PostIds)
+		case "unsubscribe":
+			s.unsubscribe(request.PostIds)
+		default:
+			s.enqueue(socketReply{Type: "error", Error: fmt.Sprintf("unknown message type %q", request.Type)})
+		}
+	}
+}
+
+// `subscribe` follows the threads of `postIds` the user can see, missing and hidden posts are reported as errors
+func (s *liveSocket) subscribe(postIds []int64) {
+	subscribed := []int64{}
+	for _, postId := range postIds {
+		if _, ok := s.subscriptions[postId]; ok {
+			subscribed = append(subscribed, postId)
+			continue
+		}
+		if len(s.subscriptions) >= s.app.config.liveComments.maxPosts {
+			s.enqueue(socketReply{Type: "error", PostIds: []int64{postId}, Error: "too many subscriptions"})
+			continue
+		}
+
+		post, err := s.app.store.PostsRepository.GetById(s.ctx, postId)
+		if err == nil {
+			var visible bool
+			visible, err = s.app.canView(s.ctx, s.user, post.UserId)
+			if err == nil && !visible {
+				err = store.ErrNotFound
+			}
+		}
+		if err != nil {
+			reason := "post not found"
+			if !errors.Is(err, store.ErrNotFound) {
+				s.app.logger.Errorw("failed to subscribe to comments", "post", postId, "error", err.Error())
+				reason = "internal error"
+			}
+			s.enqueue(socketReply{Type: "error", PostIds: []int64{postId}, Error: reason})
+			continue
+		}
+
+		ctx, cancel := context.WithCancel(s.ctx)
+		events, err := s.app.comments.Subscribe(ctx, postId, 0)
+		if err != nil {
+			cancel()
+			s.close(websocket.CloseInternalServerErr, "")
+			return
+		}
+		s.subscriptions[postId] = cancel
+
+		go func() {
+			for event := range events {
+				if !s.hides(event) {
+					s.enqueue(event)
+				}
+			}
+			// the broker dropped the subscription, because it fell behind or is shutting down
+			if ctx.Err() == nil {
+				s.close(websocket.CloseTryAgainLater, "too slow")
+			}
+		}()
+
+		subscribed = append(subscribed, postId)
+	}
+
+	if len(subscribed) > 0 {
+		s.enqueue(socketReply{Type: "subscribed", PostIds: subscribed})
+	}
+}
+
+// `hides` reports whether `event` is about a comment of a user who blocked the socket's user,
+// was blocked or muted by them. Answers are kept for `socketRelationTTL`, threads can be busy.
+func (s *liveSocket) hides(event stream.Event) bool {
+	var author commentAuthor
+	if err := json.Unmarshal(event.Data, &author); err != nil || author.UserId == s.user.ID {
+		return false
+	}
+
+	s.authorsMu.Lock()
+	check, ok := s.authors[author.UserId]
+	s.authorsMu.Unlock()
+	if ok && time.Since(check.checkedAt) < socketRelationTTL {
+		return check.hidden
+	}
+
+	hidden, err := s.app.isBlocked(s.ctx, s.user.ID, author.UserId)
+	if err == nil && !hidden {
+		var muterIds []int64
+		muterIds, err = s.app.store.BlocksRepository.GetMuters(s.ctx, author.UserId, []int64{s.user.ID})
+		hidden = len(muterIds) > 0
+	}
+	if err != nil {
+		// without an answer the comment may be from someone the user blocked, it's safer to leave it out
+		if s.ctx.Err() == nil {
+			s.app.logger.Warnw("failed to check comment author", "user", s.user.ID, "author", author.UserId, "error", err.Error())
+		}
+		return true
+	}
+
+	s.authorsMu.Lock()
+	s.authors[author.UserId] = authorCheck{hidden: hidden, checkedAt: time.Now()}
+	s.authorsMu.Unlock()
+
+	return hidden
+}
+
+func (s *liveSocket) unsubscribe(postIds []int64) {
+	for _, postId := range postIds {
+		if cancel, ok := s.subscriptions[postId]; ok {
+			cancel()
+			delete(s.subscriptions, postId)
+		}
+	}
+
+	s.enqueue(socketReply{Type: "unsubscribed", PostIds: postIds})
+}
+
+// `socketSet` tracks open WebSockets, the server doesn't know about them once they are upgraded
+type socketSet struct {
+	mu      sync.Mutex
+	sockets map[*liveSocket]struct{}
+	closed  bool
+}
+
+func newSocketSet() *socketSet {
+	return &socketSet{sockets: make(map[*liveSocket]struct{})}
+}
+
+// `add` tracks `socket`, unless the set was closed
+func (ss *socketSet) add(socket *liveSocket) bool {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	if ss.closed {
+		return false
+	}
+	ss.sockets[socket] = struct{}{}
+	return true
+}
+
+func (ss *socketSet) remove(socket *liveSocket) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	delete(ss.sockets, socket)
+}
+
+// `closeAll` tells every client the server is going away and refuses new sockets
+func (ss *socketSet) closeAll() {
+	ss.mu.Lock()
+	ss.closed = true
+	sockets := make([]*liveSocket, 0, len(ss.sockets))
+	for socket := range ss.sockets {
+		sockets = append(sockets, socket)
+	}
+	ss.mu.Unlock()
+
+	for _, socket := range sockets {
+		socket.close(websocket.CloseGoingAway, "server shutting down")
+	}
+}
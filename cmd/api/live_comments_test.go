@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/elhambadri2411/social/internal/store"
+	"github.com/elhambadri2411/social/internal/store/cache"
+	"github.com/elhambadri2411/social/internal/stream"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestLiveComments(t *testing.T) {
+	mockApp := newTestApplication(t)
+	mockMux := mockApp.mount()
+
+	server := httptest.NewServer(mockMux)
+	defer server.Close()
+
+	testToken, _ := mockApp.authenticator.GenerateToken(nil)
+
+	mockCacheStore := mockApp.cache.UsersCache.(*cache.MockUsersCacheRedis)
+	mockCacheStore.On("Get", mock.Anything, mock.Anything).Return(nil, nil)
+	mockCacheStore.On("Set", mock.Anything, mock.Anything).Return(nil)
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/v1/comments/live"
+
+	dial := func(t *testing.T) *websocket.Conn {
+		t.Helper()
+
+		dialer := websocket.Dialer{Subprotocols: []string{socketTokenProtocol, testToken}}
+		conn, _, err := dialer.Dial(url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if conn.Subprotocol() != socketTokenProtocol {
+			t.Fatalf("expected the %s subprotocol, got %q", socketTokenProtocol, conn.Subprotocol())
+		}
+		t.Cleanup(func() { conn.Close() })
+		return conn
+	}
+
+	// `read` returns the next message of `conn` decoded into `v`
+	read := func(t *testing.T, conn *websocket.Conn, v any) {
+		t.Helper()
+
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		if err := conn.ReadJSON(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	send := func(t *testing.T, method string, path string, body string) {
+		t.Helper()
+
+		req, err := http.NewRequest(method, path, bytes.NewBufferString(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := execRequest(req, mockMux)
+		if rr.Code >= 300 {
+			t.Fatalf("%s %s: got %d", method, path, rr.Code)
+		}
+	}
+
+	t.Run("should require a token", func(t *testing.T) {
+		_, resp, err := websocket.DefaultDialer.Dial(url, nil)
+		if err == nil {
+			t.Fatal("expected the upgrade to fail")
+		}
+		assertResponseCode(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("should not take tokens from the query string", func(t *testing.T) {
+		_, resp, err := websocket.DefaultDialer.Dial(url+"?access_token="+testToken, nil)
+		if err == nil {
+			t.Fatal("expected the upgrade to fail")
+		}
+		assertResponseCode(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("should leave out comments of blocked and muted users", func(t *testing.T) {
+		mockApp.store.BlocksRepository = &hidingBlocksStore{}
+		defer func() { mockApp.store.BlocksRepository = &store.MockBlocksStore{} }()
+
+		conn := dial(t)
+		if err := conn.WriteJSON(socketRequest{Type: "subscribe", PostIds: []int64{1}}); err != nil {
+			t.Fatal(err)
+		}
+		var reply socketReply
+		read(t, conn, &reply)
+
+		ctx := context.Background()
+		mockApp.publishCommentEvent(ctx, stream.EventCommentCreated, 1, store.Comment{ID: 6, PostId: 1, UserId: 8})
+		mockApp.publishCommentEvent(ctx, stream.EventCommentCreated, 1, store.Comment{ID: 7, PostId: 1, UserId: 9})
+		mockApp.publishCommentEvent(ctx, stream.EventCommentDeleted, 1, commentDeletedEvent{PostId: 1, CommentId: 6, UserId: 8})
+		mockApp.publishCommentEvent(ctx, stream.EventCommentCreated, 1, store.Comment{ID: 10, PostId: 1, UserId: 10})
+
+		var event stream.Event
+		read(t, conn, &event)
+		var comment store.Comment
+		if err := json.Unmarshal(event.Data, &comment); err != nil {
+			t.Fatal(err)
+		}
+		if comment.ID != 10 {
+			t.Errorf("expected only the comment of user 10, got %+v", comment)
+		}
+	})
+
+	t.Run("should stream comment events of subscribed posts", func(t *testing.T) {
+		conn := dial(t)
+		if err := conn.WriteJSON(socketRequest{Type: "subscribe", PostIds: []int64{1}}); err != nil {
+			t.Fatal(err)
+		}
+
+		var reply socketReply
+		read(t, conn, &reply)
+		if reply.Type != "subscribed" || len(reply.PostIds) != 1 || reply.PostIds[0] != 1 {
+			t.Fatalf("unexpected reply %+v", reply)
+		}
+
+		send(t, http.MethodPost, "/v1/posts/1/comments", `{"content": "hello"}`)
+		send(t, http.MethodPatch, "/v1/posts/1/comments/5", `{"content": "hello again"}`)
+		send(t, http.MethodDelete, "/v1/posts/1/comments/5", "")
+
+		for _, expected := range []string{stream.EventCommentCreated, stream.EventCommentUpdated, stream.EventCommentDeleted} {
+			var event stream.Event
+			read(t, conn, &event)
+			if event.Type != expected {
+				t.Fatalf("expected %s, got %s", expected, event.Type)
+			}
+		}
+	})
+
+	t.Run("should stop streaming unsubscribed posts", func(t *testing.T) {
+		conn := dial(t)
+		for _, request := range []socketRequest{
+			{Type: "subscribe", PostIds: []int64{1}},
+			{Type: "unsubscribe", PostIds: []int64{1}},
+		} {
+			if err := conn.WriteJSON(request); err != nil {
+				t.Fatal(err)
+			}
+			var reply socketReply
+			read(t, conn, &reply)
+		}
+
+		send(t, http.MethodPost, "/v1/posts/1/comments", `{"content": "hello"}`)
+
+		conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+		var event stream.Event
+		if err := conn.ReadJSON(&event); err == nil {
+			t.Fatalf("unexpected event %+v", event)
+		}
+	})
+
+	t.Run("should limit subscriptions per connection", func(t *testing.T) {
+		conn := dial(t)
+		if err := conn.WriteJSON(socketRequest{Type: "subscribe", PostIds: []int64{1, 2, 3}}); err != nil {
+			t.Fatal(err)
+		}
+
+		var reply socketReply
+		read(t, conn, &reply)
+		if reply.Type != "error" || reply.PostIds[0] != 3 {
+			t.Errorf("expected an error for the third post, got %+v", reply)
+		}
+		read(t, conn, &reply)
+		if reply.Type != "subscribed" || len(reply.PostIds) != 2 {
+			t.Errorf("expected the first two posts to be subscribed, got %+v", reply)
+		}
+	})
+
+	t.Run("should reject unknown messages", func(t *testing.T) {
+		conn := dial(t)
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(`{"type": "dance"}`)); err != nil {
+			t.Fatal(err)
+		}
+
+		var reply socketReply
+		read(t, conn, &reply)
+		if reply.Type != "error" {
+			t.Errorf("expected an error, got %+v", reply)
+		}
+	})
+
+	t.Run("should close connections on shutdown", func(t *testing.T) {
+		conn := dial(t)
+		// the socket is registered once the subscription is answered
+		if err := conn.WriteJSON(socketRequest{Type: "subscribe", PostIds: []int64{1}}); err != nil {
+			t.Fatal(err)
+		}
+		var reply socketReply
+		read(t, conn, &reply)
+
+		mockApp.sockets.closeAll()
+
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		_, _, err := conn.ReadMessage()
+		var closeErr *websocket.CloseError
+		if !errors.As(err, &closeErr) || closeErr.Code != websocket.CloseGoingAway {
+			t.Errorf("expected a going away close, got %v", err)
+		}
+
+		late := dial(t)
+		late.SetReadDeadline(time.Now().Add(time.Second))
+		_, _, err = late.ReadMessage()
+		if !errors.As(err, &closeErr) || closeErr.Code != websocket.CloseGoingAway {
+			t.Errorf("expected new connections to be closed, got %v", err)
+		}
+	})
+}
+
+// `hidingBlocksStore` says user 8 blocked everyone and everyone muted user 9
+type hidingBlocksStore struct {
+	store.MockBlocksStore
+}
+
+func (s *hidingBlocksStore) IsBlocked(ctx context.Context, userId int64, otherId int64) (bool, error) {
+	return userId == 8 || otherId == 8, nil
+}
+
+func (s *hidingBlocksStore) GetMuters(ctx context.Context, mutedId int64, userIds []int64) ([]int64, error) {
+	if mutedId == 9 {
+		return userIds, nil
+	}
+	return []int64{}, nil
+}
+
+func (s *hidingBlocksStore) GetHiddenAuthors(ctx context.Context, viewerId int64, userIds []int64) ([]int64, error) {
+	hiddenIds := []int64{}
+	for _, id := range userIds {
+		if id == 8 || id == 9 {
+			hiddenIds = append(hiddenIds, id)
+		}
+	}
+	return hiddenIds, nil
+}
@@ -4,12 +4,17 @@ import (
 	"time"
 
 	"github.com/elhambadri2411/social/internal/auth"
+	"github.com/elhambadri2411/social/internal/blobstore"
 	"github.com/elhambadri2411/social/internal/db"  // internal package for handling db connections
 	"github.com/elhambadri2411/social/internal/env" // internal package for extracting and loading env variables
 	"github.com/elhambadri2411/social/internal/mailer"
+	"github.com/elhambadri2411/social/internal/notifications"
 	"github.com/elhambadri2411/social/internal/ratelimiter"
+	"github.com/elhambadri2411/social/internal/search"
 	"github.com/elhambadri2411/social/internal/store" // internal package, serves as abstraction layer for db
 	"github.com/elhambadri2411/social/internal/store/cache"
+	"github.com/elhambadri2411/social/internal/stream"
+	"github.com/elhambadri2411/social/internal/timeline"
 	"github.com/joho/godotenv" // package for loading environment variables
 	"go.uber.org/zap"
 )
@@ -94,6 +99,98 @@ func main() {
 			RequestsPerTimeFrame: env.GetInt("RATELIMITER_REQUESTS", 20),
 			TimeFrame:            time.Second * 30,
 		},
+		posts: postsConfig{
+			requireIfMatch: env.GetBoolean("POSTS_REQUIRE_IF_MATCH", false),
+			trashRetention: time.Hour * 24 * time.Duration(env.GetInt("POSTS_TRASH_RETENTION_DAYS", 30)),
+			purgeInterval:  time.Minute * time.Duration(env.GetInt("POSTS_PURGE_INTERVAL_MINUTES", 60)),
+		},
+		search: searchConfig{
+			backend:   env.GetString("SEARCH_BACKEND", "postgres"),
+			indexPath: env.GetString("SEARCH_INDEX_PATH", "./data/search.bleve"),
+		},
+		media: mediaConfig{
+			backend:   env.GetString("MEDIA_BACKEND", "local"),
+			localPath: env.GetString("MEDIA_LOCAL_PATH", "./data/media"),
+			s3: blobstore.S3Config{
+				Endpoint:  env.GetString("S3_ENDPOINT", "http://localhost:9000"),
+				Bucket:    env.GetString("S3_BUCKET", "social-media"),
+				Region:    env.GetString("S3_REGION", "us-east-1"),
+				AccessKey: env.GetString("S3_ACCESS_KEY", ""),
+				SecretKey: env.GetString("S3_SECRET_KEY", ""),
+			},
+			publicURL:      env.GetString("MEDIA_PUBLIC_URL", "/v1/media"),
+			maxUploadBytes: int64(env.GetInt("MEDIA_MAX_UPLOAD_MB", 10)) << 20,
+			maxPixels:      env.GetInt("MEDIA_MAX_PIXELS", 40_000_000),
+			maxGIFPixels:   env.GetInt("MEDIA_MAX_GIF_PIXELS", 100_000_000),
+			thumbnailSize:  env.GetInt("MEDIA_THUMBNAIL_SIZE", 320),
+			avatarSize:     env.GetInt("MEDIA_AVATAR_SIZE", 400),
+			maxPerPost:     env.GetInt("MEDIA_MAX_PER_POST", 4),
+			purgeInterval:  time.Minute * time.Duration(env.GetInt("MEDIA_PURGE_INTERVAL_MINUTES", 60)),
+		},
+		suggestions: suggestionsConfig{
+			size:            env.GetInt("SUGGESTIONS_SIZE", 50),
+			ttl:             time.Minute * time.Duration(env.GetInt("SUGGESTIONS_TTL_MINUTES", 120)),
+			refreshInterval: time.Minute * time.Duration(env.GetInt("SUGGESTIONS_REFRESH_MINUTES", 60)),
+			activeWindow:    time.Hour * 24 * time.Duration(env.GetInt("SUGGESTIONS_ACTIVE_DAYS", 7)),
+		},
+		accounts: accountsConfig{
+			deletionGrace:   time.Hour * 24 * time.Duration(env.GetInt("ACCOUNT_DELETION_GRACE_DAYS", 30)),
+			exportRetention: time.Hour * 24 * time.Duration(env.GetInt("EXPORT_RETENTION_DAYS", 7)),
+			exportLinkTTL:   time.Minute * time.Duration(env.GetInt("EXPORT_LINK_MINUTES", 60)),
+			purgeInterval:   time.Minute * time.Duration(env.GetInt("ACCOUNTS_PURGE_INTERVAL_MINUTES", 60)),
+		},
+		usernames: usernamesConfig{
+			changeCooldown: time.Hour * 24 * time.Duration(env.GetInt("USERNAME_CHANGE_COOLDOWN_DAYS", 30)),
+			reservation:    time.Hour * 24 * time.Duration(env.GetInt("USERNAME_RESERVATION_DAYS", 90)),
+		},
+		timelines: timeline.Config{
+			Length:      env.GetInt("TIMELINE_LENGTH", 800),
+			FanOutLimit: env.GetInt("TIMELINE_FANOUT_LIMIT", 10000),
+			TTL:         time.Hour * 24 * time.Duration(env.GetInt("TIMELINE_TTL_DAYS", 3)),
+		},
+		ranking: store.FeedRanking{
+			RecencyWeight:  env.GetFloat("FEED_RANK_RECENCY_WEIGHT", 1),
+			VelocityWeight: env.GetFloat("FEED_RANK_VELOCITY_WEIGHT", 0.5),
+			AffinityWeight: env.GetFloat("FEED_RANK_AFFINITY_WEIGHT", 0.75),
+			HalfLife:       time.Hour * time.Duration(env.GetInt("FEED_RANK_HALF_LIFE_HOURS", 12)),
+			Window:         time.Hour * time.Duration(env.GetInt("FEED_RANK_WINDOW_HOURS", 72)),
+			AffinityWindow: time.Hour * 24 * time.Duration(env.GetInt("FEED_RANK_AFFINITY_DAYS", 30)),
+		},
+		publicFeeds: publicFeedsConfig{
+			cacheTTL: time.Second * time.Duration(env.GetInt("PUBLIC_FEEDS_CACHE_SECONDS", 60)),
+			rateLimiter: ratelimiter.Config{
+				RequestsPerTimeFrame: env.GetInt("PUBLIC_FEEDS_RATELIMITER_REQUESTS", 10),
+				TimeFrame:            time.Second * 30,
+			},
+		},
+		stream: streamConfig{
+			backend:   env.GetString("STREAM_BACKEND", "memory"),
+			heartbeat: time.Second * time.Duration(env.GetInt("STREAM_HEARTBEAT_SECONDS", 15)),
+			broker: stream.Config{
+				ReplaySize: env.GetInt("STREAM_REPLAY_SIZE", 100),
+				ReplayTTL:  time.Minute * time.Duration(env.GetInt("STREAM_REPLAY_MINUTES", 5)),
+				BufferSize: env.GetInt("STREAM_BUFFER_SIZE", 64),
+			},
+		},
+		liveComments: liveCommentsConfig{
+			maxPosts:     env.GetInt("LIVE_COMMENTS_MAX_POSTS", 20),
+			sendBuffer:   env.GetInt("LIVE_COMMENTS_SEND_BUFFER", 64),
+			pingInterval: time.Second * time.Duration(env.GetInt("LIVE_COMMENTS_PING_SECONDS", 30)),
+		},
+		notifications: notificationsConfig{
+			retention:      time.Hour * 24 * time.Duration(env.GetInt("NOTIFICATIONS_RETENTION_DAYS", 90)),
+			purgeInterval:  time.Minute * time.Duration(env.GetInt("NOTIFICATIONS_PURGE_INTERVAL_MINUTES", 60)),
+			digestInterval: time.Minute * time.Duration(env.GetInt("NOTIFICATIONS_DIGEST_INTERVAL_MINUTES", 60)),
+			digestSize:     env.GetInt("NOTIFICATIONS_DIGEST_SIZE", 20),
+		},
+	}
+
+	// tickers panic on intervals that aren't positive
+	if config.stream.heartbeat <= 0 {
+		logger.Fatal("STREAM_HEARTBEAT_SECONDS must be positive")
+	}
+	if config.liveComments.pingInterval <= 0 {
+		logger.Fatal("LIVE_COMMENTS_PING_SECONDS must be positive")
 	}
 
 	// Init a new db connections with configuration setup
@@ -109,12 +206,53 @@ func main() {
 	redis := cache.NewRedisClient(config.redis.address, config.redis.password, config.redis.db)
 
 	limiter := ratelimiter.NewFixedWindowRateLimiter(config.rateLimiter.RequestsPerTimeFrame, config.rateLimiter.TimeFrame)
+	anonLimiter := ratelimiter.NewFixedWindowRateLimiter(
+		config.publicFeeds.rateLimiter.RequestsPerTimeFrame,
+		config.publicFeeds.rateLimiter.TimeFrame,
+	)
 
 	// Initialize a new storage layer (`store`) which acts as an interface between
 	// the application and the database. The `store` package is responsible
 	// for querying, inserting, updating, and deleting records in the database.
 	store := store.NewStorage(db)
 
+	// Initialize the search backend, users are always searched in postgres
+	var searchIndex search.Index = search.NewPostgresIndex(store.SearchRepository)
+	if config.search.backend == "bleve" {
+		searchIndex, err = search.OpenBleve(config.search.indexPath, searchIndex)
+		if err != nil {
+			logger.Fatal(err)
+		}
+	}
+	defer searchIndex.Close()
+
+	// Initialize the blob storage for uploaded media
+	var blobs blobstore.Store
+	switch config.media.backend {
+	case "s3":
+		blobs, err = blobstore.NewS3(config.media.s3)
+	default:
+		blobs, err = blobstore.NewLocal(config.media.localPath)
+	}
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	caches := cache.NewCacheStorage(redis, config.suggestions.ttl, config.timelines.Length, config.timelines.TTL, config.publicFeeds.cacheTTL)
+
+	// Initialize the brokers of live events, redis shares them between instances.
+	// Comment threads are only followed live, they have nothing to replay.
+	commentsBroker := stream.Config{BufferSize: config.liveComments.sendBuffer}
+	var broker, comments stream.Broker
+	switch config.stream.backend {
+	case "redis":
+		broker = stream.NewRedis(redis, "events", config.stream.broker, logger.Warnf)
+		comments = stream.NewRedis(redis, "comments", commentsBroker, logger.Warnf)
+	default:
+		broker = stream.NewLocal(config.stream.broker)
+		comments = stream.NewLocal(commentsBroker)
+	}
+
 	// Initialize a new `mailer` which is the interface for sending emails
 	mailer := mailer.NewSendgrid(config.mail.sendGrid.apiKey, config.mail.fromEmail)
 
@@ -124,13 +262,21 @@ func main() {
 	// Create an `application` instance which encapsulates configuration settings
 	// and storage, making them accessible throughout the application.
 	app := application{
-		config:        config,
-		store:         store,
-		logger:        logger,
-		mailer:        mailer,
-		authenticator: jwtAuthenticator,
-		cache:         cache.NewCacheStorage(redis),
-		rateLimiter:   limiter,
+		config:          config,
+		store:           store,
+		logger:          logger,
+		mailer:          mailer,
+		authenticator:   jwtAuthenticator,
+		cache:           caches,
+		rateLimiter:     limiter,
+		anonRateLimiter: anonLimiter,
+		search:          searchIndex,
+		blobs:           blobs,
+		timelines:       timeline.New(store, caches.TimelinesCache, config.timelines),
+		stream:          broker,
+		comments:        comments,
+		sockets:         newSocketSet(),
+		notifications:   notifications.New(store.NotificationsRepository, broker),
 	}
 
 	// Mount the application's HTTP handlers (routes) onto a multiplexer (`mux`).
@@ -0,0 +1,383 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/elhambadri2411/social/internal/blobstore"
+	"github.com/elhambadri2411/social/internal/media"
+	"github.com/elhambadri2411/social/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	maxAltTextLength = 500
+	detachedBatch    = 100
+)
+
+// UploadMedia godoc
+//
+//	@Summary		Attaches an image to a post
+//	@Description	Uploads a JPEG, PNG, GIF or WebP image as multipart form data (`file`, optional `alt`).
+//	@Description	The type is detected from the content, metadata like EXIF is stripped and a thumbnail is generated.
+//	@Tags			posts
+//	@Accept			multipart/form-data
+//	@Produce		json
+//	@Param			id		path		int		true	"Post ID"
+//	@Param			file	formData	file	true	"Image"
+//	@Param			alt		formData	string	false	"Alternative text"
+//	@Success		201		{object}	store.Media
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		409		{object}	error	"The post already has the maximum number of attachments"
+//	@Failure		413		{object}	error
+//	@Failure		415		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{id}/media [post]
+func (app *application) uploadMediaHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+	post := getPostFromCtx(r)
+	ctx := r.Context()
+
+	if post.UserId != user.ID {
+		app.forbiddenResponse(w, r)
+		return
+	}
+
+	data, altText, err := app.readUpload(w, r)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxBytesErr), errors.Is(err, errUploadTooLarge):
+			app.payloadTooLargeResponse(w, r, app.config.media.maxUploadBytes)
+		default:
+			app.badRequestResponse(w, r, err)
+		}
+		return
+	}
+
+	processed, err := media.Process(data, media.Limits{
+		MaxPixels:     app.config.media.maxPixels,
+		MaxGIFPixels:  app.config.media.maxGIFPixels,
+		ThumbnailSize: app.config.media.thumbnailSize,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, media.ErrUnsupportedType):
+			app.unsupportedMediaTypeResponse(w, r, err)
+		case errors.Is(err, media.ErrTooManyPixels):
+			app.badRequestResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	key, err := newMediaKey(time.Now())
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	attachment := &store.Media{
+		PostId:       post.ID,
+		UserId:       user.ID,
+		Key:          key + processed.Original.Ext,
+		ThumbnailKey: key + "_thumb" + processed.Thumbnail.Ext,
+		ContentType:  processed.Original.ContentType,
+		Size:         int64(len(processed.Original.Data)),
+		Width:        processed.Original.Width,
+		Height:       processed.Original.Height,
+		AltText:      altText,
+	}
+
+	if err := app.putBlob(ctx, attachment.Key, processed.Original); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.putBlob(ctx, attachment.ThumbnailKey, processed.Thumbnail); err != nil {
+		app.deleteBlobs(ctx, *attachment)
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.store.MediaRepository.Create(ctx, attachment, app.config.media.maxPerPost); err != nil {
+		app.deleteBlobs(ctx, *attachment)
+
+		switch {
+		case errors.Is(err, store.ErrLimitExceeded):
+			app.conflictError(w, r, fmt.Errorf("a post can have at most %d attachments", app.config.media.maxPerPost))
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.withMediaURLs(attachment)
+
+	if err := app.jsonResponse(w, http.StatusCreated, attachment); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// DeleteMedia godoc
+//
+//	@Summary		Removes an image from a post
+//	@Description	Deletes an attachment and its stored files
+//	@Tags			posts
+//	@Param			id		path		int	true	"Post ID"
+//	@Param			mediaId	path		int	true	"Media ID"
+//	@Success		204		{object}	string
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{id}/media/{mediaId} [delete]
+func (app *application) deleteMediaHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+	post := getPostFromCtx(r)
+	ctx := r.Context()
+
+	if post.UserId != user.ID {
+		app.forbiddenResponse(w, r)
+		return
+	}
+
+	mediaId, err := strconv.ParseInt(chi.URLParam(r, "mediaId"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	attachment, err := app.store.MediaRepository.GetById(ctx, post.ID, mediaId)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.store.MediaRepository.Delete(ctx, attachment.ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.deleteBlobs(ctx, *attachment)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetMedia godoc
+//
+//	@Summary		Serves an uploaded image
+//	@Description	Serves an image or thumbnail. Keys never change content, so responses are cacheable forever.
+//	@Tags			media
+//	@Produce		image/jpeg,image/png,image/gif
+//	@Param			key	path		string	true	"Media key"
+//	@Success		200	{file}		binary
+//	@Success		304	{string}	string	"Not modified"
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Router			/media/{key} [get]
+func (app *application) getMediaHandler(w http.ResponseWriter, r *http.Request) {
+	key := "media/" + chi.URLParam(r, "*")
+	if !blobstore.ValidKey(key) {
+		app.notFoundResponse(w, r, blobstore.ErrInvalidKey)
+		return
+	}
+
+	// a key is never reused for different content, so it doubles as the ETag
+	etag := `"` + path.Base(key) + `"`
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" && etagMatches(ifNoneMatch, etag, true) {
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	blob, info, err := app.blobs.Get(r.Context(), key)
+	if err != nil {
+		switch {
+		case errors.Is(err, blobstore.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	defer blob.Close()
+
+	contentType := info.ContentType
+	if contentType == "" {
+		contentType = mime.TypeByExtension(path.Ext(key))
+	}
+
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(info.Size, 10))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if !info.ModTime.IsZero() {
+		w.Header().Set("Last-Modified", info.ModTime.UTC().Format(http.TimeFormat))
+	}
+	w.WriteHeader(http.StatusOK)
+
+	if r.Method != http.MethodHead {
+		if _, err := io.Copy(w, blob); err != nil {
+			app.logger.Warnw("error serving media", "key", key, "error", err.Error())
+		}
+	}
+}
+
+var errUploadTooLarge = errors.New("upload too large")
+
+// `readUpload` reads the `file` and `alt` parts of a multipart upload without buffering
+// more than the configured upload size
+func (app *application) readUpload(w http.ResponseWriter, r *http.Request) ([]byte, string, error) {
+	maxBytes := app.config.media.maxUploadBytes
+	// leave room for the multipart framing and the alt text
+	r.Body = http.MaxBytesReader(w, r.Body, maxBytes+64*1024)
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, "", err
+	}
+
+	var data []byte
+	var altText string
+
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, "", err
+		}
+
+		switch part.FormName() {
+		case "file":
+			buf := bytes.Buffer{}
+			n, err := buf.ReadFrom(io.LimitReader(part, maxBytes+1))
+			if err != nil {
+				return nil, "", err
+			}
+			if n > maxBytes {
+				return nil, "", errUploadTooLarge
+			}
+			data = buf.Bytes()
+		case "alt":
+			raw, err := io.ReadAll(io.LimitReader(part, maxAltTextLength*4+1))
+			if err != nil {
+				return nil, "", err
+			}
+			altText = strings.TrimSpace(string(raw))
+			if len([]rune(altText)) > maxAltTextLength {
+				return nil, "", fmt.Errorf("alt must be at most %d characters", maxAltTextLength)
+			}
+		}
+		part.Close()
+	}
+
+	if len(data) == 0 {
+		return nil, "", errors.New("file is required")
+	}
+
+	return data, altText, nil
+}
+
+// `newMediaKey` returns a random, unguessable key prefix like `media/2025/07/<hex>`
+func newMediaKey(now time.Time) (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("media/%s/%s", now.UTC().Format("2006/01"), hex.EncodeToString(id)), nil
+}
+
+func (app *application) putBlob(ctx context.Context, key string, img media.Image) error {
+	return app.blobs.Put(ctx, key, bytes.NewReader(img.Data), int64(len(img.Data)), img.ContentType)
+}
+
+// `deleteBlobs` removes the files of an attachment, failures only leave unreachable files behind so they are logged
+func (app *application) deleteBlobs(ctx context.Context, attachment store.Media) {
+	for _, key := range []string{attachment.Key, attachment.ThumbnailKey} {
+		if err := app.blobs.Delete(ctx, key); err != nil {
+			app.logger.Errorw("error deleting blob", "key", key, "error", err.Error())
+		}
+	}
+}
+
+// `withMediaURLs` sets the URLs an attachment is served from
+func (app *application) withMediaURLs(attachment *store.Media) {
+	base := strings.TrimSuffix(app.config.media.publicURL, "/")
+	attachment.URL = base + "/" + strings.TrimPrefix(attachment.Key, "media/")
+	attachment.ThumbnailURL = base + "/" + strings.TrimPrefix(attachment.ThumbnailKey, "media/")
+}
+
+// `purgeDetachedMedia` removes the files and rows of attachments whose post was purged.
+// An attachment that can't be removed is logged and skipped, it is retried on the next run.
+func (app *application) purgeDetachedMedia(ctx context.Context) error {
+	for {
+		detached, err := app.store.MediaRepository.GetDetached(ctx, detachedBatch)
+		if err != nil {
+			return err
+		}
+
+		purged := 0
+		for _, attachment := range detached {
+			if err := app.purgeAttachment(ctx, attachment); err != nil {
+				app.logger.Errorw("error purging detached media", "media_id", attachment.ID, "error", err.Error())
+				continue
+			}
+			purged++
+		}
+
+		if purged > 0 {
+			app.logger.Infow("purged detached media", "count", purged)
+		}
+
+		// failed attachments stay detached, fetching another batch would return them again
+		if len(detached) < detachedBatch || purged < len(detached) {
+			return nil
+		}
+	}
+}
+
+func (app *application) purgeAttachment(ctx context.Context, attachment store.Media) error {
+	for _, key := range []string{attachment.Key, attachment.ThumbnailKey} {
+		if err := app.blobs.Delete(ctx, key); err != nil {
+			return err
+		}
+	}
+
+	if err := app.store.MediaRepository.Delete(ctx, attachment.ID); err != nil && !errors.Is(err, store.ErrNotFound) {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"image"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"testing"
+
+	"github.com/elhambadri2411/social/internal/store"
+	"github.com/elhambadri2411/social/internal/store/cache"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestMediaUpload(t *testing.T) {
+	mockApp := newTestApplication(t)
+	mockMux := mockApp.mount()
+
+	testToken, _ := mockApp.authenticator.GenerateToken(nil)
+
+	mockCacheStore := mockApp.cache.UsersCache.(*cache.MockUsersCacheRedis)
+	mockCacheStore.On("Get", mock.Anything, int64(21)).Return(nil, nil)
+	mockCacheStore.On("Set", mock.Anything, mock.Anything).Return(nil)
+
+	upload := func(filename string, content []byte) *http.Request {
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		form.WriteField("alt", "a blank square")
+		part, _ := form.CreateFormFile("file", filename)
+		part.Write(content)
+		form.Close()
+
+		req, err := http.NewRequest(http.MethodPost, "/v1/posts/1/media", &body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", form.FormDataContentType())
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		return req
+	}
+
+	var pngData bytes.Buffer
+	png.Encode(&pngData, image.NewNRGBA(image.Rect(0, 0, 100, 50)))
+
+	t.Run("should store the image and serve it with cache headers", func(t *testing.T) {
+		rr := execRequest(upload("square.png", pngData.Bytes()), mockMux)
+		assertResponseCode(t, http.StatusCreated, rr.Code)
+
+		var response struct {
+			Data struct {
+				URL          string `json:"url"`
+				ThumbnailURL string `json:"thumbnail_url"`
+				Width        int    `json:"width"`
+				AltText      string `json:"alt_text"`
+			} `json:"data"`
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+			t.Fatal(err)
+		}
+		if response.Data.Width != 100 || response.Data.AltText != "a blank square" {
+			t.Errorf("unexpected attachment %+v", response.Data)
+		}
+
+		for _, url := range []string{response.Data.URL, response.Data.ThumbnailURL} {
+			req, _ := http.NewRequest(http.MethodGet, url, nil)
+			rr := execRequest(req, mockMux)
+
+			assertResponseCode(t, http.StatusOK, rr.Code)
+			if cacheControl := rr.Header().Get("Cache-Control"); cacheControl != "public, max-age=31536000, immutable" {
+				t.Errorf("unexpected Cache-Control %q for %s", cacheControl, url)
+			}
+
+			req.Header.Set("If-None-Match", rr.Header().Get("ETag"))
+			assertResponseCode(t, http.StatusNotModified, execRequest(req, mockMux).Code)
+		}
+	})
+
+	t.Run("should sniff the content instead of trusting the filename", func(t *testing.T) {
+		rr := execRequest(upload("evil.png", []byte("<html><script>alert(1)</script></html>")), mockMux)
+		assertResponseCode(t, http.StatusUnsupportedMediaType, rr.Code)
+	})
+
+	t.Run("should reject uploads over the size limit", func(t *testing.T) {
+		rr := execRequest(upload("big.png", make([]byte, mockApp.config.media.maxUploadBytes+1)), mockMux)
+		assertResponseCode(t, http.StatusRequestEntityTooLarge, rr.Code)
+	})
+
+	t.Run("should not serve keys outside of the media directory", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/v1/media/..%2F..%2Fetc%2Fpasswd", nil)
+		assertResponseCode(t, http.StatusNotFound, execRequest(req, mockMux).Code)
+	})
+}
+
+// `detachedMediaStore` returns the same detached attachments until they are deleted
+type detachedMediaStore struct {
+	store.MockMediaStore
+	detached []store.Media
+	deleted  []int64
+}
+
+func (s *detachedMediaStore) GetDetached(ctx context.Context, limit int) ([]store.Media, error) {
+	return s.detached, nil
+}
+
+func (s *detachedMediaStore) Delete(ctx context.Context, id int64) error {
+	s.deleted = append(s.deleted, id)
+	return nil
+}
+
+func TestPurgeDetachedMedia(t *testing.T) {
+	mockApp := newTestApplication(t)
+
+	mediaStore := &detachedMediaStore{detached: []store.Media{
+		{ID: 1, Key: "../outside.png", ThumbnailKey: "media/1-thumb.jpg"},
+		{ID: 2, Key: "media/2.png", ThumbnailKey: "media/2-thumb.jpg"},
+	}}
+	mockApp.store.MediaRepository = mediaStore
+
+	if err := mockApp.purgeDetachedMedia(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// the attachment whose file can't be removed is skipped, not the ones after it
+	if len(mediaStore.deleted) != 1 || mediaStore.deleted[0] != 2 {
+		t.Errorf("Expected only attachment 2 to be purged. Got %v", mediaStore.deleted)
+	}
+}
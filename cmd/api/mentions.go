@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/elhambadri2411/social/internal/mentions"
+	"github.com/elhambadri2411/social/internal/store"
+)
+
+// `syncMentions` parses `content` and stores the mentions it contains for `source`.
+// Mentions are secondary to the content itself, so failures are logged rather than
+// failing the request. Returns the resolved mentions to embed in the response.
+func (app *application) syncMentions(ctx context.Context, source store.MentionSource, content string) []store.Mention {
+	all, created, err := app.store.MentionsRepository.Sync(ctx, source, mentions.Parse(content))
+	if err != nil {
+		app.logger.Errorw("error syncing mentions", "post_id", source.PostId, "error", err.Error())
+		return []store.Mention{}
+	}
+
+	app.notifyMentions(created)
+
+	if all == nil {
+		return []store.Mention{}
+	}
+	return all
+}
+
+// `attachMentions` distributes the mentions of a post to its body and its comments
+func attachMentions(post *store.Post, all []store.Mention) {
+	post.Mentions = []store.Mention{}
+	byComment := make(map[int64][]store.Mention)
+
+	for _, mention := range all {
+		if mention.CommentId == nil {
+			post.Mentions = append(post.Mentions, mention)
+			continue
+		}
+		byComment[*mention.CommentId] = append(byComment[*mention.CommentId], mention)
+	}
+
+	for i := range post.Comments {
+		post.Comments[i].Mentions = byComment[post.Comments[i].ID]
+		if post.Comments[i].Mentions == nil {
+			post.Comments[i].Mentions = []store.Mention{}
+		}
+	}
+}
+
+// getMyMentionsHandler godoc
+//
+//	@Summary		Lists mentions of the user
+//	@Description	Lists posts and comments where the authenticated user was mentioned, newest first
+//	@Tags			users
+//	@Produce		json
+//	@Param			limit	query		int	false	"Limit"
+//	@Param			offset	query		int	false	"Offset"
+//	@Success		200		{object}	[]store.UserMention
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/mentions [get]
+func (app *application) getMyMentionsHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	pfq := store.PaginatedFeedQuery{
+		Limit:  20,
+		Offset: 0,
+		Sort:   "desc",
+	}
+
+	pfq, err := pfq.Parse(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(pfq); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	result, err := app.store.MentionsRepository.GetForUser(r.Context(), user.ID, pfq.Limit, pfq.Offset)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, result); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/elhambadri2411/social/internal/mentions"
+	"github.com/elhambadri2411/social/internal/store"
+)
+
+// `recordingMentionStore` keeps the mentions synced for each source and lists them back as an inbox
+type recordingMentionStore struct {
+	store.MockMentionStore
+	mu      sync.Mutex
+	synced  map[store.MentionSource][]mentions.Entity
+	inboxOf int64
+}
+
+func (s *recordingMentionStore) Sync(ctx context.Context, source store.MentionSource, entities []mentions.Entity) ([]store.Mention, []store.Mention, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.synced[source] = entities
+
+	all := make([]store.Mention, 0, len(entities))
+	for i, entity := range entities {
+		all = append(all, store.Mention{
+			PostId:    source.PostId,
+			CommentId: source.CommentId,
+			AuthorId:  source.AuthorId,
+			UserId:    int64(30 + i),
+			Username:  entity.Username,
+			Offset:    entity.Offset,
+			Length:    entity.Length,
+		})
+	}
+
+	return all, all, nil
+}
+
+func (s *recordingMentionStore) GetForUser(ctx context.Context, userId int64, limit int, offset int) ([]store.UserMention, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.inboxOf = userId
+
+	inbox := []store.UserMention{}
+	for source, entities := range s.synced {
+		if len(entities) == 0 {
+			continue
+		}
+		inbox = append(inbox, store.UserMention{
+			PostId:         source.PostId,
+			CommentId:      source.CommentId,
+			AuthorId:       source.AuthorId,
+			AuthorUsername: "gopher",
+			Excerpt:        "hey @" + entities[0].Username,
+		})
+	}
+
+	return inbox, nil
+}
+
+func TestMentions(t *testing.T) {
+	mockApp, send := newTestServer(t)
+
+	mentionsStore := &recordingMentionStore{synced: make(map[store.MentionSource][]mentions.Entity)}
+	mockApp.store.MentionsRepository = mentionsStore
+
+	t.Run("should record the mentions of a new comment", func(t *testing.T) {
+		rr := send(http.MethodPost, "/v1/posts/1/comments", `{"content":"hey @ana and @bob"}`)
+		assertResponseCode(t, http.StatusCreated, rr.Code)
+		mockApp.wg.Wait()
+
+		var comment store.Comment
+		decodeData(t, rr, &comment)
+		if len(comment.Mentions) != 2 || comment.Mentions[0].Username != "ana" || comment.Mentions[1].Username != "bob" {
+			t.Fatalf("Expected mentions of ana and bob. Got %+v", comment.Mentions)
+		}
+		if comment.Mentions[0].Offset != 4 || comment.Mentions[0].Length != 4 {
+			t.Errorf("Expected @ana at 4 with length 4. Got %+v", comment.Mentions[0])
+		}
+
+		// sources are keyed by the comment id pointer, so match them field by field
+		var entities []mentions.Entity
+		for source, synced := range mentionsStore.synced {
+			if source.PostId == 1 && source.CommentId != nil && *source.CommentId == comment.ID && source.AuthorId == store.MockPostsUserId {
+				entities = synced
+			}
+		}
+		if len(entities) != 2 {
+			t.Errorf("Expected the comment's mentions to be synced. Got %+v", mentionsStore.synced)
+		}
+	})
+
+	t.Run("should list the mentions of the user", func(t *testing.T) {
+		rr := send(http.MethodGet, "/v1/users/me/mentions?limit=10", "")
+		assertResponseCode(t, http.StatusOK, rr.Code)
+
+		if mentionsStore.inboxOf != store.MockPostsUserId {
+			t.Errorf("Expected the inbox of user %d. Got %d", store.MockPostsUserId, mentionsStore.inboxOf)
+		}
+
+		var inbox []store.UserMention
+		decodeData(t, rr, &inbox)
+		if len(inbox) != 1 || inbox[0].PostId != 1 || inbox[0].CommentId == nil || inbox[0].Excerpt != "hey @ana" {
+			t.Errorf("Expected the mention in the comment on post 1. Got %+v", inbox)
+		}
+
+		assertResponseCode(t, http.StatusBadRequest, send(http.MethodGet, "/v1/users/me/mentions?limit=abc", "").Code)
+	})
+}
@@ -62,22 +62,10 @@ func (app *application) AuthTokenMiddleware(next http.Handler) http.Handler {
 			app.unauthorizedError(w, r, fmt.Errorf("authorization header malformed"))
 			return
 		}
-		jwtToken, err := app.authenticator.ValidateToken(parts[1])
-		if err != nil {
-			app.unauthorizedError(w, r, err)
-			return
-		}
-
-		claims, _ := jwtToken.Claims.(jwt.MapClaims)
-		userId, err := strconv.ParseInt(fmt.Sprintf("%.f", claims["sub"]), 10, 64)
-		if err != nil {
-			app.unauthorizedError(w, r, err)
-			return
-		}
 
 		ctx := r.Context()
 
-		user, err := app.getUser(ctx, userId)
+		user, err := app.authenticateToken(ctx, parts[1])
 		if err != nil {
 			app.unauthorizedError(w, r, err)
 			return
@@ -88,6 +76,22 @@ func (app *application) AuthTokenMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// `authenticateToken` validates a JWT and returns the user it was issued to
+func (app *application) authenticateToken(ctx context.Context, token string) (*store.User, error) {
+	jwtToken, err := app.authenticator.ValidateToken(token)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, _ := jwtToken.Claims.(jwt.MapClaims)
+	userId, err := strconv.ParseInt(fmt.Sprintf("%.f", claims["sub"]), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	return app.getUser(ctx, userId)
+}
+
 func (app *application) checkPostOwnership(roleName string, next http.HandlerFunc) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		user := getUserFromCtx(r)
@@ -113,6 +117,52 @@ func (app *application) checkPostOwnership(roleName string, next http.HandlerFun
 	})
 }
 
+// `checkCommentOwnership` lets the author of the comment through, and users whose role is at least `roleName`
+func (app *application) checkCommentOwnership(roleName string, next http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := getUserFromCtx(r)
+		comment := getCommentFromCtx(r)
+
+		if comment.UserId == user.ID {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		allowed, err := app.checkRolePrecedence(r.Context(), user, roleName)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		if !allowed {
+			app.forbiddenResponse(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// `requireRole` only lets through users whose role is at least as high as `roleName`
+func (app *application) requireRole(roleName string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			allowed, err := app.checkRolePrecedence(r.Context(), getUserFromCtx(r), roleName)
+			if err != nil {
+				app.internalServerError(w, r, err)
+				return
+			}
+
+			if !allowed {
+				app.forbiddenResponse(w, r)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 func (app *application) checkRolePrecedence(ctx context.Context, user *store.User, roleName string) (bool, error) {
 	role, err := app.store.RolesRepository.GetByName(ctx, roleName)
 	if err != nil {
@@ -136,7 +186,7 @@ func (app *application) getUser(ctx context.Context, userId int64) (*store.User,
 		return nil, err
 	}
 
-	err = app.cache.Set(ctx, dbUser)
+	err = app.cache.UsersCache.Set(ctx, dbUser)
 	if err != nil {
 		return nil, err
 	}
@@ -150,5 +200,28 @@ func (app *application) RateLimiterMiddleware(next http.Handler) http.Handler {
 			app.rateLimitExceededResponse(w, r, retryAfter.String())
 			return
 		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// `AnonymousRateLimiterMiddleware` applies the stricter anonymous rate limit to requests
+// without a valid token, on endpoints open to visitors
+func (app *application) AnonymousRateLimiterMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(r.Header.Get("Authorization"), " ")
+		if len(parts) == 2 && parts[0] == "Bearer" {
+			if _, err := app.authenticator.ValidateToken(parts[1]); err == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		if allow, retryAfter := app.anonRateLimiter.Allow(r.RemoteAddr); !allow {
+			app.rateLimitExceededResponse(w, r, retryAfter.String())
+			return
+		}
+
+		next.ServeHTTP(w, r)
 	})
 }
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+
+	"github.com/elhambadri2411/social/internal/mailer"
+	"github.com/elhambadri2411/social/internal/notifications"
+	"github.com/elhambadri2411/social/internal/store"
+)
+
+const (
+	digestBatch = 100
+
+	// `unsubscribeDigest` is the scope of unsubscribe links in digests, links in other emails are
+	// scoped to the notification type they are about
+	unsubscribeDigest = "digest"
+
+	// `unsubscribeKeyLabel` derives the key of unsubscribe links from the token secret
+	unsubscribeKeyLabel = "social unsubscribe links v1"
+)
+
+// `notificationEmailGroup` is a line of a digest
+type notificationEmailGroup struct {
+	Summary string
+	URL     string
+	Count   int
+}
+
+// `emailNotification` emails `n` to its recipient right away. Failures are only logged, the
+// notification is in the inbox anyway.
+func (app *application) emailNotification(ctx context.Context, n store.Notification) {
+	user, err := app.getUser(ctx, n.UserId)
+	if err != nil {
+		app.logger.Warnw("failed to email notification", "notification", n.ID, "error", err.Error())
+		return
+	}
+
+	group := store.NotificationGroup{
+		ID:         n.ID,
+		Type:       n.Type,
+		PostId:     n.PostId,
+		CommentId:  n.CommentId,
+		Actors:     []store.Author{n.Actor},
+		ActorCount: 1,
+		Count:      1,
+	}
+
+	vars := struct {
+		Username       string
+		Summary        string
+		URL            string
+		UnsubscribeURL string
+	}{
+		Username:       user.Username,
+		Summary:        notifications.Summary(group),
+		URL:            app.notificationURL(group),
+		UnsubscribeURL: app.unsubscribeURL(app.config.frontendUrl+"/unsubscribe", user.ID, n.Type),
+	}
+
+	if err := app.sendNotificationEmail(mailer.NotificationTemplate, user.Username, user.Email, vars, user.ID, n.Type); err != nil {
+		app.logger.Warnw("failed to email notification", "notification", n.ID, "error", err.Error())
+	}
+}
+
+// `sendDigests` emails the users whose digest is due a summary of their unread notifications
+// since the last one. Users without any are skipped until their next digest is due.
+// A failed recipient is logged and tried again on the next run.
+func (app *application) sendDigests(ctx context.Context) error {
+	for {
+		recipients, err := app.store.NotificationPreferencesRepository.GetDueDigests(ctx, digestBatch)
+		if err != nil {
+			return err
+		}
+
+		sent, failed := 0, 0
+		for _, recipient := range recipients {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			emailed, err := app.sendDueDigest(ctx, recipient)
+			if err != nil {
+				app.logger.Errorw("error sending digest", "user_id", recipient.UserId, "error", err.Error())
+				failed++
+				continue
+			}
+			if emailed {
+				sent++
+			}
+		}
+
+		if sent > 0 {
+			app.logger.Infow("sent digests", "count", sent)
+		}
+
+		// failed recipients are still due, fetching another batch would return them again
+		if len(recipients) < digestBatch || failed > 0 {
+			return nil
+		}
+	}
+}
+
+// `sendDueDigest` emails `recipient` their digest if they have unread notifications, and marks it sent
+func (app *application) sendDueDigest(ctx context.Context, recipient store.DigestRecipient) (bool, error) {
+	groups, err := app.store.NotificationPreferencesRepository.GetDigest(ctx, recipient.UserId, recipient.Since, app.config.notifications.digestSize)
+	if err != nil {
+		return false, err
+	}
+
+	if len(groups) > 0 {
+		if err := app.sendDigest(recipient, groups); err != nil {
+			return false, err
+		}
+	}
+
+	if err := app.store.NotificationPreferencesRepository.MarkDigestSent(ctx, recipient.UserId); err != nil {
+		return false, err
+	}
+
+	return len(groups) > 0, nil
+}
+
+// `sendDigest` emails `recipient` a line per notification group
+func (app *application) sendDigest(recipient store.DigestRecipient, groups []store.NotificationGroup) error {
+	lines := make([]notificationEmailGroup, len(groups))
+	for i, group := range groups {
+		lines[i] = notificationEmailGroup{
+			Summary: notifications.Summary(group),
+			URL:     app.notificationURL(group),
+			Count:   group.Count,
+		}
+	}
+
+	vars := struct {
+		Username       string
+		Frequency      string
+		Groups         []notificationEmailGroup
+		InboxURL       string
+		UnsubscribeURL string
+	}{
+		Username:       recipient.Username,
+		Frequency:      recipient.Frequency,
+		Groups:         lines,
+		InboxURL:       app.config.frontendUrl + "/notifications",
+		UnsubscribeURL: app.unsubscribeURL(app.config.frontendUrl+"/unsubscribe", recipient.UserId, unsubscribeDigest),
+	}
+
+	return app.sendNotificationEmail(mailer.DigestTemplate, recipient.Username, recipient.Email, vars, recipient.UserId, unsubscribeDigest)
+}
+
+// `sendNotificationEmail` sends an email with RFC 8058 one-click unsubscribe headers for `scope`.
+// Mail clients POST to the `List-Unsubscribe` link without the user's token.
+func (app *application) sendNotificationEmail(templateFile, username, email string, vars any, userId int64, scope string) error {
+	headers := map[string]string{
+		"List-Unsubscribe":      "<" + app.unsubscribeURL(app.config.apiUrl+"/v1/notifications/unsubscribe", userId, scope) + ">",
+		"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+	}
+
+	status, err := app.mailer.SendWithHeaders(templateFile, username, email, vars, headers, app.config.env != "PROD")
+	if err != nil {
+		return err
+	}
+	if status < 200 || status > 299 {
+		return fmt.Errorf("mailer answered %d", status)
+	}
+
+	return nil
+}
+
+// `notificationURL` links to what `group` is about on the frontend
+func (app *application) notificationURL(group store.NotificationGroup) string {
+	switch {
+	case group.PostId != nil:
+		return fmt.Sprintf("%s/posts/%d", app.config.frontendUrl, *group.PostId)
+	case group.Type == notifications.TypeFollow && len(group.Actors) == 1:
+		return app.config.frontendUrl + "/users/" + url.PathEscape(group.Actors[0].Username)
+	default:
+		return app.config.frontendUrl + "/notifications"
+	}
+}
+
+// `unsubscribeURL` returns `base` with the query of a signed unsubscribe link for `scope`.
+// The links don't expire, people unsubscribe from old emails too.
+func (app *application) unsubscribeURL(base string, userId int64, scope string) string {
+	query := url.Values{}
+	query.Set("user", strconv.FormatInt(userId, 10))
+	query.Set("scope", scope)
+	query.Set("signature", hex.EncodeToString(app.signUnsubscribe(userId, scope)))
+
+	return base + "?" + query.Encode()
+}
+
+// `signUnsubscribe` signs an unsubscribe link with a key derived from the token secret, so the
+// signatures can't be mistaken for anything else signed with it
+func (app *application) signUnsubscribe(userId int64, scope string) []byte {
+	mac := hmac.New(sha256.New, app.derivedKey(unsubscribeKeyLabel))
+	fmt.Fprintf(mac, "unsubscribe:%d:%s", userId, scope)
+	return mac.Sum(nil)
+}
+
+// Unsubscribe godoc
+//
+//	@Summary		Unsubscribes from notification emails
+//	@Description	One-click unsubscribe (RFC 8058) from the links in notification emails, it doesn't need a token.
+//	@Description	The `digest` scope moves every type delivered in digests back to in-app only, a notification type
+//	@Description	stops the emails sent right away for that type. The types stay in the inbox.
+//	@Tags			users
+//	@Accept			application/x-www-form-urlencoded
+//	@Param			user		query	int		true	"User ID"
+//	@Param			scope		query	string	true	"`digest` or a notification type"
+//	@Param			signature	query	string	true	"Link signature"
+//	@Success		204
+//	@Failure		400	{object}	error
+//	@Failure		403	{object}	error	"Invalid link"
+//	@Failure		500	{object}	error
+//	@Router			/notifications/unsubscribe [post]
+func (app *application) unsubscribeHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	userId, err := strconv.ParseInt(query.Get("user"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	scope := query.Get("scope")
+	signature, err := hex.DecodeString(query.Get("signature"))
+	if err != nil || !hmac.Equal(signature, app.signUnsubscribe(userId, scope)) {
+		app.forbiddenResponse(w, r)
+		return
+	}
+
+	delivery, notificationType := store.DeliveryEmail, scope
+	if scope == unsubscribeDigest {
+		delivery, notificationType = store.DeliveryDigest, ""
+	} else if !slices.Contains(notifications.Types, scope) {
+		app.forbiddenResponse(w, r)
+		return
+	}
+
+	if err := app.store.NotificationPreferencesRepository.Unsubscribe(r.Context(), userId, delivery, notificationType); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
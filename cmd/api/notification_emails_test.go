@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/elhambadri2411/social/internal/notifications"
+	"github.com/elhambadri2411/social/internal/store"
+	"github.com/elhambadri2411/social/internal/store/cache"
+	"github.com/stretchr/testify/mock"
+)
+
+// `recordingMailer` keeps the emails it is asked to send, and answers them with `status`
+type recordingMailer struct {
+	sent   []recordedEmail
+	status int
+}
+
+type recordedEmail struct {
+	template string
+	email    string
+	headers  map[string]string
+}
+
+func (m *recordingMailer) Send(templateFile, username, email string, data any, isSandbox bool) (int, error) {
+	return m.SendWithHeaders(templateFile, username, email, data, nil, isSandbox)
+}
+
+func (m *recordingMailer) SendWithHeaders(templateFile, username, email string, data any, headers map[string]string, isSandbox bool) (int, error) {
+	m.sent = append(m.sent, recordedEmail{template: templateFile, email: email, headers: headers})
+	if m.status != 0 {
+		return m.status, nil
+	}
+	return http.StatusAccepted, nil
+}
+
+// `assertUnsubscribeHeaders` checks `email` has one-click unsubscribe headers pointing at the API
+func assertUnsubscribeHeaders(t *testing.T, email recordedEmail) *url.URL {
+	t.Helper()
+
+	if email.headers["List-Unsubscribe-Post"] != "List-Unsubscribe=One-Click" {
+		t.Errorf("expected a one-click unsubscribe header, got %v", email.headers)
+	}
+
+	link := strings.TrimSuffix(strings.TrimPrefix(email.headers["List-Unsubscribe"], "<"), ">")
+	if !strings.HasPrefix(link, "https://api.example.com/v1/notifications/unsubscribe?") {
+		t.Fatalf("unexpected unsubscribe link %q", link)
+	}
+	target, err := url.Parse(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return target
+}
+
+// `emailNotificationsStore` records every notification for delivery by email
+type emailNotificationsStore struct {
+	store.MockNotificationsStore
+}
+
+func (s *emailNotificationsStore) Create(ctx context.Context, notification store.Notification, userIds []int64) ([]store.Notification, error) {
+	created, err := s.MockNotificationsStore.Create(ctx, notification, userIds)
+	for i := range created {
+		created[i].Delivery = store.DeliveryEmail
+	}
+	return created, err
+}
+
+func TestNotificationEmails(t *testing.T) {
+	mockApp := newTestApplication(t)
+	mockApp.notifications = notifications.New(&emailNotificationsStore{}, mockApp.stream)
+
+	mailer := &recordingMailer{}
+	mockApp.mailer = mailer
+	mockApp.config.apiUrl = "https://api.example.com"
+
+	mockCacheStore := mockApp.cache.UsersCache.(*cache.MockUsersCacheRedis)
+	mockCacheStore.On("Get", mock.Anything, mock.Anything).Return(nil, nil)
+	mockCacheStore.On("Set", mock.Anything, mock.Anything).Return(nil)
+
+	mockApp.notify([]int64{1}, store.Notification{Type: notifications.TypeMention, ActorId: store.MockPostsUserId})
+	mockApp.wg.Wait()
+
+	if len(mailer.sent) != 1 || mailer.sent[0].template != "notification.tmpl" {
+		t.Fatalf("expected a notification email, got %+v", mailer.sent)
+	}
+
+	target := assertUnsubscribeHeaders(t, mailer.sent[0])
+	if scope := target.Query().Get("scope"); scope != notifications.TypeMention {
+		t.Errorf("expected the link to unsubscribe from mentions, got %q", scope)
+	}
+}
+
+// `flakyPreferencesStore` has two digests due, reading the one of user 4 fails
+type flakyPreferencesStore struct {
+	store.MockNotificationPreferencesStore
+	callLog
+}
+
+func (s *flakyPreferencesStore) GetDueDigests(ctx context.Context, limit int) ([]store.DigestRecipient, error) {
+	return []store.DigestRecipient{
+		{UserId: 4, Username: "four", Email: "four@test.com", Frequency: store.DigestDaily},
+		{UserId: store.MockPostsUserId, Username: "test", Email: "test@test.com", Frequency: store.DigestDaily},
+	}, nil
+}
+
+func (s *flakyPreferencesStore) GetDigest(ctx context.Context, userId int64, since string, limit int) ([]store.NotificationGroup, error) {
+	if userId == 4 {
+		return nil, errors.New("connection reset")
+	}
+	return s.MockNotificationPreferencesStore.GetDigest(ctx, userId, since, limit)
+}
+
+func (s *flakyPreferencesStore) MarkDigestSent(ctx context.Context, userId int64) error {
+	s.record("MarkDigestSent(%d)", userId)
+	return nil
+}
+
+func TestDigestFailures(t *testing.T) {
+	mockApp := newTestApplication(t)
+	preferences := &flakyPreferencesStore{}
+	mockApp.store.NotificationPreferencesRepository = preferences
+
+	mailer := &recordingMailer{}
+	mockApp.mailer = mailer
+
+	t.Run("should go on with the next recipient after a failure", func(t *testing.T) {
+		if err := mockApp.sendDigests(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(mailer.sent) != 1 || mailer.sent[0].email != "test@test.com" {
+			t.Errorf("expected the digest of the second recipient, got %+v", mailer.sent)
+		}
+		preferences.assertCalls(t, "MarkDigestSent(21)")
+	})
+
+	t.Run("should keep digests the mailer refused due", func(t *testing.T) {
+		mailer.status = http.StatusBadRequest
+		defer func() { mailer.status = 0 }()
+
+		if err := mockApp.sendDigests(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		preferences.assertCalls(t)
+	})
+}
+
+func TestDigests(t *testing.T) {
+	mockApp := newTestApplication(t)
+	mockMux := mockApp.mount()
+
+	mailer := &recordingMailer{}
+	mockApp.mailer = mailer
+	mockApp.config.apiUrl = "https://api.example.com"
+
+	if err := mockApp.sendDigests(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(mailer.sent) != 1 || mailer.sent[0].template != "digest.tmpl" {
+		t.Fatalf("expected a digest to be sent, got %+v", mailer.sent)
+	}
+
+	target := assertUnsubscribeHeaders(t, mailer.sent[0])
+
+	unsubscribe := func(query string) int {
+		req, err := http.NewRequest(http.MethodPost, "/v1/notifications/unsubscribe?"+query, strings.NewReader("List-Unsubscribe=One-Click"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return execRequest(req, mockMux).Code
+	}
+
+	t.Run("should unsubscribe in one click without a token", func(t *testing.T) {
+		assertResponseCode(t, http.StatusNoContent, unsubscribe(target.RawQuery))
+	})
+
+	t.Run("should reject tampered links", func(t *testing.T) {
+		query := target.Query()
+		query.Set("user", "22")
+		assertResponseCode(t, http.StatusForbidden, unsubscribe(query.Encode()))
+	})
+
+	t.Run("should not sign links with the token secret itself", func(t *testing.T) {
+		mac := hmac.New(sha256.New, []byte(mockApp.config.auth.token.secret))
+		fmt.Fprintf(mac, "unsubscribe:%d:%s", 21, unsubscribeDigest)
+
+		query := target.Query()
+		query.Set("signature", hex.EncodeToString(mac.Sum(nil)))
+		assertResponseCode(t, http.StatusForbidden, unsubscribe(query.Encode()))
+	})
+
+	t.Run("should reject unknown scopes", func(t *testing.T) {
+		query, _ := url.ParseQuery(strings.TrimPrefix(mockApp.unsubscribeURL("", 21, "unknown"), "?"))
+		assertResponseCode(t, http.StatusForbidden, unsubscribe(query.Encode()))
+	})
+}
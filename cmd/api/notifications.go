@@ -0,0 +1,284 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/elhambadri2411/social/internal/notifications"
+	"github.com/elhambadri2411/social/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+// `notificationsConfig` holds settings for the notifications inbox
+type notificationsConfig struct {
+	retention      time.Duration // how long notifications are kept, read or not
+	purgeInterval  time.Duration // how often old notifications are purged (0 disables it)
+	digestInterval time.Duration // how often due digests are sent (0 disables them)
+	digestSize     int           // notification groups listed in a digest
+}
+
+// `updateNotificationPreferencesPayload` sets the delivery of some notification types, the others keep theirs
+type updateNotificationPreferencesPayload struct {
+	Delivery        map[string]string `json:"delivery" validate:"dive,keys,oneof=follow follow_request comment reply mention,endkeys,oneof=in_app email digest off"`
+	DigestFrequency string            `json:"digest_frequency" validate:"omitempty,oneof=daily weekly"`
+}
+
+// `notify` records `n` for `userIds` in the background and emails those who want it right away.
+// Notifications are secondary to what caused them, so failures are logged rather than failing the request.
+func (app *application) notify(userIds []int64, n store.Notification) {
+	if len(userIds) == 0 {
+		return
+	}
+
+	app.background(func() {
+		ctx := context.Background()
+
+		created, err := app.notifications.Notify(ctx, userIds, n)
+		if err != nil {
+			app.logger.Warnw("failed to notify", "type", n.Type, "actor", n.ActorId, "error", err.Error())
+		}
+
+		for _, notification := range created {
+			if notification.Delivery == store.DeliveryEmail {
+				app.emailNotification(ctx, notification)
+			}
+		}
+	})
+}
+
+// `notifyComment` tells the author of a post about a new comment on it, and the other users
+// who commented on it about a reply
+func (app *application) notifyComment(post *store.Post, comment *store.Comment) {
+	n := store.Notification{
+		ActorId:   comment.UserId,
+		PostId:    &post.ID,
+		CommentId: &comment.ID,
+	}
+
+	n.Type = notifications.TypeComment
+	app.notify([]int64{post.UserId}, n)
+
+	app.background(func() {
+		commenterIds, err := app.store.CommentsRepository.GetCommenterIds(context.Background(), post.ID)
+		if err != nil {
+			app.logger.Warnw("failed to notify", "type", notifications.TypeReply, "actor", comment.UserId, "error", err.Error())
+			return
+		}
+		commenterIds = slices.DeleteFunc(commenterIds, func(userId int64) bool { return userId == post.UserId })
+
+		n.Type = notifications.TypeReply
+		app.notify(commenterIds, n)
+	})
+}
+
+// `notifyMentions` tells newly mentioned users they were mentioned
+func (app *application) notifyMentions(created []store.Mention) {
+	for _, mention := range created {
+		app.notify([]int64{mention.UserId}, store.Notification{
+			Type:      notifications.TypeMention,
+			ActorId:   mention.AuthorId,
+			PostId:    &mention.PostId,
+			CommentId: mention.CommentId,
+		})
+	}
+}
+
+// getMyNotificationsHandler godoc
+//
+//	@Summary		Lists notifications of the user
+//	@Description	Lists the notifications of the authenticated user, latest first. Notifications of the same type
+//	@Description	about the same post are grouped, with the latest actors and how many there were in total, and so
+//	@Description	are follows. `unread_count` counts the unread groups. New notifications are also streamed live as
+//	@Description	`notification` events, see `/stream`. The types are `follow`, `follow_request`, `comment`, `reply`
+//	@Description	and `mention`, there are no reaction notifications since the API has no reactions.
+//	@Tags			users
+//	@Produce		json
+//	@Param			limit	query		int	false	"Limit (default 20, max 100)"
+//	@Param			offset	query		int	false	"Offset"
+//	@Success		200		{object}	store.NotificationsPage
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/notifications [get]
+func (app *application) getMyNotificationsHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	pfq := store.PaginatedFeedQuery{
+		Limit:  20,
+		Offset: 0,
+		Sort:   "desc",
+	}
+
+	pfq, err := pfq.Parse(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(pfq); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if pfq.Limit < 1 || pfq.Limit > 100 {
+		app.badRequestResponse(w, r, errors.New("limit must be between 1 and 100"))
+		return
+	}
+
+	page, err := app.store.NotificationsRepository.GetForUser(r.Context(), user.ID, pfq.Limit, pfq.Offset)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, page); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// markNotificationReadHandler godoc
+//
+//	@Summary		Marks a notification read
+//	@Description	Marks a notification of the authenticated user read, with the notifications grouped with it
+//	@Tags			users
+//	@Param			notificationId	path	int	true	"Notification ID"
+//	@Success		204
+//	@Failure		400	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/notifications/{notificationId}/read [put]
+func (app *application) markNotificationReadHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "notificationId"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := app.store.NotificationsRepository.MarkRead(r.Context(), user.ID, id); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// markAllNotificationsReadHandler godoc
+//
+//	@Summary		Marks all notifications read
+//	@Description	Marks every notification of the authenticated user read
+//	@Tags			users
+//	@Success		204
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/notifications/read [put]
+func (app *application) markAllNotificationsReadHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	if _, err := app.store.NotificationsRepository.MarkAllRead(r.Context(), user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getNotificationPreferencesHandler godoc
+//
+//	@Summary		Shows notification preferences
+//	@Description	Shows how the authenticated user gets each type of notification: `in_app` (inbox and live events),
+//	@Description	`email` (also emailed right away), `digest` (also listed in a daily or weekly email) or `off`
+//	@Tags			users
+//	@Produce		json
+//	@Success		200	{object}	store.NotificationPreferences
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/notifications/preferences [get]
+func (app *application) getNotificationPreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	preferences, err := app.store.NotificationPreferencesRepository.Get(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, notifications.WithDefaults(preferences)); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// updateNotificationPreferencesHandler godoc
+//
+//	@Summary		Updates notification preferences
+//	@Description	Sets the delivery of the given notification types and how often digests are sent, types left out
+//	@Description	keep their delivery. Returns every preference.
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		updateNotificationPreferencesPayload	true	"Preferences"
+//	@Success		200		{object}	store.NotificationPreferences
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/notifications/preferences [put]
+func (app *application) updateNotificationPreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	var payload updateNotificationPreferencesPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	err := app.store.NotificationPreferencesRepository.Set(ctx, user.ID, store.NotificationPreferences{
+		Delivery:        payload.Delivery,
+		DigestFrequency: payload.DigestFrequency,
+	})
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	preferences, err := app.store.NotificationPreferencesRepository.Get(ctx, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, notifications.WithDefaults(preferences)); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// `purgeNotifications` deletes notifications past their retention
+func (app *application) purgeNotifications(ctx context.Context) error {
+	purged, err := app.store.NotificationsRepository.Purge(ctx, app.config.notifications.retention)
+	if err != nil {
+		return err
+	}
+
+	if purged > 0 {
+		app.logger.Infow("purged notifications", "count", purged)
+	}
+
+	return nil
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/elhambadri2411/social/internal/notifications"
+	"github.com/elhambadri2411/social/internal/store"
+	"github.com/elhambadri2411/social/internal/store/cache"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestNotifications(t *testing.T) {
+	mockApp := newTestApplication(t)
+	mockMux := mockApp.mount()
+
+	testToken, _ := mockApp.authenticator.GenerateToken(nil)
+
+	mockCacheStore := mockApp.cache.UsersCache.(*cache.MockUsersCacheRedis)
+	mockCacheStore.On("Get", mock.Anything, mock.Anything).Return(nil, nil)
+	mockCacheStore.On("Set", mock.Anything, mock.Anything).Return(nil)
+
+	request := func(method string, url string) *http.Request {
+		req, err := http.NewRequest(method, url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		return req
+	}
+
+	t.Run("should list grouped notifications with the unread count", func(t *testing.T) {
+		rr := execRequest(request(http.MethodGet, "/v1/users/me/notifications"), mockMux)
+		assertResponseCode(t, http.StatusOK, rr.Code)
+
+		var response struct {
+			Data store.NotificationsPage `json:"data"`
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+			t.Fatal(err)
+		}
+
+		if response.Data.UnreadCount != 1 || len(response.Data.Notifications) != 1 {
+			t.Fatalf("unexpected page %+v", response.Data)
+		}
+		if group := response.Data.Notifications[0]; group.ActorCount != 5 || len(group.Actors) != 1 {
+			t.Errorf("expected the group to list its latest actors and count the rest, got %+v", group)
+		}
+	})
+
+	t.Run("should reject an invalid limit", func(t *testing.T) {
+		rr := execRequest(request(http.MethodGet, "/v1/users/me/notifications?limit=1000"), mockMux)
+		assertResponseCode(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("should mark a notification read", func(t *testing.T) {
+		rr := execRequest(request(http.MethodPut, "/v1/users/me/notifications/3/read"), mockMux)
+		assertResponseCode(t, http.StatusNoContent, rr.Code)
+	})
+
+	t.Run("should not find notifications of others", func(t *testing.T) {
+		rr := execRequest(request(http.MethodPut, "/v1/users/me/notifications/4/read"), mockMux)
+		assertResponseCode(t, http.StatusNotFound, rr.Code)
+	})
+
+	t.Run("should mark all notifications read", func(t *testing.T) {
+		rr := execRequest(request(http.MethodPut, "/v1/users/me/notifications/read"), mockMux)
+		assertResponseCode(t, http.StatusNoContent, rr.Code)
+	})
+}
+
+// `recordingPreferencesStore` records the preferences it is asked to store
+type recordingPreferencesStore struct {
+	store.MockNotificationPreferencesStore
+	callLog
+}
+
+func (s *recordingPreferencesStore) Set(ctx context.Context, userId int64, preferences store.NotificationPreferences) error {
+	s.record("Set(%d, %v, %q)", userId, preferences.Delivery, preferences.DigestFrequency)
+	return nil
+}
+
+func TestNotificationPreferences(t *testing.T) {
+	mockApp := newTestApplication(t)
+	mockMux := mockApp.mount()
+
+	preferences := &recordingPreferencesStore{}
+	mockApp.store.NotificationPreferencesRepository = preferences
+
+	testToken, _ := mockApp.authenticator.GenerateToken(nil)
+
+	mockCacheStore := mockApp.cache.UsersCache.(*cache.MockUsersCacheRedis)
+	mockCacheStore.On("Get", mock.Anything, mock.Anything).Return(nil, nil)
+	mockCacheStore.On("Set", mock.Anything, mock.Anything).Return(nil)
+
+	request := func(method string, body string) *http.Request {
+		req, err := http.NewRequest(method, "/v1/users/me/notifications/preferences", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		return req
+	}
+
+	t.Run("should list a delivery for every type", func(t *testing.T) {
+		rr := execRequest(request(http.MethodGet, ""), mockMux)
+		assertResponseCode(t, http.StatusOK, rr.Code)
+
+		var response struct {
+			Data store.NotificationPreferences `json:"data"`
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(response.Data.Delivery) != len(notifications.Types) {
+			t.Errorf("expected every type, got %v", response.Data.Delivery)
+		}
+		if response.Data.Delivery[notifications.TypeComment] != store.DeliveryDigest {
+			t.Errorf("expected the stored delivery of comments, got %v", response.Data.Delivery)
+		}
+		if response.Data.Delivery[notifications.TypeFollow] != store.DeliveryInApp {
+			t.Errorf("expected follows in-app by default, got %v", response.Data.Delivery)
+		}
+	})
+
+	t.Run("should update preferences", func(t *testing.T) {
+		rr := execRequest(request(http.MethodPut, `{"delivery": {"mention": "email", "follow": "off"}, "digest_frequency": "weekly"}`), mockMux)
+		assertResponseCode(t, http.StatusOK, rr.Code)
+		preferences.assertCalls(t, `Set(21, map[follow:off mention:email], "weekly")`)
+
+		rr = execRequest(request(http.MethodPut, `{"delivery": {"comment": "digest"}}`), mockMux)
+		assertResponseCode(t, http.StatusOK, rr.Code)
+		preferences.assertCalls(t, `Set(21, map[comment:digest], "")`)
+	})
+
+	t.Run("should reject unknown types, deliveries and frequencies", func(t *testing.T) {
+		for _, body := range []string{
+			`{"delivery": {"unknown": "email"}}`,
+			`{"delivery": {"mention": "sms"}}`,
+			`{"digest_frequency": "hourly"}`,
+		} {
+			rr := execRequest(request(http.MethodPut, body), mockMux)
+			assertResponseCode(t, http.StatusBadRequest, rr.Code)
+		}
+		preferences.assertCalls(t)
+	})
+}
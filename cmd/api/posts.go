@@ -2,10 +2,12 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"net/http"
 	"strconv"
 
+	"github.com/elhambadri2411/social/internal/markdown"
 	"github.com/elhambadri2411/social/internal/store"
 	"github.com/go-chi/chi/v5"
 )
@@ -15,14 +17,16 @@ type postKey string
 const postCtx postKey = "post"
 
 type createPostPayload struct {
-	Title   string   `json:"title" validate:"required,max=100"`
-	Content string   `json:"content" validate:"required,max=1000"`
-	Tags    []string `json:"tags"`
+	Title    string                 `json:"title" validate:"required,max=100"`
+	Content  string                 `json:"content" validate:"required,max=10000"`
+	Tags     []string               `json:"tags" validate:"max=10,dive,max=100"`
+	Snippets []createSnippetPayload `json:"snippets" validate:"max=10,dive"`
 }
 
 type updatePostPayload struct {
-	Title   *string `json:"title" validate:"omitempty,max=100"`
-	Content *string `json:"content" validate:"omitempty,max=1000"`
+	Title   *string  `json:"title" validate:"omitempty,max=100"`
+	Content *string  `json:"content" validate:"omitempty,max=10000"`
+	Tags    []string `json:"tags" validate:"omitempty,max=10,dive,max=100"`
 }
 
 func (app *application) getPostsHandler(w http.ResponseWriter, r *http.Request) {
@@ -64,20 +68,25 @@ func (app *application) getPostsHandler(w http.ResponseWriter, r *http.Request)
 // GetPost godoc
 //
 //	@Summary		Fetches a post
-//	@Description	Fetches a post by ID
+//	@Description	Fetches a post by ID with its comments, snippets, media and mentions. The ETag is the version of
+//	@Description	the post followed by a digest of the response, so it changes with new comments too. It can be
+//	@Description	sent back as If-Match, which only compares the version.
 //	@Tags			posts
 //	@Accept			json
 //	@Produce		json
-//	@Param			id	path		int	true	"Post ID"
-//	@Success		200	{object}	store.Post
-//	@Failure		404	{object}	error
-//	@Failure		500	{object}	error
+//	@Param			id				path		int		true	"Post ID"
+//	@Param			If-None-Match	header		string	false	"ETag of a cached copy"
+//	@Success		200				{object}	store.Post
+//	@Success		304				{string}	string	"Not modified"
+//	@Failure		404				{object}	error
+//	@Failure		500				{object}	error
+//	@Header			200				{string}	ETag	"Version of the post and digest of the response"
 //	@Security		ApiKeyAuth
 //	@Router			/posts/{id} [get]
 func (app *application) getPostByIdHandler(w http.ResponseWriter, r *http.Request) {
 	post := getPostFromCtx(r)
 
-	comments, err := app.store.CommentsRepository.GetByPostId(r.Context(), post.ID)
+	comments, err := app.store.CommentsRepository.GetByPostId(r.Context(), post.ID, getUserFromCtx(r).ID)
 	if err != nil {
 		app.internalServerError(w, r, err)
 		return
@@ -85,7 +94,47 @@ func (app *application) getPostByIdHandler(w http.ResponseWriter, r *http.Reques
 
 	post.Comments = comments
 
-	if err := app.jsonResponse(w, http.StatusOK, post); err != nil {
+	snippets, err := app.store.SnippetsRepository.GetByPostId(r.Context(), post.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	post.Snippets = snippets
+
+	attachments, err := app.store.MediaRepository.GetByPostId(r.Context(), post.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	for i := range attachments {
+		app.withMediaURLs(&attachments[i])
+	}
+	post.Media = attachments
+
+	postMentions, err := app.store.MentionsRepository.GetByPostId(r.Context(), post.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	attachMentions(post, postMentions)
+
+	body, err := json.Marshal(post)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	etag := postRepresentationETag(post, body)
+	w.Header().Set("ETag", etag)
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" && etagMatches(ifNoneMatch, etag, true) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, json.RawMessage(body)); err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
@@ -98,31 +147,34 @@ func (app *application) getPostByIdHandler(w http.ResponseWriter, r *http.Reques
 //	@Tags			posts
 //	@Accept			json
 //	@Produce		json
-//	@Param			id	path		int	true	"Post ID"
-//	@Success		204	{object} string
-//	@Failure		404	{object}	error
-//	@Failure		500	{object}	error
+//	@Param			id			path		int		true	"Post ID"
+//	@Param			If-Match	header		string	false	"ETag the client last saw"
+//	@Success		204			{object}	string
+//	@Failure		404			{object}	error
+//	@Failure		412			{object}	error
+//	@Failure		428			{object}	error
+//	@Failure		500			{object}	error
 //	@Security		ApiKeyAuth
 //	@Router			/posts/{id} [delete]
 func (app *application) deletePostByIdHandler(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
-	postIdParam := chi.URLParam(r, "postId")
-	postId, err := strconv.ParseInt(postIdParam, 10, 64)
-	if err != nil {
-		app.internalServerError(w, r, err)
+	post := getPostFromCtx(r)
+
+	if !app.checkIfMatch(w, r, post) {
 		return
 	}
 
-	if err := app.store.PostsRepository.DeleteById(ctx, postId); err != nil {
+	if err := app.store.PostsRepository.DeleteById(r.Context(), post.ID, post.Version); err != nil {
 		switch {
-		case errors.Is(err, store.ErrNotFound):
-			app.notFoundResponse(w, r, err)
+		case errors.Is(err, store.ErrEditConflict):
+			app.preconditionFailedResponse(w, r)
 		default:
 			app.internalServerError(w, r, err)
 		}
 		return
 	}
 
+	app.unindexPost(r.Context(), post.ID)
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -133,19 +185,28 @@ func (app *application) deletePostByIdHandler(w http.ResponseWriter, r *http.Req
 //	@Tags			posts
 //	@Accept			json
 //	@Produce		json
-//	@Param			id		path		int					true	"Post ID"
-//	@Param			payload	body		updatePostPayload	true	"Post payload"
-//	@Success		200		{object}	store.Post
-//	@Failure		400		{object}	error
-//	@Failure		401		{object}	error
-//	@Failure		404		{object}	error
-//	@Failure		500		{object}	error
+//	@Param			id			path		int					true	"Post ID"
+//	@Param			If-Match	header		string				false	"ETag the client last saw"
+//	@Param			payload		body		updatePostPayload	true	"Post payload"
+//	@Success		200			{object}	store.Post
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		409			{object}	error
+//	@Failure		412			{object}	error
+//	@Failure		428			{object}	error
+//	@Failure		500			{object}	error
+//	@Header			200			{string}	ETag	"New version of the post"
 //	@Security		ApiKeyAuth
 //	@Router			/posts/{id} [patch]
 func (app *application) updatePostByIdHandler(w http.ResponseWriter, r *http.Request) {
 	var payload updatePostPayload
 	post := getPostFromCtx(r)
 
+	if !app.checkIfMatch(w, r, post) {
+		return
+	}
+
 	if err := readJSON(w, r, &payload); err != nil {
 		app.badRequestResponse(w, r, err)
 		return
@@ -157,19 +218,48 @@ func (app *application) updatePostByIdHandler(w http.ResponseWriter, r *http.Req
 	}
 
 	if payload.Content != nil {
+		contentHTML, err := markdown.Render(*payload.Content)
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+
 		post.Content = *payload.Content
+		post.ContentHTML = contentHTML
 	}
 
 	if payload.Title != nil {
-		post.Title = *payload.Content
+		post.Title = *payload.Title
+	}
+
+	if payload.Tags != nil {
+		post.Tags = payload.Tags
 	}
 
 	err := app.store.PostsRepository.UpdateById(r.Context(), post)
 	if err != nil {
-		app.internalServerError(w, r, err)
+		switch {
+		case errors.Is(err, store.ErrEditConflict) && r.Header.Get("If-Match") != "":
+			app.preconditionFailedResponse(w, r)
+		case errors.Is(err, store.ErrEditConflict):
+			app.conflictError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
 		return
 	}
 
+	if payload.Content != nil {
+		post.Mentions = app.syncMentions(r.Context(), store.MentionSource{
+			PostId:   post.ID,
+			AuthorId: post.UserId,
+		}, post.Content)
+	}
+
+	app.indexPost(r.Context(), post)
+
+	w.Header().Set("ETag", postETag(post))
+
 	if err := app.jsonResponse(w, http.StatusOK, post); err != nil {
 		app.internalServerError(w, r, err)
 		return
@@ -203,12 +293,27 @@ func (app *application) createPostsHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	contentHTML, err := markdown.Render(payload.Content)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	snippets, err := buildSnippets(payload.Snippets)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
 	user := getUserFromCtx(r)
 	post := &store.Post{
-		Title:   payload.Title,
-		Content: payload.Content,
-		Tags:    payload.Tags,
-		UserId:  user.ID,
+		Title:       payload.Title,
+		Content:     payload.Content,
+		ContentHTML: contentHTML,
+		Tags:        payload.Tags,
+		UserId:      user.ID,
+		Snippets:    snippets,
+		User:        store.Author{ID: user.ID, Username: user.Username},
 	}
 
 	ctx := r.Context()
@@ -217,6 +322,15 @@ func (app *application) createPostsHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	post.Mentions = app.syncMentions(ctx, store.MentionSource{
+		PostId:   post.ID,
+		AuthorId: user.ID,
+	}, post.Content)
+
+	app.indexPost(ctx, post)
+	app.publishToTimelines(post)
+	app.streamPost(post)
+
 	if err := app.jsonResponse(w, http.StatusCreated, post); err != nil {
 		app.internalServerError(w, r, err)
 		return
@@ -244,6 +358,17 @@ func (app *application) postsContextMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
+		// posts of private accounts look missing to everyone but their followers
+		visible, err := app.canView(ctx, getUserFromCtx(r), post.UserId)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+		if !visible {
+			app.notFoundResponse(w, r, store.ErrNotFound)
+			return
+		}
+
 		ctx = context.WithValue(ctx, postCtx, post)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
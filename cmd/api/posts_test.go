@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/elhambadri2411/social/internal/store"
+	"github.com/elhambadri2411/social/internal/store/cache"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestPostPreconditions(t *testing.T) {
+	mockApp := newTestApplication(t)
+	mockMux := mockApp.mount()
+
+	testToken, _ := mockApp.authenticator.GenerateToken(nil)
+
+	mockCacheStore := mockApp.cache.UsersCache.(*cache.MockUsersCacheRedis)
+	mockCacheStore.On("Get", mock.Anything, int64(21)).Return(nil, nil)
+	mockCacheStore.On("Set", mock.Anything, mock.Anything).Return(nil)
+
+	newRequest := func(method, target, body string) *http.Request {
+		req, err := http.NewRequest(method, target, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		return req
+	}
+
+	t.Run("should return an ETag starting with the post version", func(t *testing.T) {
+		rr := execRequest(newRequest(http.MethodGet, "/v1/posts/1", ""), mockMux)
+
+		assertResponseCode(t, http.StatusOK, rr.Code)
+		if etag := rr.Header().Get("ETag"); !strings.HasPrefix(etag, `"1-`) {
+			t.Errorf(`Expected ETag starting with "1-. Got %s`, etag)
+		}
+	})
+
+	t.Run("should answer 304 when If-None-Match matches", func(t *testing.T) {
+		etag := execRequest(newRequest(http.MethodGet, "/v1/posts/1", ""), mockMux).Header().Get("ETag")
+
+		req := newRequest(http.MethodGet, "/v1/posts/1", "")
+		req.Header.Set("If-None-Match", "W/"+etag)
+
+		rr := execRequest(req, mockMux)
+		assertResponseCode(t, http.StatusNotModified, rr.Code)
+
+		req = newRequest(http.MethodGet, "/v1/posts/1", "")
+		req.Header.Set("If-None-Match", `"1"`)
+
+		rr = execRequest(req, mockMux)
+		assertResponseCode(t, http.StatusOK, rr.Code)
+	})
+
+	t.Run("should answer 200 to the same If-None-Match after a comment is added", func(t *testing.T) {
+		comments := &growingCommentStore{}
+		mockApp.store.CommentsRepository = comments
+		defer func() { mockApp.store.CommentsRepository = &store.MockCommentStore{} }()
+
+		etag := execRequest(newRequest(http.MethodGet, "/v1/posts/1", ""), mockMux).Header().Get("ETag")
+
+		rr := execRequest(newRequest(http.MethodPost, "/v1/posts/1/comments", `{"content":"first"}`), mockMux)
+		assertResponseCode(t, http.StatusCreated, rr.Code)
+
+		req := newRequest(http.MethodGet, "/v1/posts/1", "")
+		req.Header.Set("If-None-Match", etag)
+
+		rr = execRequest(req, mockMux)
+		assertResponseCode(t, http.StatusOK, rr.Code)
+		if newEtag := rr.Header().Get("ETag"); newEtag == etag {
+			t.Errorf("Expected a new ETag after the comment. Got %s again", newEtag)
+		}
+	})
+
+	t.Run("should accept the ETag of a read as If-Match", func(t *testing.T) {
+		etag := execRequest(newRequest(http.MethodGet, "/v1/posts/1", ""), mockMux).Header().Get("ETag")
+
+		req := newRequest(http.MethodPatch, "/v1/posts/1", `{"title":"new title"}`)
+		req.Header.Set("If-Match", etag)
+
+		rr := execRequest(req, mockMux)
+		assertResponseCode(t, http.StatusOK, rr.Code)
+	})
+
+	t.Run("should answer 412 when If-Match is stale", func(t *testing.T) {
+		req := newRequest(http.MethodPatch, "/v1/posts/1", `{"title":"new title"}`)
+		req.Header.Set("If-Match", `"0"`)
+
+		rr := execRequest(req, mockMux)
+		assertResponseCode(t, http.StatusPreconditionFailed, rr.Code)
+
+		req = newRequest(http.MethodDelete, "/v1/posts/1", "")
+		req.Header.Set("If-Match", `"0"`)
+
+		rr = execRequest(req, mockMux)
+		assertResponseCode(t, http.StatusPreconditionFailed, rr.Code)
+	})
+
+	t.Run("should update and return the new ETag when If-Match is current", func(t *testing.T) {
+		req := newRequest(http.MethodPatch, "/v1/posts/1", `{"title":"new title"}`)
+		req.Header.Set("If-Match", `"1"`)
+
+		rr := execRequest(req, mockMux)
+		assertResponseCode(t, http.StatusOK, rr.Code)
+		if etag := rr.Header().Get("ETag"); etag != `"2"` {
+			t.Errorf(`Expected ETag "2". Got %s`, etag)
+		}
+	})
+
+	t.Run("should require If-Match when configured", func(t *testing.T) {
+		mockApp.config.posts.requireIfMatch = true
+		defer func() { mockApp.config.posts.requireIfMatch = false }()
+
+		rr := execRequest(newRequest(http.MethodDelete, "/v1/posts/1", ""), mockMux)
+		assertResponseCode(t, http.StatusPreconditionRequired, rr.Code)
+	})
+}
+
+// `growingCommentStore` keeps the comments created on it, so reads see them
+type growingCommentStore struct {
+	store.MockCommentStore
+	comments []store.Comment
+}
+
+func (s *growingCommentStore) Create(ctx context.Context, comment *store.Comment) error {
+	comment.ID = int64(len(s.comments) + 1)
+	s.comments = append(s.comments, *comment)
+	return nil
+}
+
+func (s *growingCommentStore) GetByPostId(ctx context.Context, postId int64, viewerId int64) ([]store.Comment, error) {
+	return s.comments, nil
+}
+
+// `viewerCommentStore` records who the comments of a post were read for
+type viewerCommentStore struct {
+	store.MockCommentStore
+	callLog
+}
+
+func (s *viewerCommentStore) GetByPostId(ctx context.Context, postId int64, viewerId int64) ([]store.Comment, error) {
+	s.record("GetByPostId(%d, viewer=%d)", postId, viewerId)
+	return []store.Comment{}, nil
+}
+
+func TestPostComments(t *testing.T) {
+	mockApp, send := newTestServer(t)
+	comments := &viewerCommentStore{}
+	mockApp.store.CommentsRepository = comments
+
+	t.Run("should read the comments of a post for the viewer", func(t *testing.T) {
+		assertResponseCode(t, http.StatusOK, send(http.MethodGet, "/v1/posts/1", "").Code)
+		comments.assertCalls(t, "GetByPostId(1, viewer=21)")
+	})
+}
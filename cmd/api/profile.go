@@ -0,0 +1,383 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/elhambadri2411/social/internal/media"
+	"github.com/elhambadri2411/social/internal/mentions"
+	"github.com/elhambadri2411/social/internal/store"
+)
+
+const avatarThumbnailSize = 96
+
+// GitHub usernames are alphanumeric with single hyphens in between, at most 39 characters
+var githubHandleRegex = regexp.MustCompile(`^[a-zA-Z0-9](?:-?[a-zA-Z0-9])*$`)
+
+// an empty string clears a field, a missing field is left unchanged
+type updateProfilePayload struct {
+	DisplayName *string `json:"display_name" validate:"omitempty,max=50"`
+	Bio         *string `json:"bio" validate:"omitempty,max=300"`
+	Location    *string `json:"location" validate:"omitempty,max=100"`
+	Website     *string `json:"website" validate:"omitempty,max=200"`
+	GitHub      *string `json:"github" validate:"omitempty,max=39"`
+	IsPrivate   *bool   `json:"is_private"`
+}
+
+type changeUsernamePayload struct {
+	Username string `json:"username" validate:"required,max=100"`
+}
+
+// GetMyProfile godoc
+//
+//	@Summary		Fetches the profile of the authenticated user
+//	@Description	Fetches the profile of the authenticated user, including the email address
+//	@Tags			users
+//	@Produce		json
+//	@Success		200	{object}	store.PrivateProfile
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me [get]
+func (app *application) getMyProfileHandler(w http.ResponseWriter, r *http.Request) {
+	app.writePrivateProfile(w, r, getUserFromCtx(r))
+}
+
+// UpdateMyProfile godoc
+//
+//	@Summary		Updates the profile of the authenticated user
+//	@Description	Updates the display name, bio, location, website, GitHub handle and privacy. Missing fields are left unchanged, empty strings clear them.
+//	@Description	Making a private account public approves its pending follow requests.
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		updateProfilePayload	true	"Profile fields"
+//	@Success		200		{object}	store.PrivateProfile
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me [patch]
+func (app *application) updateMyProfileHandler(w http.ResponseWriter, r *http.Request) {
+	var payload updateProfilePayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	for _, field := range []*string{payload.DisplayName, payload.Bio, payload.Location, payload.Website, payload.GitHub} {
+		if field != nil {
+			*field = strings.TrimSpace(*field)
+		}
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if payload.Website != nil && *payload.Website != "" {
+		if err := validateWebsite(*payload.Website); err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+	}
+
+	if payload.GitHub != nil {
+		*payload.GitHub = strings.TrimPrefix(*payload.GitHub, "@")
+		if *payload.GitHub != "" && !githubHandleRegex.MatchString(*payload.GitHub) {
+			app.badRequestResponse(w, r, fmt.Errorf("github must be a valid GitHub username"))
+			return
+		}
+	}
+
+	user := *getUserFromCtx(r)
+	if payload.DisplayName != nil {
+		user.DisplayName = *payload.DisplayName
+	}
+	if payload.Bio != nil {
+		user.Bio = *payload.Bio
+	}
+	if payload.Location != nil {
+		user.Location = *payload.Location
+	}
+	if payload.Website != nil {
+		user.Website = *payload.Website
+	}
+	if payload.GitHub != nil {
+		user.GitHub = *payload.GitHub
+	}
+	if payload.IsPrivate != nil {
+		user.IsPrivate = *payload.IsPrivate
+	}
+
+	if err := app.saveProfile(r.Context(), &user); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.writePrivateProfile(w, r, &user)
+}
+
+// UploadAvatar godoc
+//
+//	@Summary		Sets the avatar of the authenticated user
+//	@Description	Uploads a JPEG, PNG, GIF or WebP image as multipart form data (`file`).
+//	@Description	The center square is cropped, resized and re-encoded as JPEG without metadata.
+//	@Tags			users
+//	@Accept			multipart/form-data
+//	@Produce		json
+//	@Param			file	formData	file	true	"Image"
+//	@Success		200		{object}	store.PrivateProfile
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		413		{object}	error
+//	@Failure		415		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/avatar [put]
+func (app *application) uploadAvatarHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	data, _, err := app.readUpload(w, r)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxBytesErr), errors.Is(err, errUploadTooLarge):
+			app.payloadTooLargeResponse(w, r, app.config.media.maxUploadBytes)
+		default:
+			app.badRequestResponse(w, r, err)
+		}
+		return
+	}
+
+	avatar, err := media.Avatar(data, media.Limits{
+		MaxPixels:     app.config.media.maxPixels,
+		ThumbnailSize: avatarThumbnailSize,
+	}, app.config.media.avatarSize)
+	if err != nil {
+		switch {
+		case errors.Is(err, media.ErrUnsupportedType):
+			app.unsupportedMediaTypeResponse(w, r, err)
+		case errors.Is(err, media.ErrTooManyPixels):
+			app.badRequestResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	key, err := newMediaKey(time.Now())
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	user := *getUserFromCtx(r)
+	previous := store.Media{Key: deref(user.AvatarKey), ThumbnailKey: deref(user.AvatarThumbnailKey)}
+
+	uploaded := store.Media{Key: key + avatar.Original.Ext, ThumbnailKey: key + "_thumb" + avatar.Thumbnail.Ext}
+	if err := app.putBlob(ctx, uploaded.Key, avatar.Original); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.putBlob(ctx, uploaded.ThumbnailKey, avatar.Thumbnail); err != nil {
+		app.deleteBlobs(ctx, uploaded)
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	user.AvatarKey, user.AvatarThumbnailKey = &uploaded.Key, &uploaded.ThumbnailKey
+	if err := app.saveProfile(ctx, &user); err != nil {
+		app.deleteBlobs(ctx, uploaded)
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if previous.Key != "" {
+		app.deleteBlobs(ctx, previous)
+	}
+
+	app.writePrivateProfile(w, r, &user)
+}
+
+// DeleteAvatar godoc
+//
+//	@Summary	Removes the avatar of the authenticated user
+//	@Tags		users
+//	@Success	204	{object}	string
+//	@Failure	401	{object}	error
+//	@Failure	500	{object}	error
+//	@Security	ApiKeyAuth
+//	@Router		/users/me/avatar [delete]
+func (app *application) deleteAvatarHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user := *getUserFromCtx(r)
+	if user.AvatarKey == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	previous := store.Media{Key: deref(user.AvatarKey), ThumbnailKey: deref(user.AvatarThumbnailKey)}
+	user.AvatarKey, user.AvatarThumbnailKey = nil, nil
+	if err := app.saveProfile(ctx, &user); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.deleteBlobs(ctx, previous)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ChangeUsername godoc
+//
+//	@Summary		Changes the username of the authenticated user
+//	@Description	Usernames can be changed once per cooldown period. The old username stays reserved for a while,
+//	@Description	looking it up redirects to the new one and mentions of it still reach the user.
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		changeUsernamePayload	true	"New username"
+//	@Success		200		{object}	store.PrivateProfile
+//	@Failure		400		{object}	error
+//	@Failure		409		{object}	error	"Username taken or changed too recently"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/username [put]
+func (app *application) changeUsernameHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+	ctx := r.Context()
+
+	var payload changeUsernamePayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	payload.Username = strings.TrimPrefix(strings.TrimSpace(payload.Username), "@")
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	// usernames must survive being mentioned, "@ana." mentions "ana"
+	if !mentions.ValidUsername(payload.Username) {
+		app.badRequestResponse(w, r, errors.New("usernames can only contain letters, digits, '_', '-' and '.', and can't end with '-' or '.'"))
+		return
+	}
+
+	err := app.store.UsersRepository.ChangeUsername(ctx, user.ID, payload.Username, app.config.usernames.changeCooldown, app.config.usernames.reservation)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrDuplicateUsername):
+			app.conflictError(w, r, err)
+		case errors.Is(err, store.ErrUsernameChangeTooSoon):
+			app.conflictError(w, r, fmt.Errorf("%w, it can be changed once every %s", err, app.config.usernames.changeCooldown))
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	user.Username = payload.Username
+	if err := app.cache.UsersCache.Set(ctx, user); err != nil {
+		app.logger.Warnw("error caching user", "user_id", user.ID, "error", err.Error())
+	}
+
+	app.writePrivateProfile(w, r, user)
+}
+
+// `saveProfile` stores the profile and refreshes the cached user, so the change shows up right away
+func (app *application) saveProfile(ctx context.Context, user *store.User) error {
+	if err := app.store.UsersRepository.UpdateProfile(ctx, user); err != nil {
+		return err
+	}
+
+	if err := app.cache.UsersCache.Set(ctx, user); err != nil {
+		app.logger.Warnw("error caching user", "user_id", user.ID, "error", err.Error())
+	}
+
+	return nil
+}
+
+// `publicProfile` projects a user to what anyone may see, with the avatar URLs and follow counts filled in
+func (app *application) publicProfile(ctx context.Context, user *store.User) (*store.PublicProfile, error) {
+	profile := user.PublicProfile()
+	profile.AvatarURL, profile.AvatarThumbnailURL = app.avatarURLs(user.AvatarKey, user.AvatarThumbnailKey)
+
+	counts, err := app.store.FollowersRepository.GetCounts(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	profile.FollowCounts = *counts
+
+	return &profile, nil
+}
+
+func (app *application) privateProfile(ctx context.Context, user *store.User) (*store.PrivateProfile, error) {
+	public, err := app.publicProfile(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	profile := user.PrivateProfile()
+	profile.PublicProfile = *public
+
+	return &profile, nil
+}
+
+// `avatarURLs` returns the URLs an avatar is served from, empty without an avatar
+func (app *application) avatarURLs(key, thumbnailKey *string) (string, string) {
+	if key == nil || thumbnailKey == nil {
+		return "", ""
+	}
+
+	avatar := store.Media{Key: *key, ThumbnailKey: *thumbnailKey}
+	app.withMediaURLs(&avatar)
+
+	return avatar.URL, avatar.ThumbnailURL
+}
+
+// `writePrivateProfile` responds with the profile of the authenticated user
+func (app *application) writePrivateProfile(w http.ResponseWriter, r *http.Request, user *store.User) {
+	profile, err := app.privateProfile(r.Context(), user)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, profile); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// `validateWebsite` only accepts absolute http(s) URLs, so a profile link can't run script
+func validateWebsite(website string) error {
+	u, err := url.Parse(website)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("website must be an http or https URL")
+	}
+
+	return nil
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
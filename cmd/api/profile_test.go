@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/elhambadri2411/social/internal/store/cache"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestProfile(t *testing.T) {
+	mockApp := newTestApplication(t)
+	mockMux := mockApp.mount()
+
+	testToken, _ := mockApp.authenticator.GenerateToken(nil)
+
+	mockCacheStore := mockApp.cache.UsersCache.(*cache.MockUsersCacheRedis)
+	mockCacheStore.On("Get", mock.Anything, mock.Anything).Return(nil, nil)
+	mockCacheStore.On("Set", mock.Anything, mock.Anything).Return(nil)
+
+	patch := func(body string) *http.Request {
+		req, err := http.NewRequest(http.MethodPatch, "/v1/users/me", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		return req
+	}
+
+	t.Run("should update the profile", func(t *testing.T) {
+		rr := execRequest(patch(`{"display_name": " Ada ", "website": "https://ada.dev", "github": "@ada-l"}`), mockMux)
+		assertResponseCode(t, http.StatusOK, rr.Code)
+
+		var response struct {
+			Data map[string]any `json:"data"`
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+			t.Fatal(err)
+		}
+		if response.Data["display_name"] != "Ada" || response.Data["github"] != "ada-l" {
+			t.Errorf("unexpected profile %v", response.Data)
+		}
+		if _, ok := response.Data["password"]; ok {
+			t.Error("the profile leaks the password field")
+		}
+	})
+
+	t.Run("should reject invalid fields", func(t *testing.T) {
+		for _, body := range []string{
+			`{"website": "javascript:alert(1)"}`,
+			`{"github": "-nope-"}`,
+			`{"bio": "` + strings.Repeat("a", 301) + `"}`,
+			`{"email": "someone@example.com"}`,
+		} {
+			rr := execRequest(patch(body), mockMux)
+			assertResponseCode(t, http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("should never expose the email or password publicly", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/v1/users/1", nil)
+		req.Header.Set("Authorization", "Bearer "+testToken)
+
+		rr := execRequest(req, mockMux)
+		assertResponseCode(t, http.StatusOK, rr.Code)
+
+		if body := rr.Body.String(); strings.Contains(body, `"email"`) || strings.Contains(body, `"password"`) {
+			t.Errorf("public profile leaks private fields: %s", body)
+		}
+	})
+
+	t.Run("should crop the avatar to a square", func(t *testing.T) {
+		var img bytes.Buffer
+		png.Encode(&img, image.NewNRGBA(image.Rect(0, 0, 300, 200)))
+
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		part, _ := form.CreateFormFile("file", "me.png")
+		part.Write(img.Bytes())
+		form.Close()
+
+		req, _ := http.NewRequest(http.MethodPut, "/v1/users/me/avatar", &body)
+		req.Header.Set("Content-Type", form.FormDataContentType())
+		req.Header.Set("Authorization", "Bearer "+testToken)
+
+		rr := execRequest(req, mockMux)
+		assertResponseCode(t, http.StatusOK, rr.Code)
+
+		var response struct {
+			Data struct {
+				AvatarURL string `json:"avatar_url"`
+			} `json:"data"`
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+			t.Fatal(err)
+		}
+
+		req, _ = http.NewRequest(http.MethodGet, response.Data.AvatarURL, nil)
+		rr = execRequest(req, mockMux)
+		assertResponseCode(t, http.StatusOK, rr.Code)
+
+		config, _, err := image.DecodeConfig(rr.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if config.Width != 128 || config.Height != 128 {
+			t.Errorf("got a %dx%d avatar, want 128x128", config.Width, config.Height)
+		}
+	})
+}
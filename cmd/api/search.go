@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/elhambadri2411/social/internal/search"
+	"github.com/elhambadri2411/social/internal/store"
+)
+
+// Search godoc
+//
+//	@Summary		Searches posts, comments or users
+//	@Description	Full-text search ranked by relevance. `q` accepts quoted phrases, `or` and `-excluded` words.
+//	@Description	Pass `next_cursor` from a response as `cursor` to fetch the following page. Results of users
+//	@Description	blocked either way are left out, so are comments of muted users.
+//	@Tags			search
+//	@Produce		json
+//	@Param			q		query		string	true	"Search query"
+//	@Param			type	query		string	false	"posts (default), comments or users"
+//	@Param			limit	query		int		false	"Limit (default 20, max 50)"
+//	@Param			cursor	query		string	false	"Cursor from a previous page"
+//	@Success		200		{object}	search.Results
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/search [get]
+func (app *application) searchHandler(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+
+	query := search.Query{
+		Type:     store.SearchPosts,
+		Text:     qs.Get("q"),
+		Limit:    20,
+		Cursor:   qs.Get("cursor"),
+		ViewerId: getUserFromCtx(r).ID,
+	}
+
+	if searchType := qs.Get("type"); searchType != "" {
+		query.Type = searchType
+	}
+
+	if limit := qs.Get("limit"); limit != "" {
+		parsed, err := strconv.Atoi(limit)
+		if err != nil {
+			app.badRequestResponse(w, r, errors.New("limit must be a number"))
+			return
+		}
+		query.Limit = parsed
+	}
+
+	if err := Validate.Struct(query); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	results, err := app.search.Search(r.Context(), query)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrInvalidCursor):
+			app.badRequestResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.hidePrivateResults(r.Context(), getUserFromCtx(r), results); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, results); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// `hidePrivateResults` drops posts and comments of private accounts the viewer doesn't follow,
+// and comments of users blocked either way or muted by them. Only the Postgres index knows who
+// follows or blocks whom, so a page can come back shorter than the limit, the cursor still
+// points after the last hit.
+func (app *application) hidePrivateResults(ctx context.Context, viewer *store.User, results *search.Results) error {
+	postIds := []int64{}
+	commenterIds := []int64{}
+	for _, result := range results.Results {
+		switch result.Type {
+		case store.SearchPosts:
+			postIds = append(postIds, result.ID)
+		case store.SearchComments:
+			postIds = append(postIds, result.PostId)
+			commenterIds = append(commenterIds, result.UserId)
+		}
+	}
+	if len(postIds) == 0 {
+		return nil
+	}
+
+	hiddenIds, err := app.store.FollowersRepository.GetHiddenPosts(ctx, viewer.ID, postIds)
+	if err != nil {
+		return err
+	}
+
+	hiddenAuthorIds := []int64{}
+	if len(commenterIds) > 0 {
+		hiddenAuthorIds, err = app.store.BlocksRepository.GetHiddenAuthors(ctx, viewer.ID, commenterIds)
+		if err != nil {
+			return err
+		}
+	}
+	if len(hiddenIds) == 0 && len(hiddenAuthorIds) == 0 {
+		return nil
+	}
+
+	hidden := make(map[int64]bool, len(hiddenIds))
+	for _, id := range hiddenIds {
+		hidden[id] = true
+	}
+
+	hiddenAuthors := make(map[int64]bool, len(hiddenAuthorIds))
+	for _, id := range hiddenAuthorIds {
+		hiddenAuthors[id] = true
+	}
+
+	// moderators see every post, only ask once something is hidden. Blocks and mutes
+	// are the viewer's own choice and apply to them too.
+	if len(hidden) > 0 {
+		moderator, err := app.checkRolePrecedence(ctx, viewer, "moderator")
+		if err != nil {
+			return err
+		}
+		if moderator {
+			hidden = nil
+		}
+	}
+
+	visible := results.Results[:0]
+	for _, result := range results.Results {
+		switch result.Type {
+		case store.SearchPosts:
+			if hidden[result.ID] {
+				continue
+			}
+		case store.SearchComments:
+			if hidden[result.PostId] || hiddenAuthors[result.UserId] {
+				continue
+			}
+		}
+		visible = append(visible, result)
+	}
+	results.Results = visible
+
+	return nil
+}
+
+// `indexPost` pushes a post and its comments to the search index. The index can be
+// rebuilt with `cmd/reindex`, so failures are logged rather than failing the request.
+func (app *application) indexPost(ctx context.Context, post *store.Post) {
+	author := post.User
+	if author.Username == "" {
+		user, err := app.store.UsersRepository.GetById(ctx, post.UserId)
+		if err != nil {
+			app.logger.Errorw("error indexing post", "post_id", post.ID, "error", err.Error())
+			return
+		}
+		author = store.Author{ID: user.ID, Username: user.Username}
+	}
+
+	// the index is shared by every user, so it gets every comment
+	comments, err := app.store.CommentsRepository.GetByPostId(ctx, post.ID, 0)
+	if err != nil {
+		app.logger.Errorw("error indexing post", "post_id", post.ID, "error", err.Error())
+		return
+	}
+
+	indexed := *post
+	indexed.User = author
+
+	docs := []search.Document{search.PostDocument(&indexed)}
+	for i := range comments {
+		docs = append(docs, search.CommentDocument(&indexed, &comments[i]))
+	}
+
+	if err := app.search.Index(ctx, docs...); err != nil {
+		app.logger.Errorw("error indexing post", "post_id", post.ID, "error", err.Error())
+	}
+}
+
+// `indexComment` pushes a new or edited comment to the search index
+func (app *application) indexComment(ctx context.Context, post *store.Post, comment *store.Comment) {
+	if err := app.search.Index(ctx, search.CommentDocument(post, comment)); err != nil {
+		app.logger.Errorw("error indexing comment", "comment_id", comment.ID, "error", err.Error())
+	}
+}
+
+// `unindexComment` removes a deleted comment from the search index
+func (app *application) unindexComment(ctx context.Context, commentId int64) {
+	if err := app.search.DeleteComment(ctx, commentId); err != nil {
+		app.logger.Errorw("error removing comment from search index", "comment_id", commentId, "error", err.Error())
+	}
+}
+
+// `unindexPost` removes a post and its comments from the search index
+func (app *application) unindexPost(ctx context.Context, postId int64) {
+	if err := app.search.DeletePost(ctx, postId); err != nil {
+		app.logger.Errorw("error removing post from search index", "post_id", postId, "error", err.Error())
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/elhambadri2411/social/internal/search"
+	"github.com/elhambadri2411/social/internal/store"
+)
+
+// `recordingSearchStore` returns a full page for every query and keeps the last one
+type recordingSearchStore struct {
+	store.MockSearchStore
+	query store.SearchQuery
+}
+
+func (s *recordingSearchStore) Search(ctx context.Context, q store.SearchQuery) ([]store.SearchResult, error) {
+	s.query = q
+
+	results := make([]store.SearchResult, 0, q.Limit)
+	for i := range q.Limit {
+		results = append(results, store.SearchResult{Type: q.Type, ID: int64(100 - i), Rank: 0.5})
+	}
+	return results, nil
+}
+
+// `commentsIndex` is an index that doesn't know about blocks, it returns a comment of each of `authorIds`
+type commentsIndex struct {
+	search.PostgresIndex
+	authorIds []int64
+}
+
+func (i *commentsIndex) Search(ctx context.Context, q search.Query) (*search.Results, error) {
+	results := &search.Results{}
+	for n, authorId := range i.authorIds {
+		results.Results = append(results.Results, store.SearchResult{Type: store.SearchComments, ID: int64(n + 1), PostId: 1, UserId: authorId})
+	}
+	return results, nil
+}
+
+func TestSearch(t *testing.T) {
+	mockApp, send := newTestServer(t)
+
+	searchStore := &recordingSearchStore{}
+	mockApp.store.SearchRepository = searchStore
+	mockApp.search = search.NewPostgresIndex(searchStore)
+
+	t.Run("should search with defaults and return a cursor", func(t *testing.T) {
+		rr := send(http.MethodGet, "/v1/search?q="+url.QueryEscape(`"go generics" -java`), "")
+		assertResponseCode(t, http.StatusOK, rr.Code)
+
+		if searchStore.query.Type != store.SearchPosts || searchStore.query.Query != `"go generics" -java` || searchStore.query.Limit != 20 || searchStore.query.After != nil {
+			t.Errorf("Expected a first page of 20 posts. Got %+v", searchStore.query)
+		}
+		if searchStore.query.ViewerId != 21 {
+			t.Errorf("Expected the search to be run for user 21. Got %d", searchStore.query.ViewerId)
+		}
+
+		var results search.Results
+		decodeData(t, rr, &results)
+		if len(results.Results) != 20 || results.NextCursor == "" {
+			t.Fatalf("Expected a full page and a cursor. Got %d results, cursor %q", len(results.Results), results.NextCursor)
+		}
+
+		rr = send(http.MethodGet, "/v1/search?type=users&limit=5&q=ana&cursor="+results.NextCursor, "")
+		assertResponseCode(t, http.StatusOK, rr.Code)
+
+		after := searchStore.query.After
+		if searchStore.query.Type != store.SearchUsers || searchStore.query.Limit != 5 || after == nil || after.ID != 81 || after.Rank != 0.5 {
+			t.Errorf("Expected 5 users after result 81. Got %+v (after %+v)", searchStore.query, after)
+		}
+	})
+
+	t.Run("should leave out comments of blocked and muted users", func(t *testing.T) {
+		mockApp.store.BlocksRepository = &hidingBlocksStore{}
+		mockApp.search = &commentsIndex{authorIds: []int64{7, 8, 9}}
+		defer func() {
+			mockApp.store.BlocksRepository = &store.MockBlocksStore{}
+			mockApp.search = search.NewPostgresIndex(searchStore)
+		}()
+
+		rr := send(http.MethodGet, "/v1/search?type=comments&q=go", "")
+		assertResponseCode(t, http.StatusOK, rr.Code)
+
+		var results search.Results
+		decodeData(t, rr, &results)
+		if len(results.Results) != 1 || results.Results[0].UserId != 7 {
+			t.Errorf("Expected only the comment of user 7. Got %+v", results.Results)
+		}
+	})
+
+	t.Run("should validate the query", func(t *testing.T) {
+		for _, query := range []string{
+			"",
+			"q=",
+			"q=" + strings.Repeat("a", 201),
+			"q=go&type=tags",
+			"q=go&limit=abc",
+			"q=go&limit=0",
+			"q=go&limit=51",
+			"q=go&cursor=bm9wZQ",
+		} {
+			rr := send(http.MethodGet, "/v1/search?"+query, "")
+			if rr.Code != http.StatusBadRequest {
+				t.Errorf("Expected response 400 for %q. Got %d", query, rr.Code)
+			}
+		}
+	})
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/elhambadri2411/social/internal/highlight"
+	"github.com/elhambadri2411/social/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+type createSnippetPayload struct {
+	Filename string `json:"filename" validate:"required,max=255,excludesall=/\\"`
+	Language string `json:"language" validate:"omitempty,max=50"`
+	Body     string `json:"body" validate:"required,max=65536"`
+}
+
+// `buildSnippets` validates snippet payloads and highlights their bodies
+func buildSnippets(payloads []createSnippetPayload) ([]store.Snippet, error) {
+	snippets := make([]store.Snippet, 0, len(payloads))
+	seen := make(map[string]bool, len(payloads))
+
+	for _, payload := range payloads {
+		if seen[payload.Filename] {
+			return nil, fmt.Errorf("duplicate snippet filename %q", payload.Filename)
+		}
+		seen[payload.Filename] = true
+
+		bodyHTML, language, err := highlight.Highlight(payload.Filename, strings.ToLower(payload.Language), payload.Body)
+		if err != nil {
+			return nil, err
+		}
+
+		snippets = append(snippets, store.Snippet{
+			Filename: payload.Filename,
+			Language: language,
+			Body:     payload.Body,
+			BodyHTML: bodyHTML,
+		})
+	}
+
+	return snippets, nil
+}
+
+// getRawSnippetHandler godoc
+//
+//	@Summary		Downloads a snippet
+//	@Description	Returns the raw body of a code snippet attached to a post
+//	@Tags			posts
+//	@Produce		plain
+//	@Param			id			path		int		true	"Post ID"
+//	@Param			snippetId	path		int		true	"Snippet ID"
+//	@Success		200			{string}	string	"Raw snippet"
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{id}/snippets/{snippetId}/raw [get]
+func (app *application) getRawSnippetHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+
+	snippetId, err := strconv.ParseInt(chi.URLParam(r, "snippetId"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	snippet, err := app.store.SnippetsRepository.GetById(r.Context(), post.ID, snippetId)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": snippet.Filename}))
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write([]byte(snippet.Body)); err != nil {
+		app.logger.Warnw("error serving snippet", "path", r.URL.Path, "error", err.Error())
+	}
+}
+
+// getSnippetStylesheetHandler godoc
+//
+//	@Summary		Snippet stylesheet
+//	@Description	CSS for the classes used in highlighted snippets (`body_html`)
+//	@Tags			posts
+//	@Produce		text/css
+//	@Success		200	{string}	string	"Stylesheet"
+//	@Router			/snippets/highlight.css [get]
+func (app *application) getSnippetStylesheetHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/css; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+
+	if err := highlight.Stylesheet(w); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
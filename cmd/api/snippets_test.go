@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/elhambadri2411/social/internal/highlight"
+	"github.com/elhambadri2411/social/internal/store"
+)
+
+// `highlightedSnippetStore` serves a snippet with its highlighted body, as stored on creation
+type highlightedSnippetStore struct {
+	store.MockSnippetStore
+}
+
+func (s *highlightedSnippetStore) GetByPostId(ctx context.Context, postId int64) ([]store.Snippet, error) {
+	snippets, err := buildSnippets([]createSnippetPayload{{Filename: "main.go", Body: "package main\n"}})
+	if err != nil {
+		return nil, err
+	}
+	snippets[0].ID = 1
+	snippets[0].PostId = postId
+
+	return snippets, nil
+}
+
+func TestSnippets(t *testing.T) {
+	mockApp, send := newTestServer(t)
+	mockApp.store.SnippetsRepository = &highlightedSnippetStore{}
+
+	t.Run("should download the raw snippet", func(t *testing.T) {
+		rr := send(http.MethodGet, "/v1/posts/1/snippets/1/raw", "")
+		assertResponseCode(t, http.StatusOK, rr.Code)
+
+		if body := rr.Body.String(); body != "package main\n" {
+			t.Errorf("Expected the raw body. Got %q", body)
+		}
+		if contentType := rr.Header().Get("Content-Type"); contentType != "text/plain; charset=utf-8" {
+			t.Errorf("Expected a plain text response. Got %s", contentType)
+		}
+		if disposition := rr.Header().Get("Content-Disposition"); disposition != "attachment; filename=main.go" {
+			t.Errorf("Expected main.go as attachment. Got %s", disposition)
+		}
+		if rr.Header().Get("X-Content-Type-Options") != "nosniff" {
+			t.Error("Expected nosniff")
+		}
+
+		assertResponseCode(t, http.StatusBadRequest, send(http.MethodGet, "/v1/posts/1/snippets/abc/raw", "").Code)
+	})
+
+	t.Run("should highlight snippets of new posts", func(t *testing.T) {
+		rr := send(http.MethodPost, "/v1/posts", `{"title":"t","content":"c","snippets":[{"filename":"main.go","body":"package <main>\n"}]}`)
+		assertResponseCode(t, http.StatusCreated, rr.Code)
+
+		var post store.Post
+		decodeData(t, rr, &post)
+		if len(post.Snippets) != 1 {
+			t.Fatalf("Expected one snippet. Got %+v", post.Snippets)
+		}
+
+		snippet := post.Snippets[0]
+		if snippet.Language != "go" || snippet.Body != "package <main>\n" {
+			t.Errorf("Expected the go snippet as sent. Got %+v", snippet)
+		}
+		if !strings.Contains(snippet.BodyHTML, `class="`+highlight.ClassPrefix) || strings.Contains(snippet.BodyHTML, "<main>") {
+			t.Errorf("Expected highlighted and escaped HTML. Got %s", snippet.BodyHTML)
+		}
+	})
+
+	t.Run("should reject invalid snippets", func(t *testing.T) {
+		for _, snippets := range []string{
+			`[{"filename":"../main.go","body":"x"}]`,
+			`[{"filename":"main.go","body":""}]`,
+			`[{"filename":"main.go","body":"x"},{"filename":"main.go","body":"y"}]`,
+		} {
+			rr := send(http.MethodPost, "/v1/posts", `{"title":"t","content":"c","snippets":`+snippets+`}`)
+			assertResponseCode(t, http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("should return highlighted snippets with the post", func(t *testing.T) {
+		rr := send(http.MethodGet, "/v1/posts/1", "")
+		assertResponseCode(t, http.StatusOK, rr.Code)
+
+		var post store.Post
+		decodeData(t, rr, &post)
+		if len(post.Snippets) != 1 || !strings.Contains(post.Snippets[0].BodyHTML, `class="`+highlight.ClassPrefix) {
+			t.Errorf("Expected the highlighted snippet. Got %+v", post.Snippets)
+		}
+	})
+
+	t.Run("should serve the stylesheet for the highlighted classes", func(t *testing.T) {
+		rr := send(http.MethodGet, "/v1/snippets/highlight.css", "")
+		assertResponseCode(t, http.StatusOK, rr.Code)
+
+		if !strings.Contains(rr.Body.String(), "."+highlight.ClassPrefix) {
+			t.Errorf("Expected rules for the highlighted classes")
+		}
+	})
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/elhambadri2411/social/internal/store"
+	"github.com/elhambadri2411/social/internal/stream"
+)
+
+// `streamConfig` holds settings for live events
+type streamConfig struct {
+	backend   string        // "memory" (single instance) or "redis"
+	heartbeat time.Duration // how often an idle stream gets a comment, so proxies keep it open
+	broker    stream.Config
+}
+
+// `commentEvent` tells the author of a post about a new comment
+type commentEvent struct {
+	PostId  int64          `json:"post_id"`
+	Comment *store.Comment `json:"comment"`
+}
+
+// getStreamHandler godoc
+//
+//	@Summary		Streams live events
+//	@Description	Streams events for the authenticated user as Server-Sent Events: `post` when a followed user publishes,
+//	@Description	`comment` when someone comments on your post and `notification` for each new notification. Each event has
+//	@Description	an `id`, reconnect with it in the `Last-Event-ID` header to get the events missed in between, as long
+//	@Description	as they are recent. Idle streams get a comment line every few seconds. There are no reaction events,
+//	@Description	the API doesn't have reactions.
+//	@Tags			feed
+//	@Produce		text/event-stream
+//	@Param			Last-Event-ID	header		int		false	"Id of the last event received"
+//	@Success		200				{string}	string	"Event stream"
+//	@Failure		400				{object}	error
+//	@Failure		500				{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/stream [get]
+func (app *application) getStreamHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	var lastEventId int64
+	if header := r.Header.Get("Last-Event-ID"); header != "" {
+		id, err := strconv.ParseInt(header, 10, 64)
+		if err != nil || id < 0 {
+			app.badRequestResponse(w, r, errors.New("Last-Event-ID must be an event id"))
+			return
+		}
+		lastEventId = id
+	}
+
+	ctx := r.Context()
+
+	events, err := app.stream.Subscribe(ctx, user.ID, lastEventId)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	// the stream lasts as long as the client stays, not as long as the write timeout
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	heartbeat := time.NewTicker(app.config.stream.heartbeat)
+	defer heartbeat.Stop()
+
+	write := func(format string, args ...any) bool {
+		if _, err := fmt.Fprintf(w, format, args...); err != nil {
+			return false
+		}
+		return rc.Flush() == nil
+	}
+
+	if !write("retry: %d\n\n", (5 * time.Second).Milliseconds()) {
+		return
+	}
+
+	for {
+		select {
+		case event, ok := <-events:
+			// closed when the broker drops a slow client or shuts down, the client reconnects
+			if !ok {
+				return
+			}
+			if !write("id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, event.Data) {
+				return
+			}
+		case <-heartbeat.C:
+			if !write(": heartbeat\n\n") {
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// `streamPost` sends a new post to the followers of its author who didn't mute them.
+// Authors with too many followers to push to aren't streamed, like in home timelines.
+func (app *application) streamPost(post *store.Post) {
+	app.background(func() {
+		ctx := context.Background()
+
+		followerIds, err := app.store.TimelinesRepository.GetFollowerIds(ctx, post.UserId, app.config.timelines.FanOutLimit+1)
+		if err != nil {
+			app.logger.Warnw("failed to stream post", "post", post.ID, "error", err.Error())
+			return
+		}
+		if len(followerIds) == 0 || len(followerIds) > app.config.timelines.FanOutLimit {
+			return
+		}
+
+		app.publishEvent(ctx, post.UserId, followerIds, stream.EventPost, post)
+	})
+}
+
+// `streamComment` sends a new comment to the author of the post
+func (app *application) streamComment(post *store.Post, comment *store.Comment) {
+	if post.UserId == comment.UserId {
+		return
+	}
+
+	app.background(func() {
+		app.publishEvent(context.Background(), comment.UserId, []int64{post.UserId}, stream.EventComment, commentEvent{
+			PostId:  post.ID,
+			Comment: comment,
+		})
+	})
+}
+
+// `publishEvent` publishes an event caused by `actorId` to the `userIds` who didn't mute them.
+// Live events are a convenience on top of the API, failures are only logged.
+func (app *application) publishEvent(ctx context.Context, actorId int64, userIds []int64, eventType string, data any) {
+	muterIds, err := app.store.BlocksRepository.GetMuters(ctx, actorId, userIds)
+	if err != nil {
+		app.logger.Warnw("failed to publish event", "type", eventType, "error", err.Error())
+		return
+	}
+	userIds = slices.DeleteFunc(slices.Clone(userIds), func(userId int64) bool {
+		return slices.Contains(muterIds, userId)
+	})
+	if len(userIds) == 0 {
+		return
+	}
+
+	if err := app.stream.Publish(ctx, userIds, eventType, data); err != nil {
+		app.logger.Warnw("failed to publish event", "type", eventType, "error", err.Error())
+	}
+}
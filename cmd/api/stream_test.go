@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/elhambadri2411/social/internal/store/cache"
+	"github.com/elhambadri2411/social/internal/stream"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestStream(t *testing.T) {
+	mockApp := newTestApplication(t)
+	mockMux := mockApp.mount()
+
+	testToken, _ := mockApp.authenticator.GenerateToken(nil)
+
+	mockCacheStore := mockApp.cache.UsersCache.(*cache.MockUsersCacheRedis)
+	mockCacheStore.On("Get", mock.Anything, mock.Anything).Return(nil, nil)
+	mockCacheStore.On("Set", mock.Anything, mock.Anything).Return(nil)
+
+	// `open` opens the stream of the test user for `duration` and returns what it received
+	open := func(lastEventId string, duration time.Duration) (int, string) {
+		ctx, cancel := context.WithTimeout(context.Background(), duration)
+		defer cancel()
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, "/v1/stream", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		if lastEventId != "" {
+			req.Header.Set("Last-Event-ID", lastEventId)
+		}
+		rr := execRequest(req, mockMux)
+		return rr.Code, rr.Body.String()
+	}
+
+	t.Run("should resume after the last event id", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		events, err := mockApp.stream.Subscribe(ctx, 21, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, title := range []string{"first", "second"} {
+			if err := mockApp.stream.Publish(ctx, []int64{21}, "post", map[string]string{"title": title}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		first, second := <-events, <-events
+		cancel()
+
+		code, body := open(strconv.FormatInt(first.ID, 10), 100*time.Millisecond)
+		assertResponseCode(t, http.StatusOK, code)
+
+		if !strings.HasPrefix(body, "retry: ") {
+			t.Errorf("expected a retry interval first, got %q", body)
+		}
+		expected := "id: " + strconv.FormatInt(second.ID, 10) + "\nevent: post\ndata: {\"title\":\"second\"}\n\n"
+		if !strings.Contains(body, expected) {
+			t.Errorf("expected %q in %q", expected, body)
+		}
+		if strings.Contains(body, "first") {
+			t.Errorf("expected events up to Last-Event-ID to be skipped, got %q", body)
+		}
+	})
+
+	t.Run("should send heartbeats on an idle stream", func(t *testing.T) {
+		mockApp.config.stream.heartbeat = 10 * time.Millisecond
+		defer func() { mockApp.config.stream.heartbeat = time.Second }()
+
+		code, body := open("", 100*time.Millisecond)
+		assertResponseCode(t, http.StatusOK, code)
+
+		if !strings.Contains(body, ": heartbeat\n\n") {
+			t.Errorf("expected a heartbeat, got %q", body)
+		}
+	})
+
+	t.Run("should reject an invalid Last-Event-ID", func(t *testing.T) {
+		code, _ := open("abc", time.Second)
+		assertResponseCode(t, http.StatusBadRequest, code)
+	})
+
+	t.Run("should fail to subscribe once the broker is closed", func(t *testing.T) {
+		mockApp.stream = stream.NewLocal(mockApp.config.stream.broker)
+		mockApp.stream.Close()
+
+		code, _ := open("", time.Second)
+		assertResponseCode(t, http.StatusInternalServerError, code)
+	})
+}
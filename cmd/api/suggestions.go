@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/elhambadri2411/social/internal/store"
+)
+
+// GetSuggestions godoc
+//
+//	@Summary		Suggests users to follow
+//	@Description	Ranks accounts followed by the people you follow and accounts posting about your tags,
+//	@Description	favouring recently active ones. Followed, requested, blocked and muted users are left out.
+//	@Description	Suggestions are cached and refreshed periodically, following, blocking or muting someone clears them.
+//	@Tags			users
+//	@Produce		json
+//	@Param			limit	query		int	false	"Limit (default 10)"
+//	@Success		200		{object}	[]store.Suggestion
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/suggestions [get]
+func (app *application) getSuggestionsHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+	ctx := r.Context()
+
+	limit := 10
+	if param := r.URL.Query().Get("limit"); param != "" {
+		parsed, err := strconv.Atoi(param)
+		if err != nil || parsed < 1 || parsed > app.config.suggestions.size {
+			app.badRequestResponse(w, r, fmt.Errorf("limit must be between 1 and %d", app.config.suggestions.size))
+			return
+		}
+		limit = parsed
+	}
+
+	// keeps the user's suggestions warm in the refresh job, a cache outage shouldn't fail the request
+	if err := app.cache.SuggestionsCache.Touch(ctx, user.ID); err != nil {
+		app.logger.Warnw("failed to record suggestions request", "user", user.ID, "error", err.Error())
+	}
+
+	suggestions, err := app.cache.SuggestionsCache.Get(ctx, user.ID)
+	if err != nil {
+		app.logger.Warnw("failed to read cached suggestions", "user", user.ID, "error", err.Error())
+	}
+	if suggestions == nil {
+		suggestions, err = app.computeSuggestions(ctx, user.ID)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	if len(suggestions) > limit {
+		suggestions = suggestions[:limit]
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, suggestions); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// `computeSuggestions` ranks suggestions for a user from the database and caches them
+func (app *application) computeSuggestions(ctx context.Context, userId int64) ([]store.Suggestion, error) {
+	suggestions, err := app.store.SuggestionsRepository.GetForUser(ctx, userId, app.config.suggestions.size)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range suggestions {
+		suggestions[i].AvatarURL, suggestions[i].AvatarThumbnailURL = app.avatarURLs(suggestions[i].AvatarKey, suggestions[i].AvatarThumbnailKey)
+	}
+
+	if err := app.cache.SuggestionsCache.Set(ctx, userId, suggestions); err != nil {
+		app.logger.Warnw("failed to cache suggestions", "user", userId, "error", err.Error())
+	}
+
+	return suggestions, nil
+}
+
+// `forgetSuggestions` drops the cached suggestions of `userId` after they followed, blocked or
+// muted someone, so that user isn't suggested until the refresh job runs
+func (app *application) forgetSuggestions(ctx context.Context, userId int64) {
+	if err := app.cache.SuggestionsCache.Delete(ctx, userId); err != nil {
+		app.logger.Warnw("failed to clear cached suggestions", "user", userId, "error", err.Error())
+	}
+}
+
+// `refreshSuggestions` recomputes the suggestions of users who asked for them recently,
+// so they are served from the cache and reflect new follows and posts
+func (app *application) refreshSuggestions(ctx context.Context) error {
+	userIds, err := app.cache.SuggestionsCache.Requesters(ctx, time.Now().Add(-app.config.suggestions.activeWindow))
+	if err != nil {
+		return err
+	}
+
+	refreshed := 0
+	for _, userId := range userIds {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		// a failed user keeps their cached suggestions until the next run
+		if _, err := app.computeSuggestions(ctx, userId); err != nil {
+			app.logger.Errorw("error refreshing suggestions", "user_id", userId, "error", err.Error())
+			continue
+		}
+		refreshed++
+	}
+
+	if refreshed > 0 {
+		app.logger.Infow("refreshed suggestions", "users", refreshed)
+	}
+
+	return nil
+}
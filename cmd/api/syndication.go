@@ -0,0 +1,244 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/elhambadri2411/social/internal/store"
+	"github.com/elhambadri2411/social/internal/syndication"
+	"github.com/go-chi/chi/v5"
+)
+
+// posts listed in Atom and RSS feeds
+const syndicationFeedSize = 20
+
+const (
+	formatAtom = "atom"
+	formatRSS  = "rss"
+)
+
+// GetUserAtomFeed godoc
+//
+//	@Summary		Fetches the Atom feed of a user
+//	@Description	Fetches the latest posts of a public account as an Atom 1.0 feed, for feed readers. No authentication
+//	@Description	is needed. Supports conditional requests with If-None-Match and If-Modified-Since.
+//	@Tags			feed
+//	@Produce		application/atom+xml
+//	@Param			username			path		string	true	"Username"
+//	@Param			If-None-Match		header		string	false	"ETag of a cached copy"
+//	@Param			If-Modified-Since	header		string	false	"Last-Modified of a cached copy"
+//	@Success		200					{string}	string	"Atom feed"
+//	@Success		304					{string}	string	"Not modified"
+//	@Failure		404					{object}	error
+//	@Failure		500					{object}	error
+//	@Router			/users/{username}/feed.atom [get]
+func (app *application) getUserAtomFeedHandler(w http.ResponseWriter, r *http.Request) {
+	app.userSyndicationFeed(w, r, formatAtom)
+}
+
+// GetUserRSSFeed godoc
+//
+//	@Summary		Fetches the RSS feed of a user
+//	@Description	Fetches the latest posts of a public account as an RSS 2.0 feed, for feed readers. No authentication
+//	@Description	is needed. Supports conditional requests with If-None-Match and If-Modified-Since.
+//	@Tags			feed
+//	@Produce		application/rss+xml
+//	@Param			username			path		string	true	"Username"
+//	@Param			If-None-Match		header		string	false	"ETag of a cached copy"
+//	@Param			If-Modified-Since	header		string	false	"Last-Modified of a cached copy"
+//	@Success		200					{string}	string	"RSS feed"
+//	@Success		304					{string}	string	"Not modified"
+//	@Failure		404					{object}	error
+//	@Failure		500					{object}	error
+//	@Router			/users/{username}/feed.rss [get]
+func (app *application) getUserRSSFeedHandler(w http.ResponseWriter, r *http.Request) {
+	app.userSyndicationFeed(w, r, formatRSS)
+}
+
+// GetTagAtomFeed godoc
+//
+//	@Summary		Fetches the Atom feed of a tag
+//	@Description	Fetches the latest posts of public accounts carrying a tag (aliases are resolved) as an Atom 1.0 feed.
+//	@Description	No authentication is needed. Supports conditional requests with If-None-Match and If-Modified-Since.
+//	@Tags			tags
+//	@Produce		application/atom+xml
+//	@Param			tag					path		string	true	"Tag name or alias"
+//	@Param			If-None-Match		header		string	false	"ETag of a cached copy"
+//	@Param			If-Modified-Since	header		string	false	"Last-Modified of a cached copy"
+//	@Success		200					{string}	string	"Atom feed"
+//	@Success		304					{string}	string	"Not modified"
+//	@Failure		404					{object}	error
+//	@Failure		500					{object}	error
+//	@Router			/tags/{tag}/feed.atom [get]
+func (app *application) getTagAtomFeedHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	tag, err := app.store.TagsRepository.GetByName(ctx, chi.URLParam(r, "tag"))
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	posts, err := app.store.PostsRepository.GetPublic(ctx, 0, tag.Name, syndicationFeedSize, nil)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	feed := syndication.Feed{
+		ID:      app.config.frontendUrl + "/tags/" + url.PathEscape(tag.Name),
+		Title:   "Posts tagged #" + tag.Name,
+		Link:    app.config.frontendUrl + "/tags/" + url.PathEscape(tag.Name),
+		Self:    app.config.apiUrl + r.URL.Path,
+		BaseURL: app.config.frontendUrl,
+	}
+	app.serveSyndicationFeed(w, r, formatAtom, feed, posts)
+}
+
+// `userSyndicationFeed` serves the feed of the user named in the path. Private accounts and
+// accounts being deleted have no feed, old usernames redirect to the feed of the new one.
+func (app *application) userSyndicationFeed(w http.ResponseWriter, r *http.Request, format string) {
+	ctx := r.Context()
+	username := chi.URLParam(r, "username")
+
+	userId, err := app.store.UsersRepository.ResolveUsername(ctx, username)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	user, err := app.getUser(ctx, userId)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if user.IsPrivate || !user.IsActive || user.DeletionScheduledAt != nil {
+		app.notFoundResponse(w, r, store.ErrNotFound)
+		return
+	}
+
+	if !strings.EqualFold(user.Username, username) {
+		http.Redirect(w, r, "/v1/users/"+url.PathEscape(user.Username)+"/feed."+format, http.StatusMovedPermanently)
+		return
+	}
+
+	posts, err := app.store.PostsRepository.GetPublic(ctx, user.ID, "", syndicationFeedSize, nil)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	feed := syndication.Feed{
+		// the id outlives username changes
+		ID:      app.config.frontendUrl + "/users/" + strconv.FormatInt(user.ID, 10),
+		Title:   "Posts by " + user.Username,
+		Link:    app.config.frontendUrl + "/users/" + url.PathEscape(user.Username),
+		Self:    app.config.apiUrl + r.URL.Path,
+		BaseURL: app.config.frontendUrl,
+	}
+	app.serveSyndicationFeed(w, r, format, feed, posts)
+}
+
+// `serveSyndicationFeed` renders `posts` in `format`, or answers 304 when the reader's copy is current
+func (app *application) serveSyndicationFeed(
+	w http.ResponseWriter,
+	r *http.Request,
+	format string,
+	feed syndication.Feed,
+	feedPosts []*store.FeedPost,
+) {
+	posts := make([]*store.Post, 0, len(feedPosts))
+	for _, feedPost := range feedPosts {
+		posts = append(posts, &feedPost.Post)
+	}
+
+	etag := syndicationETag(format, feed, posts)
+	lastModified := syndication.Updated(posts)
+
+	w.Header().Set("ETag", etag)
+	if !lastModified.IsZero() {
+		w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if notModified(r, etag, lastModified) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	var (
+		body        []byte
+		err         error
+		contentType string
+	)
+	switch format {
+	case formatRSS:
+		body, err = syndication.RSS(feed, posts)
+		contentType = syndication.RSSContentType
+	default:
+		body, err = syndication.Atom(feed, posts)
+		contentType = syndication.AtomContentType
+	}
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(body); err != nil {
+		app.logger.Warnw("error serving feed", "path", r.URL.Path, "error", err.Error())
+	}
+}
+
+// `syndicationETag` changes whenever a post is added, edited or removed, or the feed is renamed
+func syndicationETag(format string, feed syndication.Feed, posts []*store.Post) string {
+	hash := sha256.New()
+	hash.Write([]byte(format + "\n" + feed.Title + "\n"))
+	for _, post := range posts {
+		hash.Write([]byte(strconv.FormatInt(post.ID, 10) + ":" + strconv.FormatInt(post.Version, 10) + "\n"))
+	}
+
+	return `W/"` + hex.EncodeToString(hash.Sum(nil)[:16]) + `"`
+}
+
+// `notModified` evaluates If-None-Match, or If-Modified-Since when there is no If-None-Match
+// (RFC 9110 section 13.2.2)
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		return etagMatches(ifNoneMatch, etag, true)
+	}
+
+	if lastModified.IsZero() {
+		return false
+	}
+	ifModifiedSince, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+
+	// Last-Modified only has a precision of seconds
+	return !lastModified.Truncate(time.Second).After(ifModifiedSince)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/elhambadri2411/social/internal/store/cache"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestSyndicationFeeds(t *testing.T) {
+	mockApp := newTestApplication(t)
+	mockMux := mockApp.mount()
+
+	mockCacheStore := mockApp.cache.UsersCache.(*cache.MockUsersCacheRedis)
+	mockCacheStore.On("Get", mock.Anything, mock.Anything).Return(nil, nil)
+	mockCacheStore.On("Set", mock.Anything, mock.Anything).Return(nil)
+
+	get := func(url string, headers map[string]string) *http.Response {
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+		return execRequest(req, mockMux).Result()
+	}
+
+	t.Run("should serve feeds without authentication", func(t *testing.T) {
+		tests := []struct {
+			url         string
+			contentType string
+			root        string
+		}{
+			{"/v1/users/user1/feed.atom", "application/atom+xml; charset=utf-8", `<feed xmlns="http://www.w3.org/2005/Atom">`},
+			{"/v1/users/user1/feed.rss", "application/rss+xml; charset=utf-8", `<rss version="2.0"`},
+			{"/v1/tags/golang/feed.atom", "application/atom+xml; charset=utf-8", `<feed xmlns="http://www.w3.org/2005/Atom">`},
+		}
+
+		for _, tt := range tests {
+			res := get(tt.url, nil)
+			assertResponseCode(t, http.StatusOK, res.StatusCode)
+
+			if contentType := res.Header.Get("Content-Type"); contentType != tt.contentType {
+				t.Errorf("unexpected Content-Type %q for %s", contentType, tt.url)
+			}
+			if res.Header.Get("ETag") == "" || res.Header.Get("Last-Modified") != "Tue, 02 Sep 2025 10:00:00 GMT" {
+				t.Errorf("missing validators for %s: %v", tt.url, res.Header)
+			}
+
+			body, err := io.ReadAll(res.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(body), tt.root) {
+				t.Errorf("unexpected document for %s\n%s", tt.url, body)
+			}
+			if !strings.Contains(string(body), "Generics &amp; &lt;interfaces&gt;") {
+				t.Errorf("expected an escaped title for %s\n%s", tt.url, body)
+			}
+		}
+	})
+
+	t.Run("should answer conditional requests", func(t *testing.T) {
+		etag := get("/v1/users/user1/feed.atom", nil).Header.Get("ETag")
+
+		res := get("/v1/users/user1/feed.atom", map[string]string{"If-None-Match": etag})
+		assertResponseCode(t, http.StatusNotModified, res.StatusCode)
+
+		res = get("/v1/users/user1/feed.rss", map[string]string{"If-None-Match": etag})
+		assertResponseCode(t, http.StatusOK, res.StatusCode)
+
+		res = get("/v1/users/user1/feed.atom", map[string]string{"If-Modified-Since": "Tue, 02 Sep 2025 10:00:00 GMT"})
+		assertResponseCode(t, http.StatusNotModified, res.StatusCode)
+
+		res = get("/v1/users/user1/feed.atom", map[string]string{"If-Modified-Since": "Mon, 01 Sep 2025 10:00:00 GMT"})
+		assertResponseCode(t, http.StatusOK, res.StatusCode)
+
+		// If-None-Match takes precedence over If-Modified-Since
+		res = get("/v1/users/user1/feed.atom", map[string]string{
+			"If-None-Match":     `W/"stale"`,
+			"If-Modified-Since": "Tue, 02 Sep 2025 10:00:00 GMT",
+		})
+		assertResponseCode(t, http.StatusOK, res.StatusCode)
+	})
+
+	t.Run("should redirect old usernames", func(t *testing.T) {
+		res := get("/v1/users/old-name/feed.rss", nil)
+		assertResponseCode(t, http.StatusMovedPermanently, res.StatusCode)
+
+		if location := res.Header.Get("Location"); location != "/v1/users/user1/feed.rss" {
+			t.Errorf("unexpected redirect to %q", location)
+		}
+	})
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/elhambadri2411/social/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+type tagKey string
+
+const tagCtx tagKey = "tag"
+
+const (
+	defaultTrendingWindow = time.Hour * 24
+	maxTrendingWindow     = time.Hour * 24 * 30
+)
+
+// GetTag godoc
+//
+//	@Summary		Fetches a tag
+//	@Description	Fetches a tag by name or alias
+//	@Tags			tags
+//	@Produce		json
+//	@Param			tag	path		string	true	"Tag name or alias"
+//	@Success		200	{object}	store.Tag
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/tags/{tag} [get]
+func (app *application) getTagHandler(w http.ResponseWriter, r *http.Request) {
+	if err := app.jsonResponse(w, http.StatusOK, getTagFromCtx(r)); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetTagPosts godoc
+//
+//	@Summary		Lists posts with a tag
+//	@Description	Lists posts carrying a tag (aliases are resolved), newest first
+//	@Tags			tags
+//	@Produce		json
+//	@Param			tag		path		string	true	"Tag name or alias"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			offset	query		int		false	"Offset"
+//	@Success		200		{object}	[]store.FeedPost
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/tags/{tag}/posts [get]
+func (app *application) getTagPostsHandler(w http.ResponseWriter, r *http.Request) {
+	tag := getTagFromCtx(r)
+
+	pfq := store.PaginatedFeedQuery{
+		Limit:  20,
+		Offset: 0,
+		Sort:   "desc",
+	}
+
+	pfq, err := pfq.Parse(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(pfq); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	posts, err := app.store.PostsRepository.GetByTag(r.Context(), tag.Name, getUserFromCtx(r).ID, pfq.Limit, pfq.Offset)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, posts); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// FollowTag godoc
+//
+//	@Summary		Follows a tag
+//	@Description	Follows a tag, its posts show up in the user feed
+//	@Tags			tags
+//	@Produce		json
+//	@Param			tag	path		string	true	"Tag name or alias"
+//	@Success		200	{string}	string	"Tag followed"
+//	@Failure		404	{object}	error
+//	@Failure		409	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/tags/{tag}/follow [put]
+func (app *application) followTagHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+	tag := getTagFromCtx(r)
+
+	if err := app.store.TagsRepository.Follow(r.Context(), tag.ID, user.ID); err != nil {
+		switch err {
+		case store.ErrConflict:
+			app.conflictError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, nil); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// UnfollowTag godoc
+//
+//	@Summary		Unfollows a tag
+//	@Description	Unfollows a tag
+//	@Tags			tags
+//	@Produce		json
+//	@Param			tag	path		string	true	"Tag name or alias"
+//	@Success		200	{string}	string	"Tag unfollowed"
+//	@Failure		404	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/tags/{tag}/unfollow [put]
+func (app *application) unfollowTagHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+	tag := getTagFromCtx(r)
+
+	if err := app.store.TagsRepository.Unfollow(r.Context(), tag.ID, user.ID); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, nil); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetTrendingTags godoc
+//
+//	@Summary		Lists trending tags
+//	@Description	Ranks tags by distinct authors, then uses, over a sliding time window. Only posts of public,
+//	@Description	active accounts are counted.
+//	@Tags			tags
+//	@Produce		json
+//	@Param			window	query		string	false	"Window as a Go duration, e.g. 6h (default 24h, max 720h)"
+//	@Param			limit	query		int		false	"Limit (default 10, max 50)"
+//	@Success		200		{object}	[]store.TrendingTag
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/tags/trending [get]
+func (app *application) getTrendingTagsHandler(w http.ResponseWriter, r *http.Request) {
+	window := defaultTrendingWindow
+	if param := r.URL.Query().Get("window"); param != "" {
+		parsed, err := time.ParseDuration(param)
+		if err != nil || parsed <= 0 || parsed > maxTrendingWindow {
+			app.badRequestResponse(w, r, fmt.Errorf("window must be a duration between 0 and %s", maxTrendingWindow))
+			return
+		}
+		window = parsed
+	}
+
+	limit := 10
+	if param := r.URL.Query().Get("limit"); param != "" {
+		parsed, err := strconv.Atoi(param)
+		if err != nil || parsed < 1 || parsed > 50 {
+			app.badRequestResponse(w, r, errors.New("limit must be between 1 and 50"))
+			return
+		}
+		limit = parsed
+	}
+
+	trending, err := app.store.TagsRepository.GetTrending(r.Context(), window, limit)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, trending); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetFollowedTags godoc
+//
+//	@Summary		Lists followed tags
+//	@Description	Lists the tags followed by the authenticated user
+//	@Tags			tags
+//	@Produce		json
+//	@Success		200	{object}	[]store.Tag
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/tags [get]
+func (app *application) getFollowedTagsHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	followed, err := app.store.TagsRepository.GetFollowed(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, followed); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+func (app *application) tagsContextMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		tag, err := app.store.TagsRepository.GetByName(ctx, chi.URLParam(r, "tag"))
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				app.notFoundResponse(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		ctx = context.WithValue(ctx, tagCtx, tag)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func getTagFromCtx(r *http.Request) *store.Tag {
+	tag := r.Context().Value(tagCtx)
+	return tag.(*store.Tag)
+}
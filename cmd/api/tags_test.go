@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/elhambadri2411/social/internal/store"
+	"github.com/elhambadri2411/social/internal/tags"
+)
+
+// `recordingTagStore` knows the `go` tag and its `golang` alias and keeps follows in memory
+type recordingTagStore struct {
+	store.MockTagStore
+	follows        map[int64]bool
+	trendingWindow time.Duration
+	trendingLimit  int
+}
+
+func (s *recordingTagStore) GetByName(ctx context.Context, name string) (*store.Tag, error) {
+	switch tags.Normalize(name) {
+	case "go", "golang":
+		return &store.Tag{ID: 1, Name: "go", Aliases: []string{"golang"}, FollowersCount: len(s.follows)}, nil
+	default:
+		return nil, store.ErrNotFound
+	}
+}
+
+func (s *recordingTagStore) Follow(ctx context.Context, tagId int64, userId int64) error {
+	if s.follows[userId] {
+		return store.ErrConflict
+	}
+	s.follows[userId] = true
+	return nil
+}
+
+func (s *recordingTagStore) Unfollow(ctx context.Context, tagId int64, userId int64) error {
+	if !s.follows[userId] {
+		return store.ErrNotFound
+	}
+	delete(s.follows, userId)
+	return nil
+}
+
+func (s *recordingTagStore) GetTrending(ctx context.Context, window time.Duration, limit int) ([]store.TrendingTag, error) {
+	s.trendingWindow = window
+	s.trendingLimit = limit
+	return []store.TrendingTag{{Name: "go", Authors: 3, Uses: 5, PreviousUses: 1}}, nil
+}
+
+// `taggedPostStore` records which tag page was read
+type taggedPostStore struct {
+	store.MockPostStore
+	tag    string
+	viewer int64
+	limit  int
+}
+
+func (s *taggedPostStore) GetByTag(ctx context.Context, tag string, viewerId int64, limit int, offset int) ([]*store.FeedPost, error) {
+	s.tag, s.viewer, s.limit = tag, viewerId, limit
+	return []*store.FeedPost{{Post: store.Post{ID: 7, Tags: []string{tag}}}}, nil
+}
+
+func TestTags(t *testing.T) {
+	mockApp, send := newTestServer(t)
+
+	tagStore := &recordingTagStore{follows: make(map[int64]bool)}
+	mockApp.store.TagsRepository = tagStore
+	posts := &taggedPostStore{}
+	mockApp.store.PostsRepository = posts
+
+	t.Run("should resolve aliases on the tag page", func(t *testing.T) {
+		rr := send(http.MethodGet, "/v1/tags/Golang", "")
+		assertResponseCode(t, http.StatusOK, rr.Code)
+
+		var tag store.Tag
+		decodeData(t, rr, &tag)
+		if tag.Name != "go" || len(tag.Aliases) != 1 || tag.Aliases[0] != "golang" {
+			t.Errorf("Expected the go tag. Got %+v", tag)
+		}
+
+		assertResponseCode(t, http.StatusNotFound, send(http.MethodGet, "/v1/tags/cobol", "").Code)
+	})
+
+	t.Run("should list the posts of the canonical tag", func(t *testing.T) {
+		rr := send(http.MethodGet, "/v1/tags/golang/posts?limit=5", "")
+		assertResponseCode(t, http.StatusOK, rr.Code)
+
+		if posts.tag != "go" || posts.viewer != store.MockPostsUserId || posts.limit != 5 {
+			t.Errorf("Expected posts tagged go seen by user %d, 5 at a time. Got %q, %d, %d", store.MockPostsUserId, posts.tag, posts.viewer, posts.limit)
+		}
+
+		var feed []store.FeedPost
+		decodeData(t, rr, &feed)
+		if len(feed) != 1 || feed[0].ID != 7 {
+			t.Errorf("Expected post 7. Got %+v", feed)
+		}
+
+		assertResponseCode(t, http.StatusBadRequest, send(http.MethodGet, "/v1/tags/go/posts?limit=abc", "").Code)
+	})
+
+	t.Run("should follow and unfollow a tag once", func(t *testing.T) {
+		assertResponseCode(t, http.StatusOK, send(http.MethodPut, "/v1/tags/golang/follow", "").Code)
+		if !tagStore.follows[store.MockPostsUserId] {
+			t.Errorf("Expected user %d to follow the tag", store.MockPostsUserId)
+		}
+		assertResponseCode(t, http.StatusConflict, send(http.MethodPut, "/v1/tags/go/follow", "").Code)
+
+		assertResponseCode(t, http.StatusOK, send(http.MethodPut, "/v1/tags/go/unfollow", "").Code)
+		if tagStore.follows[store.MockPostsUserId] {
+			t.Errorf("Expected user %d to no longer follow the tag", store.MockPostsUserId)
+		}
+		assertResponseCode(t, http.StatusNotFound, send(http.MethodPut, "/v1/tags/go/unfollow", "").Code)
+	})
+
+	t.Run("should rank trending tags over a window", func(t *testing.T) {
+		rr := send(http.MethodGet, "/v1/tags/trending", "")
+		assertResponseCode(t, http.StatusOK, rr.Code)
+
+		if tagStore.trendingWindow != defaultTrendingWindow || tagStore.trendingLimit != 10 {
+			t.Errorf("Expected the default window and limit. Got %s, %d", tagStore.trendingWindow, tagStore.trendingLimit)
+		}
+
+		var trending []store.TrendingTag
+		decodeData(t, rr, &trending)
+		if len(trending) != 1 || trending[0].Name != "go" || trending[0].Authors != 3 {
+			t.Errorf("Expected go to be trending. Got %+v", trending)
+		}
+
+		assertResponseCode(t, http.StatusOK, send(http.MethodGet, "/v1/tags/trending?window=6h&limit=3", "").Code)
+		if tagStore.trendingWindow != time.Hour*6 || tagStore.trendingLimit != 3 {
+			t.Errorf("Expected a 6h window and 3 tags. Got %s, %d", tagStore.trendingWindow, tagStore.trendingLimit)
+		}
+
+		for _, query := range []string{"window=abc", "window=-1h", "window=1000h", "limit=0", "limit=51"} {
+			assertResponseCode(t, http.StatusBadRequest, send(http.MethodGet, "/v1/tags/trending?"+query, "").Code)
+		}
+	})
+}
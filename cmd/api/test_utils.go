@@ -1,14 +1,26 @@
 package main
 
 import (
+	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/elhambadri2411/social/internal/auth"
+	"github.com/elhambadri2411/social/internal/blobstore"
+	"github.com/elhambadri2411/social/internal/notifications"
+	"github.com/elhambadri2411/social/internal/ratelimiter"
+	"github.com/elhambadri2411/social/internal/search"
 	"github.com/elhambadri2411/social/internal/store"
 	"github.com/elhambadri2411/social/internal/store/cache"
+	"github.com/elhambadri2411/social/internal/stream"
+	"github.com/elhambadri2411/social/internal/timeline"
 	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/mock"
 	"go.uber.org/zap"
 )
 
@@ -20,11 +32,88 @@ func newTestApplication(t *testing.T) *application {
 	mockCacheStore := cache.NewMockCacheStore()
 	mockAuthenticator := auth.NewMockJWTAuthenticator()
 
+	blobs, err := blobstore.NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	timelines := timeline.Config{
+		Length:      800,
+		FanOutLimit: 100,
+		TTL:         time.Hour,
+	}
+
+	broker := stream.Config{
+		ReplaySize: 10,
+		ReplayTTL:  time.Minute,
+		BufferSize: 16,
+	}
+
+	eventBroker := stream.NewLocal(broker)
+
 	return &application{
-		store:         mockStore,
-		logger:        logger,
-		cache:         mockCacheStore,
-		authenticator: mockAuthenticator,
+		config: config{
+			media: mediaConfig{
+				publicURL:      "/v1/media",
+				maxUploadBytes: 1 << 20,
+				maxPixels:      1000 * 1000,
+				maxGIFPixels:   10 * 1000 * 1000,
+				thumbnailSize:  64,
+				avatarSize:     128,
+				maxPerPost:     4,
+			},
+			suggestions: suggestionsConfig{
+				size: 50,
+				ttl:  time.Hour,
+			},
+			accounts: accountsConfig{
+				deletionGrace:   time.Hour * 24 * 30,
+				exportRetention: time.Hour * 24 * 7,
+				exportLinkTTL:   time.Hour,
+			},
+			usernames: usernamesConfig{
+				changeCooldown: time.Hour * 24 * 30,
+				reservation:    time.Hour * 24 * 90,
+			},
+			timelines: timelines,
+			ranking: store.FeedRanking{
+				RecencyWeight:  1,
+				VelocityWeight: 0.5,
+				AffinityWeight: 0.75,
+				HalfLife:       time.Hour * 12,
+				Window:         time.Hour * 72,
+				AffinityWindow: time.Hour * 24 * 30,
+			},
+			publicFeeds: publicFeedsConfig{
+				cacheTTL: time.Minute,
+			},
+			stream: streamConfig{
+				heartbeat: time.Second,
+				broker:    broker,
+			},
+			liveComments: liveCommentsConfig{
+				maxPosts:     2,
+				sendBuffer:   16,
+				pingInterval: time.Second,
+			},
+			notifications: notificationsConfig{
+				retention:  time.Hour * 24 * 90,
+				digestSize: 20,
+			},
+		},
+		blobs:           blobs,
+		store:           mockStore,
+		logger:          logger,
+		cache:           mockCacheStore,
+		authenticator:   mockAuthenticator,
+		rateLimiter:     ratelimiter.NewFixedWindowRateLimiter(1000, time.Minute),
+		anonRateLimiter: ratelimiter.NewFixedWindowRateLimiter(1000, time.Minute),
+		search:          search.NewPostgresIndex(mockStore.SearchRepository),
+		timelines:       timeline.New(mockStore, mockCacheStore.TimelinesCache, timelines),
+		stream:          eventBroker,
+		comments:        stream.NewLocal(stream.Config{BufferSize: 16}),
+		sockets:         newSocketSet(),
+		notifications:   notifications.New(mockStore.NotificationsRepository, eventBroker),
 	}
 }
 
@@ -40,3 +129,69 @@ func assertResponseCode(t *testing.T, expected, actual int) {
 		t.Errorf("Expected response %d. Got %d", expected, actual)
 	}
 }
+
+// `newTestServer` mounts a test application and returns it with a function sending requests
+// authenticated as the mock user (`store.MockPostsUserId`). The users cache always misses,
+// so users come from the mock store.
+func newTestServer(t *testing.T) (*application, func(method, target, body string) *httptest.ResponseRecorder) {
+	t.Helper()
+	mockApp := newTestApplication(t)
+	mockMux := mockApp.mount()
+
+	testToken, err := mockApp.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mockCacheStore := mockApp.cache.UsersCache.(*cache.MockUsersCacheRedis)
+	mockCacheStore.On("Get", mock.Anything, mock.Anything).Return(nil, nil)
+	mockCacheStore.On("Set", mock.Anything, mock.Anything).Return(nil)
+
+	send := func(method, target, body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, target, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+
+		return execRequest(req, mockMux)
+	}
+
+	return mockApp, send
+}
+
+// `decodeData` reads the `data` envelope written by `jsonResponse` into `v`
+func decodeData(t *testing.T, rr *httptest.ResponseRecorder, v any) {
+	t.Helper()
+	response := struct {
+		Data any `json:"data"`
+	}{Data: v}
+
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// `callLog` records the calls a test double receives, formatted as strings
+type callLog struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (l *callLog) record(format string, args ...any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.calls = append(l.calls, fmt.Sprintf(format, args...))
+}
+
+// `assertCalls` checks the calls recorded since the last check, in order, then forgets them
+func (l *callLog) assertCalls(t *testing.T, want ...string) {
+	t.Helper()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if strings.Join(l.calls, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Expected calls:\n%s\nGot:\n%s", strings.Join(want, "\n"), strings.Join(l.calls, "\n"))
+	}
+	l.calls = nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/elhambadri2411/social/internal/store"
+)
+
+// `publishToTimelines` pushes a new post to the home timelines of its author and their followers
+// in the background. Timelines are rebuilt from the database when they expire, a failed push
+// only delays the post until then.
+func (app *application) publishToTimelines(post *store.Post) {
+	createdAt, err := time.Parse(time.RFC3339Nano, post.CreatedAt)
+	if err != nil {
+		app.logger.Warnw("failed to publish post to timelines", "post", post.ID, "error", err.Error())
+		return
+	}
+
+	entry := store.TimelineEntry{PostId: post.ID, AuthorId: post.UserId, CreatedAt: createdAt}
+	app.background(func() {
+		if err := app.timelines.Publish(context.Background(), entry); err != nil {
+			app.logger.Warnw("failed to publish post to timelines", "post", entry.PostId, "error", err.Error())
+		}
+	})
+}
+
+// `backfillTimeline` adds the latest posts of `authorId` to the timeline of their new follower `userId`
+func (app *application) backfillTimeline(userId int64, authorId int64) {
+	app.background(func() {
+		if err := app.timelines.Follow(context.Background(), userId, authorId); err != nil {
+			app.logger.Warnw("failed to backfill timeline", "user", userId, "author", authorId, "error", err.Error())
+		}
+	})
+}
+
+// `trimTimeline` removes the posts of `authorId` from the timeline of `userId` once they stop following them
+func (app *application) trimTimeline(userId int64, authorId int64) {
+	app.background(func() {
+		if err := app.timelines.Unfollow(context.Background(), userId, authorId); err != nil {
+			app.logger.Warnw("failed to trim timeline", "user", userId, "author", authorId, "error", err.Error())
+		}
+	})
+}
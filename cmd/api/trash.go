@@ -0,0 +1,141 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/elhambadri2411/social/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+// getTrashHandler godoc
+//
+//	@Summary		Lists the user's trash
+//	@Description	Lists posts the authenticated user deleted and can still restore
+//	@Tags			posts
+//	@Produce		json
+//	@Param			limit	query		int	false	"Limit"
+//	@Param			offset	query		int	false	"Offset"
+//	@Success		200		{object}	[]store.Post
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/trash [get]
+func (app *application) getTrashHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+	app.listDeletedPosts(w, r, user.ID)
+}
+
+// getDeletedPostsHandler godoc
+//
+//	@Summary		Lists deleted posts of every user
+//	@Description	Moderation view of the trash, requires the moderator role
+//	@Tags			posts
+//	@Produce		json
+//	@Param			limit	query		int	false	"Limit"
+//	@Param			offset	query		int	false	"Offset"
+//	@Success		200		{object}	[]store.Post
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/deleted [get]
+func (app *application) getDeletedPostsHandler(w http.ResponseWriter, r *http.Request) {
+	app.listDeletedPosts(w, r, 0)
+}
+
+func (app *application) listDeletedPosts(w http.ResponseWriter, r *http.Request, userId int64) {
+	pfq := store.PaginatedFeedQuery{
+		Limit:  20,
+		Offset: 0,
+		Sort:   "desc",
+	}
+
+	pfq, err := pfq.Parse(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(pfq); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	posts, err := app.store.PostsRepository.GetDeleted(r.Context(), userId, int64(pfq.Limit), int64(pfq.Offset))
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, posts); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// restorePostHandler godoc
+//
+//	@Summary		Restores a deleted post
+//	@Description	Moves a post out of the trash, only possible within the retention window
+//	@Tags			posts
+//	@Produce		json
+//	@Param			id	path		int	true	"Post ID"
+//	@Success		200	{object}	store.Post
+//	@Failure		403	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/trash/{id}/restore [put]
+func (app *application) restorePostHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user := getUserFromCtx(r)
+
+	postId, err := strconv.ParseInt(chi.URLParam(r, "postId"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	post, err := app.store.PostsRepository.GetDeletedById(ctx, postId)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	// same rule as deleting: the owner or an admin
+	if post.UserId != user.ID {
+		allowed, err := app.checkRolePrecedence(ctx, user, "admin")
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		if !allowed {
+			app.forbiddenResponse(w, r)
+			return
+		}
+	}
+
+	if err := app.store.PostsRepository.Restore(ctx, post, app.config.posts.trashRetention); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	app.indexPost(ctx, post)
+
+	w.Header().Set("ETag", postETag(post))
+	if err := app.jsonResponse(w, http.StatusOK, post); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/elhambadri2411/social/internal/store"
+)
+
+// `trashPostStore` records how the trash was read and purged
+type trashPostStore struct {
+	store.MockPostStore
+	deletedOwner *int64
+	deletedLimit int64
+	purgedBefore time.Duration
+	trashedOwner int64
+}
+
+func (s *trashPostStore) GetDeleted(ctx context.Context, userId int64, limit int64, offset int64) ([]*store.Post, error) {
+	s.deletedOwner = &userId
+	s.deletedLimit = limit
+
+	deletedAt := "2025-06-01T10:00:00Z"
+	return []*store.Post{{ID: 4, UserId: max(userId, 8), Title: "gone", DeletedAt: &deletedAt}}, nil
+}
+
+func (s *trashPostStore) GetDeletedById(ctx context.Context, id int64) (*store.Post, error) {
+	post, err := s.MockPostStore.GetDeletedById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if s.trashedOwner != 0 {
+		post.UserId = s.trashedOwner
+	}
+
+	return post, nil
+}
+
+func (s *trashPostStore) PurgeDeleted(ctx context.Context, retention time.Duration) (int64, error) {
+	s.purgedBefore = retention
+	return 3, nil
+}
+
+// `roleUserStore` gives every user the same role
+type roleUserStore struct {
+	store.MockUserStore
+	level int
+}
+
+func (s *roleUserStore) GetById(ctx context.Context, id int64) (*store.User, error) {
+	user, err := s.MockUserStore.GetById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	user.Role.Level = s.level
+
+	return user, nil
+}
+
+func TestTrash(t *testing.T) {
+	mockApp, send := newTestServer(t)
+	mockApp.config.posts.trashRetention = time.Hour * 24 * 30
+
+	posts := &trashPostStore{}
+	mockApp.store.PostsRepository = posts
+
+	t.Run("should list the user's own trash", func(t *testing.T) {
+		rr := send(http.MethodGet, "/v1/posts/trash?limit=5", "")
+		assertResponseCode(t, http.StatusOK, rr.Code)
+
+		if posts.deletedOwner == nil || *posts.deletedOwner != store.MockPostsUserId || posts.deletedLimit != 5 {
+			t.Errorf("Expected the trash of user %d with limit 5. Got %v, %d", store.MockPostsUserId, posts.deletedOwner, posts.deletedLimit)
+		}
+
+		var trash []store.Post
+		decodeData(t, rr, &trash)
+		if len(trash) != 1 || trash[0].ID != 4 || trash[0].DeletedAt == nil {
+			t.Errorf("Expected the deleted post 4. Got %+v", trash)
+		}
+	})
+
+	t.Run("should keep the feed limits to the feeds", func(t *testing.T) {
+		assertResponseCode(t, http.StatusOK, send(http.MethodGet, "/v1/posts/trash?limit=150", "").Code)
+		if posts.deletedLimit != 150 {
+			t.Errorf("Expected the trash with limit 150. Got %d", posts.deletedLimit)
+		}
+	})
+
+	t.Run("should restore a post", func(t *testing.T) {
+		rr := send(http.MethodPut, "/v1/posts/trash/4/restore", "")
+		assertResponseCode(t, http.StatusOK, rr.Code)
+
+		var post store.Post
+		decodeData(t, rr, &post)
+		if post.ID != 4 || post.DeletedAt != nil || post.Version != 2 {
+			t.Errorf("Expected post 4 restored at version 2. Got %+v", post)
+		}
+		if etag := rr.Header().Get("ETag"); etag != `"2"` {
+			t.Errorf(`Expected ETag "2". Got %s`, etag)
+		}
+	})
+
+	t.Run("should not restore other users' posts", func(t *testing.T) {
+		posts.trashedOwner = 8
+		defer func() { posts.trashedOwner = 0 }()
+
+		assertResponseCode(t, http.StatusForbidden, send(http.MethodPut, "/v1/posts/trash/4/restore", "").Code)
+		assertResponseCode(t, http.StatusBadRequest, send(http.MethodPut, "/v1/posts/trash/abc/restore", "").Code)
+	})
+
+	t.Run("should only show every user's deleted posts to moderators", func(t *testing.T) {
+		assertResponseCode(t, http.StatusForbidden, send(http.MethodGet, "/v1/posts/deleted", "").Code)
+
+		users := mockApp.store.UsersRepository
+		mockApp.store.UsersRepository = &roleUserStore{level: 2}
+		defer func() { mockApp.store.UsersRepository = users }()
+
+		rr := send(http.MethodGet, "/v1/posts/deleted", "")
+		assertResponseCode(t, http.StatusOK, rr.Code)
+
+		if posts.deletedOwner == nil || *posts.deletedOwner != 0 {
+			t.Errorf("Expected the trash of every user. Got %v", posts.deletedOwner)
+		}
+
+		var trash []store.Post
+		decodeData(t, rr, &trash)
+		if len(trash) != 1 || trash[0].UserId != 8 {
+			t.Errorf("Expected the deleted post of user 8. Got %+v", trash)
+		}
+	})
+
+	t.Run("should purge posts past the retention window", func(t *testing.T) {
+		if err := mockApp.purgeDeletedPosts(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		if posts.purgedBefore != mockApp.config.posts.trashRetention {
+			t.Errorf("Expected a purge with retention %s. Got %s", mockApp.config.posts.trashRetention, posts.purgedBefore)
+		}
+	})
+}
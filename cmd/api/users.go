@@ -4,8 +4,11 @@ import (
 	"context"
 	"errors"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 
+	"github.com/elhambadri2411/social/internal/notifications"
 	"github.com/elhambadri2411/social/internal/store"
 	"github.com/go-chi/chi/v5"
 )
@@ -17,12 +20,12 @@ const userCtx userKey = "user"
 // GetUser godoc
 //
 //	@Summary		Fetches a user profile
-//	@Description	Fetches a user profile by ID
+//	@Description	Fetches the public profile of a user by ID
 //	@Tags			users
 //	@Accept			json
 //	@Produce		json
 //	@Param			id	path		int	true	"User ID"
-//	@Success		200	{object}	store.User
+//	@Success		200	{object}	store.PublicProfile
 //	@Failure		400	{object}	error
 //	@Failure		404	{object}	error
 //	@Failure		500	{object}	error
@@ -48,23 +51,109 @@ func (app *application) getUserHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := app.jsonResponse(w, http.StatusOK, user); err != nil {
+	// blocked users look missing to each other
+	blocked, err := app.isBlocked(ctx, getUserFromCtx(r).ID, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if blocked {
+		app.notFoundResponse(w, r, store.ErrNotFound)
+		return
+	}
+
+	profile, err := app.publicProfile(ctx, user)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, profile); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// GetUserByUsername godoc
+//
+//	@Summary		Fetches a user profile by username
+//	@Description	Fetches the public profile of a user by username. A username the user recently changed
+//	@Description	away from redirects to the profile under the current one.
+//	@Tags			users
+//	@Produce		json
+//	@Param			username	path		string	true	"Username"
+//	@Success		200			{object}	store.PublicProfile
+//	@Success		301			{string}	string	"Old username, Location has the current one"
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/by-username/{username} [get]
+func (app *application) getUserByUsernameHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	username := chi.URLParam(r, "username")
+
+	userId, err := app.store.UsersRepository.ResolveUsername(ctx, username)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	user, err := app.getUser(ctx, userId)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	// blocked users look missing to each other, old usernames included
+	blocked, err := app.isBlocked(ctx, getUserFromCtx(r).ID, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if blocked {
+		app.notFoundResponse(w, r, store.ErrNotFound)
+		return
+	}
+
+	if !strings.EqualFold(user.Username, username) {
+		http.Redirect(w, r, "/v1/users/by-username/"+url.PathEscape(user.Username), http.StatusMovedPermanently)
+		return
+	}
+
+	profile, err := app.publicProfile(ctx, user)
+	if err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
+
+	if err := app.jsonResponse(w, http.StatusOK, profile); err != nil {
+		app.internalServerError(w, r, err)
+	}
 }
 
 // FollowUser godoc
 //
 //	@Summary		Follows a user
-//	@Description	Follows a user by ID
+//	@Description	Follows a user by ID. Following a private account sends a follow request instead.
 //	@Tags			users
 //	@Accept			json
 //	@Produce		json
-//	@Param			userID	path		int		true	"User ID"
-//	@Success		204		{string}	string	"User followed"
-//	@Failure		400		{object}	error	"User payload missing"
-//	@Failure		404		{object}	error	"User not found"
+//	@Param			userID	path		int				true	"User ID"
+//	@Success		204		{string}	string			"User followed"
+//	@Success		202		{object}	followStatus	"Follow requested"
+//	@Failure		403		{object}	error			"One of the users blocked the other"
+//	@Failure		400		{object}	error			"User payload missing"
+//	@Failure		404		{object}	error			"User not found"
 //	@Security		ApiKeyAuth
 //	@Router			/users/{userID}/follow [put]
 func (app *application) followUserHandler(w http.ResponseWriter, r *http.Request) {
@@ -75,6 +164,47 @@ func (app *application) followUserHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	followed, err := app.getUser(r.Context(), followedId)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	blocked, err := app.isBlocked(r.Context(), followerUser.ID, followed.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if blocked {
+		app.forbiddenResponse(w, r)
+		return
+	}
+
+	if followed.IsPrivate && followed.ID != followerUser.ID {
+		if err := app.store.FollowersRepository.CreateRequest(r.Context(), followed.ID, followerUser.ID); err != nil {
+			switch {
+			case errors.Is(err, store.ErrConflict):
+				app.conflictError(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		app.forgetSuggestions(r.Context(), followerUser.ID)
+		app.notify([]int64{followed.ID}, store.Notification{Type: notifications.TypeFollowRequest, ActorId: followerUser.ID})
+
+		if err := app.jsonResponse(w, http.StatusAccepted, followStatus{Status: "requested"}); err != nil {
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
 	if err := app.store.UsersRepository.Follow(r.Context(), followedId, followerUser.ID); err != nil {
 		switch err {
 		case store.ErrConflict:
@@ -86,6 +216,10 @@ func (app *application) followUserHandler(w http.ResponseWriter, r *http.Request
 		}
 	}
 
+	app.backfillTimeline(followerUser.ID, followedId)
+	app.forgetSuggestions(r.Context(), followerUser.ID)
+	app.notify([]int64{followedId}, store.Notification{Type: notifications.TypeFollow, ActorId: followerUser.ID})
+
 	if err := app.jsonResponse(w, http.StatusOK, nil); err != nil {
 		app.internalServerError(w, r, err)
 		return
@@ -95,7 +229,7 @@ func (app *application) followUserHandler(w http.ResponseWriter, r *http.Request
 // UnfollowUser gdoc
 //
 //	@Summary		Unfollow a user
-//	@Description	Unfollow a user by ID
+//	@Description	Unfollow a user by ID, or cancel a pending follow request
 //	@Tags			users
 //	@Accept			json
 //	@Produce		json
@@ -113,8 +247,19 @@ func (app *application) unfollowUserHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	if err := app.store.UsersRepository.Unfollow(r.Context(), followedId, followerUser.ID); err != nil {
-		app.internalServerError(w, r, err)
+	err = app.store.UsersRepository.Unfollow(r.Context(), followedId, followerUser.ID)
+	if err == nil {
+		app.trimTimeline(followerUser.ID, followedId)
+	} else if errors.Is(err, store.ErrNotFound) {
+		err = app.store.FollowersRepository.DeleteRequest(r.Context(), followedId, followerUser.ID)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
 		return
 	}
 
@@ -124,6 +269,148 @@ func (app *application) unfollowUserHandler(w http.ResponseWriter, r *http.Reque
 	}
 }
 
+type followStatus struct {
+	Status string `json:"status"`
+}
+
+// `followList` is a page of a followers or following list, `NextCursor` is empty on the last page
+type followList struct {
+	Users      []store.FollowListEntry `json:"users"`
+	NextCursor string                  `json:"next_cursor,omitempty"`
+}
+
+// GetFollowers godoc
+//
+//	@Summary		Lists the followers of a user
+//	@Description	Lists the users following a user, most recent first. `follows_you` and `you_follow` are relative to the caller.
+//	@Description	Pass `next_cursor` from a response as `cursor` to fetch the following page.
+//	@Tags			users
+//	@Produce		json
+//	@Param			userID	path		int		true	"User ID"
+//	@Param			limit	query		int		false	"Limit (default 20, max 100)"
+//	@Param			cursor	query		string	false	"Cursor from a previous page"
+//	@Success		200		{object}	followList
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/followers [get]
+func (app *application) getFollowersHandler(w http.ResponseWriter, r *http.Request) {
+	app.listFollows(w, r, app.store.FollowersRepository.GetFollowers)
+}
+
+// GetFollowing godoc
+//
+//	@Summary		Lists the users a user follows
+//	@Description	Lists the users a user follows, most recent first. `follows_you` and `you_follow` are relative to the caller.
+//	@Description	Pass `next_cursor` from a response as `cursor` to fetch the following page.
+//	@Tags			users
+//	@Produce		json
+//	@Param			userID	path		int		true	"User ID"
+//	@Param			limit	query		int		false	"Limit (default 20, max 100)"
+//	@Param			cursor	query		string	false	"Cursor from a previous page"
+//	@Success		200		{object}	followList
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/following [get]
+func (app *application) getFollowingHandler(w http.ResponseWriter, r *http.Request) {
+	app.listFollows(w, r, app.store.FollowersRepository.GetFollowing)
+}
+
+func (app *application) listFollows(
+	w http.ResponseWriter,
+	r *http.Request,
+	list func(context.Context, int64, store.FollowListQuery) ([]store.FollowListEntry, error),
+) {
+	ctx := r.Context()
+
+	userId, err := strconv.ParseInt(chi.URLParam(r, "userId"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	query, err := app.readFollowListQuery(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	// 404 for unknown users rather than an empty list
+	if _, err := app.getUser(ctx, userId); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	visible, err := app.canView(ctx, getUserFromCtx(r), userId)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if !visible {
+		app.forbiddenResponse(w, r)
+		return
+	}
+
+	entries, err := list(ctx, userId, *query)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.writeFollowList(w, r, entries, query.Limit)
+}
+
+// `readFollowListQuery` reads the `limit` and `cursor` of a follow list page, relative to the caller
+func (app *application) readFollowListQuery(r *http.Request) (*store.FollowListQuery, error) {
+	qs := r.URL.Query()
+
+	query := store.FollowListQuery{
+		ViewerId: getUserFromCtx(r).ID,
+		Limit:    20,
+	}
+
+	if limit := qs.Get("limit"); limit != "" {
+		parsed, err := strconv.Atoi(limit)
+		if err != nil {
+			return nil, errors.New("limit must be a number")
+		}
+		query.Limit = parsed
+	}
+
+	if cursor := qs.Get("cursor"); cursor != "" {
+		after, err := store.DecodeFollowCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		query.After = after
+	}
+
+	if err := Validate.Struct(query); err != nil {
+		return nil, err
+	}
+
+	return &query, nil
+}
+
+func (app *application) writeFollowList(w http.ResponseWriter, r *http.Request, entries []store.FollowListEntry, limit int) {
+	for i := range entries {
+		entries[i].AvatarURL, entries[i].AvatarThumbnailURL = app.avatarURLs(entries[i].AvatarKey, entries[i].AvatarThumbnailKey)
+	}
+
+	page := followList{Users: entries, NextCursor: store.NextFollowCursor(entries, limit)}
+	if err := app.jsonResponse(w, http.StatusOK, page); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
 func (app *application) usersContextMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
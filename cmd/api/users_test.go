@@ -1,10 +1,15 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
 	"net/http"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/elhambadri2411/social/internal/store"
 	"github.com/elhambadri2411/social/internal/store/cache"
 	"github.com/stretchr/testify/mock"
 )
@@ -68,3 +73,381 @@ func TestGetUser(t *testing.T) {
 		mockCacheStore.Calls = nil
 	})
 }
+
+// `recordingFollowersStore` serves two follows on every list and records what was asked
+type recordingFollowersStore struct {
+	store.MockFollowersStore
+	callLog
+}
+
+func (s *recordingFollowersStore) list(name string, userId int64, q store.FollowListQuery) []store.FollowListEntry {
+	after := int64(0)
+	if q.After != nil {
+		after = q.After.UserId
+	}
+	s.record("%s(%d, viewer=%d, limit=%d, after=%d)", name, userId, q.ViewerId, q.Limit, after)
+
+	return []store.FollowListEntry{
+		{ID: 5, Username: "ana", FollowedAt: "2025-08-02T10:00:00Z", FollowsYou: true},
+		{ID: 7, Username: "bob", FollowedAt: "2025-08-01T10:00:00Z"},
+	}
+}
+
+func (s *recordingFollowersStore) GetFollowers(ctx context.Context, userId int64, q store.FollowListQuery) ([]store.FollowListEntry, error) {
+	return s.list("GetFollowers", userId, q), nil
+}
+
+func (s *recordingFollowersStore) GetFollowing(ctx context.Context, userId int64, q store.FollowListQuery) ([]store.FollowListEntry, error) {
+	return s.list("GetFollowing", userId, q), nil
+}
+
+func (s *recordingFollowersStore) GetRequests(ctx context.Context, userId int64, q store.FollowListQuery) ([]store.FollowListEntry, error) {
+	return s.list("GetRequests", userId, q), nil
+}
+
+func (s *recordingFollowersStore) ApproveRequest(ctx context.Context, userId int64, followerId int64) error {
+	s.record("ApproveRequest(%d, %d)", userId, followerId)
+	return nil
+}
+
+func (s *recordingFollowersStore) DeleteRequest(ctx context.Context, userId int64, followerId int64) error {
+	s.record("DeleteRequest(%d, %d)", userId, followerId)
+	if followerId == 4 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+func TestFollowLists(t *testing.T) {
+	mockApp, send := newTestServer(t)
+	followers := &recordingFollowersStore{}
+	mockApp.store.FollowersRepository = followers
+
+	t.Run("should list followers and following", func(t *testing.T) {
+		rr := send(http.MethodGet, "/v1/users/1/followers", "")
+		assertResponseCode(t, http.StatusOK, rr.Code)
+
+		var page followList
+		decodeData(t, rr, &page)
+		if len(page.Users) != 2 || page.Users[0].Username != "ana" || !page.Users[0].FollowsYou || page.NextCursor != "" {
+			t.Errorf("Expected ana and bob on a last page. Got %+v", page)
+		}
+
+		assertResponseCode(t, http.StatusOK, send(http.MethodGet, "/v1/users/1/following?limit=50", "").Code)
+		followers.assertCalls(t,
+			"GetFollowers(1, viewer=21, limit=20, after=0)",
+			"GetFollowing(1, viewer=21, limit=50, after=0)",
+		)
+	})
+
+	t.Run("should page with its own cursors and reject others", func(t *testing.T) {
+		rr := send(http.MethodGet, "/v1/users/1/followers?limit=2", "")
+		assertResponseCode(t, http.StatusOK, rr.Code)
+
+		var page followList
+		decodeData(t, rr, &page)
+		if page.NextCursor == "" {
+			t.Fatal("Expected a cursor after a full page")
+		}
+
+		assertResponseCode(t, http.StatusOK, send(http.MethodGet, "/v1/users/1/followers?limit=2&cursor="+page.NextCursor, "").Code)
+		followers.assertCalls(t,
+			"GetFollowers(1, viewer=21, limit=2, after=0)",
+			"GetFollowers(1, viewer=21, limit=2, after=7)",
+		)
+
+		for _, query := range []string{"cursor=bm9wZQ", "limit=500", "limit=0", "limit=abc"} {
+			assertResponseCode(t, http.StatusBadRequest, send(http.MethodGet, "/v1/users/1/followers?"+query, "").Code)
+		}
+		followers.assertCalls(t)
+	})
+}
+
+func TestFollowRequests(t *testing.T) {
+	mockApp, send := newTestServer(t)
+	followers := &recordingFollowersStore{}
+	mockApp.store.FollowersRepository = followers
+
+	mockTimelines := mockApp.cache.TimelinesCache.(*cache.MockTimelinesCacheRedis)
+	mockTimelines.On("PulledAuthors", mock.Anything).Return([]int64{}, nil)
+	mockTimelines.On("Add", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	t.Run("should list pending requests", func(t *testing.T) {
+		rr := send(http.MethodGet, "/v1/users/me/follow-requests?limit=10", "")
+		assertResponseCode(t, http.StatusOK, rr.Code)
+
+		var page followList
+		decodeData(t, rr, &page)
+		if len(page.Users) != 2 || page.Users[1].Username != "bob" {
+			t.Errorf("Expected requests from ana and bob. Got %+v", page.Users)
+		}
+		followers.assertCalls(t, "GetRequests(21, viewer=21, limit=10, after=0)")
+	})
+
+	t.Run("should approve and reject requests", func(t *testing.T) {
+		assertResponseCode(t, http.StatusNoContent, send(http.MethodPut, "/v1/users/me/follow-requests/3/approve", "").Code)
+
+		// the approved follower's timeline gets the posts of the user they now follow
+		mockApp.wg.Wait()
+		mockTimelines.AssertCalled(t, "Add", mock.Anything, int64(3), mock.Anything)
+
+		assertResponseCode(t, http.StatusNoContent, send(http.MethodDelete, "/v1/users/me/follow-requests/3", "").Code)
+		assertResponseCode(t, http.StatusNotFound, send(http.MethodDelete, "/v1/users/me/follow-requests/4", "").Code)
+		assertResponseCode(t, http.StatusBadRequest, send(http.MethodDelete, "/v1/users/me/follow-requests/abc", "").Code)
+		followers.assertCalls(t,
+			"ApproveRequest(21, 3)",
+			"DeleteRequest(21, 3)",
+			"DeleteRequest(21, 4)",
+		)
+	})
+}
+
+// `recordingBlocksStore` records blocks and mutes and lists one related user
+type recordingBlocksStore struct {
+	store.MockBlocksStore
+	callLog
+}
+
+func (s *recordingBlocksStore) Block(ctx context.Context, blockerId int64, blockedId int64) error {
+	s.record("Block(%d, %d)", blockerId, blockedId)
+	return nil
+}
+
+func (s *recordingBlocksStore) Unblock(ctx context.Context, blockerId int64, blockedId int64) error {
+	s.record("Unblock(%d, %d)", blockerId, blockedId)
+	return nil
+}
+
+func (s *recordingBlocksStore) Mute(ctx context.Context, muterId int64, mutedId int64) error {
+	s.record("Mute(%d, %d)", muterId, mutedId)
+	return nil
+}
+
+func (s *recordingBlocksStore) Unmute(ctx context.Context, muterId int64, mutedId int64) error {
+	s.record("Unmute(%d, %d)", muterId, mutedId)
+	return nil
+}
+
+func (s *recordingBlocksStore) GetBlocked(ctx context.Context, userId int64, limit int, offset int) ([]store.RelatedUser, error) {
+	s.record("GetBlocked(%d, %d, %d)", userId, limit, offset)
+	return []store.RelatedUser{{ID: 3, Username: "troll"}}, nil
+}
+
+func (s *recordingBlocksStore) GetMuted(ctx context.Context, userId int64, limit int, offset int) ([]store.RelatedUser, error) {
+	s.record("GetMuted(%d, %d, %d)", userId, limit, offset)
+	return []store.RelatedUser{{ID: 4, Username: "loud"}}, nil
+}
+
+func TestBlocksAndMutes(t *testing.T) {
+	mockApp, send := newTestServer(t)
+	blocks := &recordingBlocksStore{}
+	mockApp.store.BlocksRepository = blocks
+
+	mockTimelines := mockApp.cache.TimelinesCache.(*cache.MockTimelinesCacheRedis)
+	mockTimelines.On("Remove", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	mockSuggestionsCache := mockApp.cache.SuggestionsCache.(*cache.MockSuggestionsCacheRedis)
+	mockSuggestionsCache.On("Delete", mock.Anything, mock.Anything).Return(nil)
+
+	t.Run("should block, mute and undo both", func(t *testing.T) {
+		for _, action := range []string{"block", "unblock", "mute", "unmute"} {
+			assertResponseCode(t, http.StatusNoContent, send(http.MethodPut, "/v1/users/3/"+action, "").Code)
+		}
+		blocks.assertCalls(t, "Block(21, 3)", "Unblock(21, 3)", "Mute(21, 3)", "Unmute(21, 3)")
+
+		// blocking ends the follows both ways, so both timelines are trimmed
+		mockApp.wg.Wait()
+		mockTimelines.AssertCalled(t, "Remove", mock.Anything, int64(21), mock.Anything)
+		mockTimelines.AssertCalled(t, "Remove", mock.Anything, int64(3), mock.Anything)
+	})
+
+	t.Run("should not block yourself", func(t *testing.T) {
+		assertResponseCode(t, http.StatusBadRequest, send(http.MethodPut, "/v1/users/21/block", "").Code)
+		blocks.assertCalls(t)
+	})
+
+	t.Run("should list blocked and muted users", func(t *testing.T) {
+		rr := send(http.MethodGet, "/v1/users/me/blocks", "")
+		assertResponseCode(t, http.StatusOK, rr.Code)
+
+		var blocked []store.RelatedUser
+		decodeData(t, rr, &blocked)
+		if len(blocked) != 1 || blocked[0].Username != "troll" {
+			t.Errorf("Expected troll to be blocked. Got %+v", blocked)
+		}
+
+		rr = send(http.MethodGet, "/v1/users/me/mutes?limit=5&offset=10", "")
+		assertResponseCode(t, http.StatusOK, rr.Code)
+
+		var muted []store.RelatedUser
+		decodeData(t, rr, &muted)
+		if len(muted) != 1 || muted[0].Username != "loud" {
+			t.Errorf("Expected loud to be muted. Got %+v", muted)
+		}
+
+		blocks.assertCalls(t, "GetBlocked(21, 20, 0)", "GetMuted(21, 5, 10)")
+	})
+}
+
+// `recordingSuggestionsStore` suggests three users and records how many were asked for
+type recordingSuggestionsStore struct {
+	store.MockSuggestionsStore
+	callLog
+}
+
+func (s *recordingSuggestionsStore) GetForUser(ctx context.Context, userId int64, limit int) ([]store.Suggestion, error) {
+	s.record("GetForUser(%d, %d)", userId, limit)
+	if userId == 4 {
+		return nil, errors.New("connection reset")
+	}
+	return []store.Suggestion{
+		{ID: 5, Username: "ana", MutualFollows: 3},
+		{ID: 7, Username: "bob", SharedTags: 2},
+		{ID: 9, Username: "eve", MutualFollows: 1},
+	}, nil
+}
+
+func TestSuggestions(t *testing.T) {
+	mockApp, send := newTestServer(t)
+	suggestions := &recordingSuggestionsStore{}
+	mockApp.store.SuggestionsRepository = suggestions
+
+	mockSuggestionsCache := mockApp.cache.SuggestionsCache.(*cache.MockSuggestionsCacheRedis)
+	mockSuggestionsCache.On("Touch", mock.Anything, int64(21)).Return(nil)
+	mockSuggestionsCache.On("Get", mock.Anything, int64(21)).Return(nil, nil)
+	mockSuggestionsCache.On("Set", mock.Anything, int64(21), mock.Anything).Return(nil)
+
+	t.Run("should compute and cache suggestions on a miss", func(t *testing.T) {
+		rr := send(http.MethodGet, "/v1/users/me/suggestions?limit=2", "")
+		assertResponseCode(t, http.StatusOK, rr.Code)
+
+		var got []store.Suggestion
+		decodeData(t, rr, &got)
+		if len(got) != 2 || got[0].Username != "ana" || got[0].MutualFollows != 3 || got[1].Username != "bob" {
+			t.Errorf("Expected the two best suggestions. Got %+v", got)
+		}
+
+		// the whole ranking is computed and cached, the limit only applies to the response
+		suggestions.assertCalls(t, "GetForUser(21, 50)")
+		mockSuggestionsCache.AssertCalled(t, "Touch", mock.Anything, int64(21))
+		mockSuggestionsCache.AssertCalled(t, "Set", mock.Anything, int64(21), mock.MatchedBy(func(cached []store.Suggestion) bool {
+			return len(cached) == 3 && cached[2].Username == "eve"
+		}))
+	})
+
+	t.Run("should refresh every recent requester, even after a failure", func(t *testing.T) {
+		mockSuggestionsCache.On("Requesters", mock.Anything, mock.Anything).Return([]int64{4, 21}, nil).Once()
+
+		if err := mockApp.refreshSuggestions(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		suggestions.assertCalls(t, "GetForUser(4, 50)", "GetForUser(21, 50)")
+	})
+
+	t.Run("should clear cached suggestions on follow, block and mute", func(t *testing.T) {
+		mockSuggestionsCache.On("Delete", mock.Anything, mock.Anything).Return(nil)
+
+		assertResponseCode(t, http.StatusOK, send(http.MethodPut, "/v1/users/5/follow", "").Code)
+		mockSuggestionsCache.AssertNumberOfCalls(t, "Delete", 1)
+		mockSuggestionsCache.AssertCalled(t, "Delete", mock.Anything, int64(21))
+
+		assertResponseCode(t, http.StatusNoContent, send(http.MethodPut, "/v1/users/7/block", "").Code)
+		mockSuggestionsCache.AssertNumberOfCalls(t, "Delete", 3)
+		mockSuggestionsCache.AssertCalled(t, "Delete", mock.Anything, int64(7))
+
+		assertResponseCode(t, http.StatusNoContent, send(http.MethodPut, "/v1/users/9/mute", "").Code)
+		mockSuggestionsCache.AssertNumberOfCalls(t, "Delete", 4)
+	})
+
+	t.Run("should reject limits above the cached size", func(t *testing.T) {
+		assertResponseCode(t, http.StatusBadRequest, send(http.MethodGet, "/v1/users/me/suggestions?limit=500", "").Code)
+		assertResponseCode(t, http.StatusBadRequest, send(http.MethodGet, "/v1/users/me/suggestions?limit=0", "").Code)
+		suggestions.assertCalls(t)
+	})
+}
+
+// `renamedUserStore` knows user 1 as "user1", formerly "old-handle", and records username changes
+type renamedUserStore struct {
+	store.MockUserStore
+	callLog
+}
+
+func (s *renamedUserStore) ResolveUsername(ctx context.Context, username string) (int64, error) {
+	switch strings.ToLower(username) {
+	case "user1", "old-handle":
+		return 1, nil
+	default:
+		return 0, store.ErrNotFound
+	}
+}
+
+func (s *renamedUserStore) ChangeUsername(ctx context.Context, userId int64, username string, cooldown time.Duration, reservation time.Duration) error {
+	s.record("ChangeUsername(%d, %s, %s, %s)", userId, username, cooldown, reservation)
+	switch username {
+	case "taken":
+		return store.ErrDuplicateUsername
+	case "again":
+		return store.ErrUsernameChangeTooSoon
+	default:
+		return nil
+	}
+}
+
+func TestUsernames(t *testing.T) {
+	mockApp, send := newTestServer(t)
+	users := &renamedUserStore{}
+	mockApp.store.UsersRepository = users
+
+	t.Run("should find a user by their current username", func(t *testing.T) {
+		for _, username := range []string{"user1", "USER1"} {
+			rr := send(http.MethodGet, "/v1/users/by-username/"+username, "")
+			assertResponseCode(t, http.StatusOK, rr.Code)
+
+			var profile store.PublicProfile
+			decodeData(t, rr, &profile)
+			if profile.ID != 1 || profile.Username != "user1" {
+				t.Errorf("Expected the profile of user1. Got %+v", profile)
+			}
+		}
+
+		assertResponseCode(t, http.StatusNotFound, send(http.MethodGet, "/v1/users/by-username/nobody", "").Code)
+	})
+
+	t.Run("should redirect old usernames to the current one", func(t *testing.T) {
+		rr := send(http.MethodGet, "/v1/users/by-username/old-handle", "")
+		assertResponseCode(t, http.StatusMovedPermanently, rr.Code)
+		if location := rr.Header().Get("Location"); location != "/v1/users/by-username/user1" {
+			t.Errorf("redirected to %q", location)
+		}
+	})
+
+	t.Run("should change the username", func(t *testing.T) {
+		rr := send(http.MethodPut, "/v1/users/me/username", `{"username": "@ada.l"}`)
+		assertResponseCode(t, http.StatusOK, rr.Code)
+
+		var profile store.PrivateProfile
+		decodeData(t, rr, &profile)
+		if profile.Username != "ada.l" {
+			t.Errorf("Expected the new username ada.l. Got %q", profile.Username)
+		}
+
+		users.assertCalls(t, "ChangeUsername(21, ada.l, 720h0m0s, 2160h0m0s)")
+	})
+
+	t.Run("should answer conflicts for taken usernames and changes too soon", func(t *testing.T) {
+		assertResponseCode(t, http.StatusConflict, send(http.MethodPut, "/v1/users/me/username", `{"username": "taken"}`).Code)
+		assertResponseCode(t, http.StatusConflict, send(http.MethodPut, "/v1/users/me/username", `{"username": "again"}`).Code)
+		users.assertCalls(t,
+			"ChangeUsername(21, taken, 720h0m0s, 2160h0m0s)",
+			"ChangeUsername(21, again, 720h0m0s, 2160h0m0s)",
+		)
+	})
+
+	t.Run("should reject usernames that can't be mentioned", func(t *testing.T) {
+		for _, username := range []string{"", "ada.", "ada lovelace", "ada@home"} {
+			rr := send(http.MethodPut, "/v1/users/me/username", `{"username": "`+username+`"}`)
+			assertResponseCode(t, http.StatusBadRequest, rr.Code)
+		}
+		users.assertCalls(t)
+	})
+}
@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE posts ADD COLUMN deleted_at timestamp(0) with time zone;
+
+CREATE INDEX IF NOT EXISTS idx_posts_deleted_at ON posts (deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- comments used to be left behind by hard deletes, drop the orphans before adding the constraint
+DELETE FROM comments WHERE post_id NOT IN (SELECT id FROM posts);
+
+ALTER TABLE comments
+ADD CONSTRAINT fk_comments_post FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE comments DROP CONSTRAINT IF EXISTS fk_comments_post;
+
+DROP INDEX IF EXISTS idx_posts_deleted_at;
+
+ALTER TABLE posts DROP COLUMN deleted_at;
+-- +goose StatementEnd
@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE posts ADD COLUMN content_html text NOT NULL DEFAULT '';
+
+-- existing posts were plain text, escape them and keep their line breaks: blank lines
+-- separate paragraphs and single newlines become <br>
+UPDATE posts SET content_html = '<p>' || replace(regexp_replace(
+  replace(replace(replace(replace(replace(replace(content,
+    E'\r\n', E'\n'), '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '"', '&#34;'), '''', '&#39;'),
+  E'\n{2,}', '</p><p>', 'g'), E'\n', '<br>') || '</p>';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE posts DROP COLUMN content_html;
+-- +goose StatementEnd
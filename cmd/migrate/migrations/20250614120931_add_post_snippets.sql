@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS post_snippets (
+  id bigserial PRIMARY KEY,
+  post_id bigint NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+  position int NOT NULL DEFAULT 0,
+  filename varchar(255) NOT NULL,
+  language varchar(50) NOT NULL,
+  body text NOT NULL,
+  body_html text NOT NULL,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  UNIQUE (post_id, filename)
+);
+
+CREATE INDEX IF NOT EXISTS idx_post_snippets_post_id ON post_snippets (post_id, position);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS post_snippets;
+-- +goose StatementEnd
@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS mentions (
+  id bigserial PRIMARY KEY,
+  post_id bigint NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+  comment_id bigint REFERENCES comments(id) ON DELETE CASCADE,
+  author_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  mentioned_user_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  "offset" int NOT NULL,
+  length int NOT NULL,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_mentions_mentioned_user ON mentions (mentioned_user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_mentions_source ON mentions (post_id, comment_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS mentions;
+-- +goose StatementEnd
@@ -0,0 +1,78 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS tags (
+  id bigserial PRIMARY KEY,
+  name citext UNIQUE NOT NULL,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+-- alternative spellings that resolve to a canonical tag ("golang" -> "go")
+CREATE TABLE IF NOT EXISTS tag_aliases (
+  alias citext PRIMARY KEY,
+  tag_id bigint NOT NULL REFERENCES tags(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS tag_follows (
+  user_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  tag_id bigint NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  PRIMARY KEY (user_id, tag_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_tag_follows_tag_id ON tag_follows (tag_id);
+CREATE INDEX IF NOT EXISTS idx_posts_created_at ON posts (created_at);
+
+INSERT INTO tags (name) VALUES
+  ('go'), ('javascript'), ('typescript'), ('kubernetes'), ('postgresql'), ('python'), ('machine-learning');
+
+INSERT INTO tag_aliases (alias, tag_id) VALUES
+  ('golang', (SELECT id FROM tags WHERE name = 'go')),
+  ('js', (SELECT id FROM tags WHERE name = 'javascript')),
+  ('ts', (SELECT id FROM tags WHERE name = 'typescript')),
+  ('k8s', (SELECT id FROM tags WHERE name = 'kubernetes')),
+  ('postgres', (SELECT id FROM tags WHERE name = 'postgresql')),
+  ('py', (SELECT id FROM tags WHERE name = 'python')),
+  ('ml', (SELECT id FROM tags WHERE name = 'machine-learning'));
+
+-- normalize the tags of existing posts with the rules of `tags.Normalize`, step by step:
+-- trim whitespace, lower case and drop leading `#`, drop characters other than letters,
+-- digits, `+#.` and separators, collapse runs of whitespace, `_` and `-` into one `-`
+-- (none at either end), trim `.` and cut at 100 characters. Then resolve aliases and
+-- drop empty and duplicate tags, keeping the original order like `canonicalTags`.
+UPDATE posts p SET tags = (
+  SELECT COALESCE(array_agg(c.tag ORDER BY c.position), '{}')
+  FROM (
+    SELECT lower(COALESCE(t.name::varchar, n.tag)) AS tag, min(n.position) AS position
+    FROM (
+      SELECT CASE WHEN char_length(s.tag) > 100 THEN rtrim(left(s.tag, 100), '-.') ELSE s.tag END AS tag, s.position
+      FROM (
+        SELECT trim(both '.' from trim(both '-' from regexp_replace(
+          regexp_replace(
+            ltrim(regexp_replace(lower(r.raw), '^[[:space:]]+|[[:space:]]+$', '', 'g'), '#'),
+            '[^[:alnum:][:space:]_+#.-]', '', 'g'
+          ),
+          '[[:space:]_-]+', '-', 'g'
+        ))) AS tag, r.position
+        FROM unnest(p.tags) WITH ORDINALITY AS r(raw, position)
+      ) s
+    ) n
+    LEFT JOIN tag_aliases a ON a.alias = n.tag
+    LEFT JOIN tags t ON t.id = a.tag_id
+    WHERE n.tag <> ''
+    GROUP BY 1
+  ) c
+)
+WHERE p.tags IS NOT NULL;
+
+INSERT INTO tags (name)
+SELECT DISTINCT unnest(tags) FROM posts
+ON CONFLICT (name) DO NOTHING;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_posts_created_at;
+DROP TABLE IF EXISTS tag_follows;
+DROP TABLE IF EXISTS tag_aliases;
+DROP TABLE IF EXISTS tags;
+-- +goose StatementEnd
@@ -0,0 +1,68 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE posts ADD COLUMN search_vector tsvector;
+ALTER TABLE comments ADD COLUMN search_vector tsvector;
+ALTER TABLE users ADD COLUMN search_vector tsvector;
+
+-- array_to_string is not immutable so the vectors can't be generated columns, triggers keep them up to date
+CREATE OR REPLACE FUNCTION posts_search_vector_update() RETURNS trigger AS $$
+BEGIN
+  NEW.search_vector :=
+    setweight(to_tsvector('english', coalesce(NEW.title, '')), 'A') ||
+    setweight(to_tsvector('english', coalesce(array_to_string(NEW.tags, ' '), '')), 'B') ||
+    setweight(to_tsvector('english', coalesce(NEW.content, '')), 'C');
+  RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION comments_search_vector_update() RETURNS trigger AS $$
+BEGIN
+  NEW.search_vector := setweight(to_tsvector('english', coalesce(NEW.content, '')), 'C');
+  RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+-- usernames are not natural language, don't stem them
+CREATE OR REPLACE FUNCTION users_search_vector_update() RETURNS trigger AS $$
+BEGIN
+  NEW.search_vector := setweight(to_tsvector('simple', coalesce(NEW.username::text, '')), 'A');
+  RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER posts_search_vector_trigger
+BEFORE INSERT OR UPDATE OF title, tags, content ON posts
+FOR EACH ROW EXECUTE FUNCTION posts_search_vector_update();
+
+CREATE TRIGGER comments_search_vector_trigger
+BEFORE INSERT OR UPDATE OF content ON comments
+FOR EACH ROW EXECUTE FUNCTION comments_search_vector_update();
+
+CREATE TRIGGER users_search_vector_trigger
+BEFORE INSERT OR UPDATE OF username ON users
+FOR EACH ROW EXECUTE FUNCTION users_search_vector_update();
+
+-- backfill, the triggers fire on these updates
+UPDATE posts SET title = title;
+UPDATE comments SET content = content;
+UPDATE users SET username = username;
+
+CREATE INDEX IF NOT EXISTS idx_posts_search_vector ON posts USING gin (search_vector);
+CREATE INDEX IF NOT EXISTS idx_comments_search_vector ON comments USING gin (search_vector);
+CREATE INDEX IF NOT EXISTS idx_users_search_vector ON users USING gin (search_vector);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER IF EXISTS posts_search_vector_trigger ON posts;
+DROP TRIGGER IF EXISTS comments_search_vector_trigger ON comments;
+DROP TRIGGER IF EXISTS users_search_vector_trigger ON users;
+
+DROP FUNCTION IF EXISTS posts_search_vector_update();
+DROP FUNCTION IF EXISTS comments_search_vector_update();
+DROP FUNCTION IF EXISTS users_search_vector_update();
+
+ALTER TABLE posts DROP COLUMN search_vector;
+ALTER TABLE comments DROP COLUMN search_vector;
+ALTER TABLE users DROP COLUMN search_vector;
+-- +goose StatementEnd
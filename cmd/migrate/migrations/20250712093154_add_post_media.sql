@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+-- post_id and user_id are set to NULL instead of cascading, so the rows of deleted
+-- posts stay around until a job has removed their blobs from storage
+CREATE TABLE IF NOT EXISTS post_media (
+  id bigserial PRIMARY KEY,
+  post_id bigint REFERENCES posts(id) ON DELETE SET NULL,
+  user_id bigint REFERENCES users(id) ON DELETE SET NULL,
+  position int NOT NULL DEFAULT 0,
+  storage_key varchar(512) NOT NULL UNIQUE,
+  thumbnail_key varchar(512) NOT NULL UNIQUE,
+  content_type varchar(50) NOT NULL,
+  size_bytes bigint NOT NULL,
+  width int NOT NULL,
+  height int NOT NULL,
+  alt_text varchar(500) NOT NULL DEFAULT '',
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_post_media_post_id ON post_media (post_id, position);
+CREATE INDEX IF NOT EXISTS idx_post_media_detached ON post_media (id) WHERE post_id IS NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS post_media;
+-- +goose StatementEnd
@@ -0,0 +1,50 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users
+  ADD COLUMN display_name varchar(50) NOT NULL DEFAULT '',
+  ADD COLUMN bio varchar(300) NOT NULL DEFAULT '',
+  ADD COLUMN location varchar(100) NOT NULL DEFAULT '',
+  ADD COLUMN website varchar(200) NOT NULL DEFAULT '',
+  ADD COLUMN github varchar(39) NOT NULL DEFAULT '',
+  ADD COLUMN avatar_key varchar(512),
+  ADD COLUMN avatar_thumbnail_key varchar(512);
+
+-- display names are searchable too, they are natural language unlike usernames
+CREATE OR REPLACE FUNCTION users_search_vector_update() RETURNS trigger AS $$
+BEGIN
+  NEW.search_vector :=
+    setweight(to_tsvector('simple', coalesce(NEW.username::text, '')), 'A') ||
+    setweight(to_tsvector('simple', coalesce(NEW.display_name, '')), 'B');
+  RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS users_search_vector_trigger ON users;
+CREATE TRIGGER users_search_vector_trigger
+BEFORE INSERT OR UPDATE OF username, display_name ON users
+FOR EACH ROW EXECUTE FUNCTION users_search_vector_update();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION users_search_vector_update() RETURNS trigger AS $$
+BEGIN
+  NEW.search_vector := setweight(to_tsvector('simple', coalesce(NEW.username::text, '')), 'A');
+  RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS users_search_vector_trigger ON users;
+CREATE TRIGGER users_search_vector_trigger
+BEFORE INSERT OR UPDATE OF username ON users
+FOR EACH ROW EXECUTE FUNCTION users_search_vector_update();
+
+ALTER TABLE users
+  DROP COLUMN display_name,
+  DROP COLUMN bio,
+  DROP COLUMN location,
+  DROP COLUMN website,
+  DROP COLUMN github,
+  DROP COLUMN avatar_key,
+  DROP COLUMN avatar_thumbnail_key;
+-- +goose StatementEnd
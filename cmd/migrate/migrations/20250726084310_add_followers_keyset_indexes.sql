@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+-- followers and following lists are paged newest follow first
+CREATE INDEX IF NOT EXISTS idx_followers_user_id_created_at ON followers (user_id, created_at DESC, follower_id DESC);
+CREATE INDEX IF NOT EXISTS idx_followers_follower_id_created_at ON followers (follower_id, created_at DESC, user_id DESC);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_followers_user_id_created_at;
+DROP INDEX IF EXISTS idx_followers_follower_id_created_at;
+-- +goose StatementEnd
@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users ADD COLUMN is_private boolean NOT NULL DEFAULT false;
+
+-- pending follows of private accounts, moved to followers once approved
+CREATE TABLE IF NOT EXISTS follow_requests (
+  user_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  follower_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  PRIMARY KEY (user_id, follower_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_follow_requests_user_id_created_at ON follow_requests (user_id, created_at DESC, follower_id DESC);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS follow_requests;
+ALTER TABLE users DROP COLUMN is_private;
+-- +goose StatementEnd
@@ -0,0 +1,41 @@
+-- +goose Up
+-- +goose StatementBegin
+-- set when the user asked to delete their account, a job removes it once the grace period is over
+ALTER TABLE users ADD COLUMN deletion_scheduled_at timestamp(0) with time zone;
+
+CREATE INDEX IF NOT EXISTS idx_users_deletion_scheduled_at ON users (deletion_scheduled_at) WHERE deletion_scheduled_at IS NOT NULL;
+
+-- comments didn't reference their author, deleted users used to leave orphans behind
+DELETE FROM comments WHERE user_id NOT IN (SELECT id FROM users);
+
+ALTER TABLE comments
+ADD CONSTRAINT fk_comments_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+
+-- ZIP archives of a user's data, the file lives in blob storage until expires_at.
+-- user_id is set to NULL instead of cascading, so a job can still remove the files of deleted users
+CREATE TABLE IF NOT EXISTS data_exports (
+  id bigserial PRIMARY KEY,
+  user_id bigint REFERENCES users(id) ON DELETE SET NULL,
+  status varchar(20) NOT NULL DEFAULT 'pending',
+  storage_key varchar(512),
+  size_bytes bigint NOT NULL DEFAULT 0,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  completed_at timestamp(0) with time zone,
+  expires_at timestamp(0) with time zone NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_data_exports_user_id ON data_exports (user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_data_exports_expires_at ON data_exports (expires_at);
+CREATE INDEX IF NOT EXISTS idx_data_exports_orphaned ON data_exports (id) WHERE user_id IS NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS data_exports;
+
+ALTER TABLE comments DROP CONSTRAINT IF EXISTS fk_comments_user;
+
+DROP INDEX IF EXISTS idx_users_deletion_scheduled_at;
+
+ALTER TABLE users DROP COLUMN deletion_scheduled_at;
+-- +goose StatementEnd
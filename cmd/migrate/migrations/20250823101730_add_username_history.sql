@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users ADD COLUMN username_changed_at timestamp(0) with time zone;
+
+-- previous usernames, reserved for their last owner until reserved_until so old links and
+-- mentions keep resolving. A handle has one row, for the last user who gave it up.
+CREATE TABLE IF NOT EXISTS username_history (
+  username citext PRIMARY KEY,
+  user_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  changed_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  reserved_until timestamp(0) with time zone NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_username_history_user_id ON username_history (user_id, changed_at DESC);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS username_history;
+
+ALTER TABLE users DROP COLUMN username_changed_at;
+-- +goose StatementEnd
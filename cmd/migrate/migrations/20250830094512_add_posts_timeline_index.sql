@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+-- timelines are backfilled, trimmed and rebuilt from the latest live posts of each author
+CREATE INDEX IF NOT EXISTS idx_posts_user_id_created_at ON posts (user_id, created_at DESC, id DESC) WHERE deleted_at IS NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_posts_user_id_created_at;
+-- +goose StatementEnd
@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+-- the ranked feed counts how often a user recently commented on or mentioned each author
+CREATE INDEX IF NOT EXISTS idx_comments_user_id_created_at ON comments (user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_mentions_author_created_at ON mentions (author_id, created_at DESC);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_comments_user_id_created_at;
+DROP INDEX IF EXISTS idx_mentions_author_created_at;
+-- +goose StatementEnd
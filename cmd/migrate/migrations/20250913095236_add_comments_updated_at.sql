@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+-- set when a comment is edited, so clients can mark it as edited
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS updated_at timestamp(0) with time zone;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE comments DROP COLUMN IF EXISTS updated_at;
+-- +goose StatementEnd
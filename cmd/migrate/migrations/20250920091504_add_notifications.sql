@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS notifications (
+  id bigserial PRIMARY KEY,
+  user_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  type text NOT NULL,
+  actor_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  post_id bigint REFERENCES posts(id) ON DELETE CASCADE,
+  comment_id bigint REFERENCES comments(id) ON DELETE CASCADE,
+  -- notifications with the same key are listed as one ("ana and 4 others commented on your post")
+  group_key text NOT NULL,
+  read_at timestamp(0) with time zone,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_notifications_user_group ON notifications (user_id, group_key);
+CREATE INDEX IF NOT EXISTS idx_notifications_unread ON notifications (user_id) WHERE read_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_notifications_created_at ON notifications (created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS notifications;
+-- +goose StatementEnd
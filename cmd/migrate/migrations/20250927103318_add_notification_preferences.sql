@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+-- types without a row are delivered in-app
+CREATE TABLE IF NOT EXISTS notification_preferences (
+  user_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  type text NOT NULL,
+  delivery text NOT NULL CHECK (delivery IN ('in_app', 'email', 'digest', 'off')),
+  PRIMARY KEY (user_id, type)
+);
+
+CREATE TABLE IF NOT EXISTS notification_digests (
+  user_id bigint PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+  frequency text NOT NULL DEFAULT 'daily' CHECK (frequency IN ('daily', 'weekly')),
+  last_sent_at timestamp(0) with time zone
+);
+
+CREATE INDEX IF NOT EXISTS idx_notification_preferences_digest ON notification_preferences (user_id) WHERE delivery = 'digest';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS notification_digests;
+DROP TABLE IF EXISTS notification_preferences;
+-- +goose StatementEnd
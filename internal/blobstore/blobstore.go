@@ -0,0 +1,56 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNotFound   = errors.New("blob not found")
+	ErrInvalidKey = errors.New("invalid blob key")
+)
+
+// `Store` keeps binary objects by key. Keys are slash separated paths like
+// `media/2025/07/abc.jpg`, without `..` segments or a leading slash.
+type Store interface {
+	// `Put` stores `size` bytes read from `r` under `key`, replacing any existing object
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+
+	// `Get` opens an object, the caller closes the reader. Returns `ErrNotFound` when missing.
+	Get(ctx context.Context, key string) (io.ReadCloser, *Info, error)
+
+	// `Delete` removes an object, deleting a missing object is not an error
+	Delete(ctx context.Context, key string) error
+}
+
+// `Info` describes a stored object
+type Info struct {
+	Size        int64
+	ContentType string
+	ModTime     time.Time
+}
+
+// `ValidKey` reports whether `key` is safe to use as a path in every backend
+func ValidKey(key string) bool {
+	if key == "" || len(key) > 512 || strings.HasPrefix(key, "/") || strings.HasSuffix(key, "/") {
+		return false
+	}
+
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return false
+		}
+	}
+
+	for _, r := range key {
+		valid := r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("/._-", r)
+		if !valid {
+			return false
+		}
+	}
+
+	return true
+}
@@ -7,6 +7,7 @@ import (
 	"log"
 	"math/rand"
 
+	"github.com/elhambadri2411/social/internal/markdown"
 	"github.com/elhambadri2411/social/internal/store"
 )
 
@@ -202,6 +203,12 @@ func Seed(store store.Storage, db *sql.DB) error {
 
 	posts := generatePosts(200, users)
 	for _, post := range posts {
+		contentHTML, err := markdown.Render(post.Content)
+		if err != nil {
+			return err
+		}
+		post.ContentHTML = contentHTML
+
 		if err := store.PostsRepository.Create(ctx, post); err != nil {
 			return err
 		}
@@ -0,0 +1,74 @@
+package highlight
+
+import (
+	"bytes"
+	"io"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+)
+
+const (
+	// `ClassPrefix` is prepended to every CSS class emitted, so the stylesheet can't clash with the frontend's
+	ClassPrefix = "hl-"
+	// `Plaintext` is the language reported when nothing else matched
+	Plaintext = "plaintext"
+	// `StyleName` is the chroma style used to generate the stylesheet
+	StyleName = "github"
+)
+
+var formatter = html.New(
+	html.WithClasses(true),
+	html.ClassPrefix(ClassPrefix),
+	html.TabWidth(4),
+	html.WithLineNumbers(true),
+	html.LineNumbersInTable(true),
+)
+
+// `Highlight` renders `body` to HTML using CSS classes (see `Stylesheet`).
+//
+// The lexer is picked from `language` if given, then from the `filename` extension,
+// then by analysing the body itself. Returns the HTML and the canonical name of the
+// language that was used.
+func Highlight(filename, language, body string) (string, string, error) {
+	lexer, name := detect(filename, language, body)
+
+	iterator, err := lexer.Tokenise(nil, body)
+	if err != nil {
+		return "", "", err
+	}
+
+	var buf bytes.Buffer
+	if err := formatter.Format(&buf, styles.Get(StyleName), iterator); err != nil {
+		return "", "", err
+	}
+
+	return buf.String(), name, nil
+}
+
+// `Stylesheet` writes the CSS matching the classes emitted by `Highlight`
+func Stylesheet(w io.Writer) error {
+	return formatter.WriteCSS(w, styles.Get(StyleName))
+}
+
+func detect(filename, language, body string) (chroma.Lexer, string) {
+	var lexer chroma.Lexer
+
+	if language != "" {
+		lexer = lexers.Get(language)
+	}
+	if lexer == nil && filename != "" {
+		lexer = lexers.Match(filename)
+	}
+	if lexer == nil {
+		lexer = lexers.Analyse(body)
+	}
+	if lexer == nil {
+		return chroma.Coalesce(lexers.Fallback), Plaintext
+	}
+
+	return chroma.Coalesce(lexer), strings.ToLower(lexer.Config().Name)
+}
@@ -0,0 +1,50 @@
+package highlight
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		name     string
+		filename string
+		language string
+		body     string
+		want     string
+	}{
+		{"explicit language wins", "notes.txt", "python", "print(1)", "python"},
+		{"filename extension", "main.go", "", "package main\n", "go"},
+		{"content analysis", "script", "", "#!/bin/bash\necho hi\n", "bash"},
+		{"plaintext fallback", "notes", "", "just some words", Plaintext},
+		{"unknown language falls back to filename", "main.go", "not-a-language", "package main\n", "go"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			html, language, err := Highlight(tt.filename, tt.language, tt.body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if language != tt.want {
+				t.Errorf("expected language %q, got %q", tt.want, language)
+			}
+
+			if !strings.Contains(html, `class="`+ClassPrefix) {
+				t.Errorf("expected prefixed CSS classes in %s", html)
+			}
+		})
+	}
+
+	t.Run("escapes markup in the body", func(t *testing.T) {
+		html, _, err := Highlight("index.html", "", "<script>alert(1)</script>")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if strings.Contains(html, "<script>") {
+			t.Errorf("expected escaped body, got %s", html)
+		}
+	})
+}
@@ -3,9 +3,11 @@ package mailer
 import "embed"
 
 const (
-	FromName            = "DevSocial"
-	MaxRetries          = 3
-	UserWelcomeTemplate = "user_invitation.tmpl"
+	FromName             = "DevSocial"
+	MaxRetries           = 3
+	UserWelcomeTemplate  = "user_invitation.tmpl"
+	NotificationTemplate = "notification.tmpl"
+	DigestTemplate       = "digest.tmpl"
 )
 
 //go:embed "templates"
@@ -13,4 +15,6 @@ var FS embed.FS
 
 type Client interface {
 	Send(templateFile, username, email string, data any, isSandbox bool) (int, error)
+	// `SendWithHeaders` is `Send` with extra headers on the message, like `List-Unsubscribe`
+	SendWithHeaders(templateFile, username, email string, data any, headers map[string]string, isSandbox bool) (int, error)
 }
@@ -28,6 +28,10 @@ func NewSendgrid(apiKey, fromEmail string) *SendGridMailer {
 }
 
 func (m *SendGridMailer) Send(templateFile, username, email string, data any, isSandbox bool) (int, error) {
+	return m.SendWithHeaders(templateFile, username, email, data, nil, isSandbox)
+}
+
+func (m *SendGridMailer) SendWithHeaders(templateFile, username, email string, data any, headers map[string]string, isSandbox bool) (int, error) {
 	from := mail.NewEmail(FromName, m.fromEmail)
 	to := mail.NewEmail(username, email)
 
@@ -48,6 +52,9 @@ func (m *SendGridMailer) Send(templateFile, username, email string, data any, is
 		return -1, err
 	}
 	message := mail.NewSingleEmail(from, subject.String(), to, "", body.String())
+	for key, value := range headers {
+		message.SetHeader(key, value)
+	}
 
 	// isSandbox = false
 	message.SetMailSettings(&mail.MailSettings{
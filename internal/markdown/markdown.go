@@ -0,0 +1,53 @@
+package markdown
+
+import (
+	"bytes"
+	"regexp"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+// `renderer` converts GitHub flavoured Markdown (tables, fenced code blocks, strikethrough,
+// autolinks, task lists) to HTML. Raw HTML in the source is dropped by goldmark since
+// `html.WithUnsafe` is not set, the sanitizer below is a second line of defense.
+// Headings get no ids, ids picked by users could clobber globals or elements of the page.
+var renderer = goldmark.New(
+	goldmark.WithExtensions(extension.GFM),
+)
+
+// `policy` is the allow-list applied to every rendered document.
+var policy = newPolicy()
+
+func newPolicy() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+
+	// fenced code blocks keep their language so clients can highlight them
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[a-zA-Z0-9_+#.-]+$`)).OnElements("code")
+
+	// GFM task lists render as disabled checkboxes
+	p.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	p.AllowAttrs("checked", "disabled").Matching(regexp.MustCompile(`^(checked|disabled|)$`)).OnElements("input")
+
+	// links written by users should not pass reputation or open the app in the same context
+	p.RequireNoFollowOnLinks(true)
+	p.AddTargetBlankToFullyQualifiedLinks(true)
+
+	return p
+}
+
+// `Render` converts Markdown `source` into sanitized HTML that is safe to embed in a page.
+func Render(source string) (string, error) {
+	var buf bytes.Buffer
+	if err := renderer.Convert([]byte(source), &buf); err != nil {
+		return "", err
+	}
+
+	return Sanitize(buf.String()), nil
+}
+
+// `Sanitize` strips every element and attribute that is not on the allow-list from `html`.
+func Sanitize(html string) string {
+	return policy.Sanitize(html)
+}
@@ -0,0 +1,121 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+var xssPayloads = []string{
+	`<script>alert(1)</script>`,
+	`<img src=x onerror=alert(1)>`,
+	`<svg/onload=alert(1)>`,
+	`<iframe src="javascript:alert(1)"></iframe>`,
+	`[click](javascript:alert(1))`,
+	`[click](JaVaScRiPt:alert(1))`,
+	`[click](&#106;avascript:alert(1))`,
+	`![img](javascript:alert(1))`,
+	`<a href="data:text/html;base64,PHNjcmlwdD5hbGVydCgxKTwvc2NyaXB0Pg==">x</a>`,
+	`<div style="background:url(javascript:alert(1))">x</div>`,
+	"```\"><script>alert(1)</script>\n```",
+	"```js onload=alert(1)\ncode\n```",
+	`<details open ontoggle=alert(1)>`,
+	`<math><mtext><table><mglyph><style><img src=x onerror=alert(1)>`,
+	`[x](<javascript:alert(1)>)`,
+	`<form action="javascript:alert(1)"><input type=submit>`,
+}
+
+func TestRender(t *testing.T) {
+	t.Run("renders GFM tables and fenced code blocks", func(t *testing.T) {
+		out, err := Render("| a | b |\n|---|---|\n| 1 | 2 |\n\n```go\nfmt.Println(\"hi\")\n```\n")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, want := range []string{"<table>", "<td>1</td>", `<code class="language-go">`, "fmt.Println(&#34;hi&#34;)"} {
+			if !strings.Contains(out, want) {
+				t.Errorf("expected %q in output, got %s", want, out)
+			}
+		}
+	})
+
+	t.Run("renders headings without ids", func(t *testing.T) {
+		out, err := Render("# location\n\n## cookie")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if strings.Contains(out, "id=") {
+			t.Errorf("expected no id attributes, got %s", out)
+		}
+	})
+
+	t.Run("neutralizes known XSS payloads", func(t *testing.T) {
+		for _, payload := range xssPayloads {
+			out, err := Render(payload)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertSafeHTML(t, payload, out)
+		}
+	})
+}
+
+func FuzzRender(f *testing.F) {
+	for _, payload := range xssPayloads {
+		f.Add(payload)
+	}
+	f.Add("# title\n\n*emphasis* and `code`\n\n- [x] done\n- [ ] todo")
+
+	f.Fuzz(func(t *testing.T, source string) {
+		out, err := Render(source)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertSafeHTML(t, source, out)
+	})
+}
+
+// `assertSafeHTML` parses `out` the way a browser would and fails on any element,
+// attribute or URL that could execute script.
+func assertSafeHTML(t *testing.T, source, out string) {
+	t.Helper()
+
+	forbiddenTags := map[string]bool{
+		"script": true, "iframe": true, "object": true, "embed": true, "style": true,
+		"form": true, "svg": true, "math": true, "link": true, "meta": true, "base": true,
+	}
+
+	z := html.NewTokenizer(strings.NewReader(out))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			return
+		}
+		if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+			continue
+		}
+
+		token := z.Token()
+		if forbiddenTags[token.Data] {
+			t.Fatalf("forbidden <%s> in output\nsource: %q\noutput: %q", token.Data, source, out)
+		}
+
+		for _, attr := range token.Attr {
+			key := strings.ToLower(attr.Key)
+			if strings.HasPrefix(key, "on") || key == "style" || key == "srcdoc" || key == "formaction" {
+				t.Fatalf("forbidden attribute %q in output\nsource: %q\noutput: %q", key, source, out)
+			}
+
+			if key == "href" || key == "src" {
+				value := strings.ToLower(strings.Join(strings.Fields(attr.Val), ""))
+				for _, scheme := range []string{"javascript:", "vbscript:", "data:"} {
+					if strings.HasPrefix(value, scheme) {
+						t.Fatalf("forbidden %s URL %q in output\nsource: %q\noutput: %q", scheme, attr.Val, source, out)
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package media
+
+import (
+	"errors"
+)
+
+var errBadGIF = errors.New("malformed gif")
+
+// `gifFrames` counts the frames of a GIF by walking its blocks, without decoding any pixels
+func gifFrames(data []byte) (int, error) {
+	if len(data) < 13 {
+		return 0, errBadGIF
+	}
+
+	// header and logical screen descriptor, then the global color table if there is one
+	i := 13
+	if flags := data[10]; flags&0x80 != 0 {
+		i += 3 << ((flags & 0x07) + 1)
+	}
+
+	frames := 0
+	for i < len(data) {
+		switch data[i] {
+		case 0x21: // extension: introducer, label, then data sub-blocks
+			next, err := skipSubBlocks(data, i+2)
+			if err != nil {
+				return frames, err
+			}
+			i = next
+		case 0x2C: // image descriptor, local color table, LZW code size, then data sub-blocks
+			if i+10 > len(data) {
+				return frames, errBadGIF
+			}
+			next := i + 10
+			if flags := data[i+9]; flags&0x80 != 0 {
+				next += 3 << ((flags & 0x07) + 1)
+			}
+
+			next, err := skipSubBlocks(data, next+1)
+			if err != nil {
+				return frames, err
+			}
+			i = next
+			frames++
+		case 0x3B: // trailer
+			return frames, nil
+		default:
+			return frames, errBadGIF
+		}
+	}
+
+	return frames, errBadGIF
+}
+
+// `skipSubBlocks` returns the position after the sub-blocks starting at `i` and their terminator
+func skipSubBlocks(data []byte, i int) (int, error) {
+	for i < len(data) {
+		size := int(data[i])
+		i++
+		if size == 0 {
+			return i, nil
+		}
+		i += size
+	}
+
+	return i, errBadGIF
+}
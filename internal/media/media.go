@@ -0,0 +1,235 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/webp"
+)
+
+var (
+	ErrUnsupportedType = errors.New("unsupported image type, use JPEG, PNG, GIF or WebP")
+	ErrTooManyPixels   = errors.New("image dimensions are too large")
+)
+
+const (
+	jpegQuality      = 85
+	thumbnailQuality = 80
+	maxGIFFrames     = 300
+)
+
+// `Limits` bounds the work done on an upload
+type Limits struct {
+	MaxPixels     int // width * height of the image (or of each GIF frame)
+	MaxGIFPixels  int // width * height * frames of a GIF, what decoding every frame costs
+	ThumbnailSize int // thumbnails fit in a square of this size
+}
+
+// `Image` is an encoded image ready to be stored
+type Image struct {
+	Data        []byte
+	ContentType string
+	Ext         string
+	Width       int
+	Height      int
+}
+
+// `Processed` is an upload after processing and its thumbnail
+type Processed struct {
+	Original  Image
+	Thumbnail Image
+}
+
+// `Process` validates an uploaded image by sniffing its content, then re-encodes it.
+// Re-encoding drops every metadata block (EXIF, XMP, comments) after the EXIF
+// orientation has been applied to the pixels. WebP is converted to PNG since there
+// is no encoder for it, GIF animations are kept.
+func Process(data []byte, limits Limits) (*Processed, error) {
+	contentType, config, err := sniff(data, limits)
+	if err != nil {
+		return nil, err
+	}
+
+	var original Image
+	var first image.Image
+
+	switch contentType {
+	case "image/gif":
+		// every frame is decoded at up to the size of the logical screen,
+		// so the frames are counted before any of them is
+		frames, err := gifFrames(data)
+		if err != nil || frames == 0 {
+			return nil, ErrUnsupportedType
+		}
+		if frames > maxGIFFrames || frames*config.Width*config.Height > limits.MaxGIFPixels {
+			return nil, ErrTooManyPixels
+		}
+
+		anim, err := gif.DecodeAll(bytes.NewReader(data))
+		if err != nil {
+			return nil, ErrUnsupportedType
+		}
+
+		var buf bytes.Buffer
+		if err := gif.EncodeAll(&buf, anim); err != nil {
+			return nil, err
+		}
+
+		original = Image{Data: buf.Bytes(), ContentType: "image/gif", Ext: ".gif"}
+		first = anim.Image[0]
+	case "image/jpeg":
+		img, err := jpeg.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, ErrUnsupportedType
+		}
+		img = orient(img, exifOrientation(data))
+
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality}); err != nil {
+			return nil, err
+		}
+
+		original = Image{Data: buf.Bytes(), ContentType: "image/jpeg", Ext: ".jpg"}
+		first = img
+	default:
+		var img image.Image
+		if contentType == "image/webp" {
+			img, err = webp.Decode(bytes.NewReader(data))
+		} else {
+			img, err = png.Decode(bytes.NewReader(data))
+		}
+		if err != nil {
+			return nil, ErrUnsupportedType
+		}
+
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			return nil, err
+		}
+
+		original = Image{Data: buf.Bytes(), ContentType: "image/png", Ext: ".png"}
+		first = img
+	}
+
+	bounds := first.Bounds()
+	if contentType == "image/gif" {
+		bounds = image.Rect(0, 0, config.Width, config.Height)
+	}
+	original.Width, original.Height = bounds.Dx(), bounds.Dy()
+
+	thumbnail, err := thumbnail(first, limits.ThumbnailSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Processed{Original: original, Thumbnail: *thumbnail}, nil
+}
+
+// `Avatar` crops the center square of an uploaded image and scales it to `size`, with
+// a thumbnail of `limits.ThumbnailSize`. Both are JPEG, only the first frame of a GIF is kept.
+func Avatar(data []byte, limits Limits, size int) (*Processed, error) {
+	contentType, _, err := sniff(data, limits)
+	if err != nil {
+		return nil, err
+	}
+
+	var img image.Image
+	switch contentType {
+	case "image/webp":
+		img, err = webp.Decode(bytes.NewReader(data))
+	default:
+		// image/gif decodes the first frame
+		img, _, err = image.Decode(bytes.NewReader(data))
+	}
+	if err != nil {
+		return nil, ErrUnsupportedType
+	}
+	if contentType == "image/jpeg" {
+		img = orient(img, exifOrientation(data))
+	}
+
+	bounds := img.Bounds()
+	side := min(bounds.Dx(), bounds.Dy())
+	x0 := bounds.Min.X + (bounds.Dx()-side)/2
+	y0 := bounds.Min.Y + (bounds.Dy()-side)/2
+	square := image.Rect(x0, y0, x0+side, y0+side)
+
+	dst := image.NewRGBA(image.Rect(0, 0, min(side, size), min(side, size)))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, square, draw.Over, nil)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: jpegQuality}); err != nil {
+		return nil, err
+	}
+
+	thumbnail, err := thumbnail(dst, limits.ThumbnailSize)
+	if err != nil {
+		return nil, err
+	}
+
+	original := Image{Data: buf.Bytes(), ContentType: "image/jpeg", Ext: ".jpg", Width: dst.Rect.Dx(), Height: dst.Rect.Dy()}
+	return &Processed{Original: original, Thumbnail: *thumbnail}, nil
+}
+
+// `sniff` detects the type of an upload from its content and checks its dimensions
+// before anything is decoded, so a small file can't claim a huge canvas
+func sniff(data []byte, limits Limits) (string, image.Config, error) {
+	contentType := http.DetectContentType(data)
+	switch contentType {
+	case "image/jpeg", "image/png", "image/gif", "image/webp":
+	default:
+		return "", image.Config{}, ErrUnsupportedType
+	}
+
+	config, _, err := decodeConfig(contentType, data)
+	if err != nil {
+		return "", image.Config{}, ErrUnsupportedType
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > limits.MaxPixels {
+		return "", image.Config{}, ErrTooManyPixels
+	}
+
+	return contentType, config, nil
+}
+
+func decodeConfig(contentType string, data []byte) (image.Config, string, error) {
+	if contentType == "image/webp" {
+		config, err := webp.DecodeConfig(bytes.NewReader(data))
+		return config, "webp", err
+	}
+	return image.DecodeConfig(bytes.NewReader(data))
+}
+
+// `thumbnail` scales `img` to fit in a `size` square (never upscaling) and encodes
+// it as JPEG, transparent areas become white
+func thumbnail(img image.Image, size int) (*Image, error) {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	if width > size || height > size {
+		if width >= height {
+			width, height = size, max(1, height*size/width)
+		} else {
+			width, height = max(1, width*size/height), size
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Over, nil)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: thumbnailQuality}); err != nil {
+		return nil, err
+	}
+
+	return &Image{Data: buf.Bytes(), ContentType: "image/jpeg", Ext: ".jpg", Width: width, Height: height}, nil
+}
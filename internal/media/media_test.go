@@ -0,0 +1,213 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+var limits = Limits{MaxPixels: 1000 * 1000, MaxGIFPixels: 10 * 1000 * 1000, ThumbnailSize: 64}
+
+func encodeJPEG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// `withExif` inserts an APP1 segment carrying an orientation and a GPS marker string after the SOI
+func withExif(jpg []byte, orientation uint16) []byte {
+	tiff := []byte("II*\x00\x08\x00\x00\x00")
+	tiff = binary.LittleEndian.AppendUint16(tiff, 1)
+	tiff = binary.LittleEndian.AppendUint16(tiff, orientationTag)
+	tiff = binary.LittleEndian.AppendUint16(tiff, 3) // SHORT
+	tiff = binary.LittleEndian.AppendUint32(tiff, 1)
+	tiff = binary.LittleEndian.AppendUint16(tiff, orientation)
+	tiff = append(tiff, 0, 0, 0, 0, 0, 0)
+	tiff = append(tiff, []byte("GPS 51.5N 0.1W")...)
+
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	segment := []byte{0xFF, 0xE1}
+	segment = binary.BigEndian.AppendUint16(segment, uint16(len(payload)+2))
+	segment = append(segment, payload...)
+
+	out := append([]byte{}, jpg[:2]...)
+	out = append(out, segment...)
+	return append(out, jpg[2:]...)
+}
+
+func TestProcessJPEGStripsExifAndOrients(t *testing.T) {
+	// 40x20, left half red and right half blue
+	img := image.NewRGBA(image.Rect(0, 0, 40, 20))
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 40; x++ {
+			if x < 20 {
+				img.Set(x, y, color.RGBA{255, 0, 0, 255})
+			} else {
+				img.Set(x, y, color.RGBA{0, 0, 255, 255})
+			}
+		}
+	}
+
+	data := withExif(encodeJPEG(t, img), 6)
+	if got := exifOrientation(data); got != 6 {
+		t.Fatalf("exifOrientation() = %d, want 6", got)
+	}
+
+	processed, err := Process(data, limits)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Contains(processed.Original.Data, []byte("Exif")) || bytes.Contains(processed.Original.Data, []byte("GPS")) {
+		t.Error("EXIF data survived processing")
+	}
+
+	if processed.Original.Width != 20 || processed.Original.Height != 40 {
+		t.Errorf("got %dx%d, want the image rotated to 20x40", processed.Original.Width, processed.Original.Height)
+	}
+
+	// rotated clockwise: the red half is now on top
+	out, err := jpeg.Decode(bytes.NewReader(processed.Original.Data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, _, b, _ := out.At(10, 5).RGBA(); r < b {
+		t.Error("expected the top of the rotated image to be red")
+	}
+}
+
+func TestProcessThumbnail(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, 200, 100))); err != nil {
+		t.Fatal(err)
+	}
+
+	processed, err := Process(buf.Bytes(), limits)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if processed.Original.ContentType != "image/png" || processed.Original.Width != 200 {
+		t.Errorf("unexpected original %s %dx%d", processed.Original.ContentType, processed.Original.Width, processed.Original.Height)
+	}
+
+	thumb := processed.Thumbnail
+	if thumb.ContentType != "image/jpeg" || thumb.Width != 64 || thumb.Height != 32 {
+		t.Errorf("unexpected thumbnail %s %dx%d", thumb.ContentType, thumb.Width, thumb.Height)
+	}
+}
+
+func TestProcessKeepsGIFAnimation(t *testing.T) {
+	palette := color.Palette{color.Black, color.White}
+	anim := &gif.GIF{
+		Image: []*image.Paletted{
+			image.NewPaletted(image.Rect(0, 0, 10, 10), palette),
+			image.NewPaletted(image.Rect(0, 0, 10, 10), palette),
+		},
+		Delay: []int{10, 10},
+	}
+
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, anim); err != nil {
+		t.Fatal(err)
+	}
+
+	processed, err := Process(buf.Bytes(), limits)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := gif.DecodeAll(bytes.NewReader(processed.Original.Data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Image) != 2 {
+		t.Errorf("got %d frames, want 2", len(out.Image))
+	}
+}
+
+func TestProcessRejects(t *testing.T) {
+	if _, err := Process([]byte("<svg xmlns='http://www.w3.org/2000/svg'></svg>"), limits); err != ErrUnsupportedType {
+		t.Errorf("svg: got %v, want ErrUnsupportedType", err)
+	}
+
+	if _, err := Process([]byte("\xFF\xD8\xFF\xE0 truncated"), limits); err != ErrUnsupportedType {
+		t.Errorf("truncated jpeg: got %v, want ErrUnsupportedType", err)
+	}
+
+	big := encodeJPEG(t, image.NewGray(image.Rect(0, 0, 1001, 1000)))
+	if _, err := Process(big, limits); err != ErrTooManyPixels {
+		t.Errorf("large image: got %v, want ErrTooManyPixels", err)
+	}
+}
+
+func TestProcessRejectsGIFBombs(t *testing.T) {
+	// a few bytes per frame, but every frame decodes to the full 1000x1000 screen
+	palette := color.Palette{color.Black, color.White}
+	anim := &gif.GIF{Config: image.Config{ColorModel: palette, Width: 1000, Height: 1000}}
+	for range 11 {
+		anim.Image = append(anim.Image, image.NewPaletted(image.Rect(0, 0, 1, 1), palette))
+		anim.Delay = append(anim.Delay, 10)
+	}
+
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, anim); err != nil {
+		t.Fatal(err)
+	}
+
+	if frames, err := gifFrames(buf.Bytes()); err != nil || frames != 11 {
+		t.Fatalf("gifFrames() = %d, %v, want 11 frames", frames, err)
+	}
+
+	if _, err := Process(buf.Bytes(), limits); err != ErrTooManyPixels {
+		t.Errorf("got %v, want ErrTooManyPixels", err)
+	}
+
+	if _, err := gifFrames(buf.Bytes()[:buf.Len()-20]); err == nil {
+		t.Error("truncated gif: want an error")
+	}
+}
+
+func TestAvatarCropsCenterSquare(t *testing.T) {
+	// 30x10 with a green center square between red bands
+	img := image.NewRGBA(image.Rect(0, 0, 30, 10))
+	for y := 0; y < 10; y++ {
+		for x := 0; x < 30; x++ {
+			if x >= 10 && x < 20 {
+				img.Set(x, y, color.RGBA{0, 255, 0, 255})
+			} else {
+				img.Set(x, y, color.RGBA{255, 0, 0, 255})
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+
+	avatar, err := Avatar(buf.Bytes(), limits, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if avatar.Original.ContentType != "image/jpeg" || avatar.Original.Width != 8 || avatar.Original.Height != 8 {
+		t.Errorf("unexpected avatar %s %dx%d", avatar.Original.ContentType, avatar.Original.Width, avatar.Original.Height)
+	}
+
+	out, err := jpeg.Decode(bytes.NewReader(avatar.Original.Data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, g, _, _ := out.At(0, 4).RGBA(); r > g {
+		t.Error("expected the side bands to be cropped away")
+	}
+}
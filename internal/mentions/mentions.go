@@ -0,0 +1,127 @@
+package mentions
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// `MaxPerText` caps how many mentions are extracted from a single post or comment
+const MaxPerText = 50
+
+// `Entity` is an @username occurrence in a text.
+// `Offset` and `Length` are counted in Unicode code points and include the leading `@`.
+type Entity struct {
+	Username string `json:"username"`
+	Offset   int    `json:"offset"`
+	Length   int    `json:"length"`
+}
+
+// `Parse` extracts @username mentions from `text`.
+//
+// A mention starts with `@` that is not preceded by a letter, digit or `_` (so emails
+// don't match) and is followed by letters, digits, `_`, `-` or `.`. A trailing `.` or
+// `-` is treated as punctuation. Mentions inside inline code spans and fenced code
+// blocks are ignored.
+func Parse(text string) []Entity {
+	var entities []Entity
+
+	var (
+		prev        rune
+		runeOffset  int
+		inCodeSpan  bool
+		inCodeBlock bool
+		lineStart   = true
+	)
+
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+
+		switch {
+		case lineStart && strings.HasPrefix(text[i:], "```"):
+			inCodeBlock = !inCodeBlock
+		case r == '`' && !inCodeBlock:
+			inCodeSpan = !inCodeSpan
+		case r == '@' && !inCodeSpan && !inCodeBlock && !isWordRune(prev):
+			username, width := scanUsername(text[i+size:])
+			if username != "" && !strings.HasPrefix(text[i+size+len(username):], "@") {
+				entities = append(entities, Entity{
+					Username: username,
+					Offset:   runeOffset,
+					Length:   1 + width,
+				})
+				if len(entities) == MaxPerText {
+					return entities
+				}
+
+				i += size + len(username)
+				runeOffset += 1 + width
+				prev = 'a'
+				lineStart = false
+				continue
+			}
+		}
+
+		lineStart = r == '\n'
+		if r == '\n' {
+			inCodeSpan = false
+		}
+		prev = r
+		runeOffset++
+		i += size
+	}
+
+	return entities
+}
+
+// `Usernames` returns the distinct usernames of `entities`, compared case-insensitively
+func Usernames(entities []Entity) []string {
+	seen := make(map[string]bool, len(entities))
+	var usernames []string
+
+	for _, entity := range entities {
+		key := strings.ToLower(entity.Username)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		usernames = append(usernames, entity.Username)
+	}
+
+	return usernames
+}
+
+// `ValidUsername` reports whether `username` can be mentioned as a whole, so
+// `@username` in a text resolves to it
+func ValidUsername(username string) bool {
+	scanned, _ := scanUsername(username)
+	return scanned != "" && scanned == username
+}
+
+func scanUsername(s string) (string, int) {
+	end, width := 0, 0
+	for end < len(s) {
+		r, size := utf8.DecodeRuneInString(s[end:])
+		if !isWordRune(r) && r != '-' && r != '.' {
+			break
+		}
+		end += size
+		width++
+	}
+
+	// trailing dots and dashes are punctuation ("thanks @ana.")
+	for end > 0 && (s[end-1] == '.' || s[end-1] == '-') {
+		end--
+		width--
+	}
+
+	if width > 100 {
+		return "", 0
+	}
+
+	return s[:end], width
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
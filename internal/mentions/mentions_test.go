@@ -0,0 +1,62 @@
+package mentions
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []Entity
+	}{
+		{"single mention", "hey @ana", []Entity{{"ana", 4, 4}}},
+		{"start of text", "@ana hi", []Entity{{"ana", 0, 4}}},
+		{"trailing punctuation", "thanks @ana.", []Entity{{"ana", 7, 4}}},
+		{"dots and dashes inside", "cc @ana.b-c!", []Entity{{"ana.b-c", 3, 8}}},
+		{"offsets are code points", "héllo @ana", []Entity{{"ana", 6, 4}}},
+		{"emails are not mentions", "mail me at ana@mail.com", nil},
+		{"bare at sign", "meet @ 5", nil},
+		{"inline code is ignored", "run `@decorator` @bob", []Entity{{"bob", 17, 4}}},
+		{"fenced code is ignored", "```\n@Override\n```\n@bob", []Entity{{"bob", 18, 4}}},
+		{"several mentions", "@a, @b and @c", []Entity{{"a", 0, 2}, {"b", 4, 2}, {"c", 11, 2}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Parse(tt.text)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse(%q) = %v, want %v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUsernames(t *testing.T) {
+	got := Usernames([]Entity{{"Ana", 0, 4}, {"bob", 5, 4}, {"ana", 10, 4}})
+	want := []string{"Ana", "bob"}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Usernames() = %v, want %v", got, want)
+	}
+}
+
+func TestValidUsername(t *testing.T) {
+	for username, want := range map[string]bool{
+		"ana":                    true,
+		"ana.b-c_d":              true,
+		"José":                   true,
+		"":                       false,
+		"ana.":                   false,
+		"ana b":                  false,
+		"@ana":                   false,
+		"ana@mail":               false,
+		strings.Repeat("a", 101): false,
+	} {
+		if got := ValidUsername(username); got != want {
+			t.Errorf("ValidUsername(%q) = %v, want %v", username, got, want)
+		}
+	}
+}
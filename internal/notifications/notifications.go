@@ -0,0 +1,118 @@
+// Package notifications records what happened to users while they were away.
+//
+// Notifications are stored in Postgres and grouped when they are read: notifications of the same
+// type about the same post make a single inbox entry, like "ana and 4 others commented on your
+// post". Each new notification is also sent as a live `notification` event to its recipient.
+//
+// Users choose the delivery of each type, see `store.NotificationPreferences`: in the inbox only,
+// also by email right away, also in a daily or weekly digest email, or not at all.
+package notifications
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/elhambadri2411/social/internal/store"
+	"github.com/elhambadri2411/social/internal/stream"
+)
+
+// Notification types
+const (
+	TypeFollow        = "follow"         // someone followed you
+	TypeFollowRequest = "follow_request" // someone asked to follow your private account
+	TypeComment       = "comment"        // someone commented on your post
+	TypeReply         = "reply"          // someone commented on a post you commented on
+	TypeMention       = "mention"        // someone mentioned you in a post or comment
+)
+
+// `Types` lists every notification type, users choose a delivery for each. There is no reaction
+// type, the API has no reactions.
+var Types = []string{TypeFollow, TypeFollowRequest, TypeComment, TypeReply, TypeMention}
+
+// `WithDefaults` fills in the delivery of the types `preferences` has none for, they stay in-app
+func WithDefaults(preferences *store.NotificationPreferences) *store.NotificationPreferences {
+	for _, t := range Types {
+		if _, ok := preferences.Delivery[t]; !ok {
+			preferences.Delivery[t] = store.DeliveryInApp
+		}
+	}
+	return preferences
+}
+
+// `Summary` describes `group` in a sentence, like `ana and 4 others commented on your post "Hello"`
+func Summary(group store.NotificationGroup) string {
+	actors := "Someone"
+	if len(group.Actors) > 0 {
+		actors = group.Actors[0].Username
+	}
+	switch {
+	case group.ActorCount == 2 && len(group.Actors) == 2:
+		actors += " and " + group.Actors[1].Username
+	case group.ActorCount == 2:
+		actors += " and 1 other"
+	case group.ActorCount > 2:
+		actors += fmt.Sprintf(" and %d others", group.ActorCount-1)
+	}
+
+	post, yourPost := "a post", "your post"
+	if group.PostTitle != nil {
+		post = fmt.Sprintf("%q", *group.PostTitle)
+		yourPost += " " + post
+	}
+
+	switch group.Type {
+	case TypeFollow:
+		return actors + " started following you"
+	case TypeFollowRequest:
+		return actors + " asked to follow you"
+	case TypeComment:
+		return actors + " commented on " + yourPost
+	case TypeReply:
+		return actors + " also commented on " + post
+	case TypeMention:
+		return actors + " mentioned you in " + post
+	default:
+		return actors + " did something"
+	}
+}
+
+// `GroupKey` returns the key `n` is grouped by in the inbox. Follows are grouped together,
+// the rest by type and post.
+func GroupKey(n store.Notification) string {
+	if n.PostId == nil {
+		return n.Type
+	}
+	return fmt.Sprintf("%s:%d", n.Type, *n.PostId)
+}
+
+type Service struct {
+	repo   store.NotificationsRepository
+	broker stream.Broker
+}
+
+func New(repo store.NotificationsRepository, broker stream.Broker) *Service {
+	return &Service{repo: repo, broker: broker}
+}
+
+// `Notify` records `n` for each of `userIds` and sends it to them live. Recipients who are the
+// actor, muted the actor or are blocked either way are skipped. Returns the recorded notifications,
+// which are kept even if sending them live fails.
+func (s *Service) Notify(ctx context.Context, userIds []int64, n store.Notification) ([]store.Notification, error) {
+	if len(userIds) == 0 {
+		return nil, nil
+	}
+
+	n.GroupKey = GroupKey(n)
+	created, err := s.repo.Create(ctx, n, userIds)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, notification := range created {
+		if err := s.broker.Publish(ctx, []int64{notification.UserId}, stream.EventNotification, notification); err != nil {
+			return created, err
+		}
+	}
+
+	return created, nil
+}
@@ -0,0 +1,99 @@
+package notifications
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/elhambadri2411/social/internal/store"
+	"github.com/elhambadri2411/social/internal/stream"
+)
+
+func TestGroupKey(t *testing.T) {
+	postId := int64(7)
+
+	cases := []struct {
+		notification store.Notification
+		want         string
+	}{
+		{store.Notification{Type: TypeFollow}, "follow"},
+		{store.Notification{Type: TypeComment, PostId: &postId}, "comment:7"},
+		{store.Notification{Type: TypeMention, PostId: &postId}, "mention:7"},
+	}
+
+	for _, c := range cases {
+		if got := GroupKey(c.notification); got != c.want {
+			t.Errorf("GroupKey(%+v) = %q, want %q", c.notification, got, c.want)
+		}
+	}
+}
+
+func TestSummary(t *testing.T) {
+	title := "Hello"
+	ana, bob := store.Author{ID: 1, Username: "ana"}, store.Author{ID: 2, Username: "bob"}
+
+	cases := []struct {
+		group store.NotificationGroup
+		want  string
+	}{
+		{store.NotificationGroup{Type: TypeFollow, Actors: []store.Author{ana}, ActorCount: 1}, "ana started following you"},
+		{store.NotificationGroup{Type: TypeFollow, Actors: []store.Author{ana, bob}, ActorCount: 2}, "ana and bob started following you"},
+		{store.NotificationGroup{Type: TypeComment, PostTitle: &title, Actors: []store.Author{ana, bob}, ActorCount: 5}, `ana and 4 others commented on your post "Hello"`},
+		{store.NotificationGroup{Type: TypeComment, Actors: []store.Author{ana}, ActorCount: 1}, "ana commented on your post"},
+		{store.NotificationGroup{Type: TypeReply, Actors: []store.Author{ana}, ActorCount: 2}, "ana and 1 other also commented on a post"},
+		{store.NotificationGroup{Type: TypeMention, PostTitle: &title, Actors: []store.Author{bob}, ActorCount: 1}, `bob mentioned you in "Hello"`},
+	}
+
+	for _, c := range cases {
+		if got := Summary(c.group); got != c.want {
+			t.Errorf("Summary() = %q, want %q", got, c.want)
+		}
+	}
+}
+
+func TestWithDefaults(t *testing.T) {
+	preferences := WithDefaults(&store.NotificationPreferences{Delivery: map[string]string{TypeComment: store.DeliveryOff}})
+
+	if len(preferences.Delivery) != len(Types) {
+		t.Errorf("expected a delivery for every type, got %v", preferences.Delivery)
+	}
+	if preferences.Delivery[TypeComment] != store.DeliveryOff || preferences.Delivery[TypeFollow] != store.DeliveryInApp {
+		t.Errorf("expected set types to be kept and the rest in-app, got %v", preferences.Delivery)
+	}
+}
+
+func TestNotify(t *testing.T) {
+	broker := stream.NewLocal(stream.Config{BufferSize: 4})
+	defer broker.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	events, err := broker.Subscribe(ctx, 2, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	postId := int64(7)
+	service := New(&store.MockNotificationsStore{}, broker)
+
+	created, err := service.Notify(ctx, []int64{1, 2}, store.Notification{Type: TypeComment, ActorId: 1, PostId: &postId})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(created) != 1 || created[0].UserId != 2 {
+		t.Fatalf("expected only user 2 to be notified, got %+v", created)
+	}
+	if created[0].GroupKey != "comment:7" {
+		t.Errorf("expected the group key to be set, got %q", created[0].GroupKey)
+	}
+
+	select {
+	case event := <-events:
+		if event.Type != stream.EventNotification {
+			t.Errorf("expected a notification event, got %s", event.Type)
+		}
+	case <-ctx.Done():
+		t.Fatal("expected a live notification")
+	}
+}
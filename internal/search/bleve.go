@@ -0,0 +1,358 @@
+package search
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/analysis/analyzer/keyword"
+	"github.com/blevesearch/bleve/v2/analysis/lang/en"
+	"github.com/blevesearch/bleve/v2/mapping"
+	htmlformat "github.com/blevesearch/bleve/v2/search/highlight/format/html"
+	"github.com/blevesearch/bleve/v2/search/query"
+	"github.com/elhambadri2411/social/internal/store"
+	"github.com/elhambadri2411/social/internal/tags"
+)
+
+// Relevance of a term matching each field, relative to the content
+const (
+	titleBoost = 3.0
+	tagsBoost  = 2.0
+)
+
+// `deleteBatchSize` bounds how many comments are collected per round when deleting a post
+const deleteBatchSize = 500
+
+// Fields loaded with each hit to build a `store.SearchResult`
+var resultFields = []string{"title", "content", "post_id", "user_id", "username", "created_at"}
+
+// `BleveIndex` is an embedded on-disk index of posts and comments. Users are not
+// indexed, their searches go to the fallback index.
+type BleveIndex struct {
+	index    bleve.Index
+	fallback Index
+}
+
+// `OpenBleve` opens the index at `path`, creating it when the directory does not exist
+func OpenBleve(path string, fallback Index) (*BleveIndex, error) {
+	index, err := bleve.Open(path)
+	if errors.Is(err, bleve.ErrorIndexPathDoesNotExist) {
+		index, err = bleve.New(path, newMapping())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &BleveIndex{index: index, fallback: fallback}, nil
+}
+
+func newMapping() mapping.IndexMapping {
+	text := bleve.NewTextFieldMapping()
+	text.Analyzer = en.AnalyzerName
+	text.IncludeTermVectors = true
+
+	keywordField := bleve.NewKeywordFieldMapping()
+	keywordField.Analyzer = keyword.Name
+
+	stored := bleve.NewKeywordFieldMapping()
+	stored.Index = false
+	stored.IncludeInAll = false
+
+	numeric := bleve.NewNumericFieldMapping()
+	numeric.IncludeInAll = false
+
+	doc := bleve.NewDocumentStaticMapping()
+	doc.AddFieldMappingsAt("type", keywordField)
+	doc.AddFieldMappingsAt("title", text)
+	doc.AddFieldMappingsAt("content", text)
+	doc.AddFieldMappingsAt("tags", keywordField)
+	doc.AddFieldMappingsAt("post_id", numeric)
+	doc.AddFieldMappingsAt("user_id", numeric)
+	doc.AddFieldMappingsAt("username", stored)
+	doc.AddFieldMappingsAt("created_at", stored)
+
+	indexMapping := bleve.NewIndexMapping()
+	indexMapping.DefaultMapping = doc
+	indexMapping.DefaultAnalyzer = en.AnalyzerName
+
+	return indexMapping
+}
+
+func (b *BleveIndex) Index(ctx context.Context, docs ...Document) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	batch := b.index.NewBatch()
+	for _, doc := range docs {
+		if err := batch.Index(docId(doc.Type, doc.ID), doc); err != nil {
+			return err
+		}
+	}
+
+	return b.index.Batch(batch)
+}
+
+func (b *BleveIndex) DeletePost(ctx context.Context, postId int64) error {
+	id := float64(postId)
+	inclusive := true
+	onPost := bleve.NewNumericRangeInclusiveQuery(&id, &id, &inclusive, &inclusive)
+	onPost.SetField("post_id")
+	comments := bleve.NewConjunctionQuery(typeQuery(store.SearchComments), onPost)
+
+	for {
+		result, err := b.index.SearchInContext(ctx, bleve.NewSearchRequestOptions(comments, deleteBatchSize, 0, false))
+		if err != nil {
+			return err
+		}
+
+		batch := b.index.NewBatch()
+		for _, hit := range result.Hits {
+			batch.Delete(hit.ID)
+		}
+		if len(result.Hits) < deleteBatchSize {
+			batch.Delete(docId(store.SearchPosts, postId))
+		}
+
+		if err := b.index.Batch(batch); err != nil {
+			return err
+		}
+
+		if len(result.Hits) < deleteBatchSize {
+			return nil
+		}
+	}
+}
+
+func (b *BleveIndex) DeleteComment(ctx context.Context, commentId int64) error {
+	return b.index.Delete(docId(store.SearchComments, commentId))
+}
+
+func (b *BleveIndex) Search(ctx context.Context, q Query) (*Results, error) {
+	if q.Type == store.SearchUsers {
+		if b.fallback == nil {
+			return nil, fmt.Errorf("search type %q is not indexed", q.Type)
+		}
+		return b.fallback.Search(ctx, q)
+	}
+
+	textQuery := parseQuery(b.index.Mapping(), q.Text)
+	if textQuery == nil {
+		return &Results{Results: []store.SearchResult{}}, nil
+	}
+
+	req := bleve.NewSearchRequestOptions(bleve.NewConjunctionQuery(typeQuery(q.Type), textQuery), q.Limit, 0, false)
+	req.SortBy([]string{"-_score", "-_id"})
+	req.Fields = resultFields
+	req.Highlight = bleve.NewHighlightWithStyle(htmlformat.Name)
+	req.Highlight.AddField("content")
+
+	if q.Cursor != "" {
+		after, err := decodeBleveCursor(q.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		req.SearchAfter = after
+	}
+
+	result, err := b.index.SearchInContext(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &Results{Results: make([]store.SearchResult, 0, len(result.Hits))}
+	for _, hit := range result.Hits {
+		_, rawId, _ := strings.Cut(hit.ID, ":")
+		id, err := strconv.ParseInt(rawId, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("malformed document id %q", hit.ID)
+		}
+
+		res := store.SearchResult{
+			Type:      q.Type,
+			ID:        id,
+			Rank:      float32(hit.Score),
+			Title:     stringField(hit.Fields, "title"),
+			UserId:    int64Field(hit.Fields, "user_id"),
+			Username:  stringField(hit.Fields, "username"),
+			CreatedAt: stringField(hit.Fields, "created_at"),
+			Headline:  strings.Join(hit.Fragments["content"], " … "),
+		}
+
+		if q.Type == store.SearchComments {
+			res.PostId = int64Field(hit.Fields, "post_id")
+		}
+
+		// the match was in the title or the tags, show the start of the content instead
+		if res.Headline == "" {
+			res.Headline = html.EscapeString(truncate(stringField(hit.Fields, "content"), 200))
+		}
+
+		page.Results = append(page.Results, res)
+	}
+
+	if len(result.Hits) == q.Limit {
+		last := result.Hits[len(result.Hits)-1]
+		// `hit.Sort` only holds a placeholder for the score, keep it at full precision instead
+		page.NextCursor = encodeBleveCursor([]string{strconv.FormatFloat(last.Score, 'g', -1, 64), last.ID})
+	}
+
+	return page, nil
+}
+
+func (b *BleveIndex) Close() error {
+	return b.index.Close()
+}
+
+// `parseQuery` turns the `websearch_to_tsquery` syntax into a bleve query so both
+// backends accept the same input: words and "quoted phrases" must all match, `-` excludes
+// a word or phrase and `or` separates alternatives. Stop words are ignored, like in Postgres.
+// Returns nil when nothing can match.
+func parseQuery(m mapping.IndexMapping, text string) query.Query {
+	var groups []query.Query
+
+	group := bleve.NewBooleanQuery()
+	positive := 0
+	flush := func() {
+		if positive > 0 {
+			groups = append(groups, group)
+		}
+		group = bleve.NewBooleanQuery()
+		positive = 0
+	}
+
+	analyzer := m.AnalyzerNamed(en.AnalyzerName)
+	for _, token := range tokenize(text) {
+		switch {
+		case !token.negated && !token.phrase && strings.EqualFold(token.text, "or"):
+			flush()
+		case len(analyzer.Analyze([]byte(token.text))) == 0:
+			continue
+		case token.negated:
+			group.AddMustNot(termQuery(token))
+		default:
+			group.AddMust(termQuery(token))
+			positive++
+		}
+	}
+	flush()
+
+	switch len(groups) {
+	case 0:
+		return nil
+	case 1:
+		return groups[0]
+	default:
+		return bleve.NewDisjunctionQuery(groups...)
+	}
+}
+
+type token struct {
+	text    string
+	phrase  bool
+	negated bool
+}
+
+func tokenize(text string) []token {
+	var tokens []token
+
+	for text = strings.TrimSpace(text); text != ""; text = strings.TrimSpace(text) {
+		var t token
+		if strings.HasPrefix(text, "-") {
+			t.negated = true
+			text = text[1:]
+		}
+
+		if strings.HasPrefix(text, `"`) {
+			phrase, rest, _ := strings.Cut(text[1:], `"`)
+			t.text, t.phrase, text = strings.TrimSpace(phrase), true, rest
+		} else {
+			end := strings.IndexAny(text, " \t\n")
+			if end == -1 {
+				end = len(text)
+			}
+			t.text, text = text[:end], text[end:]
+		}
+
+		if t.text != "" {
+			tokens = append(tokens, t)
+		}
+	}
+
+	return tokens
+}
+
+// `termQuery` matches a word or a phrase in the title, content or tags
+func termQuery(t token) query.Query {
+	if t.phrase {
+		title := bleve.NewMatchPhraseQuery(t.text)
+		title.SetField("title")
+		title.SetBoost(titleBoost)
+
+		content := bleve.NewMatchPhraseQuery(t.text)
+		content.SetField("content")
+
+		return bleve.NewDisjunctionQuery(title, content)
+	}
+
+	title := bleve.NewMatchQuery(t.text)
+	title.SetField("title")
+	title.SetBoost(titleBoost)
+
+	content := bleve.NewMatchQuery(t.text)
+	content.SetField("content")
+
+	tag := bleve.NewTermQuery(tags.Normalize(t.text))
+	tag.SetField("tags")
+	tag.SetBoost(tagsBoost)
+
+	return bleve.NewDisjunctionQuery(title, content, tag)
+}
+
+func typeQuery(docType string) query.Query {
+	q := bleve.NewTermQuery(docType)
+	q.SetField("type")
+	return q
+}
+
+func encodeBleveCursor(sort []string) string {
+	raw, _ := json.Marshal(sort)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeBleveCursor(cursor string) ([]string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, store.ErrInvalidCursor
+	}
+
+	var sort []string
+	if err := json.Unmarshal(raw, &sort); err != nil || len(sort) != 2 {
+		return nil, store.ErrInvalidCursor
+	}
+
+	return sort, nil
+}
+
+func stringField(fields map[string]interface{}, name string) string {
+	value, _ := fields[name].(string)
+	return value
+}
+
+func int64Field(fields map[string]interface{}, name string) int64 {
+	value, _ := fields[name].(float64)
+	return int64(value)
+}
+
+func truncate(s string, max int) string {
+	if runes := []rune(s); len(runes) > max {
+		return string(runes[:max]) + "…"
+	}
+	return s
+}
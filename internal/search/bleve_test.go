@@ -0,0 +1,191 @@
+package search
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/elhambadri2411/social/internal/store"
+)
+
+func newTestIndex(t *testing.T) *BleveIndex {
+	t.Helper()
+
+	index, err := OpenBleve(filepath.Join(t.TempDir(), "search.bleve"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { index.Close() })
+
+	posts := []*store.Post{
+		{ID: 1, UserId: 1, Title: "Goroutines explained", Content: "Channels and goroutines in <b>Go</b>", Tags: []string{"go"}, User: store.Author{Username: "alice"}},
+		{ID: 2, UserId: 2, Title: "Postgres tips", Content: "Indexes make queries fast", Tags: []string{"databases"}, User: store.Author{Username: "bob"}},
+		{ID: 3, UserId: 1, Title: "Rust or Go", Content: "Comparing the borrow checker with the garbage collector", Tags: []string{"rust"}, User: store.Author{Username: "alice"}},
+	}
+
+	var docs []Document
+	for _, post := range posts {
+		docs = append(docs, PostDocument(post))
+	}
+	docs = append(docs,
+		CommentDocument(posts[1], &store.Comment{ID: 10, UserId: 1, Content: "Partial indexes are great", User: store.Author{Username: "alice"}}),
+		CommentDocument(posts[1], &store.Comment{ID: 11, UserId: 3, Content: "What about covering indexes?", User: store.Author{Username: "carol"}}),
+	)
+
+	if err := index.Index(context.Background(), docs...); err != nil {
+		t.Fatal(err)
+	}
+
+	return index
+}
+
+func searchIds(t *testing.T, index *BleveIndex, q Query) []int64 {
+	t.Helper()
+
+	results, err := index.Search(context.Background(), q)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids := []int64{}
+	for _, result := range results.Results {
+		ids = append(ids, result.ID)
+	}
+	return ids
+}
+
+func TestBleveSearch(t *testing.T) {
+	index := newTestIndex(t)
+
+	tests := []struct {
+		name string
+		q    Query
+		want []int64
+	}{
+		{"title ranks first", Query{Type: store.SearchPosts, Text: "goroutines", Limit: 10}, []int64{1}},
+		{"tags", Query{Type: store.SearchPosts, Text: "databases", Limit: 10}, []int64{2}},
+		{"stemming", Query{Type: store.SearchPosts, Text: "index", Limit: 10}, []int64{2}},
+		{"all words must match", Query{Type: store.SearchPosts, Text: "go postgres", Limit: 10}, []int64{}},
+		{"or", Query{Type: store.SearchPosts, Text: "goroutines or postgres", Limit: 10}, []int64{2, 1}},
+		{"excluded", Query{Type: store.SearchPosts, Text: "go -rust", Limit: 10}, []int64{1}},
+		{"phrase", Query{Type: store.SearchPosts, Text: `"borrow checker"`, Limit: 10}, []int64{3}},
+		{"stop words are ignored", Query{Type: store.SearchPosts, Text: "the goroutines", Limit: 10}, []int64{1}},
+		{"comments", Query{Type: store.SearchComments, Text: "indexes", Limit: 10}, []int64{10, 11}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := searchIds(t, index, tt.q)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+
+			// equal scores are ordered by id, only compare the set for those
+			for _, id := range tt.want {
+				if !containsId(got, id) {
+					t.Fatalf("got %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestBleveSearchHeadline(t *testing.T) {
+	index := newTestIndex(t)
+
+	results, err := index.Search(context.Background(), Query{Type: store.SearchPosts, Text: "channels", Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(results.Results) != 1 {
+		t.Fatalf("got %d results, want 1", len(results.Results))
+	}
+
+	result := results.Results[0]
+	if !strings.Contains(result.Headline, "<mark>Channels</mark>") || strings.Contains(result.Headline, "<b>") {
+		t.Errorf("headline %q is not escaped and marked", result.Headline)
+	}
+
+	if result.Username != "alice" || result.UserId != 1 || result.Title != "Goroutines explained" {
+		t.Errorf("unexpected result %+v", result)
+	}
+}
+
+func TestBleveSearchCursor(t *testing.T) {
+	index := newTestIndex(t)
+	ctx := context.Background()
+
+	q := Query{Type: store.SearchComments, Text: "indexes", Limit: 1}
+	seen := map[int64]bool{}
+
+	for page := 0; page < 3; page++ {
+		results, err := index.Search(ctx, q)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, result := range results.Results {
+			if seen[result.ID] {
+				t.Fatalf("result %d returned twice", result.ID)
+			}
+			seen[result.ID] = true
+		}
+
+		if results.NextCursor == "" {
+			break
+		}
+		q.Cursor = results.NextCursor
+	}
+
+	if len(seen) != 2 {
+		t.Errorf("got %d distinct results across pages, want 2", len(seen))
+	}
+
+	if _, err := index.Search(ctx, Query{Type: store.SearchPosts, Text: "go", Limit: 1, Cursor: "!"}); err != store.ErrInvalidCursor {
+		t.Errorf("got %v for a malformed cursor, want ErrInvalidCursor", err)
+	}
+}
+
+func TestBleveDeletePost(t *testing.T) {
+	index := newTestIndex(t)
+	ctx := context.Background()
+
+	if err := index.DeletePost(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := searchIds(t, index, Query{Type: store.SearchPosts, Text: "postgres", Limit: 10}); len(got) != 0 {
+		t.Errorf("deleted post still found: %v", got)
+	}
+
+	if got := searchIds(t, index, Query{Type: store.SearchComments, Text: "indexes", Limit: 10}); len(got) != 0 {
+		t.Errorf("comments of a deleted post still found: %v", got)
+	}
+
+	if got := searchIds(t, index, Query{Type: store.SearchPosts, Text: "goroutines", Limit: 10}); len(got) != 1 {
+		t.Errorf("other posts were removed: %v", got)
+	}
+}
+
+func TestBleveDeleteComment(t *testing.T) {
+	index := newTestIndex(t)
+
+	if err := index.DeleteComment(context.Background(), 10); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := searchIds(t, index, Query{Type: store.SearchComments, Text: "indexes", Limit: 10}); len(got) != 1 || got[0] != 11 {
+		t.Errorf("got %v, want [11]", got)
+	}
+}
+
+func containsId(ids []int64, id int64) bool {
+	for _, candidate := range ids {
+		if candidate == id {
+			return true
+		}
+	}
+	return false
+}
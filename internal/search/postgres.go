@@ -0,0 +1,63 @@
+package search
+
+import (
+	"context"
+
+	"github.com/elhambadri2411/social/internal/store"
+)
+
+// `PostgresIndex` searches with Postgres full-text search. The search vectors are
+// maintained by triggers, so indexing and deleting are no-ops.
+type PostgresIndex struct {
+	repo store.SearchRepository
+}
+
+func NewPostgresIndex(repo store.SearchRepository) *PostgresIndex {
+	return &PostgresIndex{repo: repo}
+}
+
+func (p *PostgresIndex) Index(ctx context.Context, docs ...Document) error {
+	return nil
+}
+
+func (p *PostgresIndex) DeletePost(ctx context.Context, postId int64) error {
+	return nil
+}
+
+func (p *PostgresIndex) DeleteComment(ctx context.Context, commentId int64) error {
+	return nil
+}
+
+func (p *PostgresIndex) Search(ctx context.Context, q Query) (*Results, error) {
+	query := store.SearchQuery{
+		Type:     q.Type,
+		Query:    q.Text,
+		Limit:    q.Limit,
+		ViewerId: q.ViewerId,
+	}
+
+	if q.Cursor != "" {
+		after, err := store.DecodeSearchCursor(q.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		query.After = after
+	}
+
+	results, err := p.repo.Search(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &Results{Results: results}
+	if len(results) == q.Limit {
+		last := results[len(results)-1]
+		page.NextCursor = store.SearchCursor{Rank: last.Rank, ID: last.ID}.Encode()
+	}
+
+	return page, nil
+}
+
+func (p *PostgresIndex) Close() error {
+	return nil
+}
@@ -0,0 +1,90 @@
+package search
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/elhambadri2411/social/internal/store"
+)
+
+// `Index` is a search backend. Posts and comments are pushed to it as they are
+// created, updated and deleted; `Search` returns ranked results with an opaque
+// cursor for the next page.
+type Index interface {
+	// `Index` adds or replaces documents
+	Index(context.Context, ...Document) error
+
+	// `DeletePost` removes a post and all of its comments
+	DeletePost(context.Context, int64) error
+
+	// `DeleteComment` removes a comment
+	DeleteComment(context.Context, int64) error
+
+	// `Search` runs a query, cursors are only valid for the index that produced them
+	Search(context.Context, Query) (*Results, error)
+
+	Close() error
+}
+
+// `Document` is a post or a comment as stored in an index
+type Document struct {
+	Type      string   `json:"type"` // store.SearchPosts or store.SearchComments
+	ID        int64    `json:"id"`
+	PostId    int64    `json:"post_id"`
+	Title     string   `json:"title"` // title of the post, also set on comments
+	Content   string   `json:"content"`
+	Tags      []string `json:"tags"`
+	UserId    int64    `json:"user_id"`
+	Username  string   `json:"username"`
+	CreatedAt string   `json:"created_at"`
+}
+
+// `Query` describes a search, `Text` uses the same syntax as `store.SearchQuery`.
+// `ViewerId` is who searches, indexes that know about blocks and mutes filter by it.
+type Query struct {
+	Type     string `validate:"oneof=posts comments users"`
+	Text     string `validate:"required,max=200"`
+	Limit    int    `validate:"gte=1,lte=50"`
+	Cursor   string
+	ViewerId int64
+}
+
+// `Results` is a page of results, `NextCursor` is empty on the last page
+type Results struct {
+	Results    []store.SearchResult `json:"results"`
+	NextCursor string               `json:"next_cursor,omitempty"`
+}
+
+// `PostDocument` builds the document of a post, `post.User.Username` must be set
+func PostDocument(post *store.Post) Document {
+	return Document{
+		Type:      store.SearchPosts,
+		ID:        post.ID,
+		PostId:    post.ID,
+		Title:     post.Title,
+		Content:   post.Content,
+		Tags:      post.Tags,
+		UserId:    post.UserId,
+		Username:  post.User.Username,
+		CreatedAt: post.CreatedAt,
+	}
+}
+
+// `CommentDocument` builds the document of a comment on `post`, `comment.User.Username` must be set
+func CommentDocument(post *store.Post, comment *store.Comment) Document {
+	return Document{
+		Type:      store.SearchComments,
+		ID:        comment.ID,
+		PostId:    post.ID,
+		Title:     post.Title,
+		Content:   comment.Content,
+		UserId:    comment.UserId,
+		Username:  comment.User.Username,
+		CreatedAt: comment.CreatedAt,
+	}
+}
+
+// `docId` is the key of a document, posts and comments share one index
+func docId(docType string, id int64) string {
+	return docType + ":" + strconv.FormatInt(id, 10)
+}
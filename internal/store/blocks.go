@@ -0,0 +1,229 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+)
+
+// `RelatedUser` is a user in a blocks or mutes list, `CreatedAt` is when they were blocked or muted
+type RelatedUser struct {
+	ID                 int64   `json:"id"`
+	Username           string  `json:"username"`
+	DisplayName        string  `json:"display_name"`
+	AvatarKey          *string `json:"-"`
+	AvatarThumbnailKey *string `json:"-"`
+	AvatarURL          string  `json:"avatar_url,omitempty"`
+	AvatarThumbnailURL string  `json:"avatar_thumbnail_url,omitempty"`
+	CreatedAt          string  `json:"created_at"`
+}
+
+// `blockedSQL` is true when either of the users `a` and `b` blocked the other
+func blockedSQL(a, b string) string {
+	return `EXISTS (SELECT 1 FROM user_blocks ub
+		WHERE (ub.blocker_id = ` + a + ` AND ub.blocked_id = ` + b + `)
+			OR (ub.blocker_id = ` + b + ` AND ub.blocked_id = ` + a + `))`
+}
+
+// `mutedSQL` is true when `muter` muted `muted`
+func mutedSQL(muter, muted string) string {
+	return `EXISTS (SELECT 1 FROM user_mutes um WHERE um.muter_id = ` + muter + ` AND um.muted_id = ` + muted + `)`
+}
+
+type BlocksRepositoryPostgres struct {
+	db *sql.DB
+}
+
+// `Block` blocks `blockedId` on behalf of `blockerId` and removes the follows and
+// follow requests between them, in both directions
+func (s *BlocksRepositoryPostgres) Block(ctx context.Context, blockerId int64, blockedId int64) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
+	defer cancel()
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(
+			ctx,
+			`INSERT INTO user_blocks (blocker_id, blocked_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`,
+			blockerId,
+			blockedId,
+		)
+		if err != nil {
+			return err
+		}
+
+		for _, table := range []string{"followers", "follow_requests"} {
+			query := `
+				DELETE FROM ` + table + `
+				WHERE (user_id = $1 AND follower_id = $2) OR (user_id = $2 AND follower_id = $1)
+			`
+			if _, err := tx.ExecContext(ctx, query, blockerId, blockedId); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (s *BlocksRepositoryPostgres) Unblock(ctx context.Context, blockerId int64, blockedId int64) error {
+	return s.delete(ctx, `DELETE FROM user_blocks WHERE blocker_id = $1 AND blocked_id = $2`, blockerId, blockedId)
+}
+
+func (s *BlocksRepositoryPostgres) Mute(ctx context.Context, muterId int64, mutedId int64) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(
+		ctx,
+		`INSERT INTO user_mutes (muter_id, muted_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`,
+		muterId,
+		mutedId,
+	)
+	return err
+}
+
+func (s *BlocksRepositoryPostgres) Unmute(ctx context.Context, muterId int64, mutedId int64) error {
+	return s.delete(ctx, `DELETE FROM user_mutes WHERE muter_id = $1 AND muted_id = $2`, muterId, mutedId)
+}
+
+func (s *BlocksRepositoryPostgres) delete(ctx context.Context, query string, userId int64, otherId int64) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userId, otherId)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// `GetBlocked` lists the users `userId` blocked, most recent first
+func (s *BlocksRepositoryPostgres) GetBlocked(ctx context.Context, userId int64, limit int, offset int) ([]RelatedUser, error) {
+	query := `
+		SELECT u.id, u.username, u.display_name, u.avatar_key, u.avatar_thumbnail_key, b.created_at
+		FROM user_blocks b JOIN users u ON u.id = b.blocked_id
+		WHERE b.blocker_id = $1
+		ORDER BY b.created_at DESC, b.blocked_id DESC
+		LIMIT $2 OFFSET $3
+	`
+	return s.list(ctx, query, userId, limit, offset)
+}
+
+// `GetMuted` lists the users `userId` muted, most recent first
+func (s *BlocksRepositoryPostgres) GetMuted(ctx context.Context, userId int64, limit int, offset int) ([]RelatedUser, error) {
+	query := `
+		SELECT u.id, u.username, u.display_name, u.avatar_key, u.avatar_thumbnail_key, m.created_at
+		FROM user_mutes m JOIN users u ON u.id = m.muted_id
+		WHERE m.muter_id = $1
+		ORDER BY m.created_at DESC, m.muted_id DESC
+		LIMIT $2 OFFSET $3
+	`
+	return s.list(ctx, query, userId, limit, offset)
+}
+
+func (s *BlocksRepositoryPostgres) list(ctx context.Context, query string, userId int64, limit int, offset int) ([]RelatedUser, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userId, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []RelatedUser{}
+	for rows.Next() {
+		var user RelatedUser
+		err := rows.Scan(
+			&user.ID,
+			&user.Username,
+			&user.DisplayName,
+			&user.AvatarKey,
+			&user.AvatarThumbnailKey,
+			&user.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	return users, rows.Err()
+}
+
+// `IsBlocked` reports whether either user blocked the other
+func (s *BlocksRepositoryPostgres) IsBlocked(ctx context.Context, userId int64, otherId int64) (bool, error) {
+	query := `SELECT ` + blockedSQL("$1::bigint", "$2::bigint")
+
+	ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
+	defer cancel()
+
+	var blocked bool
+	if err := s.db.QueryRowContext(ctx, query, userId, otherId).Scan(&blocked); err != nil {
+		return false, err
+	}
+
+	return blocked, nil
+}
+
+// `GetMuters` returns which of `userIds` muted `mutedId`
+func (s *BlocksRepositoryPostgres) GetMuters(ctx context.Context, mutedId int64, userIds []int64) ([]int64, error) {
+	query := `SELECT muter_id FROM user_mutes WHERE muted_id = $1 AND muter_id = ANY($2)`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, mutedId, pq.Array(userIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	muterIds := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		muterIds = append(muterIds, id)
+	}
+
+	return muterIds, rows.Err()
+}
+
+// `GetHiddenAuthors` returns which of `userIds` are blocked either way with `viewerId` or muted by them
+func (s *BlocksRepositoryPostgres) GetHiddenAuthors(ctx context.Context, viewerId int64, userIds []int64) ([]int64, error) {
+	query := `
+		SELECT a.id FROM unnest($2::bigint[]) AS a(id)
+		WHERE ` + blockedSQL("a.id", "$1::bigint") + ` OR ` + mutedSQL("$1::bigint", "a.id")
+
+	ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, viewerId, pq.Array(userIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hiddenIds := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		hiddenIds = append(hiddenIds, id)
+	}
+
+	return hiddenIds, rows.Err()
+}
@@ -3,6 +3,7 @@ package cache
 import (
 	"context"
 	"log"
+	"time"
 
 	"github.com/elhambadri2411/social/internal/store"
 	"github.com/stretchr/testify/mock"
@@ -10,7 +11,10 @@ import (
 
 func NewMockCacheStore() Storage {
 	return Storage{
-		UsersCache: &MockUsersCacheRedis{},
+		UsersCache:       &MockUsersCacheRedis{},
+		SuggestionsCache: &MockSuggestionsCacheRedis{},
+		TimelinesCache:   &MockTimelinesCacheRedis{},
+		PublicFeedsCache: &MockPublicFeedsCacheRedis{},
 	}
 }
 
@@ -29,3 +33,100 @@ func (m *MockUsersCacheRedis) Set(ctx context.Context, user *store.User) error {
 	args := m.Called(mock.Anything, user)
 	return args.Error(0)
 }
+
+func (m *MockUsersCacheRedis) Delete(ctx context.Context, id int64) error {
+	args := m.Called(mock.Anything, id)
+	return args.Error(0)
+}
+
+type MockSuggestionsCacheRedis struct {
+	mock.Mock
+}
+
+func (m *MockSuggestionsCacheRedis) Get(ctx context.Context, userId int64) ([]store.Suggestion, error) {
+	args := m.Called(mock.Anything, userId)
+	suggestions, _ := args.Get(0).([]store.Suggestion)
+	return suggestions, args.Error(1)
+}
+
+func (m *MockSuggestionsCacheRedis) Set(ctx context.Context, userId int64, suggestions []store.Suggestion) error {
+	args := m.Called(mock.Anything, userId, suggestions)
+	return args.Error(0)
+}
+
+func (m *MockSuggestionsCacheRedis) Touch(ctx context.Context, userId int64) error {
+	args := m.Called(mock.Anything, userId)
+	return args.Error(0)
+}
+
+func (m *MockSuggestionsCacheRedis) Delete(ctx context.Context, userId int64) error {
+	args := m.Called(mock.Anything, userId)
+	return args.Error(0)
+}
+
+func (m *MockSuggestionsCacheRedis) Requesters(ctx context.Context, since time.Time) ([]int64, error) {
+	args := m.Called(mock.Anything, since)
+	userIds, _ := args.Get(0).([]int64)
+	return userIds, args.Error(1)
+}
+
+type MockTimelinesCacheRedis struct {
+	mock.Mock
+}
+
+func (m *MockTimelinesCacheRedis) Range(ctx context.Context, userId int64, after *store.FeedCursor, limit int) ([]store.TimelineEntry, error) {
+	args := m.Called(mock.Anything, userId, after, limit)
+	entries, _ := args.Get(0).([]store.TimelineEntry)
+	return entries, args.Error(1)
+}
+
+func (m *MockTimelinesCacheRedis) Len(ctx context.Context, userId int64) (int, error) {
+	args := m.Called(mock.Anything, userId)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockTimelinesCacheRedis) Build(ctx context.Context, userId int64, entries []store.TimelineEntry) error {
+	args := m.Called(mock.Anything, userId, entries)
+	return args.Error(0)
+}
+
+func (m *MockTimelinesCacheRedis) Push(ctx context.Context, userIds []int64, entry store.TimelineEntry) error {
+	args := m.Called(mock.Anything, userIds, entry)
+	return args.Error(0)
+}
+
+func (m *MockTimelinesCacheRedis) Add(ctx context.Context, userId int64, entries []store.TimelineEntry) error {
+	args := m.Called(mock.Anything, userId, entries)
+	return args.Error(0)
+}
+
+func (m *MockTimelinesCacheRedis) Remove(ctx context.Context, userId int64, postIds []int64) error {
+	args := m.Called(mock.Anything, userId, postIds)
+	return args.Error(0)
+}
+
+func (m *MockTimelinesCacheRedis) MarkPulled(ctx context.Context, authorId int64) error {
+	args := m.Called(mock.Anything, authorId)
+	return args.Error(0)
+}
+
+func (m *MockTimelinesCacheRedis) PulledAuthors(ctx context.Context) ([]int64, error) {
+	args := m.Called(mock.Anything)
+	authorIds, _ := args.Get(0).([]int64)
+	return authorIds, args.Error(1)
+}
+
+type MockPublicFeedsCacheRedis struct {
+	mock.Mock
+}
+
+func (m *MockPublicFeedsCacheRedis) Get(ctx context.Context, key string) ([]byte, error) {
+	args := m.Called(mock.Anything, key)
+	page, _ := args.Get(0).([]byte)
+	return page, args.Error(1)
+}
+
+func (m *MockPublicFeedsCacheRedis) Set(ctx context.Context, key string, page []byte) error {
+	args := m.Called(mock.Anything, key, page)
+	return args.Error(0)
+}
@@ -0,0 +1,30 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+type PublicFeedsCacheRedis struct {
+	rdb *redis.Client
+	ttl time.Duration
+}
+
+func (s *PublicFeedsCacheRedis) Get(ctx context.Context, key string) ([]byte, error) {
+	page, err := s.rdb.Get(ctx, publicFeedKey(key)).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+
+	return page, err
+}
+
+func (s *PublicFeedsCacheRedis) Set(ctx context.Context, key string, page []byte) error {
+	return s.rdb.SetEX(ctx, publicFeedKey(key), page, s.ttl).Err()
+}
+
+func publicFeedKey(key string) string {
+	return "public-feed-" + key
+}
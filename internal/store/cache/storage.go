@@ -2,6 +2,7 @@ package cache
 
 import (
 	"context"
+	"time"
 
 	"github.com/elhambadri2411/social/internal/store"
 	"github.com/go-redis/redis/v8"
@@ -10,14 +11,59 @@ import (
 type UsersCache interface {
 	Get(context.Context, int64) (*store.User, error)
 	Set(context.Context, *store.User) error
+	Delete(context.Context, int64) error
+}
+
+// `SuggestionsCache` holds the who-to-follow suggestions of each user. `Get` returns nil on a miss.
+type SuggestionsCache interface {
+	Get(context.Context, int64) ([]store.Suggestion, error)
+	Set(context.Context, int64, []store.Suggestion) error
+	Touch(context.Context, int64) error
+	Requesters(context.Context, time.Time) ([]int64, error)
+	Delete(context.Context, int64) error
+}
+
+// `TimelinesCache` holds the home timelines of users, see `internal/timeline`.
+// `Range` returns nil when the timeline isn't built, pushes and backfills skip unbuilt timelines.
+type TimelinesCache interface {
+	Range(ctx context.Context, userId int64, after *store.FeedCursor, limit int) ([]store.TimelineEntry, error)
+	Len(context.Context, int64) (int, error)
+	Build(context.Context, int64, []store.TimelineEntry) error
+	Push(context.Context, []int64, store.TimelineEntry) error
+	Add(context.Context, int64, []store.TimelineEntry) error
+	Remove(context.Context, int64, []int64) error
+	MarkPulled(context.Context, int64) error
+	PulledAuthors(context.Context) ([]int64, error)
+}
+
+// `PublicFeedsCache` holds rendered pages of the public feeds, keyed by feed, limit and cursor.
+// Pages expire on their own instead of being invalidated, `Get` returns nil on a miss.
+type PublicFeedsCache interface {
+	Get(context.Context, string) ([]byte, error)
+	Set(context.Context, string, []byte) error
 }
 
 type Storage struct {
 	UsersCache
+	SuggestionsCache
+	TimelinesCache
+	PublicFeedsCache
 }
 
-func NewCacheStorage(rdb *redis.Client) Storage {
+// `NewCacheStorage` builds the Redis caches, suggestions expire after `suggestionsTTL`.
+// Timelines keep `timelineLength` posts and expire `timelineTTL` after they were last read,
+// public feed pages expire after `publicFeedTTL`.
+func NewCacheStorage(
+	rdb *redis.Client,
+	suggestionsTTL time.Duration,
+	timelineLength int,
+	timelineTTL time.Duration,
+	publicFeedTTL time.Duration,
+) Storage {
 	return Storage{
-		UsersCache: &UsersCacheRedis{rdb: rdb},
+		UsersCache:       &UsersCacheRedis{rdb: rdb},
+		SuggestionsCache: &SuggestionsCacheRedis{rdb: rdb, ttl: suggestionsTTL},
+		TimelinesCache:   &TimelinesCacheRedis{rdb: rdb, length: timelineLength, ttl: timelineTTL},
+		PublicFeedsCache: &PublicFeedsCacheRedis{rdb: rdb, ttl: publicFeedTTL},
 	}
 }
@@ -0,0 +1,87 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/elhambadri2411/social/internal/store"
+	"github.com/go-redis/redis/v8"
+)
+
+// users who asked for suggestions, scored by when they last did, so the refresh job
+// only recomputes suggestions for people still using them
+const suggestionsRequestersKey = "suggestions-requesters"
+
+type SuggestionsCacheRedis struct {
+	rdb *redis.Client
+	ttl time.Duration
+}
+
+func (s *SuggestionsCacheRedis) Get(ctx context.Context, userId int64) ([]store.Suggestion, error) {
+	data, err := s.rdb.Get(ctx, suggestionsKey(userId)).Result()
+	if err == redis.Nil {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	suggestions := []store.Suggestion{}
+	if err := json.Unmarshal([]byte(data), &suggestions); err != nil {
+		return nil, err
+	}
+
+	return suggestions, nil
+}
+
+func (s *SuggestionsCacheRedis) Set(ctx context.Context, userId int64, suggestions []store.Suggestion) error {
+	suggestionsJson, err := json.Marshal(suggestions)
+	if err != nil {
+		return err
+	}
+
+	return s.rdb.SetEX(ctx, suggestionsKey(userId), suggestionsJson, s.ttl).Err()
+}
+
+// `Delete` drops the cached suggestions of `userId`, the next request computes them again
+func (s *SuggestionsCacheRedis) Delete(ctx context.Context, userId int64) error {
+	return s.rdb.Del(ctx, suggestionsKey(userId)).Err()
+}
+
+// `Touch` records that `userId` just asked for suggestions
+func (s *SuggestionsCacheRedis) Touch(ctx context.Context, userId int64) error {
+	return s.rdb.ZAdd(ctx, suggestionsRequestersKey, &redis.Z{
+		Score:  float64(time.Now().Unix()),
+		Member: userId,
+	}).Err()
+}
+
+// `Requesters` lists the users who asked for suggestions since `since` and forgets the others
+func (s *SuggestionsCacheRedis) Requesters(ctx context.Context, since time.Time) ([]int64, error) {
+	cutoff := strconv.FormatInt(since.Unix(), 10)
+	if err := s.rdb.ZRemRangeByScore(ctx, suggestionsRequestersKey, "-inf", "("+cutoff).Err(); err != nil {
+		return nil, err
+	}
+
+	members, err := s.rdb.ZRange(ctx, suggestionsRequestersKey, 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	userIds := make([]int64, 0, len(members))
+	for _, member := range members {
+		userId, err := strconv.ParseInt(member, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		userIds = append(userIds, userId)
+	}
+
+	return userIds, nil
+}
+
+func suggestionsKey(userId int64) string {
+	return fmt.Sprintf("suggestions-%v", userId)
+}
@@ -0,0 +1,241 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/elhambadri2411/social/internal/store"
+	"github.com/go-redis/redis/v8"
+)
+
+// authors with too many followers to push their posts to, their followers pull them instead
+const pulledAuthorsKey = "timeline-pulled-authors"
+
+// a timeline always holds this member, scored +inf, so an empty timeline is still known to be built
+const timelineSentinel = "-"
+
+// `timelineAddScript` adds `ARGV[2..]` (score, member pairs) to each timeline in `KEYS` that exists
+// and trims it to its newest `ARGV[1]` members, a missing timeline is rebuilt when next read.
+// A full timeline only takes posts newer than its oldest one, older posts may have been trimmed
+// already and the timeline must not skip over them.
+var timelineAddScript = redis.NewScript(`
+local keep = tonumber(ARGV[1])
+for _, key in ipairs(KEYS) do
+	if redis.call('EXISTS', key) == 1 then
+		local oldest = nil
+		if redis.call('ZCARD', key) >= keep then
+			oldest = tonumber(redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')[2])
+		end
+		for i = 2, #ARGV, 2 do
+			if oldest == nil or tonumber(ARGV[i]) > oldest then
+				redis.call('ZADD', key, ARGV[i], ARGV[i + 1])
+			end
+		end
+		redis.call('ZREMRANGEBYRANK', key, 0, -keep - 1)
+	end
+end
+return 0
+`)
+
+// timelines pushed to by one script call, so fanning out to many followers doesn't block Redis
+const timelinePushBatch = 500
+
+// `TimelinesCacheRedis` keeps home timelines as sorted sets of post ids scored by creation time.
+// Timelines hold the newest `length` posts and expire `ttl` after they were last read.
+type TimelinesCacheRedis struct {
+	rdb    *redis.Client
+	length int
+	ttl    time.Duration
+}
+
+// `Range` returns up to `limit` entries after `after` newest first, or nil if the timeline isn't built.
+// Entries only carry the post and its creation time.
+func (s *TimelinesCacheRedis) Range(ctx context.Context, userId int64, after *store.FeedCursor, limit int) ([]store.TimelineEntry, error) {
+	key := timelineKey(userId)
+
+	pipe := s.rdb.Pipeline()
+	exists := pipe.Expire(ctx, key, s.ttl)
+	var tied, older *redis.ZSliceCmd
+	if after == nil {
+		older = pipe.ZRevRangeByScoreWithScores(ctx, key, &redis.ZRangeBy{Max: "+inf", Min: "-inf", Count: int64(limit) + 1})
+	} else {
+		// posts created at the same microsecond as the cursor are ordered by id in Go
+		score := strconv.FormatInt(after.CreatedAt.UnixMicro(), 10)
+		tied = pipe.ZRevRangeByScoreWithScores(ctx, key, &redis.ZRangeBy{Max: score, Min: score})
+		older = pipe.ZRevRangeByScoreWithScores(ctx, key, &redis.ZRangeBy{Max: "(" + score, Min: "-inf", Count: int64(limit)})
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+	if !exists.Val() {
+		return nil, nil
+	}
+
+	entries := []store.TimelineEntry{}
+	if tied != nil {
+		tiedEntries, err := timelineEntries(tied.Val())
+		if err != nil {
+			return nil, err
+		}
+		sort.Slice(tiedEntries, func(i, j int) bool { return tiedEntries[i].PostId > tiedEntries[j].PostId })
+		for _, entry := range tiedEntries {
+			if entry.PostId < after.PostId {
+				entries = append(entries, entry)
+			}
+		}
+	}
+
+	olderEntries, err := timelineEntries(older.Val())
+	if err != nil {
+		return nil, err
+	}
+	entries = append(entries, olderEntries...)
+
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+
+	return entries, nil
+}
+
+// `Len` counts the posts in a timeline, 0 if it isn't built
+func (s *TimelinesCacheRedis) Len(ctx context.Context, userId int64) (int, error) {
+	n, err := s.rdb.ZCard(ctx, timelineKey(userId)).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	return max(int(n)-1, 0), nil
+}
+
+// `Build` replaces the timeline of `userId` with `entries`
+func (s *TimelinesCacheRedis) Build(ctx context.Context, userId int64, entries []store.TimelineEntry) error {
+	key := timelineKey(userId)
+
+	members := make([]*redis.Z, 0, len(entries)+1)
+	members = append(members, &redis.Z{Score: math.Inf(1), Member: timelineSentinel})
+	for _, entry := range entries {
+		members = append(members, &redis.Z{Score: timelineScore(entry), Member: timelineMember(entry.PostId)})
+	}
+
+	_, err := s.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, key)
+		pipe.ZAdd(ctx, key, members...)
+		pipe.ZRemRangeByRank(ctx, key, 0, -int64(s.length)-2)
+		pipe.Expire(ctx, key, s.ttl)
+		return nil
+	})
+	return err
+}
+
+// `Push` adds a new post to the built timelines of `userIds`
+func (s *TimelinesCacheRedis) Push(ctx context.Context, userIds []int64, entry store.TimelineEntry) error {
+	args := []any{s.length + 1, timelineScore(entry), timelineMember(entry.PostId)}
+
+	for start := 0; start < len(userIds); start += timelinePushBatch {
+		end := min(start+timelinePushBatch, len(userIds))
+
+		keys := make([]string, 0, end-start)
+		for _, userId := range userIds[start:end] {
+			keys = append(keys, timelineKey(userId))
+		}
+
+		if err := timelineAddScript.Run(ctx, s.rdb, keys, args...).Err(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// `Add` backfills `entries` into the timeline of `userId` if it is built
+func (s *TimelinesCacheRedis) Add(ctx context.Context, userId int64, entries []store.TimelineEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	args := make([]any, 0, len(entries)*2+1)
+	args = append(args, s.length+1)
+	for _, entry := range entries {
+		args = append(args, timelineScore(entry), timelineMember(entry.PostId))
+	}
+
+	return timelineAddScript.Run(ctx, s.rdb, []string{timelineKey(userId)}, args...).Err()
+}
+
+// `Remove` takes posts out of the timeline of `userId`
+func (s *TimelinesCacheRedis) Remove(ctx context.Context, userId int64, postIds []int64) error {
+	if len(postIds) == 0 {
+		return nil
+	}
+
+	members := make([]any, 0, len(postIds))
+	for _, postId := range postIds {
+		members = append(members, timelineMember(postId))
+	}
+
+	return s.rdb.ZRem(ctx, timelineKey(userId), members...).Err()
+}
+
+// `MarkPulled` records that the posts of `authorId` are no longer pushed. Authors stay marked,
+// so posts they made while having too many followers are never missing from timelines.
+func (s *TimelinesCacheRedis) MarkPulled(ctx context.Context, authorId int64) error {
+	return s.rdb.SAdd(ctx, pulledAuthorsKey, authorId).Err()
+}
+
+// `PulledAuthors` lists the authors whose posts followers pull instead of receiving them
+func (s *TimelinesCacheRedis) PulledAuthors(ctx context.Context) ([]int64, error) {
+	members, err := s.rdb.SMembers(ctx, pulledAuthorsKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	authorIds := make([]int64, 0, len(members))
+	for _, member := range members {
+		authorId, err := strconv.ParseInt(member, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		authorIds = append(authorIds, authorId)
+	}
+
+	return authorIds, nil
+}
+
+func timelineEntries(members []redis.Z) ([]store.TimelineEntry, error) {
+	entries := make([]store.TimelineEntry, 0, len(members))
+	for _, member := range members {
+		if member.Member == timelineSentinel {
+			continue
+		}
+
+		postId, err := strconv.ParseInt(member.Member.(string), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, store.TimelineEntry{
+			PostId:    postId,
+			CreatedAt: time.UnixMicro(int64(member.Score)).UTC(),
+		})
+	}
+
+	return entries, nil
+}
+
+// microseconds since the epoch fit the float64 score exactly
+func timelineScore(entry store.TimelineEntry) float64 {
+	return float64(entry.CreatedAt.UnixMicro())
+}
+
+// ids are zero padded so posts created at the same time sort by id like in Postgres
+func timelineMember(postId int64) string {
+	return fmt.Sprintf("%019d", postId)
+}
+
+func timelineKey(userId int64) string {
+	return fmt.Sprintf("timeline-%v", userId)
+}
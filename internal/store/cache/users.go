@@ -35,6 +35,11 @@ func (s *UsersCacheRedis) Get(ctx context.Context, userId int64) (*store.User, e
 	return nil, nil
 }
 
+func (s *UsersCacheRedis) Delete(ctx context.Context, userId int64) error {
+	cacheKey := fmt.Sprintf("user-%v", userId)
+	return s.rdb.Del(ctx, cacheKey).Err()
+}
+
 func (s *UsersCacheRedis) Set(ctx context.Context, user *store.User) error {
 	cacheKey := fmt.Sprintf("user-%v", user.ID)
 
@@ -3,30 +3,35 @@ package store
 import (
 	"context"
 	"database/sql"
+	"errors"
 )
 
 type Comment struct {
-	ID        int64  `json:"id"`
-	Content   string `json:"content"`
-	UserId    int64  `json:"user_id"`
-	PostId    int64  `json:"post_id"`
-	CreatedAt string `json:"created_at"`
-	User      User   `json:"user"`
+	ID        int64     `json:"id"`
+	Content   string    `json:"content"`
+	UserId    int64     `json:"user_id"`
+	PostId    int64     `json:"post_id"`
+	CreatedAt string    `json:"created_at"`
+	UpdatedAt *string   `json:"updated_at"` // nil until the comment is edited
+	User      Author    `json:"user"`
+	Mentions  []Mention `json:"mentions"`
 }
 
 type CommentRepositoryPostgres struct {
 	db *sql.DB
 }
 
-func (s *CommentRepositoryPostgres) GetByPostId(ctx context.Context, postId int64) ([]Comment, error) {
-	query := `SELECT c.id, c.post_id, c.user_id, c.content, c.created_at, u.username, u.id
+// `GetByPostId` returns the comments on post `postId`, leaving out those of users
+// who blocked `viewerId` or were blocked by them. A `viewerId` of 0 returns them all.
+func (s *CommentRepositoryPostgres) GetByPostId(ctx context.Context, postId int64, viewerId int64) ([]Comment, error) {
+	query := `SELECT c.id, c.post_id, c.user_id, c.content, c.created_at, c.updated_at, u.username, u.id
 	FROM comments c JOIN users u on u.id = c.user_id 
-	WHERE c.post_id = $1 
+	WHERE c.post_id = $1 AND NOT ` + blockedSQL("c.user_id", "$2::bigint") + `
 	ORDER BY c.created_at DESC`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
 	defer cancel()
-	rows, err := s.db.QueryContext(ctx, query, postId)
+	rows, err := s.db.QueryContext(ctx, query, postId, viewerId)
 	if err != nil {
 		return nil, err
 	}
@@ -35,9 +40,9 @@ func (s *CommentRepositoryPostgres) GetByPostId(ctx context.Context, postId int6
 
 	for rows.Next() {
 		var comment Comment
-		comment.User = User{}
+		comment.User = Author{}
 
-		err := rows.Scan(&comment.ID, &comment.PostId, &comment.UserId, &comment.Content, &comment.CreatedAt, &comment.User.Username, &comment.User.ID)
+		err := rows.Scan(&comment.ID, &comment.PostId, &comment.UserId, &comment.Content, &comment.CreatedAt, &comment.UpdatedAt, &comment.User.Username, &comment.User.ID)
 		if err != nil {
 			return nil, err
 		}
@@ -72,3 +77,107 @@ func (s *CommentRepositoryPostgres) Create(ctx context.Context, comment *Comment
 
 	return nil
 }
+
+// `GetById` returns the comment `id` on post `postId`
+func (s *CommentRepositoryPostgres) GetById(ctx context.Context, postId int64, id int64) (*Comment, error) {
+	query := `SELECT c.id, c.post_id, c.user_id, c.content, c.created_at, c.updated_at, u.username, u.id
+	FROM comments c JOIN users u on u.id = c.user_id
+	WHERE c.post_id = $1 AND c.id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
+	defer cancel()
+
+	var comment Comment
+	err := s.db.QueryRowContext(ctx, query, postId, id).Scan(
+		&comment.ID,
+		&comment.PostId,
+		&comment.UserId,
+		&comment.Content,
+		&comment.CreatedAt,
+		&comment.UpdatedAt,
+		&comment.User.Username,
+		&comment.User.ID,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &comment, nil
+}
+
+// `Update` writes the content of a comment and marks it as edited
+func (s *CommentRepositoryPostgres) Update(ctx context.Context, comment *Comment) error {
+	query := `
+	UPDATE comments SET content = $1, updated_at = NOW()
+	WHERE id = $2
+	RETURNING updated_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(ctx, query, comment.Content, comment.ID).Scan(&comment.UpdatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// `Delete` removes a comment for good, its mentions go with it
+func (s *CommentRepositoryPostgres) Delete(ctx context.Context, id int64) error {
+	query := `DELETE FROM comments WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// `GetCommenterIds` returns the users who commented on post `postId`
+func (s *CommentRepositoryPostgres) GetCommenterIds(ctx context.Context, postId int64) ([]int64, error) {
+	query := `SELECT DISTINCT user_id FROM comments WHERE post_id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, postId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	userIds := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		userIds = append(userIds, id)
+	}
+
+	return userIds, rows.Err()
+}
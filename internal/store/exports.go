@@ -0,0 +1,368 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+const (
+	ExportPending = "pending"
+	ExportReady   = "ready"
+	ExportFailed  = "failed"
+)
+
+// `Export` is a ZIP archive of everything a user stored, built in the background.
+// `DownloadURL` is a signed link the API adds once the archive is ready.
+type Export struct {
+	ID          int64   `json:"id"`
+	UserId      int64   `json:"-"`
+	Status      string  `json:"status"`
+	StorageKey  *string `json:"-"`
+	Size        int64   `json:"size_bytes"`
+	CreatedAt   string  `json:"created_at"`
+	CompletedAt *string `json:"completed_at,omitempty"`
+	ExpiresAt   string  `json:"expires_at"`
+	DownloadURL string  `json:"download_url,omitempty"`
+}
+
+// `UserData` is the content of a user's data export, besides their profile.
+// There is no reactions table, add reactions here once they are stored.
+type UserData struct {
+	Posts          []ExportedPost    `json:"posts"`
+	Comments       []ExportedComment `json:"comments"`
+	Followers      []RelatedUser     `json:"followers"`
+	Following      []RelatedUser     `json:"following"`
+	FollowRequests []RelatedUser     `json:"follow_requests"`
+	Blocked        []RelatedUser     `json:"blocked"`
+	Muted          []RelatedUser     `json:"muted"`
+	FollowedTags   []string          `json:"followed_tags"`
+}
+
+// `ExportedPost` is a post as written by its author, including trashed ones
+type ExportedPost struct {
+	ID        int64    `json:"id"`
+	Title     string   `json:"title"`
+	Content   string   `json:"content"`
+	Tags      []string `json:"tags"`
+	CreatedAt string   `json:"created_at"`
+	UpdatedAt string   `json:"updated_at"`
+	DeletedAt *string  `json:"deleted_at,omitempty"`
+}
+
+type ExportedComment struct {
+	ID        int64  `json:"id"`
+	PostId    int64  `json:"post_id"`
+	Content   string `json:"content"`
+	CreatedAt string `json:"created_at"`
+}
+
+type ExportsRepositoryPostgres struct {
+	db *sql.DB
+}
+
+// `Create` starts an export, `ErrConflict` if the user already has one being built.
+// Exports pending for over an hour are considered lost, for example to a restart.
+func (s *ExportsRepositoryPostgres) Create(ctx context.Context, export *Export) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
+	defer cancel()
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		// serializes concurrent requests of the same user
+		if _, err := tx.ExecContext(ctx, `SELECT id FROM users WHERE id = $1 FOR UPDATE`, export.UserId); err != nil {
+			return err
+		}
+
+		var pending bool
+		err := tx.QueryRowContext(
+			ctx,
+			`SELECT EXISTS (
+				SELECT 1 FROM data_exports
+				WHERE user_id = $1 AND status = $2 AND created_at > NOW() - INTERVAL '1 hour'
+			)`,
+			export.UserId,
+			ExportPending,
+		).Scan(&pending)
+		if err != nil {
+			return err
+		}
+		if pending {
+			return ErrConflict
+		}
+
+		query := `
+			INSERT INTO data_exports (user_id, status, expires_at) VALUES ($1, $2, $3)
+			RETURNING id, status, created_at, expires_at
+		`
+		return tx.QueryRowContext(ctx, query, export.UserId, ExportPending, export.ExpiresAt).Scan(
+			&export.ID,
+			&export.Status,
+			&export.CreatedAt,
+			&export.ExpiresAt,
+		)
+	})
+}
+
+// `GetById` returns an export that hasn't expired and whose user still exists
+func (s *ExportsRepositoryPostgres) GetById(ctx context.Context, id int64) (*Export, error) {
+	query := `
+		SELECT id, user_id, status, storage_key, size_bytes, created_at, completed_at, expires_at
+		FROM data_exports
+		WHERE id = $1 AND user_id IS NOT NULL AND expires_at > NOW()
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
+	defer cancel()
+
+	var export Export
+	err := s.db.QueryRowContext(ctx, query, id).Scan(
+		&export.ID,
+		&export.UserId,
+		&export.Status,
+		&export.StorageKey,
+		&export.Size,
+		&export.CreatedAt,
+		&export.CompletedAt,
+		&export.ExpiresAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &export, nil
+}
+
+// `Complete` records the stored archive of a pending export
+func (s *ExportsRepositoryPostgres) Complete(ctx context.Context, id int64, key string, size int64) error {
+	query := `
+		UPDATE data_exports SET status = $2, storage_key = $3, size_bytes = $4, completed_at = NOW()
+		WHERE id = $1 AND status = $5
+	`
+	return s.update(ctx, query, id, ExportReady, key, size, ExportPending)
+}
+
+// `Fail` marks a pending export as failed, the user can ask for a new one
+func (s *ExportsRepositoryPostgres) Fail(ctx context.Context, id int64) error {
+	query := `
+		UPDATE data_exports SET status = $2, completed_at = NOW() WHERE id = $1 AND status = $3
+	`
+	return s.update(ctx, query, id, ExportFailed, ExportPending)
+}
+
+func (s *ExportsRepositoryPostgres) update(ctx context.Context, query string, args ...any) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// `GetExpired` lists up to `limit` exports past their expiry or whose user was deleted
+func (s *ExportsRepositoryPostgres) GetExpired(ctx context.Context, limit int) ([]Export, error) {
+	query := `
+		SELECT id, COALESCE(user_id, 0), status, storage_key, size_bytes, created_at, completed_at, expires_at
+		FROM data_exports
+		WHERE expires_at <= NOW() OR user_id IS NULL
+		ORDER BY id
+		LIMIT $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	exports := []Export{}
+	for rows.Next() {
+		var export Export
+		err := rows.Scan(
+			&export.ID,
+			&export.UserId,
+			&export.Status,
+			&export.StorageKey,
+			&export.Size,
+			&export.CreatedAt,
+			&export.CompletedAt,
+			&export.ExpiresAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		exports = append(exports, export)
+	}
+
+	return exports, rows.Err()
+}
+
+func (s *ExportsRepositoryPostgres) Delete(ctx context.Context, id int64) error {
+	return s.update(ctx, `DELETE FROM data_exports WHERE id = $1`, id)
+}
+
+// `GetUserData` collects what a user wrote and their relationships for an export.
+// Reads run in a read only transaction so the archive is a consistent snapshot.
+func (s *ExportsRepositoryPostgres) GetUserData(ctx context.Context, userId int64) (*UserData, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration*6)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	data := &UserData{}
+
+	if data.Posts, err = s.getPosts(ctx, tx, userId); err != nil {
+		return nil, err
+	}
+	if data.Comments, err = s.getComments(ctx, tx, userId); err != nil {
+		return nil, err
+	}
+
+	related := []struct {
+		users *[]RelatedUser
+		query string
+	}{
+		{&data.Followers, `
+			SELECT u.id, u.username, u.display_name, u.avatar_key, u.avatar_thumbnail_key, f.created_at
+			FROM followers f JOIN users u ON u.id = f.follower_id
+			WHERE f.user_id = $1 ORDER BY f.created_at`},
+		{&data.Following, `
+			SELECT u.id, u.username, u.display_name, u.avatar_key, u.avatar_thumbnail_key, f.created_at
+			FROM followers f JOIN users u ON u.id = f.user_id
+			WHERE f.follower_id = $1 ORDER BY f.created_at`},
+		{&data.FollowRequests, `
+			SELECT u.id, u.username, u.display_name, u.avatar_key, u.avatar_thumbnail_key, r.created_at
+			FROM follow_requests r JOIN users u ON u.id = r.user_id
+			WHERE r.follower_id = $1 ORDER BY r.created_at`},
+		{&data.Blocked, `
+			SELECT u.id, u.username, u.display_name, u.avatar_key, u.avatar_thumbnail_key, b.created_at
+			FROM user_blocks b JOIN users u ON u.id = b.blocked_id
+			WHERE b.blocker_id = $1 ORDER BY b.created_at`},
+		{&data.Muted, `
+			SELECT u.id, u.username, u.display_name, u.avatar_key, u.avatar_thumbnail_key, m.created_at
+			FROM user_mutes m JOIN users u ON u.id = m.muted_id
+			WHERE m.muter_id = $1 ORDER BY m.created_at`},
+	}
+	for _, r := range related {
+		if *r.users, err = s.getRelatedUsers(ctx, tx, r.query, userId); err != nil {
+			return nil, err
+		}
+	}
+
+	data.FollowedTags = []string{}
+	err = tx.QueryRowContext(
+		ctx,
+		`SELECT COALESCE(array_agg(t.name ORDER BY t.name), '{}') FROM tag_follows tf JOIN tags t ON t.id = tf.tag_id WHERE tf.user_id = $1`,
+		userId,
+	).Scan(pq.Array(&data.FollowedTags))
+	if err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+func (s *ExportsRepositoryPostgres) getPosts(ctx context.Context, tx *sql.Tx, userId int64) ([]ExportedPost, error) {
+	query := `
+		SELECT id, title, content, tags, created_at, updated_at, deleted_at
+		FROM posts WHERE user_id = $1 ORDER BY id
+	`
+	rows, err := tx.QueryContext(ctx, query, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := []ExportedPost{}
+	for rows.Next() {
+		var post ExportedPost
+		err := rows.Scan(
+			&post.ID,
+			&post.Title,
+			&post.Content,
+			pq.Array(&post.Tags),
+			&post.CreatedAt,
+			&post.UpdatedAt,
+			&post.DeletedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		posts = append(posts, post)
+	}
+
+	return posts, rows.Err()
+}
+
+func (s *ExportsRepositoryPostgres) getComments(ctx context.Context, tx *sql.Tx, userId int64) ([]ExportedComment, error) {
+	query := `
+		SELECT id, post_id, content, created_at FROM comments WHERE user_id = $1 ORDER BY id
+	`
+	rows, err := tx.QueryContext(ctx, query, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []ExportedComment{}
+	for rows.Next() {
+		var comment ExportedComment
+		if err := rows.Scan(&comment.ID, &comment.PostId, &comment.Content, &comment.CreatedAt); err != nil {
+			return nil, err
+		}
+		comments = append(comments, comment)
+	}
+
+	return comments, rows.Err()
+}
+
+func (s *ExportsRepositoryPostgres) getRelatedUsers(ctx context.Context, tx *sql.Tx, query string, userId int64) ([]RelatedUser, error) {
+	rows, err := tx.QueryContext(ctx, query, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []RelatedUser{}
+	for rows.Next() {
+		var user RelatedUser
+		err := rows.Scan(
+			&user.ID,
+			&user.Username,
+			&user.DisplayName,
+			&user.AvatarKey,
+			&user.AvatarThumbnailKey,
+			&user.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	return users, rows.Err()
+}
@@ -0,0 +1,294 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// `FollowListQuery` pages through the followers or followed users of a user, newest follow first.
+// `ViewerId` is the user the `FollowsYou` and `YouFollow` flags are relative to.
+type FollowListQuery struct {
+	ViewerId int64         `json:"-"`
+	Limit    int           `json:"limit" validate:"gte=1,lte=100"`
+	After    *FollowCursor `json:"-"`
+}
+
+// `FollowCursor` is the keyset position after the last returned follow
+type FollowCursor struct {
+	FollowedAt time.Time
+	UserId     int64
+}
+
+// `FollowListEntry` is a user in a followers, following or follow requests list.
+// For follow requests `FollowedAt` is when the request was made.
+type FollowListEntry struct {
+	ID                 int64   `json:"id"`
+	Username           string  `json:"username"`
+	DisplayName        string  `json:"display_name"`
+	Bio                string  `json:"bio"`
+	AvatarKey          *string `json:"-"`
+	AvatarThumbnailKey *string `json:"-"`
+	AvatarURL          string  `json:"avatar_url,omitempty"`
+	AvatarThumbnailURL string  `json:"avatar_thumbnail_url,omitempty"`
+	FollowedAt         string  `json:"followed_at"`
+	FollowsYou         bool    `json:"follows_you"`
+	YouFollow          bool    `json:"you_follow"`
+}
+
+// `FollowCounts` is how many users follow a user and how many they follow
+type FollowCounts struct {
+	Followers int64 `json:"followers_count"`
+	Following int64 `json:"following_count"`
+}
+
+// `Encode` serializes the cursor into an opaque URL safe token
+func (c FollowCursor) Encode() string {
+	return encodeCursor(c.FollowedAt, c.UserId)
+}
+
+// `NextFollowCursor` returns the cursor after the last entry, or an empty string when the page isn't full
+func NextFollowCursor(entries []FollowListEntry, limit int) string {
+	if len(entries) < limit || len(entries) == 0 {
+		return ""
+	}
+
+	last := entries[len(entries)-1]
+	followedAt, err := time.Parse(time.RFC3339Nano, last.FollowedAt)
+	if err != nil {
+		return ""
+	}
+
+	return FollowCursor{FollowedAt: followedAt, UserId: last.ID}.Encode()
+}
+
+// `DecodeFollowCursor` parses a token produced by `FollowCursor.Encode`
+func DecodeFollowCursor(token string) (*FollowCursor, error) {
+	followedAt, id, err := decodeCursor(token)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FollowCursor{FollowedAt: followedAt, UserId: id}, nil
+}
+
+// `visibleSQL` is true when the posts of the user `$author` can be seen by `$viewer`: neither
+// blocked the other, and the account is public, the viewer is the author or the viewer follows them
+func visibleSQL(author, viewer string) string {
+	return `(NOT ` + blockedSQL(author, viewer) + ` AND (
+		NOT EXISTS (SELECT 1 FROM users pu WHERE pu.id = ` + author + ` AND pu.is_private)
+		OR ` + author + ` = ` + viewer + `
+		OR EXISTS (SELECT 1 FROM followers vf WHERE vf.user_id = ` + author + ` AND vf.follower_id = ` + viewer + `)))`
+}
+
+type FollowersRepositoryPostgres struct {
+	db *sql.DB
+}
+
+// `GetFollowers` lists the users following `userId`
+func (s *FollowersRepositoryPostgres) GetFollowers(ctx context.Context, userId int64, q FollowListQuery) ([]FollowListEntry, error) {
+	return s.list(ctx, "followers", "f.follower_id", "f.user_id", userId, q)
+}
+
+// `GetFollowing` lists the users `userId` follows
+func (s *FollowersRepositoryPostgres) GetFollowing(ctx context.Context, userId int64, q FollowListQuery) ([]FollowListEntry, error) {
+	return s.list(ctx, "followers", "f.user_id", "f.follower_id", userId, q)
+}
+
+// `GetRequests` lists the users waiting for `userId` to approve their follow
+func (s *FollowersRepositoryPostgres) GetRequests(ctx context.Context, userId int64, q FollowListQuery) ([]FollowListEntry, error) {
+	return s.list(ctx, "follow_requests", "f.follower_id", "f.user_id", userId, q)
+}
+
+// `list` pages through the rows of `table` where `filterColumn` is `userId` and returns the users in `userColumn`.
+// The table and columns are constants chosen by the callers above.
+func (s *FollowersRepositoryPostgres) list(ctx context.Context, table, userColumn, filterColumn string, userId int64, q FollowListQuery) ([]FollowListEntry, error) {
+	query := `
+		SELECT u.id, u.username, u.display_name, u.bio, u.avatar_key, u.avatar_thumbnail_key, f.created_at,
+			EXISTS (SELECT 1 FROM followers y WHERE y.user_id = $2 AND y.follower_id = u.id),
+			EXISTS (SELECT 1 FROM followers y WHERE y.user_id = u.id AND y.follower_id = $2)
+		FROM ` + table + ` f
+		JOIN users u ON u.id = ` + userColumn + ` AND u.is_active = true
+		WHERE ` + filterColumn + ` = $1
+			AND ($3::timestamptz IS NULL OR (f.created_at, ` + userColumn + `) < ($3::timestamptz, $4::bigint))
+		ORDER BY f.created_at DESC, ` + userColumn + ` DESC
+		LIMIT $5
+	`
+
+	var afterTime *time.Time
+	var afterId *int64
+	if q.After != nil {
+		afterTime, afterId = &q.After.FollowedAt, &q.After.UserId
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userId, q.ViewerId, afterTime, afterId, q.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []FollowListEntry{}
+	for rows.Next() {
+		var e FollowListEntry
+		err := rows.Scan(
+			&e.ID,
+			&e.Username,
+			&e.DisplayName,
+			&e.Bio,
+			&e.AvatarKey,
+			&e.AvatarThumbnailKey,
+			&e.FollowedAt,
+			&e.FollowsYou,
+			&e.YouFollow,
+		)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, rows.Err()
+}
+
+func (s *FollowersRepositoryPostgres) GetCounts(ctx context.Context, userId int64) (*FollowCounts, error) {
+	query := `
+		SELECT
+			(SELECT COUNT(*) FROM followers f JOIN users u ON u.id = f.follower_id AND u.is_active = true WHERE f.user_id = $1),
+			(SELECT COUNT(*) FROM followers f JOIN users u ON u.id = f.user_id AND u.is_active = true WHERE f.follower_id = $1)
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
+	defer cancel()
+
+	var counts FollowCounts
+	if err := s.db.QueryRowContext(ctx, query, userId).Scan(&counts.Followers, &counts.Following); err != nil {
+		return nil, err
+	}
+
+	return &counts, nil
+}
+
+// `CreateRequest` asks `userId` to approve a follow by `followerId`, returns `ErrConflict` when
+// a request is already pending or the user is already followed
+func (s *FollowersRepositoryPostgres) CreateRequest(ctx context.Context, userId int64, followerId int64) error {
+	query := `
+		INSERT INTO follow_requests (user_id, follower_id)
+		SELECT $1, $2
+		WHERE NOT EXISTS (SELECT 1 FROM followers WHERE user_id = $1 AND follower_id = $2)
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userId, followerId)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return ErrConflict
+		}
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrConflict
+	}
+
+	return nil
+}
+
+// `ApproveRequest` turns the pending request of `followerId` into a follow of `userId`
+func (s *FollowersRepositoryPostgres) ApproveRequest(ctx context.Context, userId int64, followerId int64) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
+	defer cancel()
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		if err := deleteRequest(ctx, tx, userId, followerId); err != nil {
+			return err
+		}
+
+		_, err := tx.ExecContext(
+			ctx,
+			`INSERT INTO followers (user_id, follower_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`,
+			userId,
+			followerId,
+		)
+		return err
+	})
+}
+
+// `DeleteRequest` rejects or cancels the pending request of `followerId` to follow `userId`
+func (s *FollowersRepositoryPostgres) DeleteRequest(ctx context.Context, userId int64, followerId int64) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
+	defer cancel()
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		return deleteRequest(ctx, tx, userId, followerId)
+	})
+}
+
+func deleteRequest(ctx context.Context, tx *sql.Tx, userId int64, followerId int64) error {
+	res, err := tx.ExecContext(ctx, `DELETE FROM follow_requests WHERE user_id = $1 AND follower_id = $2`, userId, followerId)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// `CanView` reports whether `viewerId` may see the posts and follows of `authorId`, see `visibleSQL`
+func (s *FollowersRepositoryPostgres) CanView(ctx context.Context, viewerId int64, authorId int64) (bool, error) {
+	query := `SELECT ` + visibleSQL("$1::bigint", "$2::bigint")
+
+	ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
+	defer cancel()
+
+	var visible bool
+	if err := s.db.QueryRowContext(ctx, query, authorId, viewerId).Scan(&visible); err != nil {
+		return false, err
+	}
+
+	return visible, nil
+}
+
+// `GetHiddenPosts` returns the ids among `postIds` whose author is blocked, or private and not followed by `viewerId`
+func (s *FollowersRepositoryPostgres) GetHiddenPosts(ctx context.Context, viewerId int64, postIds []int64) ([]int64, error) {
+	query := `
+		SELECT p.id FROM posts p
+		WHERE p.id = ANY($1) AND NOT ` + visibleSQL("p.user_id", "$2::bigint")
+
+	ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(postIds), viewerId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hidden := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		hidden = append(hidden, id)
+	}
+
+	return hidden, rows.Err()
+}
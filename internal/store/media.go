@@ -0,0 +1,193 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+var ErrLimitExceeded = errors.New("limit exceeded")
+
+// `Media` is an image attached to a post. The blobs live in a `blobstore.Store` under
+// `Key` and `ThumbnailKey`, the API fills in the URLs they are served from.
+type Media struct {
+	ID           int64  `json:"id"`
+	PostId       int64  `json:"post_id"`
+	UserId       int64  `json:"user_id"`
+	Position     int    `json:"position"`
+	Key          string `json:"-"`
+	ThumbnailKey string `json:"-"`
+	ContentType  string `json:"content_type"`
+	Size         int64  `json:"size"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+	AltText      string `json:"alt_text"`
+	URL          string `json:"url"`
+	ThumbnailURL string `json:"thumbnail_url"`
+	CreatedAt    string `json:"created_at"`
+}
+
+type MediaRepositoryPostgres struct {
+	db *sql.DB
+}
+
+// `Create` attaches media to a post, returns `ErrLimitExceeded` when the post already has `max` attachments
+func (s *MediaRepositoryPostgres) Create(ctx context.Context, media *Media, max int) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
+	defer cancel()
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		// lock the post so concurrent uploads can't go over the limit
+		var postId int64
+		err := tx.QueryRowContext(ctx, `SELECT id FROM posts WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`, media.PostId).Scan(&postId)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrNotFound
+			}
+			return err
+		}
+
+		var count int
+		if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM post_media WHERE post_id = $1`, media.PostId).Scan(&count); err != nil {
+			return err
+		}
+		if count >= max {
+			return ErrLimitExceeded
+		}
+		media.Position = count
+
+		query := `
+			INSERT INTO post_media (post_id, user_id, position, storage_key, thumbnail_key, content_type, size_bytes, width, height, alt_text)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+			RETURNING id, created_at
+		`
+
+		return tx.QueryRowContext(
+			ctx,
+			query,
+			media.PostId,
+			media.UserId,
+			media.Position,
+			media.Key,
+			media.ThumbnailKey,
+			media.ContentType,
+			media.Size,
+			media.Width,
+			media.Height,
+			media.AltText,
+		).Scan(&media.ID, &media.CreatedAt)
+	})
+}
+
+func (s *MediaRepositoryPostgres) GetByPostId(ctx context.Context, postId int64) ([]Media, error) {
+	query := `
+		SELECT id, post_id, COALESCE(user_id, 0), position, storage_key, thumbnail_key, content_type, size_bytes, width, height, alt_text, created_at
+		FROM post_media WHERE post_id = $1
+		ORDER BY position, id
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, postId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanMedia(rows)
+}
+
+func (s *MediaRepositoryPostgres) GetById(ctx context.Context, postId int64, id int64) (*Media, error) {
+	query := `
+		SELECT id, post_id, COALESCE(user_id, 0), position, storage_key, thumbnail_key, content_type, size_bytes, width, height, alt_text, created_at
+		FROM post_media WHERE post_id = $1 AND id = $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, postId, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	media, err := scanMedia(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(media) == 0 {
+		return nil, ErrNotFound
+	}
+
+	return &media[0], nil
+}
+
+// `GetDetached` lists media whose post or author was deleted, their blobs can be removed
+func (s *MediaRepositoryPostgres) GetDetached(ctx context.Context, limit int) ([]Media, error) {
+	query := `
+		SELECT id, COALESCE(post_id, 0), COALESCE(user_id, 0), position, storage_key, thumbnail_key, content_type, size_bytes, width, height, alt_text, created_at
+		FROM post_media WHERE post_id IS NULL
+		ORDER BY id
+		LIMIT $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanMedia(rows)
+}
+
+func (s *MediaRepositoryPostgres) Delete(ctx context.Context, id int64) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, `DELETE FROM post_media WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func scanMedia(rows *sql.Rows) ([]Media, error) {
+	media := []Media{}
+	for rows.Next() {
+		var m Media
+		err := rows.Scan(
+			&m.ID,
+			&m.PostId,
+			&m.UserId,
+			&m.Position,
+			&m.Key,
+			&m.ThumbnailKey,
+			&m.ContentType,
+			&m.Size,
+			&m.Width,
+			&m.Height,
+			&m.AltText,
+			&m.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		media = append(media, m)
+	}
+
+	return media, rows.Err()
+}
@@ -0,0 +1,298 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/elhambadri2411/social/internal/mentions"
+	"github.com/lib/pq"
+)
+
+// `Mention` is a resolved @username entity in a post or comment.
+// `Offset` and `Length` locate it in the source content, in Unicode code points.
+type Mention struct {
+	ID        int64  `json:"-"`
+	PostId    int64  `json:"-"`
+	CommentId *int64 `json:"-"`
+	AuthorId  int64  `json:"-"`
+	UserId    int64  `json:"user_id"`
+	Username  string `json:"username"`
+	Offset    int    `json:"offset"`
+	Length    int    `json:"length"`
+	CreatedAt string `json:"-"`
+}
+
+// `MentionSource` identifies the content mentions were parsed from:
+// the post body when `CommentId` is nil, otherwise a comment on the post.
+type MentionSource struct {
+	PostId    int64
+	CommentId *int64
+	AuthorId  int64
+}
+
+// `UserMention` is a place where a user was mentioned, as listed in their mentions inbox
+type UserMention struct {
+	PostId         int64  `json:"post_id"`
+	PostTitle      string `json:"post_title"`
+	CommentId      *int64 `json:"comment_id,omitempty"`
+	AuthorId       int64  `json:"author_id"`
+	AuthorUsername string `json:"author_username"`
+	Excerpt        string `json:"excerpt"`
+	CreatedAt      string `json:"created_at"`
+}
+
+type MentionRepositoryPostgres struct {
+	db *sql.DB
+}
+
+// `Sync` resolves `entities` against existing users and replaces the mentions stored for `source`.
+//
+// Returns every resolved mention and, separately, the mentions of users that were not
+// mentioned in `source` before (the ones worth notifying).
+func (s *MentionRepositoryPostgres) Sync(ctx context.Context, source MentionSource, entities []mentions.Entity) ([]Mention, []Mention, error) {
+	var all, created []Mention
+
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
+		defer cancel()
+
+		users, err := resolveUsernames(ctx, tx, source.AuthorId, mentions.Usernames(entities))
+		if err != nil {
+			return err
+		}
+
+		muters, err := mutersOf(ctx, tx, source.AuthorId, users)
+		if err != nil {
+			return err
+		}
+
+		previous := make(map[int64]bool)
+		rows, err := tx.QueryContext(ctx, `
+			DELETE FROM mentions
+			WHERE post_id = $1 AND comment_id IS NOT DISTINCT FROM $2
+			RETURNING mentioned_user_id
+		`, source.PostId, source.CommentId)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var userId int64
+			if err := rows.Scan(&userId); err != nil {
+				rows.Close()
+				return err
+			}
+			previous[userId] = true
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		query := `
+			INSERT INTO mentions (post_id, comment_id, author_id, mentioned_user_id, "offset", length)
+			VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at
+		`
+
+		notified := make(map[int64]bool)
+		for _, entity := range entities {
+			user, ok := users[strings.ToLower(entity.Username)]
+			if !ok {
+				continue
+			}
+
+			mention := Mention{
+				PostId:    source.PostId,
+				CommentId: source.CommentId,
+				AuthorId:  source.AuthorId,
+				UserId:    user.ID,
+				Username:  user.Username,
+				Offset:    entity.Offset,
+				Length:    entity.Length,
+			}
+
+			err := tx.QueryRowContext(
+				ctx,
+				query,
+				mention.PostId,
+				mention.CommentId,
+				mention.AuthorId,
+				mention.UserId,
+				mention.Offset,
+				mention.Length,
+			).Scan(&mention.ID, &mention.CreatedAt)
+			if err != nil {
+				return err
+			}
+
+			all = append(all, mention)
+			if !previous[user.ID] && !notified[user.ID] && !muters[user.ID] && user.ID != source.AuthorId {
+				notified[user.ID] = true
+				created = append(created, mention)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return all, created, nil
+}
+
+// `GetByPostId` returns the mentions of a post body and of all its comments
+func (s *MentionRepositoryPostgres) GetByPostId(ctx context.Context, postId int64) ([]Mention, error) {
+	query := `
+		SELECT m.id, m.post_id, m.comment_id, m.author_id, m.mentioned_user_id, u.username, m."offset", m.length, m.created_at
+		FROM mentions m JOIN users u ON u.id = m.mentioned_user_id
+		WHERE m.post_id = $1
+		ORDER BY m.comment_id NULLS FIRST, m."offset"
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, postId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []Mention
+	for rows.Next() {
+		var mention Mention
+		err := rows.Scan(
+			&mention.ID,
+			&mention.PostId,
+			&mention.CommentId,
+			&mention.AuthorId,
+			&mention.UserId,
+			&mention.Username,
+			&mention.Offset,
+			&mention.Length,
+			&mention.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, mention)
+	}
+
+	return result, rows.Err()
+}
+
+// `GetForUser` lists where `userId` was mentioned, newest first
+func (s *MentionRepositoryPostgres) GetForUser(ctx context.Context, userId int64, limit int, offset int) ([]UserMention, error) {
+	query := `
+		SELECT DISTINCT ON (m.created_at, m.post_id, m.comment_id)
+			m.post_id, p.title, m.comment_id, m.author_id, a.username,
+			LEFT(COALESCE(c.content, p.content), 200), m.created_at
+		FROM mentions m
+		JOIN posts p ON p.id = m.post_id AND p.deleted_at IS NULL
+		JOIN users a ON a.id = m.author_id
+		LEFT JOIN comments c ON c.id = m.comment_id
+		WHERE m.mentioned_user_id = $1 AND ` + visibleSQL("p.user_id", "$1") + `
+		ORDER BY m.created_at DESC, m.post_id DESC, m.comment_id DESC NULLS LAST
+		LIMIT $2 OFFSET $3
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userId, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []UserMention{}
+	for rows.Next() {
+		var mention UserMention
+		err := rows.Scan(
+			&mention.PostId,
+			&mention.PostTitle,
+			&mention.CommentId,
+			&mention.AuthorId,
+			&mention.AuthorUsername,
+			&mention.Excerpt,
+			&mention.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, mention)
+	}
+
+	return result, rows.Err()
+}
+
+// `resolveUsernames` maps lower cased usernames to the active users carrying them,
+// users who blocked `authorId` or were blocked by them can't be mentioned.
+// Usernames given up recently still resolve to their previous owner while reserved.
+func resolveUsernames(ctx context.Context, tx *sql.Tx, authorId int64, usernames []string) (map[string]User, error) {
+	users := make(map[string]User, len(usernames))
+	if len(usernames) == 0 {
+		return users, nil
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT u.id, u.username, handles.username FROM (
+			SELECT id AS user_id, username FROM users WHERE username = ANY($1::citext[])
+			UNION ALL
+			SELECT h.user_id, h.username FROM username_history h
+			WHERE h.username = ANY($1::citext[]) AND h.reserved_until > NOW()
+				AND NOT EXISTS (SELECT 1 FROM users WHERE username = h.username)
+		) handles
+		JOIN users u ON u.id = handles.user_id
+		WHERE u.is_active = true AND NOT `+blockedSQL("u.id", "$2::bigint")+`
+	`, pq.Array(usernames), authorId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			user   User
+			handle string
+		)
+		if err := rows.Scan(&user.ID, &user.Username, &handle); err != nil {
+			return nil, err
+		}
+		users[strings.ToLower(handle)] = user
+	}
+
+	return users, rows.Err()
+}
+
+// `mutersOf` returns which of `users` muted `authorId`, they are not notified of its mentions
+func mutersOf(ctx context.Context, tx *sql.Tx, authorId int64, users map[string]User) (map[int64]bool, error) {
+	muters := make(map[int64]bool)
+	if len(users) == 0 {
+		return muters, nil
+	}
+
+	ids := make([]int64, 0, len(users))
+	for _, user := range users {
+		ids = append(ids, user.ID)
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT muter_id FROM user_mutes WHERE muted_id = $1 AND muter_id = ANY($2)
+	`, authorId, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		muters[id] = true
+	}
+
+	return muters, rows.Err()
+}
@@ -3,12 +3,31 @@ package store
 import (
 	"context"
 	"database/sql"
+	"fmt"
 	"time"
+
+	"github.com/elhambadri2411/social/internal/mentions"
+	"github.com/elhambadri2411/social/internal/tags"
 )
 
 func NewMockStore() Storage {
 	return Storage{
-		UsersRepository: &MockUserStore{},
+		UsersRepository:                   &MockUserStore{},
+		PostsRepository:                   &MockPostStore{},
+		RolesRepository:                   &MockRolesStore{},
+		CommentsRepository:                &MockCommentStore{},
+		SnippetsRepository:                &MockSnippetStore{},
+		MentionsRepository:                &MockMentionStore{},
+		TagsRepository:                    &MockTagStore{},
+		SearchRepository:                  &MockSearchStore{},
+		MediaRepository:                   &MockMediaStore{},
+		FollowersRepository:               &MockFollowersStore{},
+		BlocksRepository:                  &MockBlocksStore{},
+		SuggestionsRepository:             &MockSuggestionsStore{},
+		ExportsRepository:                 &MockExportsStore{},
+		TimelinesRepository:               &MockTimelinesStore{},
+		NotificationsRepository:           &MockNotificationsStore{},
+		NotificationPreferencesRepository: &MockNotificationPreferencesStore{},
 	}
 }
 
@@ -27,7 +46,7 @@ func (m *MockUserStore) createUserInvitation(ctx context.Context, tx *sql.Tx, to
 }
 
 func (m *MockUserStore) GetById(ctx context.Context, id int64) (*User, error) {
-	return &User{ID: id}, nil
+	return &User{ID: id, Username: fmt.Sprintf("user%d", id), IsActive: true}, nil
 }
 
 func (m *MockUserStore) Follow(ctx context.Context, userToFollowId int64, userId int64) error {
@@ -58,10 +77,462 @@ func (m *MockUserStore) deleteUser(ctx context.Context, tx *sql.Tx, id int64) er
 	return nil
 }
 
-func (m *MockUserStore) Delete(ctx context.Context, id int64) error {
+func (m *MockUserStore) Delete(ctx context.Context, id int64) ([]int64, error) {
+	return []int64{}, nil
+}
+
+func (m *MockUserStore) ScheduleDeletion(ctx context.Context, id int64, at time.Time) (string, error) {
+	return at.UTC().Format(time.RFC3339), nil
+}
+
+func (m *MockUserStore) CancelDeletion(ctx context.Context, id int64) error {
+	return nil
+}
+
+func (m *MockUserStore) ChangeUsername(ctx context.Context, userId int64, username string, cooldown time.Duration, reservation time.Duration) error {
 	return nil
 }
 
+func (m *MockUserStore) ResolveUsername(ctx context.Context, username string) (int64, error) {
+	return 1, nil
+}
+
+func (m *MockUserStore) GetDueDeletions(ctx context.Context, limit int) ([]*User, error) {
+	return []*User{}, nil
+}
+
 func (m *MockUserStore) GetByEmail(ctx context.Context, email string) (*User, error) {
 	return &User{}, nil
 }
+
+func (m *MockUserStore) UpdateProfile(ctx context.Context, user *User) error {
+	return nil
+}
+
+// `MockRolesStore` knows the roles seeded by the roles migration
+type MockRolesStore struct{}
+
+func (m *MockRolesStore) GetByName(ctx context.Context, roleName string) (*Role, error) {
+	levels := map[string]int{"user": 1, "moderator": 2, "admin": 3}
+
+	level, ok := levels[roleName]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return &Role{ID: int64(level), Name: roleName, Level: level}, nil
+}
+
+// `MockPostsUserId` is the owner of every post and comment returned by `MockPostStore` and `MockCommentStore`
+// (matches the subject of the mock authenticator's token)
+const MockPostsUserId = 21
+
+type MockPostStore struct{}
+
+func (m *MockPostStore) Create(ctx context.Context, post *Post) error {
+	return nil
+}
+
+func (m *MockPostStore) GetById(ctx context.Context, id int64) (*Post, error) {
+	return &Post{ID: id, UserId: MockPostsUserId, Version: 1}, nil
+}
+
+func (m *MockPostStore) GetAll(ctx context.Context, limit int64, offset int64) ([]*Post, error) {
+	return []*Post{}, nil
+}
+
+func (m *MockPostStore) DeleteById(ctx context.Context, id int64, version int64) error {
+	return nil
+}
+
+func (m *MockPostStore) UpdateById(ctx context.Context, post *Post) error {
+	post.Version++
+	return nil
+}
+
+func (m *MockPostStore) GetDeletedById(ctx context.Context, id int64) (*Post, error) {
+	deletedAt := time.Now().Format(time.RFC3339)
+	return &Post{ID: id, UserId: MockPostsUserId, Version: 1, DeletedAt: &deletedAt}, nil
+}
+
+func (m *MockPostStore) GetDeleted(ctx context.Context, userId int64, limit int64, offset int64) ([]*Post, error) {
+	return []*Post{}, nil
+}
+
+func (m *MockPostStore) Restore(ctx context.Context, post *Post, retention time.Duration) error {
+	post.DeletedAt = nil
+	post.Version++
+	return nil
+}
+
+func (m *MockPostStore) PurgeDeleted(ctx context.Context, retention time.Duration) (int64, error) {
+	return 0, nil
+}
+
+func (m *MockPostStore) GetByTag(ctx context.Context, tag string, viewerId int64, limit int, offset int) ([]*FeedPost, error) {
+	return []*FeedPost{}, nil
+}
+
+func (m *MockPostStore) GetPublic(ctx context.Context, authorId int64, tag string, limit int, after *FeedCursor) ([]*FeedPost, error) {
+	return []*FeedPost{{Post: Post{
+		ID:          1,
+		Title:       "Generics & <interfaces>",
+		Content:     "Hello",
+		ContentHTML: "<p>Hello &amp; welcome</p>",
+		UserId:      max(authorId, 1),
+		Tags:        []string{"go"},
+		CreatedAt:   "2025-09-01T10:00:00Z",
+		UpdatedAt:   "2025-09-02T10:00:00Z",
+		Version:     2,
+		User:        Author{ID: max(authorId, 1), Username: "gopher"},
+	}}}, nil
+}
+
+func (m *MockPostStore) GetBatch(ctx context.Context, afterId int64, limit int) ([]*Post, error) {
+	return []*Post{}, nil
+}
+
+func (m *MockPostStore) GetUserFeed(ctx context.Context, userId int64, pfq PaginatedFeedQuery) ([]*FeedPost, error) {
+	return []*FeedPost{}, nil
+}
+
+func (m *MockPostStore) GetRankedFeed(ctx context.Context, userId int64, pfq PaginatedFeedQuery, ranking FeedRanking, asOf time.Time) ([]*FeedPost, error) {
+	return []*FeedPost{
+		{Post: Post{ID: 2}, Score: &FeedScore{Total: 1.5, Recency: 1, Velocity: 0.5}},
+		{Post: Post{ID: 1}, Score: &FeedScore{Total: 0.8, Recency: 0.8}},
+	}, nil
+}
+
+type MockCommentStore struct{}
+
+func (m *MockCommentStore) GetByPostId(ctx context.Context, postId int64, viewerId int64) ([]Comment, error) {
+	return []Comment{}, nil
+}
+
+func (m *MockCommentStore) GetById(ctx context.Context, postId int64, id int64) (*Comment, error) {
+	return &Comment{ID: id, PostId: postId, UserId: MockPostsUserId, Content: "comment", User: Author{ID: MockPostsUserId}}, nil
+}
+
+func (m *MockCommentStore) Create(ctx context.Context, comment *Comment) error {
+	return nil
+}
+
+func (m *MockCommentStore) Update(ctx context.Context, comment *Comment) error {
+	return nil
+}
+
+func (m *MockCommentStore) Delete(ctx context.Context, id int64) error {
+	return nil
+}
+
+func (m *MockCommentStore) GetCommenterIds(ctx context.Context, postId int64) ([]int64, error) {
+	return []int64{}, nil
+}
+
+type MockSnippetStore struct{}
+
+func (m *MockSnippetStore) GetByPostId(ctx context.Context, postId int64) ([]Snippet, error) {
+	return []Snippet{}, nil
+}
+
+func (m *MockSnippetStore) GetById(ctx context.Context, postId int64, id int64) (*Snippet, error) {
+	return &Snippet{ID: id, PostId: postId, Filename: "main.go", Language: "go", Body: "package main\n"}, nil
+}
+
+type MockMentionStore struct{}
+
+func (m *MockMentionStore) Sync(ctx context.Context, source MentionSource, entities []mentions.Entity) ([]Mention, []Mention, error) {
+	return []Mention{}, []Mention{}, nil
+}
+
+func (m *MockMentionStore) GetByPostId(ctx context.Context, postId int64) ([]Mention, error) {
+	return []Mention{}, nil
+}
+
+func (m *MockMentionStore) GetForUser(ctx context.Context, userId int64, limit int, offset int) ([]UserMention, error) {
+	return []UserMention{}, nil
+}
+
+type MockTagStore struct{}
+
+func (m *MockTagStore) Resolve(ctx context.Context, names []string) ([]string, error) {
+	return tags.NormalizeAll(names), nil
+}
+
+func (m *MockTagStore) GetByName(ctx context.Context, name string) (*Tag, error) {
+	return &Tag{ID: 1, Name: tags.Normalize(name), Aliases: []string{}}, nil
+}
+
+func (m *MockTagStore) Follow(ctx context.Context, tagId int64, userId int64) error {
+	return nil
+}
+
+func (m *MockTagStore) Unfollow(ctx context.Context, tagId int64, userId int64) error {
+	return nil
+}
+
+func (m *MockTagStore) GetFollowed(ctx context.Context, userId int64) ([]Tag, error) {
+	return []Tag{}, nil
+}
+
+func (m *MockTagStore) GetTrending(ctx context.Context, window time.Duration, limit int) ([]TrendingTag, error) {
+	return []TrendingTag{}, nil
+}
+
+type MockSearchStore struct{}
+
+func (m *MockSearchStore) Search(ctx context.Context, q SearchQuery) ([]SearchResult, error) {
+	return []SearchResult{}, nil
+}
+
+type MockMediaStore struct{}
+
+func (m *MockMediaStore) Create(ctx context.Context, media *Media, max int) error {
+	return nil
+}
+
+func (m *MockMediaStore) GetByPostId(ctx context.Context, postId int64) ([]Media, error) {
+	return []Media{}, nil
+}
+
+func (m *MockMediaStore) GetById(ctx context.Context, postId int64, id int64) (*Media, error) {
+	return &Media{ID: id, PostId: postId}, nil
+}
+
+func (m *MockMediaStore) GetDetached(ctx context.Context, limit int) ([]Media, error) {
+	return []Media{}, nil
+}
+
+func (m *MockMediaStore) Delete(ctx context.Context, id int64) error {
+	return nil
+}
+
+type MockFollowersStore struct{}
+
+func (m *MockFollowersStore) GetFollowers(ctx context.Context, userId int64, q FollowListQuery) ([]FollowListEntry, error) {
+	return []FollowListEntry{}, nil
+}
+
+func (m *MockFollowersStore) GetFollowing(ctx context.Context, userId int64, q FollowListQuery) ([]FollowListEntry, error) {
+	return []FollowListEntry{}, nil
+}
+
+func (m *MockFollowersStore) GetCounts(ctx context.Context, userId int64) (*FollowCounts, error) {
+	return &FollowCounts{}, nil
+}
+
+func (m *MockFollowersStore) GetRequests(ctx context.Context, userId int64, q FollowListQuery) ([]FollowListEntry, error) {
+	return []FollowListEntry{}, nil
+}
+
+func (m *MockFollowersStore) CreateRequest(ctx context.Context, userId int64, followerId int64) error {
+	return nil
+}
+
+func (m *MockFollowersStore) ApproveRequest(ctx context.Context, userId int64, followerId int64) error {
+	return nil
+}
+
+func (m *MockFollowersStore) DeleteRequest(ctx context.Context, userId int64, followerId int64) error {
+	return nil
+}
+
+func (m *MockFollowersStore) CanView(ctx context.Context, viewerId int64, authorId int64) (bool, error) {
+	return true, nil
+}
+
+func (m *MockFollowersStore) GetHiddenPosts(ctx context.Context, viewerId int64, postIds []int64) ([]int64, error) {
+	return []int64{}, nil
+}
+
+type MockBlocksStore struct{}
+
+func (m *MockBlocksStore) GetMuters(ctx context.Context, mutedId int64, userIds []int64) ([]int64, error) {
+	return []int64{}, nil
+}
+
+func (m *MockBlocksStore) Block(ctx context.Context, blockerId int64, blockedId int64) error {
+	return nil
+}
+
+func (m *MockBlocksStore) Unblock(ctx context.Context, blockerId int64, blockedId int64) error {
+	return nil
+}
+
+func (m *MockBlocksStore) Mute(ctx context.Context, muterId int64, mutedId int64) error {
+	return nil
+}
+
+func (m *MockBlocksStore) Unmute(ctx context.Context, muterId int64, mutedId int64) error {
+	return nil
+}
+
+func (m *MockBlocksStore) GetBlocked(ctx context.Context, userId int64, limit int, offset int) ([]RelatedUser, error) {
+	return []RelatedUser{}, nil
+}
+
+func (m *MockBlocksStore) GetMuted(ctx context.Context, userId int64, limit int, offset int) ([]RelatedUser, error) {
+	return []RelatedUser{}, nil
+}
+
+func (m *MockBlocksStore) GetHiddenAuthors(ctx context.Context, viewerId int64, userIds []int64) ([]int64, error) {
+	return []int64{}, nil
+}
+
+func (m *MockBlocksStore) IsBlocked(ctx context.Context, userId int64, otherId int64) (bool, error) {
+	return false, nil
+}
+
+type MockSuggestionsStore struct{}
+
+func (m *MockSuggestionsStore) GetForUser(ctx context.Context, userId int64, limit int) ([]Suggestion, error) {
+	return []Suggestion{}, nil
+}
+
+type MockExportsStore struct{}
+
+func (m *MockExportsStore) Create(ctx context.Context, export *Export) error {
+	export.ID = 1
+	export.Status = ExportPending
+	return nil
+}
+
+func (m *MockExportsStore) GetById(ctx context.Context, id int64) (*Export, error) {
+	key := "exports/2025/08/test.zip"
+	return &Export{ID: id, UserId: 21, Status: ExportReady, StorageKey: &key}, nil
+}
+
+func (m *MockExportsStore) Complete(ctx context.Context, id int64, key string, size int64) error {
+	return nil
+}
+
+func (m *MockExportsStore) Fail(ctx context.Context, id int64) error {
+	return nil
+}
+
+func (m *MockExportsStore) GetExpired(ctx context.Context, limit int) ([]Export, error) {
+	return []Export{}, nil
+}
+
+func (m *MockExportsStore) Delete(ctx context.Context, id int64) error {
+	return nil
+}
+
+func (m *MockExportsStore) GetUserData(ctx context.Context, userId int64) (*UserData, error) {
+	return &UserData{}, nil
+}
+
+type MockTimelinesStore struct{}
+
+func (m *MockTimelinesStore) GetFollowerIds(ctx context.Context, authorId int64, limit int) ([]int64, error) {
+	return []int64{}, nil
+}
+
+func (m *MockTimelinesStore) GetAuthorEntries(ctx context.Context, authorId int64, limit int) ([]TimelineEntry, error) {
+	return []TimelineEntry{}, nil
+}
+
+func (m *MockTimelinesStore) GetFollowedEntries(ctx context.Context, userId int64, limit int) ([]TimelineEntry, error) {
+	return []TimelineEntry{}, nil
+}
+
+func (m *MockTimelinesStore) GetPulledEntries(ctx context.Context, userId int64, authorIds []int64, after *FeedCursor, limit int) ([]TimelineEntry, error) {
+	return []TimelineEntry{}, nil
+}
+
+func (m *MockTimelinesStore) Hydrate(ctx context.Context, viewerId int64, postIds []int64) ([]*FeedPost, error) {
+	feedPosts := make([]*FeedPost, 0, len(postIds))
+	for _, id := range postIds {
+		feedPosts = append(feedPosts, &FeedPost{Post: Post{ID: id}})
+	}
+	return feedPosts, nil
+}
+
+type MockNotificationsStore struct{}
+
+func (m *MockNotificationsStore) Create(ctx context.Context, notification Notification, userIds []int64) ([]Notification, error) {
+	created := []Notification{}
+	for _, userId := range userIds {
+		if userId == notification.ActorId {
+			continue
+		}
+		n := notification
+		n.ID = int64(len(created) + 1)
+		n.UserId = userId
+		n.Actor = Author{ID: n.ActorId}
+		n.Delivery = DeliveryInApp
+		created = append(created, n)
+	}
+	return created, nil
+}
+
+func (m *MockNotificationsStore) GetForUser(ctx context.Context, userId int64, limit int, offset int) (*NotificationsPage, error) {
+	postId := int64(1)
+	return &NotificationsPage{
+		Notifications: []NotificationGroup{{
+			ID:         3,
+			Type:       "comment",
+			PostId:     &postId,
+			Actors:     []Author{{ID: 2, Username: "ana"}},
+			ActorCount: 5,
+			Count:      6,
+			Unread:     true,
+		}},
+		UnreadCount: 1,
+	}, nil
+}
+
+func (m *MockNotificationsStore) MarkRead(ctx context.Context, userId int64, id int64) error {
+	if id != 3 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (m *MockNotificationsStore) MarkAllRead(ctx context.Context, userId int64) (int64, error) {
+	return 1, nil
+}
+
+func (m *MockNotificationsStore) Purge(ctx context.Context, retention time.Duration) (int64, error) {
+	return 0, nil
+}
+
+type MockNotificationPreferencesStore struct{}
+
+func (m *MockNotificationPreferencesStore) Get(ctx context.Context, userId int64) (*NotificationPreferences, error) {
+	return &NotificationPreferences{
+		Delivery:        map[string]string{"comment": DeliveryDigest},
+		DigestFrequency: DigestDaily,
+	}, nil
+}
+
+func (m *MockNotificationPreferencesStore) Set(ctx context.Context, userId int64, preferences NotificationPreferences) error {
+	return nil
+}
+
+func (m *MockNotificationPreferencesStore) Unsubscribe(ctx context.Context, userId int64, delivery string, notificationType string) error {
+	return nil
+}
+
+func (m *MockNotificationPreferencesStore) GetDueDigests(ctx context.Context, limit int) ([]DigestRecipient, error) {
+	return []DigestRecipient{{UserId: MockPostsUserId, Username: "test", Email: "test@test.com", Frequency: DigestDaily}}, nil
+}
+
+func (m *MockNotificationPreferencesStore) GetDigest(ctx context.Context, userId int64, since string, limit int) ([]NotificationGroup, error) {
+	title := "Hello"
+	postId := int64(1)
+	return []NotificationGroup{{
+		ID:         3,
+		Type:       "comment",
+		PostId:     &postId,
+		PostTitle:  &title,
+		Actors:     []Author{{ID: 2, Username: "ana"}},
+		ActorCount: 5,
+		Count:      6,
+		Unread:     true,
+	}}, nil
+}
+
+func (m *MockNotificationPreferencesStore) MarkDigestSent(ctx context.Context, userId int64) error {
+	return nil
+}
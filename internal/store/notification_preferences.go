@@ -0,0 +1,212 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+)
+
+// How a user gets notifications of a type, `DeliveryInApp` unless they chose otherwise
+const (
+	DeliveryInApp  = "in_app" // in the inbox and live
+	DeliveryEmail  = "email"  // in the inbox, live and by email right away
+	DeliveryDigest = "digest" // in the inbox, live and in the periodic digest email
+	DeliveryOff    = "off"    // not recorded at all
+)
+
+const (
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+)
+
+// `NotificationPreferences` holds how a user wants to be notified
+type NotificationPreferences struct {
+	Delivery        map[string]string `json:"delivery"`         // delivery of each notification type
+	DigestFrequency string            `json:"digest_frequency"` // how often the digest is sent, "daily" or "weekly"
+}
+
+// `DigestRecipient` is a user whose digest is due, `Since` is when their last digest went out
+type DigestRecipient struct {
+	UserId    int64
+	Username  string
+	Email     string
+	Frequency string
+	Since     string
+}
+
+type NotificationPreferencesRepositoryPostgres struct {
+	db *sql.DB
+}
+
+// `Get` returns the preferences `userId` set, types they didn't set are missing from `Delivery`
+func (s *NotificationPreferencesRepositoryPostgres) Get(ctx context.Context, userId int64) (*NotificationPreferences, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `SELECT type, delivery FROM notification_preferences WHERE user_id = $1`, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	preferences := &NotificationPreferences{Delivery: map[string]string{}, DigestFrequency: DigestDaily}
+	for rows.Next() {
+		var notificationType, delivery string
+		if err := rows.Scan(&notificationType, &delivery); err != nil {
+			return nil, err
+		}
+		preferences.Delivery[notificationType] = delivery
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	err = s.db.QueryRowContext(ctx, `SELECT frequency FROM notification_digests WHERE user_id = $1`, userId).
+		Scan(&preferences.DigestFrequency)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	return preferences, nil
+}
+
+// `Set` stores the delivery of the types in `preferences` and the digest frequency, when set.
+// Types missing from `preferences` keep their delivery.
+func (s *NotificationPreferencesRepositoryPostgres) Set(ctx context.Context, userId int64, preferences NotificationPreferences) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
+	defer cancel()
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		for notificationType, delivery := range preferences.Delivery {
+			_, err := tx.ExecContext(ctx, `
+				INSERT INTO notification_preferences (user_id, type, delivery) VALUES ($1, $2, $3)
+				ON CONFLICT (user_id, type) DO UPDATE SET delivery = EXCLUDED.delivery
+			`, userId, notificationType, delivery)
+			if err != nil {
+				return err
+			}
+		}
+
+		if preferences.DigestFrequency == "" {
+			return nil
+		}
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO notification_digests (user_id, frequency) VALUES ($1, $2)
+			ON CONFLICT (user_id) DO UPDATE SET frequency = EXCLUDED.frequency
+		`, userId, preferences.DigestFrequency)
+		return err
+	})
+}
+
+// `Unsubscribe` moves the types `userId` gets by `delivery` back to the inbox only, or just
+// `notificationType` when it isn't empty
+func (s *NotificationPreferencesRepositoryPostgres) Unsubscribe(ctx context.Context, userId int64, delivery string, notificationType string) error {
+	query := `
+		UPDATE notification_preferences SET delivery = $4
+		WHERE user_id = $1 AND delivery = $2 AND ($3 = '' OR type = $3)
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, userId, delivery, notificationType, DeliveryInApp)
+	return err
+}
+
+// `GetDueDigests` returns up to `limit` active users with digest notifications whose last digest
+// is older than their frequency. `Since` of users who never got one is one period ago.
+func (s *NotificationPreferencesRepositoryPostgres) GetDueDigests(ctx context.Context, limit int) ([]DigestRecipient, error) {
+	query := `
+		SELECT u.id, u.username, u.email, COALESCE(d.frequency, $4), COALESCE(d.last_sent_at, NOW() - x.period)
+		FROM users u
+		LEFT JOIN notification_digests d ON d.user_id = u.id
+		CROSS JOIN LATERAL (
+			SELECT CASE WHEN d.frequency = $2 THEN INTERVAL '7 days' ELSE INTERVAL '1 day' END AS period
+		) x
+		WHERE u.is_active = true AND u.deletion_scheduled_at IS NULL
+			AND EXISTS (SELECT 1 FROM notification_preferences WHERE user_id = u.id AND delivery = $3)
+			AND (d.last_sent_at IS NULL OR d.last_sent_at <= NOW() - x.period)
+		ORDER BY u.id
+		LIMIT $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, limit, DigestWeekly, DeliveryDigest, DigestDaily)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	recipients := []DigestRecipient{}
+	for rows.Next() {
+		var recipient DigestRecipient
+		if err := rows.Scan(&recipient.UserId, &recipient.Username, &recipient.Email, &recipient.Frequency, &recipient.Since); err != nil {
+			return nil, err
+		}
+		recipients = append(recipients, recipient)
+	}
+
+	return recipients, rows.Err()
+}
+
+// `GetDigest` groups the unread notifications `userId` gets in digests that came after `since`,
+// latest first and at most `limit` groups
+func (s *NotificationPreferencesRepositoryPostgres) GetDigest(ctx context.Context, userId int64, since string, limit int) ([]NotificationGroup, error) {
+	query := `
+		WITH digested AS (
+			SELECT n.*
+			FROM notifications n
+			JOIN notification_preferences np ON np.user_id = n.user_id AND np.type = n.type AND np.delivery = $5
+			WHERE n.user_id = $1 AND n.read_at IS NULL AND n.created_at > $2
+		), groups AS (
+			SELECT group_key, MAX(id) AS latest_id, COUNT(*) AS count,
+				COUNT(DISTINCT actor_id) AS actor_count, MAX(created_at) AS latest_at
+			FROM digested
+			GROUP BY group_key
+		)
+		SELECT n.id, n.type, n.post_id, p.title, n.comment_id, g.count, g.actor_count, true, g.latest_at,
+			a.ids, a.usernames
+		FROM groups g
+		JOIN notifications n ON n.id = g.latest_id
+		LEFT JOIN posts p ON p.id = n.post_id AND p.deleted_at IS NULL
+		CROSS JOIN LATERAL (
+			SELECT array_agg(x.id ORDER BY x.last DESC) AS ids, array_agg(x.username ORDER BY x.last DESC) AS usernames
+			FROM (
+				SELECT u.id, u.username, MAX(dn.created_at) AS last
+				FROM digested dn JOIN users u ON u.id = dn.actor_id
+				WHERE dn.group_key = g.group_key
+				GROUP BY u.id, u.username
+				ORDER BY last DESC
+				LIMIT $4
+			) x
+		) a
+		ORDER BY g.latest_at DESC, n.id DESC
+		LIMIT $3
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userId, since, limit, notificationGroupActors, DeliveryDigest)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanNotificationGroups(rows)
+}
+
+// `MarkDigestSent` records that the digest of `userId` just went out
+func (s *NotificationPreferencesRepositoryPostgres) MarkDigestSent(ctx context.Context, userId int64) error {
+	query := `
+		INSERT INTO notification_digests (user_id, last_sent_at) VALUES ($1, NOW())
+		ON CONFLICT (user_id) DO UPDATE SET last_sent_at = EXCLUDED.last_sent_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, userId)
+	return err
+}
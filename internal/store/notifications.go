@@ -0,0 +1,266 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// `Notification` tells `UserId` that `ActorId` did something, see `internal/notifications`
+type Notification struct {
+	ID        int64   `json:"id"`
+	UserId    int64   `json:"-"`
+	Type      string  `json:"type"`
+	ActorId   int64   `json:"-"`
+	Actor     Author  `json:"actor"`
+	PostId    *int64  `json:"post_id,omitempty"`
+	CommentId *int64  `json:"comment_id,omitempty"`
+	GroupKey  string  `json:"-"`
+	ReadAt    *string `json:"read_at"`
+	CreatedAt string  `json:"created_at"`
+	Delivery  string  `json:"-"` // how the recipient wants notifications of this type, see `NotificationPreferences`
+}
+
+// `NotificationGroup` is an entry of the notifications inbox: the notifications with the same
+// group key and read state, described by the latest one
+type NotificationGroup struct {
+	ID         int64    `json:"id"` // the latest notification, marking it read marks the group read
+	Type       string   `json:"type"`
+	PostId     *int64   `json:"post_id,omitempty"`
+	PostTitle  *string  `json:"post_title,omitempty"`
+	CommentId  *int64   `json:"comment_id,omitempty"`
+	Actors     []Author `json:"actors"` // the latest distinct actors, at most `notificationGroupActors`
+	ActorCount int      `json:"actor_count"`
+	Count      int      `json:"count"`
+	Unread     bool     `json:"unread"`
+	CreatedAt  string   `json:"created_at"`
+}
+
+// `NotificationsPage` is a page of the inbox, `UnreadCount` counts every unread group
+type NotificationsPage struct {
+	Notifications []NotificationGroup `json:"notifications"`
+	UnreadCount   int                 `json:"unread_count"`
+}
+
+// actors listed per group, the rest are only counted
+const notificationGroupActors = 3
+
+type NotificationsRepositoryPostgres struct {
+	db *sql.DB
+}
+
+// `Create` records `notification` for each of `userIds`, except the actor themselves, users who
+// muted the actor or are blocked either way and users who turned this type off. Returns the
+// recorded notifications with their actor and delivery.
+func (s *NotificationsRepositoryPostgres) Create(ctx context.Context, notification Notification, userIds []int64) ([]Notification, error) {
+	query := `
+		WITH inserted AS (
+			INSERT INTO notifications (user_id, type, actor_id, post_id, comment_id, group_key)
+			SELECT r.id, $2, $3, $4, $5, $6
+			FROM unnest($1::bigint[]) AS r(id)
+			WHERE r.id <> $3
+				AND NOT EXISTS (SELECT 1 FROM user_mutes WHERE muter_id = r.id AND muted_id = $3)
+				AND NOT EXISTS (
+					SELECT 1 FROM notification_preferences WHERE user_id = r.id AND type = $2 AND delivery = $7
+				)
+				AND NOT EXISTS (
+					SELECT 1 FROM user_blocks
+					WHERE (blocker_id = r.id AND blocked_id = $3) OR (blocker_id = $3 AND blocked_id = r.id)
+				)
+			RETURNING id, user_id, actor_id, created_at
+		)
+		SELECT i.id, i.user_id, i.created_at, u.id, u.username, COALESCE(np.delivery, $8)
+		FROM inserted i
+		JOIN users u ON u.id = i.actor_id
+		LEFT JOIN notification_preferences np ON np.user_id = i.user_id AND np.type = $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(
+		ctx,
+		query,
+		pq.Array(userIds),
+		notification.Type,
+		notification.ActorId,
+		notification.PostId,
+		notification.CommentId,
+		notification.GroupKey,
+		DeliveryOff,
+		DeliveryInApp,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	created := []Notification{}
+	for rows.Next() {
+		n := notification
+		if err := rows.Scan(&n.ID, &n.UserId, &n.CreatedAt, &n.Actor.ID, &n.Actor.Username, &n.Delivery); err != nil {
+			return nil, err
+		}
+		created = append(created, n)
+	}
+
+	return created, rows.Err()
+}
+
+// `visibleActorSQL` is true when notifications by `actor` are shown to `userId`. Blocks and mutes
+// stop new notifications in `Create`, this hides the ones from before.
+func visibleActorSQL(actor, userId string) string {
+	return `NOT ` + blockedSQL(actor, userId) + ` AND NOT ` + mutedSQL(userId, actor)
+}
+
+// `GetForUser` lists the notification groups of `userId`, latest first
+func (s *NotificationsRepositoryPostgres) GetForUser(ctx context.Context, userId int64, limit int, offset int) (*NotificationsPage, error) {
+	query := `
+		WITH groups AS (
+			SELECT group_key, read_at IS NULL AS unread, MAX(id) AS latest_id, COUNT(*) AS count,
+				COUNT(DISTINCT actor_id) AS actor_count, MAX(created_at) AS latest_at
+			FROM notifications n
+			WHERE user_id = $1 AND ` + visibleActorSQL("n.actor_id", "$1") + `
+			GROUP BY group_key, read_at IS NULL
+		)
+		SELECT n.id, n.type, n.post_id, p.title, n.comment_id, g.count, g.actor_count, g.unread, g.latest_at,
+			a.ids, a.usernames
+		FROM groups g
+		JOIN notifications n ON n.id = g.latest_id
+		LEFT JOIN posts p ON p.id = n.post_id AND p.deleted_at IS NULL
+		CROSS JOIN LATERAL (
+			SELECT array_agg(x.id ORDER BY x.last DESC) AS ids, array_agg(x.username ORDER BY x.last DESC) AS usernames
+			FROM (
+				SELECT u.id, u.username, MAX(an.created_at) AS last
+				FROM notifications an JOIN users u ON u.id = an.actor_id
+				WHERE an.user_id = $1 AND an.group_key = g.group_key AND (an.read_at IS NULL) = g.unread
+					AND ` + visibleActorSQL("an.actor_id", "$1") + `
+				GROUP BY u.id, u.username
+				ORDER BY last DESC
+				LIMIT $4
+			) x
+		) a
+		ORDER BY g.latest_at DESC, n.id DESC
+		LIMIT $2 OFFSET $3
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userId, limit, offset, notificationGroupActors)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	page := &NotificationsPage{}
+	page.Notifications, err = scanNotificationGroups(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.QueryRowContext(ctx, `
+		SELECT COUNT(DISTINCT group_key) FROM notifications n
+		WHERE user_id = $1 AND read_at IS NULL AND `+visibleActorSQL("n.actor_id", "$1")+`
+	`, userId).Scan(&page.UnreadCount)
+	if err != nil {
+		return nil, err
+	}
+
+	return page, nil
+}
+
+// `scanNotificationGroups` reads rows of notification groups, with their actor ids and usernames as arrays
+func scanNotificationGroups(rows *sql.Rows) ([]NotificationGroup, error) {
+	groups := []NotificationGroup{}
+	for rows.Next() {
+		var group NotificationGroup
+		var actorIds []int64
+		var actorNames []string
+		err := rows.Scan(
+			&group.ID,
+			&group.Type,
+			&group.PostId,
+			&group.PostTitle,
+			&group.CommentId,
+			&group.Count,
+			&group.ActorCount,
+			&group.Unread,
+			&group.CreatedAt,
+			pq.Array(&actorIds),
+			pq.Array(&actorNames),
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		group.Actors = make([]Author, len(actorIds))
+		for i := range actorIds {
+			group.Actors[i] = Author{ID: actorIds[i], Username: actorNames[i]}
+		}
+		groups = append(groups, group)
+	}
+
+	return groups, rows.Err()
+}
+
+// `MarkRead` marks notification `id` of `userId` read, with the unread notifications grouped with it.
+// Returns `ErrNotFound` if `userId` has no such notification.
+func (s *NotificationsRepositoryPostgres) MarkRead(ctx context.Context, userId int64, id int64) error {
+	query := `
+		WITH target AS (
+			SELECT group_key FROM notifications WHERE id = $2 AND user_id = $1
+		), updated AS (
+			UPDATE notifications n SET read_at = NOW()
+			FROM target
+			WHERE n.user_id = $1 AND n.group_key = target.group_key AND n.read_at IS NULL
+			RETURNING n.id
+		)
+		SELECT EXISTS (SELECT 1 FROM target)
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
+	defer cancel()
+
+	var found bool
+	if err := s.db.QueryRowContext(ctx, query, userId, id).Scan(&found); err != nil {
+		return err
+	}
+	if !found {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// `MarkAllRead` marks every notification of `userId` read, returns how many were unread
+func (s *NotificationsRepositoryPostgres) MarkAllRead(ctx context.Context, userId int64) (int64, error) {
+	query := `UPDATE notifications SET read_at = NOW() WHERE user_id = $1 AND read_at IS NULL`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userId)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+// `Purge` deletes notifications older than `retention`, read or not. Returns how many were deleted.
+func (s *NotificationsRepositoryPostgres) Purge(ctx context.Context, retention time.Duration) (int64, error) {
+	query := `DELETE FROM notifications WHERE created_at <= $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, time.Now().Add(-retention))
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
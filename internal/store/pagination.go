@@ -1,6 +1,8 @@
 package store
 
 import (
+	"encoding/base64"
+	"errors"
 	"net/http"
 	"strconv"
 	"strings"
@@ -8,13 +10,18 @@ import (
 )
 
 type PaginatedFeedQuery struct {
-	Limit  int      `json:"limit" validate:"gte=0"`
-	Offset int      `json:"offset" validate:"gte=0"`
-	Sort   string   `json:"sort" validate:"oneof=asc desc"`
-	Tags   []string `json:"tags" validate:"max=5"`
-	Search string   `json:"search" validate:"max=100"`
-	Since  string   `json:"since"`
-	Until  string   `json:"until"`
+	Limit  int         `json:"limit" validate:"gte=0"`
+	Offset int         `json:"offset" validate:"gte=0"`
+	Sort   string      `json:"sort" validate:"oneof=asc desc"`
+	Tags   []string    `json:"tags" validate:"max=5"`
+	Search string      `json:"search" validate:"max=100"`
+	Since  *time.Time  `json:"since"`
+	Until  *time.Time  `json:"until"`
+	After  *FeedCursor `json:"-"` // keyset position of the feed, replaces `Offset`
+	Mode   string      `json:"mode" validate:"omitempty,oneof=latest ranked"`
+	Debug  bool        `json:"debug"` // include the score breakdown of ranked posts
+
+	AfterRanked *RankedFeedCursor `json:"-"` // position in the ranked feed, `After` is unused then
 }
 
 const (
@@ -25,8 +32,13 @@ const (
 	SEARCH string = "search"
 	SINCE  string = "since"
 	UNTIL  string = "until"
+	CURSOR string = "cursor"
+	MODE   string = "mode"
+	DEBUG  string = "debug"
 )
 
+const FeedModeRanked = "ranked"
+
 func (pfq PaginatedFeedQuery) Parse(r *http.Request) (PaginatedFeedQuery, error) {
 	query := r.URL.Query()
 	limitParam := query.Get(LIMIT)
@@ -36,6 +48,9 @@ func (pfq PaginatedFeedQuery) Parse(r *http.Request) (PaginatedFeedQuery, error)
 	search := query.Get(SEARCH)
 	since := query.Get(SINCE)
 	until := query.Get(UNTIL)
+	cursor := query.Get(CURSOR)
+	mode := query.Get(MODE)
+	debug := query.Get(DEBUG)
 
 	if limitParam != "" {
 		limit, err := strconv.Atoi(limitParam)
@@ -67,21 +82,123 @@ func (pfq PaginatedFeedQuery) Parse(r *http.Request) (PaginatedFeedQuery, error)
 	}
 
 	if since != "" {
-		pfq.Since = parseTime(since)
+		t, err := parseTime(since)
+		if err != nil {
+			return pfq, err
+		}
+		pfq.Since = &t
 	}
 
 	if until != "" {
-		pfq.Until = parseTime(until)
+		t, err := parseTime(until)
+		if err != nil {
+			return pfq, err
+		}
+		pfq.Until = &t
+	}
+
+	if mode != "" {
+		pfq.Mode = mode
+	}
+
+	if debug != "" {
+		d, err := strconv.ParseBool(debug)
+		if err != nil {
+			return pfq, err
+		}
+		pfq.Debug = d
+	}
+
+	// ranked pages have their own cursors
+	if cursor != "" && pfq.Mode == FeedModeRanked {
+		after, err := DecodeRankedFeedCursor(cursor)
+		if err != nil {
+			return pfq, err
+		}
+		pfq.AfterRanked = after
+	} else if cursor != "" {
+		after, err := DecodeFeedCursor(cursor)
+		if err != nil {
+			return pfq, err
+		}
+		pfq.After = after
 	}
 
 	return pfq, nil
 }
 
-func parseTime(s string) string {
-	t, err := time.Parse(time.DateTime, s)
+// `parseTime` accepts RFC 3339 timestamps and, in UTC, `2006-01-02 15:04:05` or `2006-01-02`
+func parseTime(s string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339Nano, time.DateTime, time.DateOnly} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, errors.New("since and until must be RFC 3339 timestamps or dates like 2006-01-02")
+}
+
+// `FeedCursor` is the keyset position after the last returned feed post
+type FeedCursor struct {
+	CreatedAt time.Time
+	PostId    int64
+}
+
+func (c FeedCursor) Encode() string {
+	return encodeCursor(c.CreatedAt, c.PostId)
+}
+
+// `DecodeFeedCursor` parses a token produced by `FeedCursor.Encode`
+func DecodeFeedCursor(token string) (*FeedCursor, error) {
+	at, id, err := decodeCursor(token)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FeedCursor{CreatedAt: at, PostId: id}, nil
+}
+
+// `NextFeedCursor` returns the cursor after the last post, or an empty string when the page isn't full
+func NextFeedCursor(posts []*FeedPost, limit int) string {
+	if len(posts) < limit || len(posts) == 0 {
+		return ""
+	}
+
+	last := posts[len(posts)-1]
+	createdAt, err := time.Parse(time.RFC3339Nano, last.CreatedAt)
 	if err != nil {
 		return ""
 	}
 
-	return t.Format(time.DateTime)
+	return FeedCursor{CreatedAt: createdAt, PostId: last.ID}.Encode()
+}
+
+// `encodeCursor` turns a (time, id) keyset position into an opaque token
+func encodeCursor(at time.Time, id int64) string {
+	raw := at.UTC().Format(time.RFC3339Nano) + "|" + strconv.FormatInt(id, 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(token string) (time.Time, int64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return time.Time{}, 0, ErrInvalidCursor
+	}
+
+	timePart, idPart, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return time.Time{}, 0, ErrInvalidCursor
+	}
+
+	at, err := time.Parse(time.RFC3339Nano, timePart)
+	if err != nil {
+		return time.Time{}, 0, ErrInvalidCursor
+	}
+
+	id, err := strconv.ParseInt(idPart, 10, 64)
+	if err != nil {
+		return time.Time{}, 0, ErrInvalidCursor
+	}
+
+	return at, id, nil
 }
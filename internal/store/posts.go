@@ -5,6 +5,7 @@ import (
 	"database/sql" // Standard library package for interacting with SQL databases
 	"errors"       // Standard library package for defining and handling errors
 	"log"          // Standard library package for logging errors and informational messages
+	"time"
 
 	// PostgreSQL driver for Go, which includes utilities for handling PostgreSQL-specific data types.
 	// `pq.Array` is used to handle array data types in PostgreSQL.
@@ -19,28 +20,36 @@ import (
 //
 // Fields:
 // - `ID` (int64): Unique identifier of the post.
-// - `Content` (string): The body/content of the post.
+// - `Content` (string): The body/content of the post, as Markdown.
+// - `ContentHTML` (string): `Content` rendered to sanitized HTML, kept in sync on every write.
 // - `Title` (string): Title of the post.
 // - `UserId` (int64): ID of the user who created the post.
 // - `Tags` ([]string): A list of tags associated with the post (stored as an array in PostgreSQL).
 // - `CreatedAt` (string): Timestamp when the post was created.
 // - `UpdatedAt` (string): Timestamp when the post was last updated.
+// - `DeletedAt` (*string): Timestamp when the post was moved to the trash, nil while the post is live.
 type Post struct {
-	ID        int64     `json:"id"`
-	Content   string    `json:"content"`
-	Title     string    `json:"title"`
-	UserId    int64     `json:"user_id"`
-	Tags      []string  `json:"tags"`
-	CreatedAt string    `json:"created_at"`
-	UpdatedAt string    `json:"updated_at"`
-	Version   int64     `json:"version"`
-	Comments  []Comment `json:"comments"`
-	User      User      `json:"user"`
+	ID          int64     `json:"id"`
+	Content     string    `json:"content"`
+	ContentHTML string    `json:"content_html"`
+	Title       string    `json:"title"`
+	UserId      int64     `json:"user_id"`
+	Tags        []string  `json:"tags"`
+	CreatedAt   string    `json:"created_at"`
+	UpdatedAt   string    `json:"updated_at"`
+	DeletedAt   *string   `json:"deleted_at,omitempty"`
+	Version     int64     `json:"version"`
+	Comments    []Comment `json:"comments"`
+	Snippets    []Snippet `json:"snippets,omitempty"`
+	Media       []Media   `json:"media,omitempty"`
+	Mentions    []Mention `json:"mentions"`
+	User        Author    `json:"user"`
 }
 
 type FeedPost struct {
 	Post
-	CommentCount int `json:"comments_count"`
+	CommentCount int        `json:"comments_count"`
+	Score        *FeedScore `json:"score,omitempty"` // set by the ranked feed
 }
 
 // `PostsRepositoryPostgres` is a concrete implementation of the `PostsRepository` interface.
@@ -50,6 +59,8 @@ type PostsRepositoryPostgres struct {
 }
 
 // `Create` inserts a new post into the `posts` table and retrieves its assigned ID and timestamps.
+// Tags are normalized to their canonical names and the post's snippets, if any,
+// are inserted in the same transaction.
 //
 // Parameters:
 // - `ctx` (context.Context): Provides timeout and cancellation handling for the query.
@@ -61,32 +72,41 @@ func (s *PostsRepositoryPostgres) Create(ctx context.Context, post *Post) error
 	// SQL query to insert a new post into the database.
 	// The `RETURNING` clause retrieves the newly created post's ID, creation timestamp, and update timestamp.
 	query := `
-		INSERT INTO posts (content, title, user_id, tags)
-		VALUES ($1, $2, $3, $4) RETURNING id, created_at, updated_at
+		INSERT INTO posts (content, content_html, title, user_id, tags)
+		VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at, updated_at
 	`
 
-	ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
-	defer cancel()
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
+		defer cancel()
 
-	// Execute the query and scan the returned values into the `post` struct.
-	err := s.db.QueryRowContext(
-		ctx,
-		query,
-		post.Content,
-		post.Title,
-		post.UserId,
-		pq.Array(post.Tags), // Converts Go slice to a PostgreSQL array
-	).Scan(
-		&post.ID,
-		&post.CreatedAt,
-		&post.UpdatedAt,
-	)
-	if err != nil {
-		log.Println(err.Error()) // Log the error for debugging
-		return err
-	}
+		tags, err := canonicalTags(ctx, tx, post.Tags, true)
+		if err != nil {
+			return err
+		}
+		post.Tags = tags
+
+		// Execute the query and scan the returned values into the `post` struct.
+		err = tx.QueryRowContext(
+			ctx,
+			query,
+			post.Content,
+			post.ContentHTML,
+			post.Title,
+			post.UserId,
+			pq.Array(post.Tags), // Converts Go slice to a PostgreSQL array
+		).Scan(
+			&post.ID,
+			&post.CreatedAt,
+			&post.UpdatedAt,
+		)
+		if err != nil {
+			log.Println(err.Error()) // Log the error for debugging
+			return err
+		}
 
-	return nil
+		return createSnippets(ctx, tx, post)
+	})
 }
 
 // `GetById` retrieves a post from the `posts` table by its unique ID.
@@ -104,7 +124,7 @@ func (s *PostsRepositoryPostgres) GetById(ctx context.Context, id int64) (*Post,
 
 	// SQL query to fetch a post by its ID.
 	query := `
-		SELECT id, user_id, title, content, created_at, updated_at, tags, version FROM posts WHERE id = $1
+		SELECT id, user_id, title, content, content_html, created_at, updated_at, tags, version FROM posts WHERE id = $1 AND deleted_at IS NULL
 	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
@@ -115,6 +135,7 @@ func (s *PostsRepositoryPostgres) GetById(ctx context.Context, id int64) (*Post,
 		&post.UserId,
 		&post.Title,
 		&post.Content,
+		&post.ContentHTML,
 		&post.CreatedAt,
 		&post.UpdatedAt,
 		pq.Array(&post.Tags), // Converts PostgreSQL array to Go slice
@@ -149,7 +170,7 @@ func (s *PostsRepositoryPostgres) GetAll(ctx context.Context, limit int64, offse
 	var posts []*Post
 
 	query := `
-		SELECT id, user_id, title, content, created_at, updated_at, tags, version FROM posts ORDER BY id LIMIT $1 OFFSET $2;
+		SELECT id, user_id, title, content, content_html, created_at, updated_at, tags, version FROM posts WHERE deleted_at IS NULL ORDER BY id LIMIT $1 OFFSET $2;
 	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
@@ -168,6 +189,7 @@ func (s *PostsRepositoryPostgres) GetAll(ctx context.Context, limit int64, offse
 			&post.UserId,
 			&post.Title,
 			&post.Content,
+			&post.ContentHTML,
 			&post.CreatedAt,
 			&post.UpdatedAt,
 			pq.Array(&post.Tags), // Converts PostgreSQL array to Go slice
@@ -181,93 +203,357 @@ func (s *PostsRepositoryPostgres) GetAll(ctx context.Context, limit int64, offse
 	return posts, nil
 }
 
-func (s *PostsRepositoryPostgres) DeleteById(ctx context.Context, id int64) error {
+// `DeleteById` soft deletes a post by moving it to the trash, it can be restored
+// until `PurgeDeleted` removes it for good. Like `UpdateById`, it only applies if
+// the post is still at `version`.
+//
+// Returns `ErrEditConflict` if the post was modified or deleted in the meantime.
+func (s *PostsRepositoryPostgres) DeleteById(ctx context.Context, id int64, version int64) error {
 	query := `
-		DELETE FROM posts WHERE id = $1
+		UPDATE posts SET deleted_at = NOW() WHERE id = $1 AND version = $2 AND deleted_at IS NULL
 	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
 	defer cancel()
-	// Execute the query and scan the result into the `post` struct.
-	res, err := s.db.ExecContext(ctx, query, id)
+	// Execute the delete, guarded by the version the caller loaded.
+	res, err := s.db.ExecContext(ctx, query, id, version)
 	if err != nil {
-		switch {
-		case errors.Is(err, sql.ErrNoRows):
-			// If no row is found, return a predefined `ErrNotFound` error.
-			return ErrNotFound
-		default:
-			// Return any other database-related errors.
-			return err
-		}
+		return err
 	}
 
 	rows, err := res.RowsAffected()
 	if err != nil {
-		return ErrNotFound
+		return err
 	}
 
 	if rows == 0 {
-		return ErrNotFound
+		// The post was loaded before the delete, so if nothing matched its version
+		// moved on or it went to the trash in the meantime.
+		return ErrEditConflict
 	}
 
 	return nil
 }
 
+// `UpdateById` writes the title, content and tags of a post, as long as its version
+// is still the one the caller loaded. Tags are normalized like in `Create`.
+//
+// Returns `ErrEditConflict` if the post was modified in the meantime.
 func (s *PostsRepositoryPostgres) UpdateById(ctx context.Context, post *Post) error {
-	// SQL query to fetch a post by its ID.
 	query := `
 		UPDATE posts
-		SET title = $1, content = $2, version = version + 1
-		WHERE id = $3 AND version = $4
-		RETURNING version
+		SET title = $1, content = $2, content_html = $3, tags = $4, version = version + 1, updated_at = NOW()
+		WHERE id = $5 AND version = $6 AND deleted_at IS NULL
+		RETURNING version, updated_at
+	`
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
+		defer cancel()
+
+		tags, err := canonicalTags(ctx, tx, post.Tags, true)
+		if err != nil {
+			return err
+		}
+		post.Tags = tags
+
+		err = tx.QueryRowContext(
+			ctx,
+			query,
+			post.Title,
+			post.Content,
+			post.ContentHTML,
+			pq.Array(post.Tags),
+			post.ID,
+			post.Version,
+		).Scan(&post.Version, &post.UpdatedAt)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				// The row exists (it was loaded before the update) but its version moved on,
+				// meaning someone else wrote to it in the meantime.
+				return ErrEditConflict
+			default:
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// `GetByTag` lists live posts carrying the canonical tag `tag` that `viewerId` can see, newest first
+func (s *PostsRepositoryPostgres) GetByTag(ctx context.Context, tag string, viewerId int64, limit int, offset int) ([]*FeedPost, error) {
+	query := `
+		SELECT p.id, p.title, p.content, p.content_html, p.user_id, p.created_at, p.tags, u.username,
+			(SELECT COUNT(*) FROM comments c WHERE c.post_id = p.id) AS comments_count
+		FROM posts p
+		JOIN users u ON u.id = p.user_id
+		WHERE p.deleted_at IS NULL AND p.tags @> ARRAY[$1::varchar] AND ` + visibleSQL("p.user_id", "$4::bigint") + `
+		ORDER BY p.created_at DESC, p.id DESC
+		LIMIT $2 OFFSET $3
 	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
 	defer cancel()
-	err := s.db.QueryRowContext(
-		ctx,
-		query,
-		post.Title,
-		post.Content,
-		post.ID,
-		post.Version,
-	).Scan(&post.Version)
+
+	rows, err := s.db.QueryContext(ctx, query, tag, limit, offset, viewerId)
 	if err != nil {
-		switch {
-		case errors.Is(err, sql.ErrNoRows):
-			return ErrNotFound
+		return nil, err
+	}
+	defer rows.Close()
+
+	feedPosts := []*FeedPost{}
+	for rows.Next() {
+		var feedPost FeedPost
+
+		err := rows.Scan(
+			&feedPost.ID,
+			&feedPost.Title,
+			&feedPost.Content,
+			&feedPost.ContentHTML,
+			&feedPost.UserId,
+			&feedPost.CreatedAt,
+			pq.Array(&feedPost.Tags),
+			&feedPost.User.Username,
+			&feedPost.CommentCount,
+		)
+		if err != nil {
+			return nil, err
 		}
+		feedPost.User.ID = feedPost.UserId
+		feedPosts = append(feedPosts, &feedPost)
 	}
 
-	return nil
+	return feedPosts, rows.Err()
 }
 
-func (s *PostsRepositoryPostgres) GetUserFeed(ctx context.Context, userId int64, pfq PaginatedFeedQuery) ([]*FeedPost, error) {
+// `GetPublic` lists live posts of public, active accounts newest first, for visitors without an
+// account. A non-zero `authorId` limits them to one author and a non-empty `tag` to a canonical tag.
+func (s *PostsRepositoryPostgres) GetPublic(
+	ctx context.Context,
+	authorId int64,
+	tag string,
+	limit int,
+	after *FeedCursor,
+) ([]*FeedPost, error) {
 	query := `
-		SELECT p.id, p.title, p."content", p.user_id, p.created_at, p.tags, u.username, COUNT(c.id) AS comments_count
-		FROM posts p 
-		LEFT JOIN "comments" c ON c.post_id=p.id
-		LEFT JOIN users u  ON  p.user_id = u.id 
-		JOIN followers f ON f.user_id = p.user_id or p.user_id = $1
-		WHERE 
-			f.follower_id = $1 AND 
-			(p.title ILIKE '%' || $4 || '%' OR  p.content ILIKE '%' || $4 || '%' ) AND
-			(p.tags @> $5 OR $5 IS NULL)
-		GROUP BY p.id, u.username
-		ORDER BY p.created_at ` + pfq.Sort + ` 
-		LIMIT $2 OFFSET $3;
+		SELECT p.id, p.title, p.content, p.content_html, p.user_id, p.created_at, p.updated_at, p.version,
+			p.tags, u.username, (SELECT COUNT(*) FROM comments c WHERE c.post_id = p.id) AS comments_count
+		FROM posts p
+		JOIN users u ON u.id = p.user_id
+		WHERE
+			p.deleted_at IS NULL AND
+			NOT u.is_private AND
+			u.is_active AND
+			u.deletion_scheduled_at IS NULL AND
+			($5 = 0 OR p.user_id = $5) AND
+			($1 = '' OR p.tags @> ARRAY[$1::varchar]) AND
+			($3::timestamptz IS NULL OR (p.created_at, p.id) < ($3, $4))
+		ORDER BY p.created_at DESC, p.id DESC
+		LIMIT $2
 	`
-	var feedPosts []*FeedPost
+
+	var (
+		afterCreatedAt *time.Time
+		afterId        int64
+	)
+	if after != nil {
+		afterCreatedAt, afterId = &after.CreatedAt, after.PostId
+	}
 
 	ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
 	defer cancel()
 
-	rows, err := s.db.QueryContext(ctx, query, userId, pfq.Limit, pfq.Offset, pfq.Search, pq.Array(pfq.Tags))
+	rows, err := s.db.QueryContext(ctx, query, tag, limit, afterCreatedAt, afterId, authorId)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
+
+	feedPosts := []*FeedPost{}
+	for rows.Next() {
+		var feedPost FeedPost
+
+		err := rows.Scan(
+			&feedPost.ID,
+			&feedPost.Title,
+			&feedPost.Content,
+			&feedPost.ContentHTML,
+			&feedPost.UserId,
+			&feedPost.CreatedAt,
+			&feedPost.UpdatedAt,
+			&feedPost.Version,
+			pq.Array(&feedPost.Tags),
+			&feedPost.User.Username,
+			&feedPost.CommentCount,
+		)
+		if err != nil {
+			return nil, err
+		}
+		feedPost.User.ID = feedPost.UserId
+		feedPosts = append(feedPosts, &feedPost)
+	}
+
+	return feedPosts, rows.Err()
+}
 
+// `GetBatch` lists posts with an id above `afterId` in id order, with their author and
+// comments loaded. It is used to walk the whole table, e.g. to rebuild the search index.
+func (s *PostsRepositoryPostgres) GetBatch(ctx context.Context, afterId int64, limit int) ([]*Post, error) {
+	query := `
+		SELECT p.id, p.user_id, p.title, p.content, p.content_html, p.created_at, p.updated_at, p.tags, p.version, u.username
+		FROM posts p
+		JOIN users u ON u.id = p.user_id
+		WHERE p.id > $1 AND p.deleted_at IS NULL
+		ORDER BY p.id
+		LIMIT $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, afterId, limit)
+	if err != nil {
+		return nil, err
+	}
 	defer rows.Close()
+
+	posts := []*Post{}
+	byId := make(map[int64]*Post)
+	for rows.Next() {
+		var post Post
+
+		err := rows.Scan(
+			&post.ID,
+			&post.UserId,
+			&post.Title,
+			&post.Content,
+			&post.ContentHTML,
+			&post.CreatedAt,
+			&post.UpdatedAt,
+			pq.Array(&post.Tags),
+			&post.Version,
+			&post.User.Username,
+		)
+		if err != nil {
+			return nil, err
+		}
+		post.User.ID = post.UserId
+		post.Comments = []Comment{}
+
+		posts = append(posts, &post)
+		byId[post.ID] = &post
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(posts) == 0 {
+		return posts, nil
+	}
+
+	ids := make([]int64, 0, len(posts))
+	for _, post := range posts {
+		ids = append(ids, post.ID)
+	}
+
+	commentsQuery := `
+		SELECT c.id, c.post_id, c.user_id, c.content, c.created_at, u.username
+		FROM comments c
+		JOIN users u ON u.id = c.user_id
+		WHERE c.post_id = ANY($1)
+		ORDER BY c.id
+	`
+
+	commentRows, err := s.db.QueryContext(ctx, commentsQuery, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer commentRows.Close()
+
+	for commentRows.Next() {
+		var comment Comment
+
+		err := commentRows.Scan(&comment.ID, &comment.PostId, &comment.UserId, &comment.Content, &comment.CreatedAt, &comment.User.Username)
+		if err != nil {
+			return nil, err
+		}
+		comment.User.ID = comment.UserId
+
+		post := byId[comment.PostId]
+		post.Comments = append(post.Comments, comment)
+	}
+
+	return posts, commentRows.Err()
+}
+
+// `GetUserFeed` builds the feed of `userId` from their own posts, the posts of users they follow
+// and posts with tags they follow, hiding posts they can't see and authors they muted.
+//
+// Pages are keyset paginated on (created_at, id) in `pfq.Sort` order, starting after `pfq.After`.
+// `pfq.Since` and `pfq.Until` bound created_at (inclusive and exclusive), `pfq.Offset` is ignored.
+func (s *PostsRepositoryPostgres) GetUserFeed(ctx context.Context, userId int64, pfq PaginatedFeedQuery) ([]*FeedPost, error) {
+	// never interpolate the sort from the request, only these constants
+	order, after := "DESC", "<"
+	if pfq.Sort == "asc" {
+		order, after = "ASC", ">"
+	}
+
+	query := `
+		SELECT p.id, p.title, p."content", p.content_html, p.user_id, p.created_at, p.tags, u.username,
+			(SELECT COUNT(*) FROM "comments" c WHERE c.post_id = p.id) AS comments_count
+		FROM posts p
+		JOIN users u ON u.id = p.user_id
+		WHERE
+			p.deleted_at IS NULL AND
+			(
+				p.user_id = $1 OR
+				EXISTS (SELECT 1 FROM followers f WHERE f.user_id = p.user_id AND f.follower_id = $1) OR
+				p.tags && ARRAY(
+					SELECT t.name::varchar FROM tag_follows tf JOIN tags t ON t.id = tf.tag_id WHERE tf.user_id = $1
+				)
+			) AND
+			` + visibleSQL("p.user_id", "$1") + ` AND
+			NOT ` + mutedSQL("$1", "p.user_id") + ` AND
+			($3 = '' OR p.title ILIKE '%' || $3 || '%' OR p.content ILIKE '%' || $3 || '%') AND
+			($4::varchar[] IS NULL OR p.tags @> $4) AND
+			($5::timestamptz IS NULL OR p.created_at >= $5) AND
+			($6::timestamptz IS NULL OR p.created_at < $6) AND
+			($7::timestamptz IS NULL OR (p.created_at, p.id) ` + after + ` ($7, $8))
+		ORDER BY p.created_at ` + order + `, p.id ` + order + `
+		LIMIT $2
+	`
+
+	var (
+		afterCreatedAt *time.Time
+		afterId        int64
+	)
+	if pfq.After != nil {
+		afterCreatedAt, afterId = &pfq.After.CreatedAt, pfq.After.PostId
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(
+		ctx,
+		query,
+		userId,
+		pfq.Limit,
+		pfq.Search,
+		pq.Array(pfq.Tags),
+		pfq.Since,
+		pfq.Until,
+		afterCreatedAt,
+		afterId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	feedPosts := []*FeedPost{}
 	for rows.Next() {
 		var feedPost FeedPost
 
@@ -275,6 +561,7 @@ func (s *PostsRepositoryPostgres) GetUserFeed(ctx context.Context, userId int64,
 			&feedPost.ID,
 			&feedPost.Title,
 			&feedPost.Content,
+			&feedPost.ContentHTML,
 			&feedPost.UserId,
 			&feedPost.CreatedAt,
 			pq.Array(&feedPost.Tags), // Converts PostgreSQL array to Go slice
@@ -284,7 +571,145 @@ func (s *PostsRepositoryPostgres) GetUserFeed(ctx context.Context, userId int64,
 		if err != nil {
 			return nil, err
 		}
+		feedPost.User.ID = feedPost.UserId
 		feedPosts = append(feedPosts, &feedPost)
 	}
-	return feedPosts, nil
+
+	return feedPosts, rows.Err()
+}
+
+// `GetDeletedById` retrieves a post sitting in the trash by its ID.
+//
+// Returns `ErrNotFound` if the post does not exist or is not deleted.
+func (s *PostsRepositoryPostgres) GetDeletedById(ctx context.Context, id int64) (*Post, error) {
+	query := `
+		SELECT id, user_id, title, content, content_html, created_at, updated_at, deleted_at, tags, version
+		FROM posts WHERE id = $1 AND deleted_at IS NOT NULL
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
+	defer cancel()
+
+	var post Post
+	err := s.db.QueryRowContext(ctx, query, id).Scan(
+		&post.ID,
+		&post.UserId,
+		&post.Title,
+		&post.Content,
+		&post.ContentHTML,
+		&post.CreatedAt,
+		&post.UpdatedAt,
+		&post.DeletedAt,
+		pq.Array(&post.Tags),
+		&post.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &post, nil
+}
+
+// `GetDeleted` lists posts in the trash, most recently deleted first.
+//
+// Parameters:
+// - `userId` (int64): only return posts of this user, 0 returns the trash of every user (moderation view).
+// - `limit`, `offset` (int64): for pagination
+func (s *PostsRepositoryPostgres) GetDeleted(ctx context.Context, userId int64, limit int64, offset int64) ([]*Post, error) {
+	query := `
+		SELECT p.id, p.user_id, p.title, p.content, p.content_html, p.created_at, p.updated_at, p.deleted_at, p.tags, p.version, u.username
+		FROM posts p
+		JOIN users u ON u.id = p.user_id
+		WHERE p.deleted_at IS NOT NULL AND ($1 = 0 OR p.user_id = $1)
+		ORDER BY p.deleted_at DESC, p.id DESC
+		LIMIT $2 OFFSET $3
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userId, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := []*Post{}
+	for rows.Next() {
+		var post Post
+
+		err := rows.Scan(
+			&post.ID,
+			&post.UserId,
+			&post.Title,
+			&post.Content,
+			&post.ContentHTML,
+			&post.CreatedAt,
+			&post.UpdatedAt,
+			&post.DeletedAt,
+			pq.Array(&post.Tags),
+			&post.Version,
+			&post.User.Username,
+		)
+		if err != nil {
+			return nil, err
+		}
+		post.User.ID = post.UserId
+		posts = append(posts, &post)
+	}
+
+	return posts, rows.Err()
+}
+
+// `Restore` moves a post out of the trash, as long as it was deleted less than `retention` ago.
+//
+// Returns `ErrNotFound` if the post is not in the trash or the retention window has passed.
+func (s *PostsRepositoryPostgres) Restore(ctx context.Context, post *Post, retention time.Duration) error {
+	query := `
+		UPDATE posts
+		SET deleted_at = NULL, version = version + 1
+		WHERE id = $1 AND deleted_at IS NOT NULL AND deleted_at > $2
+		RETURNING version
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(ctx, query, post.ID, time.Now().Add(-retention)).Scan(&post.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrNotFound
+		default:
+			return err
+		}
+	}
+
+	post.DeletedAt = nil
+	return nil
+}
+
+// `PurgeDeleted` permanently removes posts that have been in the trash for longer than `retention`.
+// Their comments, snippets, mentions and notifications go with them through `ON DELETE CASCADE`,
+// attached media is only detached. There is no reactions table, so there are no reactions to remove.
+// Returns the number of purged posts.
+func (s *PostsRepositoryPostgres) PurgeDeleted(ctx context.Context, retention time.Duration) (int64, error) {
+	query := `
+		DELETE FROM posts WHERE deleted_at IS NOT NULL AND deleted_at <= $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, time.Now().Add(-retention))
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
 }
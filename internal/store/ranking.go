@@ -0,0 +1,227 @@
+package store
+
+import (
+	"context"
+	"encoding/base64"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// `FeedRanking` holds the weights and windows of the ranked feed.
+// A post scores `RecencyWeight * recency + VelocityWeight * velocity + AffinityWeight * affinity`.
+type FeedRanking struct {
+	RecencyWeight  float64
+	VelocityWeight float64
+	AffinityWeight float64
+	HalfLife       time.Duration // age at which the recency of a post halves
+	Window         time.Duration // only posts this recent are ranked
+	AffinityWindow time.Duration // interactions this recent count towards affinity
+}
+
+// `FeedScore` is the breakdown of the score of a ranked post, before weighting. There are no
+// reactions, so comments and mentions are the only engagement signals:
+//   - `Recency` halves every `FeedRanking.HalfLife`, from 1 for a new post
+//   - `Velocity` is ln(1 + comments per hour since the post was created)
+//   - `Affinity` is ln(1 + comments on the author's posts and mentions of them by the reader)
+type FeedScore struct {
+	Total    float64 `json:"total"`
+	Recency  float64 `json:"recency"`
+	Velocity float64 `json:"velocity"`
+	Affinity float64 `json:"affinity"`
+}
+
+// `RankedFeedCursor` is the position after the last post of a ranked page. Every page of a
+// ranked feed is scored as of the time of its first page, so posts don't move between pages.
+type RankedFeedCursor struct {
+	AsOf   time.Time
+	Score  float64
+	PostId int64
+}
+
+func (c RankedFeedCursor) Encode() string {
+	raw := c.AsOf.UTC().Format(time.RFC3339Nano) + "|" +
+		strconv.FormatFloat(c.Score, 'g', -1, 64) + "|" +
+		strconv.FormatInt(c.PostId, 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// `DecodeRankedFeedCursor` parses a token produced by `RankedFeedCursor.Encode`
+func DecodeRankedFeedCursor(token string) (*RankedFeedCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	parts := strings.Split(string(raw), "|")
+	if len(parts) != 3 {
+		return nil, ErrInvalidCursor
+	}
+
+	asOf, err := time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	score, err := strconv.ParseFloat(parts[1], 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	postId, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return &RankedFeedCursor{AsOf: asOf, Score: score, PostId: postId}, nil
+}
+
+// `NextRankedFeedCursor` returns the cursor after the last post, or an empty string when the page isn't full
+func NextRankedFeedCursor(posts []*FeedPost, limit int, asOf time.Time) string {
+	if len(posts) < limit || len(posts) == 0 {
+		return ""
+	}
+
+	last := posts[len(posts)-1]
+	if last.Score == nil {
+		return ""
+	}
+
+	return RankedFeedCursor{AsOf: asOf, Score: last.Score.Total, PostId: last.ID}.Encode()
+}
+
+// `GetRankedFeed` ranks the recent posts of users `userId` follows and posts with tags they
+// follow by recency, comment velocity and how often `userId` interacts with the author.
+// Signals are computed as of `asOf`, posts are paged on (score, id) after `pfq.AfterRanked`.
+//
+// `pfq.Search`, `pfq.Tags`, `pfq.Since` and `pfq.Until` filter candidates like in `GetUserFeed`.
+func (s *PostsRepositoryPostgres) GetRankedFeed(
+	ctx context.Context,
+	userId int64,
+	pfq PaginatedFeedQuery,
+	ranking FeedRanking,
+	asOf time.Time,
+) ([]*FeedPost, error) {
+	query := `
+		WITH candidates AS (
+			SELECT p.id, p.title, p."content", p.content_html, p.user_id, p.created_at, p.tags, u.username,
+				(SELECT COUNT(*) FROM "comments" c WHERE c.post_id = p.id) AS comments_count,
+				(SELECT COUNT(*) FROM "comments" c WHERE c.post_id = p.id AND c.created_at <= $7)::float8 AS comments_as_of,
+				GREATEST(EXTRACT(EPOCH FROM ($7 - p.created_at))::float8 / 3600, 0) AS age_hours
+			FROM posts p
+			JOIN users u ON u.id = p.user_id
+			WHERE
+				p.deleted_at IS NULL AND
+				p.user_id <> $1 AND
+				(
+					EXISTS (SELECT 1 FROM followers f WHERE f.user_id = p.user_id AND f.follower_id = $1) OR
+					p.tags && ARRAY(
+						SELECT t.name::varchar FROM tag_follows tf JOIN tags t ON t.id = tf.tag_id WHERE tf.user_id = $1
+					)
+				) AND
+				` + visibleSQL("p.user_id", "$1") + ` AND
+				NOT ` + mutedSQL("$1", "p.user_id") + ` AND
+				($3 = '' OR p.title ILIKE '%' || $3 || '%' OR p.content ILIKE '%' || $3 || '%') AND
+				($4::varchar[] IS NULL OR p.tags @> $4) AND
+				($5::timestamptz IS NULL OR p.created_at >= $5) AND
+				($6::timestamptz IS NULL OR p.created_at < $6) AND
+				p.created_at <= $7 AND p.created_at > $8
+		),
+		interactions AS (
+			SELECT author_id, COUNT(*)::float8 AS n
+			FROM (
+				SELECT p.user_id AS author_id
+				FROM "comments" c JOIN posts p ON p.id = c.post_id
+				WHERE c.user_id = $1 AND c.created_at > $9 AND c.created_at <= $7
+				UNION ALL
+				SELECT m.mentioned_user_id
+				FROM mentions m
+				WHERE m.author_id = $1 AND m.created_at > $9 AND m.created_at <= $7
+			) i
+			GROUP BY author_id
+		),
+		signals AS (
+			SELECT c.*,
+				POWER(2::float8, -c.age_hours / $10::float8) AS recency,
+				LN(1 + c.comments_as_of / GREATEST(c.age_hours, 1)) AS velocity,
+				LN(1 + COALESCE(i.n, 0)) AS affinity
+			FROM candidates c
+			LEFT JOIN interactions i ON i.author_id = c.user_id
+		),
+		scored AS (
+			SELECT s.*, $11::float8 * s.recency + $12::float8 * s.velocity + $13::float8 * s.affinity AS score
+			FROM signals s
+		)
+		SELECT id, title, "content", content_html, user_id, created_at, tags, username, comments_count,
+			score, recency, velocity, affinity
+		FROM scored
+		WHERE $14::float8 IS NULL OR (score, id) < ($14, $15)
+		ORDER BY score DESC, id DESC
+		LIMIT $2
+	`
+
+	var (
+		afterScore *float64
+		afterId    int64
+	)
+	if pfq.AfterRanked != nil {
+		afterScore, afterId = &pfq.AfterRanked.Score, pfq.AfterRanked.PostId
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(
+		ctx,
+		query,
+		userId,
+		pfq.Limit,
+		pfq.Search,
+		pq.Array(pfq.Tags),
+		pfq.Since,
+		pfq.Until,
+		asOf,
+		asOf.Add(-ranking.Window),
+		asOf.Add(-ranking.AffinityWindow),
+		ranking.HalfLife.Hours(),
+		ranking.RecencyWeight,
+		ranking.VelocityWeight,
+		ranking.AffinityWeight,
+		afterScore,
+		afterId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	feedPosts := []*FeedPost{}
+	for rows.Next() {
+		var feedPost FeedPost
+		var score FeedScore
+
+		err := rows.Scan(
+			&feedPost.ID,
+			&feedPost.Title,
+			&feedPost.Content,
+			&feedPost.ContentHTML,
+			&feedPost.UserId,
+			&feedPost.CreatedAt,
+			pq.Array(&feedPost.Tags),
+			&feedPost.User.Username,
+			&feedPost.CommentCount,
+			&score.Total,
+			&score.Recency,
+			&score.Velocity,
+			&score.Affinity,
+		)
+		if err != nil {
+			return nil, err
+		}
+		feedPost.User.ID = feedPost.UserId
+		feedPost.Score = &score
+		feedPosts = append(feedPosts, &feedPost)
+	}
+
+	return feedPosts, rows.Err()
+}
@@ -0,0 +1,205 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+)
+
+// Result types of `SearchRepository.Search`
+const (
+	SearchPosts    = "posts"
+	SearchComments = "comments"
+	SearchUsers    = "users"
+)
+
+// `ts_headline` wraps matches in these private use characters, the text is escaped first
+// and only then are they turned into <mark> tags, so user content never reaches clients as HTML
+const (
+	headlineStart = "\uE000"
+	headlineStop  = "\uE001"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// `SearchQuery` describes a full-text search.
+// `Query` uses `websearch_to_tsquery` syntax: `"quoted phrases"`, `or`, `-excluded`.
+// Authors `ViewerId` blocked or was blocked by are left out, so are comments of users they muted.
+type SearchQuery struct {
+	Type     string        `json:"type" validate:"oneof=posts comments users"`
+	Query    string        `json:"q" validate:"required,max=200"`
+	Limit    int           `json:"limit" validate:"gte=1,lte=50"`
+	After    *SearchCursor `json:"-"`
+	ViewerId int64         `json:"-"`
+}
+
+// `SearchCursor` is the keyset position after the last returned result
+type SearchCursor struct {
+	Rank float32
+	ID   int64
+}
+
+// `SearchResult` is a ranked match. `Headline` is HTML escaped, with matches wrapped in <mark>.
+type SearchResult struct {
+	Type      string  `json:"type"`
+	ID        int64   `json:"id"`
+	Rank      float32 `json:"rank"`
+	Title     string  `json:"title,omitempty"`
+	Headline  string  `json:"headline"`
+	PostId    int64   `json:"post_id,omitempty"`
+	UserId    int64   `json:"user_id"`
+	Username  string  `json:"username"`
+	CreatedAt string  `json:"created_at"`
+}
+
+// `Encode` serializes the cursor into an opaque URL safe token
+func (c SearchCursor) Encode() string {
+	raw := strconv.FormatFloat(float64(c.Rank), 'g', -1, 32) + ":" + strconv.FormatInt(c.ID, 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// `DecodeSearchCursor` parses a token produced by `SearchCursor.Encode`
+func DecodeSearchCursor(token string) (*SearchCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	rankPart, idPart, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return nil, ErrInvalidCursor
+	}
+
+	rank, err := strconv.ParseFloat(rankPart, 32)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	id, err := strconv.ParseInt(idPart, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return &SearchCursor{Rank: float32(rank), ID: id}, nil
+}
+
+type SearchRepositoryPostgres struct {
+	db *sql.DB
+}
+
+// `Search` runs a full-text query and returns up to `Limit` results ordered by rank,
+// starting after `After` when set.
+func (s *SearchRepositoryPostgres) Search(ctx context.Context, q SearchQuery) ([]SearchResult, error) {
+	headlineOptions := fmt.Sprintf(`StartSel="%s", StopSel="%s", MaxFragments=2, MaxWords=30, MinWords=10`, headlineStart, headlineStop)
+
+	// the inner query ranks every match, the outer one applies the keyset and only
+	// builds headlines for the page that is returned
+	var query string
+	switch q.Type {
+	case SearchPosts:
+		query = `
+			SELECT r.id, r.rank, r.title, ts_headline('english', r.content, r.q, $5), 0, r.user_id, r.username, r.created_at
+			FROM (
+				SELECT p.id, p.title, p.content, p.user_id, u.username, p.created_at, q,
+					ts_rank_cd(p.search_vector, q)::real AS rank
+				FROM posts p
+				JOIN users u ON u.id = p.user_id
+				CROSS JOIN websearch_to_tsquery('english', $1) q
+				WHERE p.search_vector @@ q AND p.deleted_at IS NULL AND NOT ` + blockedSQL("p.user_id", "$6::bigint") + `
+			) r
+			WHERE $2::real IS NULL OR (r.rank, r.id) < ($2::real, $3::bigint)
+			ORDER BY r.rank DESC, r.id DESC
+			LIMIT $4
+		`
+	case SearchComments:
+		query = `
+			SELECT r.id, r.rank, r.title, ts_headline('english', r.content, r.q, $5), r.post_id, r.user_id, r.username, r.created_at
+			FROM (
+				SELECT c.id, p.title, c.content, c.post_id, c.user_id, u.username, c.created_at, q,
+					ts_rank_cd(c.search_vector, q)::real AS rank
+				FROM comments c
+				JOIN posts p ON p.id = c.post_id AND p.deleted_at IS NULL
+				JOIN users u ON u.id = c.user_id
+				CROSS JOIN websearch_to_tsquery('english', $1) q
+				WHERE c.search_vector @@ q AND
+					NOT ` + blockedSQL("c.user_id", "$6::bigint") + ` AND
+					NOT ` + mutedSQL("$6::bigint", "c.user_id") + `
+			) r
+			WHERE $2::real IS NULL OR (r.rank, r.id) < ($2::real, $3::bigint)
+			ORDER BY r.rank DESC, r.id DESC
+			LIMIT $4
+		`
+	case SearchUsers:
+		// usernames are indexed with the 'simple' configuration, prefix matching
+		// lets "oli" find "Olivia0"
+		query = `
+			SELECT r.id, r.rank, '', ts_headline('simple', r.username, r.q, $5), 0, r.id, r.username, r.created_at
+			FROM (
+				SELECT u.id, u.username::text, u.created_at, q,
+					ts_rank_cd(u.search_vector, q)::real AS rank
+				FROM users u
+				CROSS JOIN (
+					SELECT websearch_to_tsquery('simple', $1) || COALESCE(to_tsquery('simple', NULLIF(
+						regexp_replace(lower($1), '[^a-z0-9_]+', '', 'g'), '') || ':*'), ''::tsquery) AS q
+				) query
+				WHERE u.search_vector @@ q AND u.is_active = true AND NOT ` + blockedSQL("u.id", "$6::bigint") + `
+			) r
+			WHERE $2::real IS NULL OR (r.rank, r.id) < ($2::real, $3::bigint)
+			ORDER BY r.rank DESC, r.id DESC
+			LIMIT $4
+		`
+	default:
+		return nil, fmt.Errorf("unknown search type %q", q.Type)
+	}
+
+	var afterRank *float32
+	var afterId *int64
+	if q.After != nil {
+		afterRank = &q.After.Rank
+		afterId = &q.After.ID
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, q.Query, afterRank, afterId, q.Limit, headlineOptions, q.ViewerId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []SearchResult{}
+	for rows.Next() {
+		result := SearchResult{Type: q.Type}
+		err := rows.Scan(
+			&result.ID,
+			&result.Rank,
+			&result.Title,
+			&result.Headline,
+			&result.PostId,
+			&result.UserId,
+			&result.Username,
+			&result.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		result.Headline = markHeadline(result.Headline)
+		results = append(results, result)
+	}
+
+	return results, rows.Err()
+}
+
+// `markHeadline` escapes a `ts_headline` fragment and turns the match markers into <mark> tags
+func markHeadline(headline string) string {
+	escaped := html.EscapeString(headline)
+	escaped = strings.ReplaceAll(escaped, headlineStart, "<mark>")
+	return strings.ReplaceAll(escaped, headlineStop, "</mark>")
+}
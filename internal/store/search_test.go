@@ -0,0 +1,66 @@
+package store
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+func TestSearchCursor(t *testing.T) {
+	t.Run("round-trips through its token", func(t *testing.T) {
+		for _, cursor := range []SearchCursor{
+			{Rank: 0, ID: 1},
+			{Rank: 0.0607927, ID: 42},
+			{Rank: 1e-20, ID: 9007199254740993},
+			{Rank: 3.4028235e38, ID: -1},
+		} {
+			token := cursor.Encode()
+
+			decoded, err := DecodeSearchCursor(token)
+			if err != nil {
+				t.Fatalf("decoding %q: %v", token, err)
+			}
+
+			if *decoded != cursor {
+				t.Errorf("expected %+v, got %+v", cursor, *decoded)
+			}
+		}
+	})
+
+	t.Run("rejects tokens it did not produce", func(t *testing.T) {
+		for _, token := range []string{
+			"",
+			"not base64!",
+			base64.RawURLEncoding.EncodeToString([]byte("0.5")),
+			base64.RawURLEncoding.EncodeToString([]byte("high:1")),
+			base64.RawURLEncoding.EncodeToString([]byte("0.5:one")),
+			base64.StdEncoding.EncodeToString([]byte("0.5:1")),
+		} {
+			if _, err := DecodeSearchCursor(token); !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("expected ErrInvalidCursor for %q, got %v", token, err)
+			}
+		}
+	})
+}
+
+func TestMarkHeadline(t *testing.T) {
+	tests := []struct {
+		name     string
+		headline string
+		want     string
+	}{
+		{"marks matches", "learning " + headlineStart + "go" + headlineStop + " today", "learning <mark>go</mark> today"},
+		{"escapes markup", `<script>alert("x")</script> ` + headlineStart + "go" + headlineStop, "&lt;script&gt;alert(&#34;x&#34;)&lt;/script&gt; <mark>go</mark>"},
+		{"escapes markup inside matches", headlineStart + "<b>go</b>" + headlineStop, "<mark>&lt;b&gt;go&lt;/b&gt;</mark>"},
+		{"escapes literal mark tags", "<mark>fake</mark> & 'quotes'", "&lt;mark&gt;fake&lt;/mark&gt; &amp; &#39;quotes&#39;"},
+		{"keeps text without matches", "plain text", "plain text"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := markHeadline(tt.headline); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// `Snippet` is a named piece of code attached to a post, similar to a file in a gist.
+// `BodyHTML` holds the syntax highlighted body, rendered once when the snippet is created.
+type Snippet struct {
+	ID        int64  `json:"id"`
+	PostId    int64  `json:"post_id"`
+	Position  int    `json:"position"`
+	Filename  string `json:"filename"`
+	Language  string `json:"language"`
+	Body      string `json:"body"`
+	BodyHTML  string `json:"body_html"`
+	CreatedAt string `json:"created_at"`
+}
+
+type SnippetRepositoryPostgres struct {
+	db *sql.DB
+}
+
+func (s *SnippetRepositoryPostgres) GetByPostId(ctx context.Context, postId int64) ([]Snippet, error) {
+	query := `
+		SELECT id, post_id, position, filename, language, body, body_html, created_at
+		FROM post_snippets WHERE post_id = $1
+		ORDER BY position, id
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, postId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var snippets []Snippet
+	for rows.Next() {
+		var snippet Snippet
+		err := rows.Scan(
+			&snippet.ID,
+			&snippet.PostId,
+			&snippet.Position,
+			&snippet.Filename,
+			&snippet.Language,
+			&snippet.Body,
+			&snippet.BodyHTML,
+			&snippet.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		snippets = append(snippets, snippet)
+	}
+
+	return snippets, rows.Err()
+}
+
+func (s *SnippetRepositoryPostgres) GetById(ctx context.Context, postId int64, id int64) (*Snippet, error) {
+	query := `
+		SELECT id, post_id, position, filename, language, body, body_html, created_at
+		FROM post_snippets WHERE post_id = $1 AND id = $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
+	defer cancel()
+
+	var snippet Snippet
+	err := s.db.QueryRowContext(ctx, query, postId, id).Scan(
+		&snippet.ID,
+		&snippet.PostId,
+		&snippet.Position,
+		&snippet.Filename,
+		&snippet.Language,
+		&snippet.Body,
+		&snippet.BodyHTML,
+		&snippet.CreatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &snippet, nil
+}
+
+// `createSnippets` inserts the snippets of a freshly created post, inside the post's transaction
+func createSnippets(ctx context.Context, tx *sql.Tx, post *Post) error {
+	query := `
+		INSERT INTO post_snippets (post_id, position, filename, language, body, body_html)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
+	defer cancel()
+
+	for i := range post.Snippets {
+		snippet := &post.Snippets[i]
+		snippet.PostId = post.ID
+		snippet.Position = i
+
+		err := tx.QueryRowContext(
+			ctx,
+			query,
+			snippet.PostId,
+			snippet.Position,
+			snippet.Filename,
+			snippet.Language,
+			snippet.Body,
+			snippet.BodyHTML,
+		).Scan(
+			&snippet.ID,
+			&snippet.CreatedAt,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
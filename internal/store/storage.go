@@ -5,6 +5,8 @@ import (
 	"database/sql"
 	"errors"
 	"time"
+
+	"github.com/elhambadri2411/social/internal/mentions"
 )
 
 // `ErrNotFound` is a predefined error returned when a requested resource does not exist in the database.
@@ -14,6 +16,8 @@ var (
 	QueryContextTimeoutDuration = time.Second * 5
 	ErrDuplicateEmail           = errors.New("there is already an account with that email")
 	ErrDuplicateUsername        = errors.New("the username already exists")
+	ErrEditConflict             = errors.New("edit conflict: resource was modified concurrently")
+	ErrUsernameChangeTooSoon    = errors.New("the username was changed too recently")
 )
 
 // `PostsRepository` defines an interface for managing posts in the database.
@@ -34,18 +38,79 @@ type PostsRepository interface {
 	// `GetAll` retrieves posts from database (paginated) default offset 0, defulat limit 10
 	GetAll(context.Context, int64, int64) ([]*Post, error)
 
-	// `DeleteById` deletes a post given an id
-	DeleteById(context.Context, int64) error
+	// `DeleteById` moves a post to the trash given an id and the version the caller loaded
+	DeleteById(context.Context, int64, int64) error
 
 	// `UpdateById` updates a post given an id
 	UpdateById(context.Context, *Post) error
 
+	// `GetDeletedById` retrieves a post from the trash given an id
+	GetDeletedById(context.Context, int64) (*Post, error)
+
+	// `GetDeleted` lists trashed posts of a user (0 for every user), paginated by limit and offset
+	GetDeleted(context.Context, int64, int64, int64) ([]*Post, error)
+
+	// `Restore` moves a post out of the trash if it was deleted within the retention window
+	Restore(context.Context, *Post, time.Duration) error
+
+	// `PurgeDeleted` permanently removes posts trashed longer than the retention window
+	PurgeDeleted(context.Context, time.Duration) (int64, error)
+
+	// `GetUserFeed` builds the feed of a user from their own posts, followed users and followed tags
 	GetUserFeed(context.Context, int64, PaginatedFeedQuery) ([]*FeedPost, error)
+
+	// `GetRankedFeed` ranks recent posts of followed users and tags for a user, as of a time
+	GetRankedFeed(ctx context.Context, userId int64, pfq PaginatedFeedQuery, ranking FeedRanking, asOf time.Time) ([]*FeedPost, error)
+
+	// `GetByTag` lists posts with a canonical tag visible to a user, paginated by limit and offset
+	GetByTag(ctx context.Context, tag string, viewerId int64, limit int, offset int) ([]*FeedPost, error)
+
+	// `GetPublic` lists posts of public accounts for visitors, optionally of one author or with a canonical tag, keyset paginated
+	GetPublic(ctx context.Context, authorId int64, tag string, limit int, after *FeedCursor) ([]*FeedPost, error)
+
+	// `GetBatch` lists posts after an id in id order, with authors and comments, to walk the table
+	GetBatch(ctx context.Context, afterId int64, limit int) ([]*Post, error)
+}
+
+type TagsRepository interface {
+	Resolve(context.Context, []string) ([]string, error)
+	GetByName(context.Context, string) (*Tag, error)
+	Follow(ctx context.Context, tagId int64, userId int64) error
+	Unfollow(ctx context.Context, tagId int64, userId int64) error
+	GetFollowed(context.Context, int64) ([]Tag, error)
+	GetTrending(ctx context.Context, window time.Duration, limit int) ([]TrendingTag, error)
+}
+
+type SnippetsRepository interface {
+	GetByPostId(context.Context, int64) ([]Snippet, error)
+	GetById(ctx context.Context, postId int64, id int64) (*Snippet, error)
+}
+
+type MediaRepository interface {
+	Create(ctx context.Context, media *Media, max int) error
+	GetByPostId(context.Context, int64) ([]Media, error)
+	GetById(ctx context.Context, postId int64, id int64) (*Media, error)
+	GetDetached(ctx context.Context, limit int) ([]Media, error)
+	Delete(context.Context, int64) error
+}
+
+type MentionsRepository interface {
+	Sync(context.Context, MentionSource, []mentions.Entity) ([]Mention, []Mention, error)
+	GetByPostId(context.Context, int64) ([]Mention, error)
+	GetForUser(ctx context.Context, userId int64, limit int, offset int) ([]UserMention, error)
+}
+
+type SearchRepository interface {
+	Search(context.Context, SearchQuery) ([]SearchResult, error)
 }
 
 type CommentsRepository interface {
-	GetByPostId(context.Context, int64) ([]Comment, error)
+	GetByPostId(ctx context.Context, postId int64, viewerId int64) ([]Comment, error)
+	GetById(ctx context.Context, postId int64, id int64) (*Comment, error)
 	Create(context.Context, *Comment) error
+	Update(context.Context, *Comment) error
+	Delete(context.Context, int64) error
+	GetCommenterIds(ctx context.Context, postId int64) ([]int64, error)
 }
 
 // `UsersRepository` defines an interface for managing users in the database.
@@ -56,8 +121,80 @@ type UsersRepository interface {
 	Unfollow(context.Context, int64, int64) error
 	CreateAndInvite(context.Context, *User, string, time.Duration) error
 	Activate(context.Context, string) error
-	Delete(context.Context, int64) error
+	Delete(context.Context, int64) ([]int64, error)
 	GetByEmail(context.Context, string) (*User, error)
+	UpdateProfile(context.Context, *User) error
+	ScheduleDeletion(context.Context, int64, time.Time) (string, error)
+	CancelDeletion(context.Context, int64) error
+	GetDueDeletions(ctx context.Context, limit int) ([]*User, error)
+	ChangeUsername(ctx context.Context, userId int64, username string, cooldown time.Duration, reservation time.Duration) error
+	ResolveUsername(context.Context, string) (int64, error)
+}
+
+type FollowersRepository interface {
+	GetFollowers(context.Context, int64, FollowListQuery) ([]FollowListEntry, error)
+	GetFollowing(context.Context, int64, FollowListQuery) ([]FollowListEntry, error)
+	GetCounts(context.Context, int64) (*FollowCounts, error)
+	GetRequests(context.Context, int64, FollowListQuery) ([]FollowListEntry, error)
+	CreateRequest(context.Context, int64, int64) error
+	ApproveRequest(context.Context, int64, int64) error
+	DeleteRequest(context.Context, int64, int64) error
+	CanView(context.Context, int64, int64) (bool, error)
+	GetHiddenPosts(context.Context, int64, []int64) ([]int64, error)
+}
+
+type BlocksRepository interface {
+	Block(context.Context, int64, int64) error
+	Unblock(context.Context, int64, int64) error
+	Mute(context.Context, int64, int64) error
+	Unmute(context.Context, int64, int64) error
+	GetBlocked(ctx context.Context, userId int64, limit int, offset int) ([]RelatedUser, error)
+	GetMuted(ctx context.Context, userId int64, limit int, offset int) ([]RelatedUser, error)
+	IsBlocked(context.Context, int64, int64) (bool, error)
+	GetMuters(ctx context.Context, mutedId int64, userIds []int64) ([]int64, error)
+	GetHiddenAuthors(ctx context.Context, viewerId int64, userIds []int64) ([]int64, error)
+}
+
+type SuggestionsRepository interface {
+	GetForUser(ctx context.Context, userId int64, limit int) ([]Suggestion, error)
+}
+
+type ExportsRepository interface {
+	Create(context.Context, *Export) error
+	GetById(context.Context, int64) (*Export, error)
+	Complete(ctx context.Context, id int64, key string, size int64) error
+	Fail(context.Context, int64) error
+	GetExpired(ctx context.Context, limit int) ([]Export, error)
+	Delete(context.Context, int64) error
+	GetUserData(context.Context, int64) (*UserData, error)
+}
+
+// `TimelinesRepository` reads what home timelines are built from, see `internal/timeline`
+type TimelinesRepository interface {
+	GetFollowerIds(ctx context.Context, authorId int64, limit int) ([]int64, error)
+	GetAuthorEntries(ctx context.Context, authorId int64, limit int) ([]TimelineEntry, error)
+	GetFollowedEntries(ctx context.Context, userId int64, limit int) ([]TimelineEntry, error)
+	GetPulledEntries(ctx context.Context, userId int64, authorIds []int64, after *FeedCursor, limit int) ([]TimelineEntry, error)
+	Hydrate(ctx context.Context, viewerId int64, postIds []int64) ([]*FeedPost, error)
+}
+
+// `NotificationsRepository` stores notifications, see `internal/notifications`
+type NotificationsRepository interface {
+	Create(ctx context.Context, notification Notification, userIds []int64) ([]Notification, error)
+	GetForUser(ctx context.Context, userId int64, limit int, offset int) (*NotificationsPage, error)
+	MarkRead(ctx context.Context, userId int64, id int64) error
+	MarkAllRead(ctx context.Context, userId int64) (int64, error)
+	Purge(ctx context.Context, retention time.Duration) (int64, error)
+}
+
+// `NotificationPreferencesRepository` stores how users want to be notified and when their digests went out
+type NotificationPreferencesRepository interface {
+	Get(ctx context.Context, userId int64) (*NotificationPreferences, error)
+	Set(ctx context.Context, userId int64, preferences NotificationPreferences) error
+	Unsubscribe(ctx context.Context, userId int64, delivery string, notificationType string) error
+	GetDueDigests(ctx context.Context, limit int) ([]DigestRecipient, error)
+	GetDigest(ctx context.Context, userId int64, since string, limit int) ([]NotificationGroup, error)
+	MarkDigestSent(ctx context.Context, userId int64) error
 }
 
 type RolesRepository interface {
@@ -67,10 +204,22 @@ type RolesRepository interface {
 // `Storage` acts as a central repository abstraction layer.
 // It embeds `PostsRepository` and `UsersRepository`, allowing unified access to database operations.
 type Storage struct {
-	PostsRepository    // Handles post-related database operations
-	UsersRepository    // Handles user-related database operations
-	CommentsRepository // Handles comment-related database operations
-	RolesRepository    // Handles role-related database operations
+	PostsRepository                   // Handles post-related database operations
+	UsersRepository                   // Handles user-related database operations
+	CommentsRepository                // Handles comment-related database operations
+	RolesRepository                   // Handles role-related database operations
+	SnippetsRepository                // Handles code snippets attached to posts
+	MentionsRepository                // Handles @username mentions in posts and comments
+	TagsRepository                    // Handles canonical tags, aliases and tag follows
+	SearchRepository                  // Handles full-text search over posts, comments and users
+	MediaRepository                   // Handles images attached to posts
+	FollowersRepository               // Handles followers and following lists
+	BlocksRepository                  // Handles blocked and muted users
+	SuggestionsRepository             // Handles who-to-follow suggestions
+	ExportsRepository                 // Handles data exports of users
+	TimelinesRepository               // Handles the posts home timelines are built from
+	NotificationsRepository           // Handles notifications of users
+	NotificationPreferencesRepository // Handles how users want to be notified
 }
 
 // `NewStorage` initializes and returns a new `Storage` instance.
@@ -83,10 +232,22 @@ type Storage struct {
 // These implementations interact with the database to perform CRUD operations.
 func NewStorage(db *sql.DB) Storage {
 	return Storage{
-		PostsRepository:    &PostsRepositoryPostgres{db}, // Instantiate PostgreSQL-backed posts repository
-		UsersRepository:    &UsersRepositoryPostgres{db}, // Instantiate PostgreSQL-backed users repository
-		CommentsRepository: &CommentRepositoryPostgres{db},
-		RolesRepository:    &RoleRepositoryPostgres{db},
+		PostsRepository:                   &PostsRepositoryPostgres{db}, // Instantiate PostgreSQL-backed posts repository
+		UsersRepository:                   &UsersRepositoryPostgres{db}, // Instantiate PostgreSQL-backed users repository
+		CommentsRepository:                &CommentRepositoryPostgres{db},
+		RolesRepository:                   &RoleRepositoryPostgres{db},
+		SnippetsRepository:                &SnippetRepositoryPostgres{db},
+		MentionsRepository:                &MentionRepositoryPostgres{db},
+		TagsRepository:                    &TagRepositoryPostgres{db},
+		SearchRepository:                  &SearchRepositoryPostgres{db},
+		MediaRepository:                   &MediaRepositoryPostgres{db},
+		FollowersRepository:               &FollowersRepositoryPostgres{db},
+		BlocksRepository:                  &BlocksRepositoryPostgres{db},
+		SuggestionsRepository:             &SuggestionsRepositoryPostgres{db},
+		ExportsRepository:                 &ExportsRepositoryPostgres{db},
+		TimelinesRepository:               &TimelinesRepositoryPostgres{db},
+		NotificationsRepository:           &NotificationsRepositoryPostgres{db},
+		NotificationPreferencesRepository: &NotificationPreferencesRepositoryPostgres{db},
 	}
 }
 
@@ -0,0 +1,111 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+)
+
+// `Suggestion` is an account the user may want to follow, with the signals it was ranked by
+type Suggestion struct {
+	ID                 int64   `json:"id"`
+	Username           string  `json:"username"`
+	DisplayName        string  `json:"display_name"`
+	Bio                string  `json:"bio"`
+	AvatarKey          *string `json:"-"`
+	AvatarThumbnailKey *string `json:"-"`
+	AvatarURL          string  `json:"avatar_url,omitempty"`
+	AvatarThumbnailURL string  `json:"avatar_thumbnail_url,omitempty"`
+	MutualFollows      int     `json:"mutual_follows"` // followed by this many users you follow
+	SharedTags         int     `json:"shared_tags"`    // tags of their recent posts you post about or follow
+	RecentPosts        int     `json:"recent_posts"`   // posts in the last 30 days
+	Score              float64 `json:"score"`
+}
+
+type SuggestionsRepositoryPostgres struct {
+	db *sql.DB
+}
+
+// `GetForUser` ranks accounts `userId` may want to follow. Candidates are followed by people
+// the user follows (friends of friends) or post about the user's tags, and are scored by
+// mutual follows, shared tags and, on a log scale, how active they have been recently.
+// Followed, requested, blocked, muted and inactive accounts are excluded.
+func (s *SuggestionsRepositoryPostgres) GetForUser(ctx context.Context, userId int64, limit int) ([]Suggestion, error) {
+	query := `
+		WITH my_follows AS (
+			SELECT user_id FROM followers WHERE follower_id = $1
+		),
+		friends_of_friends AS (
+			SELECT f.user_id AS candidate_id, COUNT(*) AS mutuals
+			FROM followers f JOIN my_follows m ON m.user_id = f.follower_id
+			GROUP BY f.user_id
+		),
+		my_tags AS (
+			SELECT DISTINCT unnest(tags) AS tag FROM posts
+			WHERE user_id = $1 AND deleted_at IS NULL AND created_at > NOW() - INTERVAL '90 days'
+			UNION
+			SELECT t.name::varchar FROM tag_follows tf JOIN tags t ON t.id = tf.tag_id WHERE tf.user_id = $1
+		),
+		shared_tags AS (
+			SELECT p.user_id AS candidate_id, COUNT(DISTINCT tag) AS shared
+			FROM posts p CROSS JOIN LATERAL unnest(p.tags) AS tag
+			WHERE p.deleted_at IS NULL AND p.created_at > NOW() - INTERVAL '90 days'
+				AND tag IN (SELECT tag FROM my_tags)
+			GROUP BY p.user_id
+		),
+		candidates AS (
+			SELECT candidate_id FROM friends_of_friends
+			UNION
+			SELECT candidate_id FROM shared_tags
+		)
+		SELECT u.id, u.username, u.display_name, u.bio, u.avatar_key, u.avatar_thumbnail_key,
+			COALESCE(fof.mutuals, 0), COALESCE(st.shared, 0), activity.recent_posts,
+			(3 * COALESCE(fof.mutuals, 0) + 2 * COALESCE(st.shared, 0) + LN(1 + activity.recent_posts))::float8 AS score
+		FROM candidates c
+		JOIN users u ON u.id = c.candidate_id AND u.is_active = true
+		LEFT JOIN friends_of_friends fof ON fof.candidate_id = u.id
+		LEFT JOIN shared_tags st ON st.candidate_id = u.id
+		CROSS JOIN LATERAL (
+			SELECT COUNT(*) AS recent_posts FROM posts
+			WHERE user_id = u.id AND deleted_at IS NULL AND created_at > NOW() - INTERVAL '30 days'
+		) activity
+		WHERE u.id <> $1
+			AND NOT EXISTS (SELECT 1 FROM followers WHERE user_id = u.id AND follower_id = $1)
+			AND NOT EXISTS (SELECT 1 FROM follow_requests WHERE user_id = u.id AND follower_id = $1)
+			AND NOT ` + blockedSQL("u.id", "$1") + `
+			AND NOT ` + mutedSQL("$1", "u.id") + `
+		ORDER BY score DESC, u.id DESC
+		LIMIT $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userId, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	suggestions := []Suggestion{}
+	for rows.Next() {
+		var suggestion Suggestion
+		err := rows.Scan(
+			&suggestion.ID,
+			&suggestion.Username,
+			&suggestion.DisplayName,
+			&suggestion.Bio,
+			&suggestion.AvatarKey,
+			&suggestion.AvatarThumbnailKey,
+			&suggestion.MutualFollows,
+			&suggestion.SharedTags,
+			&suggestion.RecentPosts,
+			&suggestion.Score,
+		)
+		if err != nil {
+			return nil, err
+		}
+		suggestions = append(suggestions, suggestion)
+	}
+
+	return suggestions, rows.Err()
+}
@@ -0,0 +1,257 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/elhambadri2411/social/internal/tags"
+	"github.com/lib/pq"
+)
+
+// `Tag` is a canonical hashtag. Posts always carry canonical names in `posts.tags`,
+// aliases ("golang") are resolved to the tag they point to ("go") when posts are written.
+type Tag struct {
+	ID             int64    `json:"id"`
+	Name           string   `json:"name"`
+	Aliases        []string `json:"aliases"`
+	FollowersCount int      `json:"followers_count"`
+	CreatedAt      string   `json:"created_at"`
+}
+
+// `TrendingTag` is a tag ranked by how many distinct authors used it within a time window.
+// `PreviousUses` counts uses in the window of the same length right before it.
+type TrendingTag struct {
+	Name         string `json:"name"`
+	Authors      int    `json:"authors"`
+	Uses         int    `json:"uses"`
+	PreviousUses int    `json:"previous_uses"`
+}
+
+type TagRepositoryPostgres struct {
+	db *sql.DB
+}
+
+// `queryer` is satisfied by both `*sql.DB` and `*sql.Tx`
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// `canonicalTags` normalizes `raw`, resolves aliases and removes duplicates.
+// When `create` is set, tags that don't exist yet are added to the `tags` table.
+func canonicalTags(ctx context.Context, q queryer, raw []string, create bool) ([]string, error) {
+	normalized := tags.NormalizeAll(raw)
+	if len(normalized) == 0 {
+		return normalized, nil
+	}
+
+	rows, err := q.QueryContext(ctx, `
+		SELECT n.name, COALESCE(t.name, n.name)
+		FROM unnest($1::citext[]) AS n(name)
+		LEFT JOIN tag_aliases a ON a.alias = n.name
+		LEFT JOIN tags t ON t.id = a.tag_id
+	`, pq.Array(normalized))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	resolved := make(map[string]string, len(normalized))
+	for rows.Next() {
+		var name, canonical string
+		if err := rows.Scan(&name, &canonical); err != nil {
+			return nil, err
+		}
+		resolved[name] = strings.ToLower(canonical)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool, len(normalized))
+	canonical := make([]string, 0, len(normalized))
+	for _, name := range normalized {
+		if c, ok := resolved[name]; ok {
+			name = c
+		}
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		canonical = append(canonical, name)
+	}
+
+	if create {
+		_, err := q.ExecContext(ctx, `
+			INSERT INTO tags (name) SELECT unnest($1::citext[]) ON CONFLICT (name) DO NOTHING
+		`, pq.Array(canonical))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return canonical, nil
+}
+
+// `Resolve` returns the canonical names of `names`, without creating missing tags
+func (s *TagRepositoryPostgres) Resolve(ctx context.Context, names []string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
+	defer cancel()
+
+	return canonicalTags(ctx, s.db, names, false)
+}
+
+// `GetByName` retrieves a tag by its name or one of its aliases
+func (s *TagRepositoryPostgres) GetByName(ctx context.Context, name string) (*Tag, error) {
+	query := `
+		SELECT t.id, t.name, t.created_at,
+			ARRAY(SELECT alias FROM tag_aliases WHERE tag_id = t.id ORDER BY alias)::text[],
+			(SELECT COUNT(*) FROM tag_follows WHERE tag_id = t.id)
+		FROM tags t
+		WHERE t.name = $1 OR t.id = (SELECT tag_id FROM tag_aliases WHERE alias = $1)
+		LIMIT 1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
+	defer cancel()
+
+	var tag Tag
+	err := s.db.QueryRowContext(ctx, query, tags.Normalize(name)).Scan(
+		&tag.ID,
+		&tag.Name,
+		&tag.CreatedAt,
+		pq.Array(&tag.Aliases),
+		&tag.FollowersCount,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &tag, nil
+}
+
+func (s *TagRepositoryPostgres) Follow(ctx context.Context, tagId int64, userId int64) error {
+	query := `
+		INSERT INTO tag_follows (user_id, tag_id) VALUES ($1, $2)
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, userId, tagId)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return ErrConflict
+		}
+	}
+
+	return err
+}
+
+func (s *TagRepositoryPostgres) Unfollow(ctx context.Context, tagId int64, userId int64) error {
+	query := `
+		DELETE FROM tag_follows WHERE user_id = $1 AND tag_id = $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userId, tagId)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil || rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// `GetFollowed` lists the tags followed by a user
+func (s *TagRepositoryPostgres) GetFollowed(ctx context.Context, userId int64) ([]Tag, error) {
+	query := `
+		SELECT t.id, t.name, t.created_at, (SELECT COUNT(*) FROM tag_follows WHERE tag_id = t.id)
+		FROM tag_follows tf JOIN tags t ON t.id = tf.tag_id
+		WHERE tf.user_id = $1
+		ORDER BY t.name
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	followed := []Tag{}
+	for rows.Next() {
+		var tag Tag
+		if err := rows.Scan(&tag.ID, &tag.Name, &tag.CreatedAt, &tag.FollowersCount); err != nil {
+			return nil, err
+		}
+		followed = append(followed, tag)
+	}
+
+	return followed, rows.Err()
+}
+
+// `GetTrending` ranks tags by the number of distinct authors who used them in posts
+// created within the last `window`, then by number of uses. Like the public feed only posts of
+// public, active accounts count, so trending tags don't reveal what private accounts post about.
+func (s *TagRepositoryPostgres) GetTrending(ctx context.Context, window time.Duration, limit int) ([]TrendingTag, error) {
+	query := `
+		WITH usage AS (
+			SELECT tag, p.user_id, p.created_at > NOW() - make_interval(secs => $1) AS current
+			FROM posts p
+			JOIN users u ON u.id = p.user_id
+			CROSS JOIN unnest(p.tags) AS tag
+			WHERE
+				p.deleted_at IS NULL AND
+				NOT u.is_private AND
+				u.is_active AND
+				u.deletion_scheduled_at IS NULL AND
+				p.created_at > NOW() - make_interval(secs => $1 * 2)
+		)
+		SELECT tag,
+			COUNT(DISTINCT user_id) FILTER (WHERE current) AS authors,
+			COUNT(*) FILTER (WHERE current) AS uses,
+			COUNT(*) FILTER (WHERE NOT current) AS previous_uses
+		FROM usage
+		GROUP BY tag
+		HAVING COUNT(*) FILTER (WHERE current) > 0
+		ORDER BY authors DESC, uses DESC, previous_uses ASC, tag
+		LIMIT $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, window.Seconds(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	trending := []TrendingTag{}
+	for rows.Next() {
+		var tag TrendingTag
+		if err := rows.Scan(&tag.Name, &tag.Authors, &tag.Uses, &tag.PreviousUses); err != nil {
+			return nil, err
+		}
+		trending = append(trending, tag)
+	}
+
+	return trending, rows.Err()
+}
@@ -0,0 +1,192 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// `TimelineEntry` is a post as held in a home timeline, enough to order and page it
+type TimelineEntry struct {
+	PostId    int64
+	AuthorId  int64
+	CreatedAt time.Time
+}
+
+type TimelinesRepositoryPostgres struct {
+	db *sql.DB
+}
+
+// `GetFollowerIds` lists up to `limit` followers of `authorId`, the audience of their new posts
+func (s *TimelinesRepositoryPostgres) GetFollowerIds(ctx context.Context, authorId int64, limit int) ([]int64, error) {
+	query := `SELECT follower_id FROM followers WHERE user_id = $1 LIMIT $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, authorId, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// `GetAuthorEntries` lists the latest `limit` live posts of `authorId`, to backfill or trim a timeline
+func (s *TimelinesRepositoryPostgres) GetAuthorEntries(ctx context.Context, authorId int64, limit int) ([]TimelineEntry, error) {
+	query := `
+		SELECT id, user_id, created_at FROM posts
+		WHERE user_id = $1 AND deleted_at IS NULL
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2
+	`
+	return s.getEntries(ctx, query, authorId, limit)
+}
+
+// `GetFollowedEntries` lists the latest `limit` live posts of `userId` and the users they follow,
+// to rebuild a timeline that expired or was never built
+func (s *TimelinesRepositoryPostgres) GetFollowedEntries(ctx context.Context, userId int64, limit int) ([]TimelineEntry, error) {
+	query := `
+		SELECT p.id, p.user_id, p.created_at FROM posts p
+		WHERE
+			p.deleted_at IS NULL AND
+			(
+				p.user_id = $1 OR
+				EXISTS (SELECT 1 FROM followers f WHERE f.user_id = p.user_id AND f.follower_id = $1)
+			)
+		ORDER BY p.created_at DESC, p.id DESC
+		LIMIT $2
+	`
+	return s.getEntries(ctx, query, userId, limit)
+}
+
+// `GetPulledEntries` lists the posts of `userId`'s feed that aren't pushed to timelines: posts of
+// the authors in `authorIds` they follow and posts with tags they follow, in feed order after `after`
+func (s *TimelinesRepositoryPostgres) GetPulledEntries(
+	ctx context.Context,
+	userId int64,
+	authorIds []int64,
+	after *FeedCursor,
+	limit int,
+) ([]TimelineEntry, error) {
+	query := `
+		SELECT p.id, p.user_id, p.created_at FROM posts p
+		WHERE
+			p.deleted_at IS NULL AND
+			(
+				(
+					p.user_id = ANY($2) AND
+					EXISTS (SELECT 1 FROM followers f WHERE f.user_id = p.user_id AND f.follower_id = $1)
+				) OR
+				p.tags && ARRAY(
+					SELECT t.name::varchar FROM tag_follows tf JOIN tags t ON t.id = tf.tag_id WHERE tf.user_id = $1
+				)
+			) AND
+			($4::timestamptz IS NULL OR (p.created_at, p.id) < ($4, $5))
+		ORDER BY p.created_at DESC, p.id DESC
+		LIMIT $3
+	`
+
+	var (
+		afterCreatedAt *time.Time
+		afterId        int64
+	)
+	if after != nil {
+		afterCreatedAt, afterId = &after.CreatedAt, after.PostId
+	}
+
+	return s.getEntries(ctx, query, userId, pq.Array(authorIds), limit, afterCreatedAt, afterId)
+}
+
+func (s *TimelinesRepositoryPostgres) getEntries(ctx context.Context, query string, args ...any) ([]TimelineEntry, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []TimelineEntry{}
+	for rows.Next() {
+		var entry TimelineEntry
+		if err := rows.Scan(&entry.PostId, &entry.AuthorId, &entry.CreatedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+// `Hydrate` loads the feed posts with ids `postIds` in that order, leaving out posts that were
+// deleted since they were pushed and posts `viewerId` can't see or muted the author of
+func (s *TimelinesRepositoryPostgres) Hydrate(ctx context.Context, viewerId int64, postIds []int64) ([]*FeedPost, error) {
+	query := `
+		SELECT p.id, p.title, p."content", p.content_html, p.user_id, p.created_at, p.tags, u.username,
+			(SELECT COUNT(*) FROM "comments" c WHERE c.post_id = p.id) AS comments_count
+		FROM posts p
+		JOIN users u ON u.id = p.user_id
+		WHERE
+			p.id = ANY($2) AND
+			p.deleted_at IS NULL AND
+			` + visibleSQL("p.user_id", "$1") + ` AND
+			NOT ` + mutedSQL("$1", "p.user_id") + `
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, viewerId, pq.Array(postIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	byId := make(map[int64]*FeedPost, len(postIds))
+	for rows.Next() {
+		var feedPost FeedPost
+
+		err := rows.Scan(
+			&feedPost.ID,
+			&feedPost.Title,
+			&feedPost.Content,
+			&feedPost.ContentHTML,
+			&feedPost.UserId,
+			&feedPost.CreatedAt,
+			pq.Array(&feedPost.Tags),
+			&feedPost.User.Username,
+			&feedPost.CommentCount,
+		)
+		if err != nil {
+			return nil, err
+		}
+		feedPost.User.ID = feedPost.UserId
+		byId[feedPost.ID] = &feedPost
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	feedPosts := make([]*FeedPost, 0, len(byId))
+	for _, id := range postIds {
+		if feedPost, ok := byId[id]; ok {
+			feedPosts = append(feedPosts, feedPost)
+		}
+	}
+
+	return feedPosts, nil
+}
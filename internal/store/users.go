@@ -6,6 +6,7 @@ import (
 	"database/sql" // Standard library package for interacting with SQL databases
 	"encoding/hex"
 	"errors"
+	"strings"
 	"time"
 
 	"github.com/lib/pq"
@@ -23,15 +24,84 @@ import (
 // - `Email` (string): The user's email address.
 // - `Password` (string): The user's hashed password (excluded from JSON serialization).
 // - `CreatedAt` (string): Timestamp indicating when the user was created.
+// - `DisplayName`, `Bio`, `Location`, `Website`, `GitHub`: Optional profile fields.
+// - `AvatarKey`, `AvatarThumbnailKey` (*string): Blob keys of the avatar, nil without one.
+// - `IsPrivate` (bool): Follows need approval and posts are only visible to followers.
+// - `DeletionScheduledAt` (*string): When the account will be deleted, nil unless the user asked for it.
+//
+// The JSON form is what the users cache stores, API responses use `PublicProfile` or `PrivateProfile`.
 type User struct {
-	ID        int64    `json:"id"`
-	Username  string   `json:"username"`
-	Email     string   `json:"email"`
-	Password  Password `json:"password"`
-	CreatedAt string   `json:"created_at"`
-	IsActive  bool     `json:"is_active"`
-	RoleId    int64    `json:"role_id"`
-	Role      Role     `json:"role"`
+	ID                  int64    `json:"id"`
+	Username            string   `json:"username"`
+	Email               string   `json:"email"`
+	Password            Password `json:"-"`
+	CreatedAt           string   `json:"created_at"`
+	IsActive            bool     `json:"is_active"`
+	RoleId              int64    `json:"role_id"`
+	Role                Role     `json:"role"`
+	DisplayName         string   `json:"display_name"`
+	Bio                 string   `json:"bio"`
+	Location            string   `json:"location"`
+	Website             string   `json:"website"`
+	GitHub              string   `json:"github"`
+	AvatarKey           *string  `json:"avatar_key"`
+	AvatarThumbnailKey  *string  `json:"avatar_thumbnail_key"`
+	IsPrivate           bool     `json:"is_private"`
+	DeletionScheduledAt *string  `json:"deletion_scheduled_at"`
+}
+
+// `Author` is the user shown next to a post or comment
+type Author struct {
+	ID       int64  `json:"id"`
+	Username string `json:"username"`
+}
+
+// `PublicProfile` is what anyone can see about a user
+type PublicProfile struct {
+	ID                 int64  `json:"id"`
+	Username           string `json:"username"`
+	DisplayName        string `json:"display_name"`
+	Bio                string `json:"bio"`
+	Location           string `json:"location"`
+	Website            string `json:"website"`
+	GitHub             string `json:"github"`
+	IsPrivate          bool   `json:"is_private"`
+	AvatarURL          string `json:"avatar_url,omitempty"`
+	AvatarThumbnailURL string `json:"avatar_thumbnail_url,omitempty"`
+	CreatedAt          string `json:"created_at"`
+	FollowCounts
+}
+
+// `PrivateProfile` is the profile of the authenticated user, with the fields only they can see
+type PrivateProfile struct {
+	PublicProfile
+	Email               string  `json:"email"`
+	Role                string  `json:"role"`
+	DeletionScheduledAt *string `json:"deletion_scheduled_at,omitempty"`
+}
+
+// `PublicProfile` projects the user to the fields anyone can see, the API fills in the avatar URLs
+func (u *User) PublicProfile() PublicProfile {
+	return PublicProfile{
+		ID:          u.ID,
+		Username:    u.Username,
+		DisplayName: u.DisplayName,
+		Bio:         u.Bio,
+		Location:    u.Location,
+		Website:     u.Website,
+		GitHub:      u.GitHub,
+		IsPrivate:   u.IsPrivate,
+		CreatedAt:   u.CreatedAt,
+	}
+}
+
+func (u *User) PrivateProfile() PrivateProfile {
+	return PrivateProfile{
+		PublicProfile:       u.PublicProfile(),
+		Email:               u.Email,
+		Role:                u.Role.Name,
+		DeletionScheduledAt: u.DeletionScheduledAt,
+	}
 }
 
 type Follower struct {
@@ -85,8 +155,18 @@ func (s *UsersRepositoryPostgres) Create(ctx context.Context, tx *sql.Tx, user *
 
 	ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
 	defer cancel()
+
+	// usernames given up recently stay reserved for their previous owner
+	reserved, err := usernameReserved(ctx, tx, user.Username, 0)
+	if err != nil {
+		return err
+	}
+	if reserved {
+		return ErrDuplicateUsername
+	}
+
 	// Execute the query and scan the returned values into the `user` struct.
-	err := tx.QueryRowContext(
+	err = tx.QueryRowContext(
 		ctx,
 		query,
 		user.Username,      // Insert username
@@ -145,7 +225,9 @@ func (s *UsersRepositoryPostgres) createUserInvitation(ctx context.Context, tx *
 
 func (s *UsersRepositoryPostgres) GetById(ctx context.Context, id int64) (*User, error) {
 	query := `
-		SELECT users.id, email, username, password, created_at, roles.*
+		SELECT users.id, email, username, password, created_at,
+			display_name, bio, location, website, github, avatar_key, avatar_thumbnail_key, is_private,
+			deletion_scheduled_at, roles.*
 		FROM users
 		JOIN roles
 		ON users.role_id = roles.id
@@ -161,6 +243,15 @@ func (s *UsersRepositoryPostgres) GetById(ctx context.Context, id int64) (*User,
 		&user.Username,
 		&user.Password.hash,
 		&user.CreatedAt,
+		&user.DisplayName,
+		&user.Bio,
+		&user.Location,
+		&user.Website,
+		&user.GitHub,
+		&user.AvatarKey,
+		&user.AvatarThumbnailKey,
+		&user.IsPrivate,
+		&user.DeletionScheduledAt,
 		&user.Role.ID,
 		&user.Role.Name,
 		&user.Role.Level,
@@ -304,6 +395,60 @@ func (s *UsersRepositoryPostgres) update(ctx context.Context, tx *sql.Tx, user *
 	return nil
 }
 
+// `UpdateProfile` saves the profile fields, avatar keys and privacy of a user.
+// Making an account public approves its pending follow requests.
+func (s *UsersRepositoryPostgres) UpdateProfile(ctx context.Context, user *User) error {
+	query := `
+		UPDATE users
+		SET display_name = $1, bio = $2, location = $3, website = $4, github = $5,
+			avatar_key = $6, avatar_thumbnail_key = $7, is_private = $8
+		WHERE id = $9 AND is_active = true
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
+	defer cancel()
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(
+			ctx,
+			query,
+			user.DisplayName,
+			user.Bio,
+			user.Location,
+			user.Website,
+			user.GitHub,
+			user.AvatarKey,
+			user.AvatarThumbnailKey,
+			user.IsPrivate,
+			user.ID,
+		)
+		if err != nil {
+			return err
+		}
+
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return ErrNotFound
+		}
+
+		if user.IsPrivate {
+			return nil
+		}
+
+		approve := `
+			WITH pending AS (DELETE FROM follow_requests WHERE user_id = $1 RETURNING user_id, follower_id, created_at)
+			INSERT INTO followers (user_id, follower_id, created_at)
+			SELECT user_id, follower_id, created_at FROM pending
+			ON CONFLICT DO NOTHING
+		`
+		_, err = tx.ExecContext(ctx, approve, user.ID)
+		return err
+	})
+}
+
 func (s *UsersRepositoryPostgres) deleteInvite(ctx context.Context, tx *sql.Tx, userId int64) error {
 	query := `
 	DELETE FROM user_invitations WHERE user_id = $1
@@ -326,17 +471,63 @@ func (s *UsersRepositoryPostgres) deleteUser(ctx context.Context, tx *sql.Tx, id
 	ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
 	defer cancel()
 
-	_, err := tx.ExecContext(ctx, query, id)
-	switch {
-	case errors.Is(err, sql.ErrNoRows):
-		return ErrNotFound
-	default:
+	res, err := tx.ExecContext(ctx, query, id)
+	if err != nil {
 		return err
 	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
 }
 
-func (s *UsersRepositoryPostgres) Delete(ctx context.Context, id int64) error {
-	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+// `deletePosts` removes every post of a user, trashed or not, and returns their ids.
+// Comments, snippets and mentions go with them through `ON DELETE CASCADE`, attachments
+// are detached for the media purge job to remove their files.
+func (s *UsersRepositoryPostgres) deletePosts(ctx context.Context, tx *sql.Tx, userId int64) ([]int64, error) {
+	query := `
+		DELETE FROM posts WHERE user_id = $1 RETURNING id
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
+	defer cancel()
+
+	rows, err := tx.QueryContext(ctx, query, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	postIds := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		postIds = append(postIds, id)
+	}
+
+	return postIds, rows.Err()
+}
+
+// `Delete` removes a user with their posts, and through `ON DELETE CASCADE` their comments,
+// mentions, follows, follow requests, blocks, mutes and tag follows. Attachments and data
+// exports are detached for jobs to remove their files.
+// Returns the ids of the removed posts, so they can be dropped from the search index.
+func (s *UsersRepositoryPostgres) Delete(ctx context.Context, id int64) ([]int64, error) {
+	var postIds []int64
+
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		var err error
+		if postIds, err = s.deletePosts(ctx, tx, id); err != nil {
+			return err
+		}
+
 		if err := s.deleteUser(ctx, tx, id); err != nil {
 			return err
 		}
@@ -347,6 +538,209 @@ func (s *UsersRepositoryPostgres) Delete(ctx context.Context, id int64) error {
 
 		return nil
 	})
+	if err != nil {
+		return nil, err
+	}
+
+	return postIds, nil
+}
+
+// `ScheduleDeletion` marks an account for deletion at `at`, rescheduling keeps the earliest date
+func (s *UsersRepositoryPostgres) ScheduleDeletion(ctx context.Context, id int64, at time.Time) (string, error) {
+	query := `
+		UPDATE users SET deletion_scheduled_at = LEAST(COALESCE(deletion_scheduled_at, $2), $2)
+		WHERE id = $1 AND is_active = true
+		RETURNING deletion_scheduled_at
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
+	defer cancel()
+
+	var scheduledAt string
+	err := s.db.QueryRowContext(ctx, query, id, at).Scan(&scheduledAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return "", ErrNotFound
+		default:
+			return "", err
+		}
+	}
+
+	return scheduledAt, nil
+}
+
+// `CancelDeletion` unschedules the deletion of an account, `ErrNotFound` if none was scheduled
+func (s *UsersRepositoryPostgres) CancelDeletion(ctx context.Context, id int64) error {
+	query := `
+		UPDATE users SET deletion_scheduled_at = NULL WHERE id = $1 AND deletion_scheduled_at IS NOT NULL
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// `GetDueDeletions` lists up to `limit` users whose deletion grace period is over
+func (s *UsersRepositoryPostgres) GetDueDeletions(ctx context.Context, limit int) ([]*User, error) {
+	query := `
+		SELECT id, username, avatar_key, avatar_thumbnail_key FROM users
+		WHERE deletion_scheduled_at IS NOT NULL AND deletion_scheduled_at <= NOW()
+		ORDER BY deletion_scheduled_at
+		LIMIT $1
+	`
+	ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []*User{}
+	for rows.Next() {
+		var user User
+		if err := rows.Scan(&user.ID, &user.Username, &user.AvatarKey, &user.AvatarThumbnailKey); err != nil {
+			return nil, err
+		}
+		users = append(users, &user)
+	}
+
+	return users, rows.Err()
+}
+
+// `ChangeUsername` renames a user, at most once per `cooldown`. The old username is kept in
+// `username_history` for `reservation`, nobody else can take it and it keeps resolving to the user.
+//
+// Returns `ErrUsernameChangeTooSoon` within the cooldown and `ErrDuplicateUsername` when the
+// username is taken or reserved for someone else.
+func (s *UsersRepositoryPostgres) ChangeUsername(ctx context.Context, userId int64, username string, cooldown time.Duration, reservation time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
+	defer cancel()
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		var (
+			current   string
+			changedAt *time.Time
+		)
+		err := tx.QueryRowContext(
+			ctx,
+			`SELECT username, username_changed_at FROM users WHERE id = $1 AND is_active = true FOR UPDATE`,
+			userId,
+		).Scan(&current, &changedAt)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		if current == username {
+			return nil
+		}
+		if changedAt != nil && time.Since(*changedAt) < cooldown {
+			return ErrUsernameChangeTooSoon
+		}
+
+		reserved, err := usernameReserved(ctx, tx, username, userId)
+		if err != nil {
+			return err
+		}
+		if reserved {
+			return ErrDuplicateUsername
+		}
+
+		// taking back one of your own old usernames, or an expired one, ends its reservation.
+		// Changing the case only keeps the history as it is.
+		if _, err := tx.ExecContext(ctx, `DELETE FROM username_history WHERE username = $1`, username); err != nil {
+			return err
+		}
+
+		if !strings.EqualFold(current, username) {
+			query := `
+				INSERT INTO username_history (username, user_id, changed_at, reserved_until)
+				VALUES ($1, $2, NOW(), $3)
+				ON CONFLICT (username) DO UPDATE
+				SET user_id = EXCLUDED.user_id, changed_at = EXCLUDED.changed_at, reserved_until = EXCLUDED.reserved_until
+			`
+			if _, err := tx.ExecContext(ctx, query, current, userId, time.Now().Add(reservation)); err != nil {
+				return err
+			}
+		}
+
+		_, err = tx.ExecContext(
+			ctx,
+			`UPDATE users SET username = $2, username_changed_at = NOW() WHERE id = $1`,
+			userId,
+			username,
+		)
+		if err != nil {
+			var pqErr *pq.Error
+			if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+				return ErrDuplicateUsername
+			}
+			return err
+		}
+
+		return nil
+	})
+}
+
+// `usernameReserved` reports whether `username` was recently given up by a user other than `userId`
+func usernameReserved(ctx context.Context, tx *sql.Tx, username string, userId int64) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM username_history WHERE username = $1 AND user_id <> $2 AND reserved_until > NOW()
+		)
+	`
+
+	var reserved bool
+	err := tx.QueryRowContext(ctx, query, username, userId).Scan(&reserved)
+	return reserved, err
+}
+
+// `ResolveUsername` returns the id of the active user with `username`, or of the user who
+// gave it up while it is still reserved for them
+func (s *UsersRepositoryPostgres) ResolveUsername(ctx context.Context, username string) (int64, error) {
+	query := `
+		SELECT id FROM (
+			SELECT id, 0 AS rank FROM users WHERE username = $1 AND is_active = true
+			UNION ALL
+			SELECT user_id, 1 FROM username_history WHERE username = $1 AND reserved_until > NOW()
+		) matches
+		ORDER BY rank
+		LIMIT 1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryContextTimeoutDuration)
+	defer cancel()
+
+	var id int64
+	if err := s.db.QueryRowContext(ctx, query, username).Scan(&id); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, ErrNotFound
+		default:
+			return 0, err
+		}
+	}
+
+	return id, nil
 }
 
 func (s *UsersRepositoryPostgres) GetByEmail(ctx context.Context, email string) (*User, error) {
@@ -0,0 +1,112 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// `Local` is a `Broker` for a single instance, events and replay buffers live in memory
+type Local struct {
+	hub    *hub
+	config Config
+
+	mu        sync.Mutex
+	lastId    int64
+	buffers   map[int64]*replayBuffer
+	lastSweep time.Time
+}
+
+type replayBuffer struct {
+	events  []Event
+	updated time.Time
+}
+
+func NewLocal(config Config) *Local {
+	return &Local{
+		hub:    newHub(config.BufferSize),
+		config: config,
+		// ids keep increasing across restarts, so clients resuming with an old id aren't ahead
+		lastId:    time.Now().UnixNano(),
+		buffers:   make(map[int64]*replayBuffer),
+		lastSweep: time.Now(),
+	}
+}
+
+func (l *Local) Publish(ctx context.Context, userIds []int64, eventType string, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	l.lastId++
+	event := Event{ID: l.lastId, Type: eventType, Data: payload}
+
+	if l.config.ReplaySize > 0 {
+		l.remember(userIds, event)
+	}
+	l.mu.Unlock()
+
+	for _, userId := range userIds {
+		l.hub.deliver(userId, event)
+	}
+
+	return nil
+}
+
+func (l *Local) Subscribe(ctx context.Context, userId int64, lastEventId int64) (<-chan Event, error) {
+	return l.hub.subscribe(ctx, userId, func() ([]Event, error) {
+		if lastEventId == 0 {
+			return nil, nil
+		}
+
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		buffer := l.buffers[userId]
+		if buffer == nil || time.Since(buffer.updated) > l.config.ReplayTTL {
+			return nil, nil
+		}
+		return after(buffer.events, lastEventId), nil
+	})
+}
+
+func (l *Local) Close() error {
+	l.hub.close()
+	return nil
+}
+
+// `remember` adds `event` to the replay buffers of `userIds`, `l.mu` must be held
+func (l *Local) remember(userIds []int64, event Event) {
+	now := time.Now()
+	l.sweep(now)
+
+	for _, userId := range userIds {
+		buffer := l.buffers[userId]
+		if buffer == nil {
+			buffer = &replayBuffer{}
+			l.buffers[userId] = buffer
+		}
+		buffer.events = append(buffer.events, event)
+		if len(buffer.events) > l.config.ReplaySize {
+			buffer.events = buffer.events[len(buffer.events)-l.config.ReplaySize:]
+		}
+		buffer.updated = now
+	}
+}
+
+// `sweep` drops the buffers that expired, at most once per TTL
+func (l *Local) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.config.ReplayTTL {
+		return
+	}
+
+	for userId, buffer := range l.buffers {
+		if now.Sub(buffer.updated) > l.config.ReplayTTL {
+			delete(l.buffers, userId)
+		}
+	}
+	l.lastSweep = now
+}
@@ -0,0 +1,159 @@
+package stream
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func newTestLocal() *Local {
+	return NewLocal(Config{ReplaySize: 3, ReplayTTL: time.Minute, BufferSize: 4})
+}
+
+func receive(t *testing.T, events <-chan Event) Event {
+	t.Helper()
+
+	select {
+	case event, ok := <-events:
+		if !ok {
+			t.Fatal("subscription closed")
+		}
+		return event
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for an event")
+	}
+	return Event{}
+}
+
+func TestLocalDelivers(t *testing.T) {
+	broker := newTestLocal()
+	defer broker.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, err := broker.Subscribe(ctx, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := broker.Subscribe(ctx, 2, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := broker.Publish(ctx, []int64{1}, EventPost, map[string]int64{"post_id": 7}); err != nil {
+		t.Fatal(err)
+	}
+
+	event := receive(t, events)
+	if event.Type != EventPost || string(event.Data) != `{"post_id":7}` {
+		t.Errorf("unexpected event %+v", event)
+	}
+
+	select {
+	case event := <-other:
+		t.Errorf("user 2 got an event for user 1: %+v", event)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestLocalResumes(t *testing.T) {
+	broker := newTestLocal()
+	defer broker.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ids := []int64{}
+	for i := range 5 {
+		if err := broker.Publish(ctx, []int64{1}, EventComment, i); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, broker.lastId)
+	}
+
+	// the buffer keeps the last 3 events
+	events, err := broker.Subscribe(ctx, 1, ids[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range ids[2:] {
+		if got := receive(t, events); got.ID != want {
+			t.Errorf("expected event %d, got %d", want, got.ID)
+		}
+	}
+
+	if err := broker.Publish(ctx, []int64{1}, EventComment, 5); err != nil {
+		t.Fatal(err)
+	}
+	if got := receive(t, events); got.ID != broker.lastId {
+		t.Errorf("expected the live event after the replayed ones, got %d", got.ID)
+	}
+}
+
+func TestLocalIdsIncreaseAcrossRestarts(t *testing.T) {
+	first := newTestLocal()
+	if err := first.Publish(context.Background(), []int64{1}, EventPost, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if second := newTestLocal(); second.lastId <= first.lastId {
+		t.Errorf("ids went back from %d to %d", first.lastId, second.lastId)
+	}
+}
+
+func TestLocalDropsSlowSubscribers(t *testing.T) {
+	broker := newTestLocal()
+	defer broker.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, err := broker.Subscribe(ctx, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// one event waits in the subscription, the buffer holds 4 more
+	for i := range 10 {
+		if err := broker.Publish(ctx, []int64{1}, EventPost, i); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deadline := time.After(time.Second)
+	for {
+		select {
+		case _, ok := <-events:
+			if !ok {
+				return
+			}
+		case <-deadline:
+			t.Fatal("a slow subscription wasn't dropped")
+		}
+	}
+}
+
+func TestLocalClose(t *testing.T) {
+	broker := newTestLocal()
+
+	events, err := broker.Subscribe(context.Background(), 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	broker.Close()
+
+	select {
+	case _, ok := <-events:
+		if ok {
+			t.Error("expected the subscription to end")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("subscription wasn't closed")
+	}
+
+	if _, err := broker.Subscribe(context.Background(), 1, 0); err != ErrClosed {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+}
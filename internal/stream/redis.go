@@ -0,0 +1,138 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// ids are shared by every broker, so they stay ordered whichever broker an event went through
+const redisLastIdKey = "stream-event-id"
+
+// `Redis` is a `Broker` for several instances. Events are published on one Redis channel every
+// instance listens to, each instance delivers them to its own subscriptions. Replay buffers
+// are capped Redis lists.
+type Redis struct {
+	hub     *hub
+	rdb     *redis.Client
+	pubsub  *redis.PubSub
+	config  Config
+	name    string
+	channel string
+	logf    func(format string, args ...any)
+}
+
+// `redisMessage` is an event on the Redis channel
+type redisMessage struct {
+	UserIds []int64 `json:"user_ids"`
+	Event   Event   `json:"event"`
+}
+
+// `NewRedis` subscribes to the Redis channel of the broker `name` and delivers events until
+// the broker is closed. Brokers with different names don't see each other's events.
+// Undecodable messages are reported to `logf`.
+func NewRedis(rdb *redis.Client, name string, config Config, logf func(format string, args ...any)) *Redis {
+	channel := fmt.Sprintf("stream-%s", name)
+	r := &Redis{
+		hub:     newHub(config.BufferSize),
+		rdb:     rdb,
+		pubsub:  rdb.Subscribe(context.Background(), channel),
+		config:  config,
+		name:    name,
+		channel: channel,
+		logf:    logf,
+	}
+	go r.listen()
+
+	return r
+}
+
+func (r *Redis) listen() {
+	for msg := range r.pubsub.Channel() {
+		var message redisMessage
+		if err := json.Unmarshal([]byte(msg.Payload), &message); err != nil {
+			r.logf("stream: invalid message on %s: %v", r.channel, err)
+			continue
+		}
+
+		for _, userId := range message.UserIds {
+			r.hub.deliver(userId, message.Event)
+		}
+	}
+}
+
+func (r *Redis) Publish(ctx context.Context, userIds []int64, eventType string, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	id, err := r.rdb.Incr(ctx, redisLastIdKey).Result()
+	if err != nil {
+		return err
+	}
+
+	event := Event{ID: id, Type: eventType, Data: payload}
+	eventJson, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	if r.config.ReplaySize > 0 {
+		_, err = r.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			for _, userId := range userIds {
+				key := r.replayKey(userId)
+				pipe.RPush(ctx, key, eventJson)
+				pipe.LTrim(ctx, key, int64(-r.config.ReplaySize), -1)
+				pipe.Expire(ctx, key, r.config.ReplayTTL)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	message, err := json.Marshal(redisMessage{UserIds: userIds, Event: event})
+	if err != nil {
+		return err
+	}
+
+	return r.rdb.Publish(ctx, r.channel, message).Err()
+}
+
+func (r *Redis) Subscribe(ctx context.Context, userId int64, lastEventId int64) (<-chan Event, error) {
+	return r.hub.subscribe(ctx, userId, func() ([]Event, error) {
+		if lastEventId == 0 || r.config.ReplaySize == 0 {
+			return nil, nil
+		}
+
+		entries, err := r.rdb.LRange(ctx, r.replayKey(userId), 0, -1).Result()
+		if err != nil {
+			return nil, err
+		}
+
+		buffer := make([]Event, 0, len(entries))
+		for _, entry := range entries {
+			var event Event
+			if err := json.Unmarshal([]byte(entry), &event); err != nil {
+				return nil, err
+			}
+			buffer = append(buffer, event)
+		}
+
+		return after(buffer, lastEventId), nil
+	})
+}
+
+func (r *Redis) Close() error {
+	err := r.pubsub.Close()
+	r.hub.close()
+	return err
+}
+
+func (r *Redis) replayKey(userId int64) string {
+	return fmt.Sprintf("stream-%s-replay-%v", r.name, userId)
+}
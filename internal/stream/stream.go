@@ -0,0 +1,196 @@
+// Package stream delivers live events to the users they concern, for Server-Sent Events.
+//
+// A `Broker` fans events out to the open subscriptions of each recipient and keeps the latest
+// events of every user for a short while, so a client that reconnects with the id of the last
+// event it got receives what it missed. `Local` does it in memory for a single instance,
+// `Redis` shares events and replay buffers between instances through Redis pub/sub.
+//
+// Delivery is best effort: a subscriber that falls too far behind is dropped and resumes
+// from the replay buffer when it reconnects.
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Event types
+const (
+	EventPost         = "post"         // a user the recipient follows published a post
+	EventComment      = "comment"      // someone commented on a post of the recipient
+	EventNotification = "notification" // something happened the recipient should be told about
+
+	// events of comment threads, their recipients are posts rather than users
+	EventCommentCreated = "comment.created"
+	EventCommentUpdated = "comment.updated"
+	EventCommentDeleted = "comment.deleted"
+)
+
+var ErrClosed = errors.New("stream: broker closed")
+
+// `Event` is a message for a user, ids increase in the order events are published
+type Event struct {
+	ID   int64           `json:"id"`
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+// `Config` holds settings shared by the brokers
+type Config struct {
+	ReplaySize int           // events kept per user for clients resuming with Last-Event-ID, 0 disables replay
+	ReplayTTL  time.Duration // replay buffers are dropped when they got no event for this long
+	BufferSize int           // events queued per subscription before it's dropped as too slow
+}
+
+type Broker interface {
+	// `Publish` sends an event of `eventType` with `data` as JSON to every user in `userIds`
+	Publish(ctx context.Context, userIds []int64, eventType string, data any) error
+	// `Subscribe` streams the events of `userId`, starting with the buffered ones after
+	// `lastEventId` (0 for none). The channel is closed once `ctx` is done, the subscription
+	// is dropped as too slow or the broker is closed.
+	Subscribe(ctx context.Context, userId int64, lastEventId int64) (<-chan Event, error)
+	// `Close` ends every subscription
+	Close() error
+}
+
+// `hub` fans events out to the subscriptions of this instance
+type hub struct {
+	mu          sync.Mutex
+	subscribers map[int64]map[chan Event]struct{}
+	bufferSize  int
+	closed      bool
+}
+
+func newHub(bufferSize int) *hub {
+	return &hub{subscribers: make(map[int64]map[chan Event]struct{}), bufferSize: bufferSize}
+}
+
+func (h *hub) add(userId int64) (chan Event, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return nil, ErrClosed
+	}
+
+	events := make(chan Event, h.bufferSize)
+	if h.subscribers[userId] == nil {
+		h.subscribers[userId] = make(map[chan Event]struct{})
+	}
+	h.subscribers[userId][events] = struct{}{}
+
+	return events, nil
+}
+
+func (h *hub) remove(userId int64, events chan Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.removeLocked(userId, events)
+}
+
+func (h *hub) removeLocked(userId int64, events chan Event) {
+	if _, ok := h.subscribers[userId][events]; !ok {
+		return
+	}
+
+	delete(h.subscribers[userId], events)
+	if len(h.subscribers[userId]) == 0 {
+		delete(h.subscribers, userId)
+	}
+	close(events)
+}
+
+func (h *hub) deliver(userId int64, event Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for events := range h.subscribers[userId] {
+		select {
+		case events <- event:
+		default:
+			// blocking would hold up every other subscriber
+			h.removeLocked(userId, events)
+		}
+	}
+}
+
+func (h *hub) close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for userId, subscribers := range h.subscribers {
+		for events := range subscribers {
+			h.removeLocked(userId, events)
+		}
+	}
+}
+
+// `subscribe` registers a subscription of `userId` and streams what `replay` returns before the
+// live events. The subscription is registered first so nothing published meanwhile is lost,
+// live events that were replayed are skipped.
+func (h *hub) subscribe(ctx context.Context, userId int64, replay func() ([]Event, error)) (<-chan Event, error) {
+	live, err := h.add(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	missed, err := replay()
+	if err != nil {
+		h.remove(userId, live)
+		return nil, err
+	}
+
+	out := make(chan Event)
+	go func() {
+		defer close(out)
+		defer h.remove(userId, live)
+
+		replayed := make(map[int64]bool, len(missed))
+		for _, event := range missed {
+			select {
+			case out <- event:
+				replayed[event.ID] = true
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		for {
+			select {
+			case event, ok := <-live:
+				if !ok {
+					return
+				}
+				if replayed[event.ID] {
+					continue
+				}
+				select {
+				case out <- event:
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+// `after` returns the events of `buffer` with an id above `lastEventId`
+func after(buffer []Event, lastEventId int64) []Event {
+	missed := []Event{}
+	for _, event := range buffer {
+		if event.ID > lastEventId {
+			missed = append(missed, event)
+		}
+	}
+
+	return missed
+}
@@ -0,0 +1,214 @@
+// Package syndication renders posts as Atom 1.0 (RFC 4287) and RSS 2.0 documents for feed readers.
+//
+// Documents are built with encoding/xml, so titles and post content are escaped, and characters
+// XML can't carry are replaced. Post HTML is embedded as escaped text, never as markup.
+package syndication
+
+import (
+	"encoding/xml"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/elhambadri2411/social/internal/store"
+)
+
+const (
+	AtomContentType = "application/atom+xml; charset=utf-8"
+	RSSContentType  = "application/rss+xml; charset=utf-8"
+)
+
+// `Feed` describes the document posts are rendered in
+type Feed struct {
+	ID      string // permanent URI of the feed, the Atom id
+	Title   string
+	Link    string // page of the feed on the website
+	Self    string // URL the document is served from
+	BaseURL string // posts link to `BaseURL/posts/{id}`
+}
+
+// `Updated` is when the latest of `posts` was last changed, zero without posts
+func Updated(posts []*store.Post) time.Time {
+	var updated time.Time
+	for _, post := range posts {
+		if t := postUpdated(post); t.After(updated) {
+			updated = t
+		}
+	}
+
+	return updated
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomEntry struct {
+	ID         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Published  string         `xml:"published"`
+	Updated    string         `xml:"updated"`
+	Link       atomLink       `xml:"link"`
+	Author     atomAuthor     `xml:"author"`
+	Categories []atomCategory `xml:"category"`
+	Content    atomContent    `xml:"content"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+// `Atom` renders `posts` as an Atom 1.0 feed
+func Atom(feed Feed, posts []*store.Post) ([]byte, error) {
+	doc := atomFeed{
+		ID:      feed.ID,
+		Title:   feed.Title,
+		Updated: Updated(posts).UTC().Format(time.RFC3339),
+		Links: []atomLink{
+			{Href: feed.Self, Rel: "self", Type: "application/atom+xml"},
+			{Href: feed.Link, Rel: "alternate", Type: "text/html"},
+		},
+		Entries: make([]atomEntry, 0, len(posts)),
+	}
+
+	for _, post := range posts {
+		entry := atomEntry{
+			ID:        feed.postURL(post),
+			Title:     post.Title,
+			Published: postCreated(post).UTC().Format(time.RFC3339),
+			Updated:   postUpdated(post).UTC().Format(time.RFC3339),
+			Link:      atomLink{Href: feed.postURL(post), Rel: "alternate", Type: "text/html"},
+			Author:    atomAuthor{Name: post.User.Username},
+			Content:   atomContent{Type: "html", Body: post.ContentHTML},
+		}
+		// posts written before content was rendered only have the source
+		if post.ContentHTML == "" {
+			entry.Content = atomContent{Type: "text", Body: post.Content}
+		}
+		for _, tag := range post.Tags {
+			entry.Categories = append(entry.Categories, atomCategory{Term: tag})
+		}
+
+		doc.Entries = append(doc.Entries, entry)
+	}
+
+	return marshal(doc)
+}
+
+type rssDocument struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	AtomNS  string     `xml:"xmlns:atom,attr"`
+	DCNS    string     `xml:"xmlns:dc,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	AtomLink      atomLink  `xml:"atom:link"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	GUID        rssGUID  `xml:"guid"`
+	Author      string   `xml:"dc:creator"`
+	PubDate     string   `xml:"pubDate"`
+	Categories  []string `xml:"category"`
+	Description string   `xml:"description"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+// `RSS` renders `posts` as an RSS 2.0 feed. RSS authors must be email addresses, so the author
+// is given as `dc:creator` instead.
+func RSS(feed Feed, posts []*store.Post) ([]byte, error) {
+	doc := rssDocument{
+		Version: "2.0",
+		AtomNS:  "http://www.w3.org/2005/Atom",
+		DCNS:    "http://purl.org/dc/elements/1.1/",
+		Channel: rssChannel{
+			Title:       feed.Title,
+			Link:        feed.Link,
+			Description: feed.Title,
+			AtomLink:    atomLink{Href: feed.Self, Rel: "self", Type: "application/rss+xml"},
+			Items:       make([]rssItem, 0, len(posts)),
+		},
+	}
+	if updated := Updated(posts); !updated.IsZero() {
+		doc.Channel.LastBuildDate = updated.UTC().Format(time.RFC1123Z)
+	}
+
+	for _, post := range posts {
+		description := post.ContentHTML
+		if description == "" {
+			description = post.Content
+		}
+
+		doc.Channel.Items = append(doc.Channel.Items, rssItem{
+			Title:       post.Title,
+			Link:        feed.postURL(post),
+			GUID:        rssGUID{IsPermaLink: true, Value: feed.postURL(post)},
+			Author:      post.User.Username,
+			PubDate:     postCreated(post).UTC().Format(time.RFC1123Z),
+			Categories:  post.Tags,
+			Description: description,
+		})
+	}
+
+	return marshal(doc)
+}
+
+func (f Feed) postURL(post *store.Post) string {
+	return strings.TrimSuffix(f.BaseURL, "/") + "/posts/" + strconv.FormatInt(post.ID, 10)
+}
+
+func marshal(doc any) ([]byte, error) {
+	out, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte(xml.Header), out...), nil
+}
+
+func postCreated(post *store.Post) time.Time {
+	created, _ := time.Parse(time.RFC3339Nano, post.CreatedAt)
+	return created
+}
+
+func postUpdated(post *store.Post) time.Time {
+	updated, err := time.Parse(time.RFC3339Nano, post.UpdatedAt)
+	if err != nil {
+		return postCreated(post)
+	}
+
+	return updated
+}
@@ -0,0 +1,149 @@
+package syndication
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/elhambadri2411/social/internal/store"
+)
+
+var testFeed = Feed{
+	ID:      "https://social.test/users/gopher",
+	Title:   "Posts by gopher",
+	Link:    "https://social.test/users/gopher",
+	Self:    "https://api.social.test/v1/users/gopher/feed.atom",
+	BaseURL: "https://social.test/",
+}
+
+func testPosts() []*store.Post {
+	return []*store.Post{
+		{
+			ID:          2,
+			Title:       `Generics & <interfaces> "quoted"`,
+			ContentHTML: "<p>a &amp; b</p><script>alert(1)</script>]]>\x01",
+			Tags:        []string{"go", "c++"},
+			CreatedAt:   "2025-09-02T10:00:00.123456Z",
+			UpdatedAt:   "2025-09-03T08:30:00Z",
+			User:        store.Author{ID: 1, Username: "gopher"},
+		},
+		{
+			ID:        1,
+			Title:     "First",
+			Content:   "plain <text>",
+			CreatedAt: "2025-09-01T10:00:00Z",
+			UpdatedAt: "2025-09-01T10:00:00Z",
+			User:      store.Author{ID: 1, Username: "gopher"},
+		},
+	}
+}
+
+func TestAtom(t *testing.T) {
+	out, err := Atom(testFeed, testPosts())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var feed struct {
+		XMLName xml.Name `xml:"http://www.w3.org/2005/Atom feed"`
+		Updated string   `xml:"updated"`
+		Entries []struct {
+			ID      string `xml:"id"`
+			Title   string `xml:"title"`
+			Updated string `xml:"updated"`
+			Content struct {
+				Type string `xml:"type,attr"`
+				Body string `xml:",chardata"`
+			} `xml:"content"`
+			Categories []struct {
+				Term string `xml:"term,attr"`
+			} `xml:"category"`
+		} `xml:"entry"`
+	}
+	if err := xml.Unmarshal(out, &feed); err != nil {
+		t.Fatalf("invalid XML: %v\n%s", err, out)
+	}
+
+	if feed.Updated != "2025-09-03T08:30:00Z" {
+		t.Errorf("expected the feed to be updated with its latest post, got %q", feed.Updated)
+	}
+	if len(feed.Entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(feed.Entries))
+	}
+
+	entry := feed.Entries[0]
+	if entry.ID != "https://social.test/posts/2" {
+		t.Errorf("unexpected entry id %q", entry.ID)
+	}
+	if entry.Title != `Generics & <interfaces> "quoted"` {
+		t.Errorf("title didn't round trip, got %q", entry.Title)
+	}
+	if entry.Content.Type != "html" || entry.Content.Body != "<p>a &amp; b</p><script>alert(1)</script>]]>�" {
+		t.Errorf("content didn't round trip as escaped html, got %s %q", entry.Content.Type, entry.Content.Body)
+	}
+	if len(entry.Categories) != 2 || entry.Categories[1].Term != "c++" {
+		t.Errorf("unexpected categories %+v", entry.Categories)
+	}
+	if feed.Entries[1].Content.Type != "text" || feed.Entries[1].Content.Body != "plain <text>" {
+		t.Errorf("expected the source of unrendered posts, got %+v", feed.Entries[1].Content)
+	}
+
+	if strings.Contains(string(out), "<script>") {
+		t.Error("post html must not be embedded as markup")
+	}
+}
+
+func TestRSS(t *testing.T) {
+	out, err := RSS(testFeed, testPosts())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var rss struct {
+		XMLName xml.Name `xml:"rss"`
+		Version string   `xml:"version,attr"`
+		Channel struct {
+			LastBuildDate string `xml:"lastBuildDate"`
+			Items         []struct {
+				Title       string `xml:"title"`
+				GUID        string `xml:"guid"`
+				Creator     string `xml:"http://purl.org/dc/elements/1.1/ creator"`
+				PubDate     string `xml:"pubDate"`
+				Description string `xml:"description"`
+			} `xml:"item"`
+		} `xml:"channel"`
+	}
+	if err := xml.Unmarshal(out, &rss); err != nil {
+		t.Fatalf("invalid XML: %v\n%s", err, out)
+	}
+
+	if rss.Version != "2.0" || len(rss.Channel.Items) != 2 {
+		t.Fatalf("unexpected document\n%s", out)
+	}
+
+	item := rss.Channel.Items[0]
+	if item.GUID != "https://social.test/posts/2" || item.Creator != "gopher" {
+		t.Errorf("unexpected item %+v", item)
+	}
+	if _, err := time.Parse(time.RFC1123Z, item.PubDate); err != nil {
+		t.Errorf("pubDate isn't an RFC 822 date: %v", err)
+	}
+	if rss.Channel.LastBuildDate != "Wed, 03 Sep 2025 08:30:00 +0000" {
+		t.Errorf("unexpected lastBuildDate %q", rss.Channel.LastBuildDate)
+	}
+	if item.Description != "<p>a &amp; b</p><script>alert(1)</script>]]>�" {
+		t.Errorf("description didn't round trip, got %q", item.Description)
+	}
+}
+
+func TestUpdated(t *testing.T) {
+	if !Updated(nil).IsZero() {
+		t.Error("expected no update time without posts")
+	}
+
+	want := time.Date(2025, 9, 3, 8, 30, 0, 0, time.UTC)
+	if got := Updated(testPosts()); !got.Equal(want) {
+		t.Errorf("Updated() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,59 @@
+package tags
+
+import (
+	"strings"
+	"unicode"
+)
+
+// `MaxLength` matches the size of the `posts.tags` elements (VARCHAR(100))
+const MaxLength = 100
+
+// `Normalize` turns a user supplied tag into its normalized spelling:
+// lower case, without a leading `#`, surrounding spaces trimmed and inner
+// whitespace or underscores collapsed to a single `-`. Characters other than
+// letters, digits and `-+#.` are dropped (so `C#` and `c++` survive).
+// Returns "" when nothing usable is left. The backfill of the add_tags migration
+// applies the same rules in SQL, keep both in sync.
+func Normalize(raw string) string {
+	raw = strings.TrimSpace(strings.ToLower(raw))
+	raw = strings.TrimLeft(raw, "#")
+
+	var b strings.Builder
+	pendingDash := false
+	for _, r := range raw {
+		switch {
+		case unicode.IsSpace(r) || r == '_' || r == '-':
+			pendingDash = b.Len() > 0
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '+' || r == '#' || r == '.':
+			if pendingDash {
+				b.WriteRune('-')
+				pendingDash = false
+			}
+			b.WriteRune(r)
+		}
+	}
+
+	normalized := strings.Trim(b.String(), ".")
+	if runes := []rune(normalized); len(runes) > MaxLength {
+		normalized = strings.TrimRight(string(runes[:MaxLength]), "-.")
+	}
+
+	return normalized
+}
+
+// `NormalizeAll` normalizes `raw` and drops empty and duplicate tags, keeping the original order
+func NormalizeAll(raw []string) []string {
+	seen := make(map[string]bool, len(raw))
+	normalized := make([]string, 0, len(raw))
+
+	for _, tag := range raw {
+		tag = Normalize(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+
+	return normalized
+}
@@ -0,0 +1,43 @@
+package tags
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{"Go", "go"},
+		{"golang ", "golang"},
+		{"#Go", "go"},
+		{"  System Design ", "system-design"},
+		{"system_design", "system-design"},
+		{"system--design", "system-design"},
+		{"C#", "c#"},
+		{"C++", "c++"},
+		{"node.js", "node.js"},
+		{"...", ""},
+		{"!!", ""},
+		{"Ünïcödé", "ünïcödé"},
+		{strings.Repeat("ab", 80), strings.Repeat("ab", 50)},
+	}
+
+	for _, tt := range tests {
+		if got := Normalize(tt.raw); got != tt.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeAll(t *testing.T) {
+	got := NormalizeAll([]string{"Go", "go", " GO ", "", "#", "Databases"})
+	want := []string{"go", "databases"}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("NormalizeAll() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,204 @@
+// Package timeline builds home feeds by fanning out on write.
+//
+// A new post is pushed to the Redis timelines of its author and their followers, so reading a
+// feed is a range over a sorted set and a batch load of the posts from Postgres. Posts that
+// aren't pushed are pulled from Postgres when the feed is read and merged in: posts of authors
+// with more followers than `Config.FanOutLimit`, and posts with tags the reader follows.
+//
+// Timelines are only kept for users who read them. Pushes skip missing timelines and a missing
+// timeline is rebuilt from Postgres on the next read.
+package timeline
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/elhambadri2411/social/internal/store"
+	"github.com/elhambadri2411/social/internal/store/cache"
+)
+
+// `Config` holds settings for home timelines
+type Config struct {
+	Length      int           // posts kept per timeline, older pages are read from Postgres
+	FanOutLimit int           // authors with more followers aren't pushed, their followers pull their posts
+	TTL         time.Duration // timelines expire when they haven't been read for this long
+}
+
+type Service struct {
+	store  store.Storage
+	cache  cache.TimelinesCache
+	config Config
+}
+
+func New(storage store.Storage, timelines cache.TimelinesCache, config Config) *Service {
+	return &Service{store: storage, cache: timelines, config: config}
+}
+
+// `Publish` pushes a new post to the timelines of its author and their followers, or marks the
+// author as pulled when they have too many followers to push to
+func (s *Service) Publish(ctx context.Context, entry store.TimelineEntry) error {
+	followerIds, err := s.store.TimelinesRepository.GetFollowerIds(ctx, entry.AuthorId, s.config.FanOutLimit+1)
+	if err != nil {
+		return err
+	}
+
+	if len(followerIds) > s.config.FanOutLimit {
+		if err := s.cache.MarkPulled(ctx, entry.AuthorId); err != nil {
+			return err
+		}
+		followerIds = nil
+	}
+
+	return s.cache.Push(ctx, append(followerIds, entry.AuthorId), entry)
+}
+
+// `Follow` backfills the timeline of `userId` with the latest posts of `authorId`
+func (s *Service) Follow(ctx context.Context, userId int64, authorId int64) error {
+	pulled, err := s.cache.PulledAuthors(ctx)
+	if err != nil {
+		return err
+	}
+	if slices.Contains(pulled, authorId) {
+		return nil
+	}
+
+	entries, err := s.store.TimelinesRepository.GetAuthorEntries(ctx, authorId, s.config.Length)
+	if err != nil {
+		return err
+	}
+
+	return s.cache.Add(ctx, userId, entries)
+}
+
+// `Unfollow` trims the posts of `authorId` from the timeline of `userId`. A timeline only holds
+// the latest posts, so only the latest posts of the author can be in it.
+func (s *Service) Unfollow(ctx context.Context, userId int64, authorId int64) error {
+	entries, err := s.store.TimelinesRepository.GetAuthorEntries(ctx, authorId, s.config.Length)
+	if err != nil {
+		return err
+	}
+
+	postIds := make([]int64, 0, len(entries))
+	for _, entry := range entries {
+		postIds = append(postIds, entry.PostId)
+	}
+
+	return s.cache.Remove(ctx, userId, postIds)
+}
+
+// `Feed` returns up to `limit` posts of the home feed of `userId` after `after`, newest first,
+// and the cursor of the next page, empty on the last one.
+//
+// Posts hidden since they were pushed are left out, so a page can be shorter than `limit`
+// without being the last one.
+func (s *Service) Feed(ctx context.Context, userId int64, after *store.FeedCursor, limit int) ([]*store.FeedPost, string, error) {
+	pushed, err := s.cache.Range(ctx, userId, after, limit)
+	if err != nil {
+		return nil, "", err
+	}
+	if pushed == nil {
+		if pushed, err = s.rebuild(ctx, userId, after, limit); err != nil {
+			return nil, "", err
+		}
+	}
+
+	// past the posts a full timeline keeps, the feed is read from Postgres
+	if len(pushed) < limit {
+		n, err := s.cache.Len(ctx, userId)
+		if err != nil {
+			return nil, "", err
+		}
+		if n >= s.config.Length {
+			return s.fromPostgres(ctx, userId, after, limit)
+		}
+	}
+
+	pulledAuthors, err := s.cache.PulledAuthors(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	pulled, err := s.store.TimelinesRepository.GetPulledEntries(ctx, userId, pulledAuthors, after, limit)
+	if err != nil {
+		return nil, "", err
+	}
+
+	entries := merge(pushed, pulled, limit)
+
+	postIds := make([]int64, 0, len(entries))
+	for _, entry := range entries {
+		postIds = append(postIds, entry.PostId)
+	}
+	posts, err := s.store.TimelinesRepository.Hydrate(ctx, userId, postIds)
+	if err != nil {
+		return nil, "", err
+	}
+
+	nextCursor := ""
+	if len(entries) == limit {
+		last := entries[len(entries)-1]
+		nextCursor = store.FeedCursor{CreatedAt: last.CreatedAt, PostId: last.PostId}.Encode()
+	}
+
+	return posts, nextCursor, nil
+}
+
+// `rebuild` builds the timeline of `userId` from Postgres and reads the requested page of it
+func (s *Service) rebuild(ctx context.Context, userId int64, after *store.FeedCursor, limit int) ([]store.TimelineEntry, error) {
+	entries, err := s.store.TimelinesRepository.GetFollowedEntries(ctx, userId, s.config.Length)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.cache.Build(ctx, userId, entries); err != nil {
+		return nil, err
+	}
+
+	pushed, err := s.cache.Range(ctx, userId, after, limit)
+	if err != nil {
+		return nil, err
+	}
+	if pushed == nil {
+		pushed = []store.TimelineEntry{}
+	}
+
+	return pushed, nil
+}
+
+func (s *Service) fromPostgres(ctx context.Context, userId int64, after *store.FeedCursor, limit int) ([]*store.FeedPost, string, error) {
+	pfq := store.PaginatedFeedQuery{Limit: limit, Sort: "desc", After: after}
+
+	posts, err := s.store.PostsRepository.GetUserFeed(ctx, userId, pfq)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return posts, store.NextFeedCursor(posts, limit), nil
+}
+
+// `merge` combines pushed and pulled entries in feed order, without duplicates, up to `limit`
+func merge(pushed []store.TimelineEntry, pulled []store.TimelineEntry, limit int) []store.TimelineEntry {
+	seen := make(map[int64]bool, len(pushed)+len(pulled))
+	entries := make([]store.TimelineEntry, 0, len(pushed)+len(pulled))
+	for _, entry := range slices.Concat(pushed, pulled) {
+		if seen[entry.PostId] {
+			continue
+		}
+		seen[entry.PostId] = true
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if !entries[i].CreatedAt.Equal(entries[j].CreatedAt) {
+			return entries[i].CreatedAt.After(entries[j].CreatedAt)
+		}
+		return entries[i].PostId > entries[j].PostId
+	})
+
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+
+	return entries
+}
@@ -0,0 +1,43 @@
+package timeline
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/elhambadri2411/social/internal/store"
+)
+
+func TestMerge(t *testing.T) {
+	now := time.Now()
+	at := func(minutesAgo int) time.Time { return now.Add(-time.Duration(minutesAgo) * time.Minute) }
+
+	pushed := []store.TimelineEntry{
+		{PostId: 9, CreatedAt: at(1)},
+		{PostId: 7, CreatedAt: at(3)},
+		{PostId: 4, CreatedAt: at(5)},
+	}
+	pulled := []store.TimelineEntry{
+		{PostId: 8, CreatedAt: at(3)}, // same time as 7, the higher id comes first
+		{PostId: 7, CreatedAt: at(3)}, // followed author posting with a followed tag
+		{PostId: 5, CreatedAt: at(4)},
+	}
+
+	postIds := func(entries []store.TimelineEntry) []int64 {
+		ids := []int64{}
+		for _, entry := range entries {
+			ids = append(ids, entry.PostId)
+		}
+		return ids
+	}
+
+	if got, want := postIds(merge(pushed, pulled, 10)), []int64{9, 8, 7, 5, 4}; !reflect.DeepEqual(got, want) {
+		t.Errorf("merge() = %v, want %v", got, want)
+	}
+	if got, want := postIds(merge(pushed, pulled, 3)), []int64{9, 8, 7}; !reflect.DeepEqual(got, want) {
+		t.Errorf("merge() with limit = %v, want %v", got, want)
+	}
+	if got := merge(nil, nil, 3); len(got) != 0 {
+		t.Errorf("merge() of nothing = %v, want no entries", got)
+	}
+}